@@ -0,0 +1,104 @@
+package shedder
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// activeRequests tracks admitted requests in arrival order so that they
+// can be canceled to reclaim their slots.
+type activeRequests struct {
+	mu       sync.Mutex
+	requests list.List // of *activeRequest, oldest first
+}
+
+// activeRequest is one admitted request.
+type activeRequest struct {
+	r           *http.Request
+	start       time.Time
+	cost        int64
+	cancel      context.CancelCauseFunc
+	preemptible bool
+	canceled    bool // canceled or finished; guarded by activeRequests.mu
+	elem        *list.Element
+	timer       Timer       // fires eviction, if configured
+	released    atomic.Bool // whether the in-flight slot was returned
+}
+
+// release marks the request's in-flight slot as returned and reports
+// whether the caller is the one to return it.
+func (ar *activeRequest) release() bool {
+	return ar.released.CompareAndSwap(false, true)
+}
+
+// register tracks r, which started at start, and returns it with a
+// cancelable context. The caller must deregister the returned entry.
+func (a *activeRequests) register(r *http.Request, start time.Time, cost int64, preemptible bool) (*http.Request, *activeRequest) {
+	ctx, cancel := context.WithCancelCause(r.Context())
+	r = r.WithContext(ctx)
+	ar := &activeRequest{r: r, start: start, cost: cost, cancel: cancel, preemptible: preemptible}
+
+	a.mu.Lock()
+	ar.elem = a.requests.PushBack(ar)
+	a.mu.Unlock()
+	return r, ar
+}
+
+// deregister stops tracking ar and releases its context. It marks ar
+// canceled, so an eviction timer that fired too late to be stopped finds
+// the request finished and leaves it alone.
+func (a *activeRequests) deregister(ar *activeRequest) {
+	if ar.timer != nil {
+		ar.timer.Stop()
+	}
+	a.mu.Lock()
+	ar.canceled = true
+	a.requests.Remove(ar.elem)
+	a.mu.Unlock()
+	ar.cancel(context.Canceled)
+}
+
+// cancelRequest cancels ar with cause unless it was canceled already, and
+// reports whether it did.
+func (a *activeRequests) cancelRequest(ar *activeRequest, cause error) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if ar.canceled {
+		return false
+	}
+	ar.canceled = true
+	ar.cancel(cause)
+	return true
+}
+
+// cancelOldest cancels the longest-running request for which match
+// returns true and that was not canceled already, with cause. It reports
+// whether a request was canceled.
+func (a *activeRequests) cancelOldest(match func(ar *activeRequest) bool, cause error) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for elem := a.requests.Front(); elem != nil; elem = elem.Next() {
+		ar := elem.Value.(*activeRequest)
+		if !ar.canceled && match(ar) {
+			ar.canceled = true
+			ar.cancel(cause)
+			return true
+		}
+	}
+	return false
+}
+
+// track registers an admitted request so it can be preempted or evicted,
+// and returns the request with a cancelable context.
+func (s *Shedder) track(r *http.Request, start time.Time, cost int64) (*http.Request, *activeRequest) {
+	preemptible := s.preemption != nil && s.preemption.preemptible(r)
+	r, ar := s.active.register(r, start, cost, preemptible)
+	if s.eviction != nil {
+		ar.timer = s.clock.AfterFunc(s.eviction.maxAge-s.now().Sub(start), func() { s.evict(ar) })
+	}
+	return r, ar
+}
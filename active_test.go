@@ -0,0 +1,27 @@
+package shedder
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestActiveRequests_CancelOldest(t *testing.T) {
+	var a activeRequests
+	base := httptest.NewRequest("GET", "/", nil)
+	r1, ar1 := a.register(base, time.Now(), 1, true)
+	r2, ar2 := a.register(base, time.Now(), 1, true)
+	defer a.deregister(ar2)
+
+	all := func(*activeRequest) bool { return true }
+	if !a.cancelOldest(all, ErrPreempted) || r1.Context().Err() == nil {
+		t.Fatal("expected the oldest request to be canceled")
+	}
+	if !a.cancelOldest(all, ErrPreempted) || r2.Context().Err() == nil {
+		t.Fatal("expected the next request to be canceled, skipping the canceled one")
+	}
+	if a.cancelOldest(all, ErrPreempted) {
+		t.Error("expected nothing left to cancel")
+	}
+	a.deregister(ar1)
+}
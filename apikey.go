@@ -0,0 +1,134 @@
+package shedder
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// APIKeyTierConfig configures resolving API keys to commercial tiers.
+type APIKeyTierConfig struct {
+	// Header carries the API key. Defaults to X-API-Key.
+	Header string
+
+	// Lookup resolves an API key to its tier, e.g. from a database or an
+	// account service. Results are cached. Required.
+	Lookup func(ctx context.Context, key string) (string, error)
+
+	// TTL is how long resolved tiers are cached. Defaults to 5m.
+	TTL time.Duration
+
+	// ErrorTTL is how long failed lookups are cached as the empty tier,
+	// sparing a struggling backend during overload. Defaults to 10s.
+	ErrorTTL time.Duration
+
+	// MaxKeys bounds the number of cached keys; the least recently used
+	// are evicted first. Defaults to 10000.
+	MaxKeys int
+}
+
+// apiKeyTiers resolves API keys to tiers through an LRU cache.
+type apiKeyTiers struct {
+	cfg APIKeyTierConfig
+
+	mu    sync.Mutex
+	keys  map[string]*list.Element
+	lru   list.List // of *cachedTier, most recently used first
+	clock func() time.Time
+}
+
+// cachedTier is a cached lookup result.
+type cachedTier struct {
+	key     string
+	tier    string
+	expires time.Time
+}
+
+// newAPIKeyTiers returns a resolver for cfg with defaults applied.
+func newAPIKeyTiers(cfg APIKeyTierConfig) *apiKeyTiers {
+	if cfg.Header == "" {
+		cfg.Header = "X-API-Key"
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 5 * time.Minute
+	}
+	if cfg.ErrorTTL <= 0 {
+		cfg.ErrorTTL = 10 * time.Second
+	}
+	if cfg.MaxKeys <= 0 {
+		cfg.MaxKeys = 10000
+	}
+	return &apiKeyTiers{cfg: cfg, keys: make(map[string]*list.Element), clock: time.Now}
+}
+
+// tier returns the tier of r's API key, or "" without a key.
+func (t *apiKeyTiers) tier(r *http.Request) string {
+	key := r.Header.Get(t.cfg.Header)
+	if key == "" {
+		return ""
+	}
+	now := t.clock()
+
+	t.mu.Lock()
+	if elem, ok := t.keys[key]; ok {
+		if c := elem.Value.(*cachedTier); now.Before(c.expires) {
+			t.lru.MoveToFront(elem)
+			t.mu.Unlock()
+			return c.tier
+		}
+	}
+	t.mu.Unlock()
+
+	// Look up without holding the lock; concurrent misses for the same
+	// key may each call Lookup.
+	tier, err := t.cfg.Lookup(r.Context(), key)
+	ttl := t.cfg.TTL
+	if err != nil {
+		tier, ttl = "", t.cfg.ErrorTTL
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := &cachedTier{key: key, tier: tier, expires: now.Add(ttl)}
+	if elem, ok := t.keys[key]; ok {
+		elem.Value = c
+		t.lru.MoveToFront(elem)
+	} else {
+		t.keys[key] = t.lru.PushFront(c)
+		if t.lru.Len() > t.cfg.MaxKeys {
+			oldest := t.lru.Back()
+			t.lru.Remove(oldest)
+			delete(t.keys, oldest.Value.(*cachedTier).key)
+		}
+	}
+	return tier
+}
+
+// ShedAPIKeyTiers returns a ShedDecider that sheds requests whose API key
+// resolves to one of tiers, so shedding follows commercial SLAs. Include
+// "" to also shed requests without a key or whose lookup failed.
+func ShedAPIKeyTiers(cfg APIKeyTierConfig, tiers ...string) ShedDecider {
+	resolver := newAPIKeyTiers(cfg)
+	set := make(map[string]bool, len(tiers))
+	for _, tier := range tiers {
+		set[tier] = true
+	}
+	return func(r *http.Request) bool {
+		return set[resolver.tier(r)]
+	}
+}
+
+// ClassifyAPIKey returns a Config.Classify function that resolves the
+// request's API key to its tier, or def without a key or on lookup
+// failure.
+func ClassifyAPIKey(cfg APIKeyTierConfig, def string) func(r *http.Request) string {
+	resolver := newAPIKeyTiers(cfg)
+	return func(r *http.Request) string {
+		if tier := resolver.tier(r); tier != "" {
+			return tier
+		}
+		return def
+	}
+}
@@ -0,0 +1,98 @@
+package shedder
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAPIKeyTiers_Caches(t *testing.T) {
+	lookups := 0
+	tiers := newAPIKeyTiers(APIKeyTierConfig{
+		TTL:      time.Minute,
+		ErrorTTL: time.Second,
+		MaxKeys:  2,
+		Lookup: func(ctx context.Context, key string) (string, error) {
+			lookups++
+			if key == "broken" {
+				return "", errors.New("backend down")
+			}
+			return "tier-" + key, nil
+		},
+	})
+	now := time.Now()
+	tiers.clock = func() time.Time { return now }
+	request := func(key string) *http.Request {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-API-Key", key)
+		return r
+	}
+
+	if got := tiers.tier(request("a")); got != "tier-a" {
+		t.Errorf("expected tier-a, got %q", got)
+	}
+	tiers.tier(request("a"))
+	if lookups != 1 {
+		t.Errorf("expected a cached second lookup, got %d lookups", lookups)
+	}
+
+	now = now.Add(2 * time.Minute)
+	tiers.tier(request("a"))
+	if lookups != 2 {
+		t.Errorf("expected a lookup after TTL, got %d lookups", lookups)
+	}
+
+	if got := tiers.tier(request("broken")); got != "" {
+		t.Errorf("expected empty tier on lookup error, got %q", got)
+	}
+	tiers.tier(request("broken"))
+	if lookups != 3 {
+		t.Errorf("expected failures to be cached, got %d lookups", lookups)
+	}
+
+	tiers.tier(request("b")) // evicts a, the least recently used
+	tiers.tier(request("a"))
+	if lookups != 5 {
+		t.Errorf("expected evicted key to be looked up again, got %d lookups", lookups)
+	}
+
+	if got := tiers.tier(httptest.NewRequest("GET", "/", nil)); got != "" || lookups != 5 {
+		t.Errorf("expected no lookup without a key, got %q after %d lookups", got, lookups)
+	}
+}
+
+func TestShedAPIKeyTiers(t *testing.T) {
+	cfg := APIKeyTierConfig{
+		Header: "Api-Key",
+		Lookup: func(ctx context.Context, key string) (string, error) {
+			return map[string]string{"k1": "free", "k2": "enterprise"}[key], nil
+		},
+	}
+	decide := ShedAPIKeyTiers(cfg, "free", "")
+	classify := ClassifyAPIKey(cfg, "anonymous")
+
+	tests := []struct {
+		key       string
+		wantShed  bool
+		wantClass string
+	}{
+		{"k1", true, "free"},
+		{"k2", false, "enterprise"},
+		{"", true, "anonymous"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.key != "" {
+			r.Header.Set("Api-Key", tt.key)
+		}
+		if got := decide(r); got != tt.wantShed {
+			t.Errorf("key %q: expected shed %v, got %v", tt.key, tt.wantShed, got)
+		}
+		if got := classify(r); got != tt.wantClass {
+			t.Errorf("key %q: expected class %q, got %q", tt.key, tt.wantClass, got)
+		}
+	}
+}
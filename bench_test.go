@@ -0,0 +1,104 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// discardWriter is a ResponseWriter that reuses one header map, so
+// benchmarks count only the middleware's allocations.
+type discardWriter struct{ header http.Header }
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+// fixedLimit is an adaptive LimitAlgorithm that keeps its limit, so
+// admitted requests are timed and sampled.
+type fixedLimit int64
+
+func (l fixedLimit) OnSample(time.Duration, int64, bool) int64 { return int64(l) }
+
+// hotPathConfigs are the configurations whose admitted requests must not
+// allocate.
+var hotPathConfigs = []struct {
+	name string
+	cfg  Config
+}{
+	{"Static", Config{HardLimit: 100}},
+	{"SoftLimit", Config{HardLimit: 100, SoftLimit: 50, ShedHeader: &HeaderMatcher{Name: "X-Priority", Value: "low"}}},
+	{"Adaptive", Config{HardLimit: 100, LimitAlgorithm: fixedLimit(100)}},
+	{"CoarseClock", Config{HardLimit: 100, LimitAlgorithm: fixedLimit(100), CoarseClock: time.Millisecond}},
+	{"FastPath", Config{HardLimit: 100, SoftLimit: 50, FastPathBelow: 0.5, LimitAlgorithm: fixedLimit(100), Deadline: &DeadlineConfig{}, ShedHeader: &HeaderMatcher{Name: "X-Priority", Value: "low"}}},
+	{"Deadline", Config{HardLimit: 100, Deadline: &DeadlineConfig{}}},
+	{"Routes", Config{HardLimit: 100, Routes: map[string]RouteLimits{"/api/": {HardLimit: 50}}, MethodLimits: []MethodLimit{{Methods: []string{"GET"}, HardLimit: 50}}}},
+	{"ClientIP", Config{HardLimit: 100, ClientIP: &ClientIPConfig{MaxInflight: 10}}},
+	{"Tenant", Config{HardLimit: 100, Tenant: &TenantConfig{Key: TenantHeader("X-Tenant")}}},
+	{"RetryAfter", Config{HardLimit: 100, RetryAfter: &RetryAfterConfig{}}},
+	{"ErrorRate", Config{HardLimit: 100, ErrorRate: &ErrorRateConfig{Threshold: 0.5}}},
+}
+
+// serveFunc returns a function serving one request through s.
+func serveFunc(s *Shedder) func() {
+	h := s.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	r := httptest.NewRequest("GET", "/api/items", nil)
+	r.Header.Set("X-Tenant", "acme")
+	w := &discardWriter{header: make(http.Header)}
+	return func() { h.ServeHTTP(w, r) }
+}
+
+func TestMiddleware_HotPathAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector allocates")
+	}
+	for _, tt := range hotPathConfigs {
+		t.Run(tt.name, func(t *testing.T) {
+			if allocs := testing.AllocsPerRun(1000, serveFunc(New(tt.cfg))); allocs != 0 {
+				t.Errorf("expected admitted requests not to allocate, got %v allocs/op", allocs)
+			}
+		})
+	}
+
+	s := New(Config{HardLimit: 1})
+	s.increment(2)
+	if allocs := testing.AllocsPerRun(1000, serveFunc(s)); allocs != 0 {
+		t.Errorf("expected shed requests not to allocate, got %v allocs/op", allocs)
+	}
+}
+
+func BenchmarkMiddleware(b *testing.B) {
+	for _, tt := range hotPathConfigs {
+		b.Run(tt.name, func(b *testing.B) {
+			serve := serveFunc(New(tt.cfg))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				serve()
+			}
+		})
+	}
+}
+
+func BenchmarkMiddleware_Shed(b *testing.B) {
+	s := New(Config{HardLimit: 1})
+	s.increment(2)
+	serve := serveFunc(s)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		serve()
+	}
+}
+
+func BenchmarkMiddleware_Parallel(b *testing.B) {
+	s := New(Config{HardLimit: 1 << 20, SoftLimit: 1 << 19})
+	h := s.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		r := httptest.NewRequest("GET", "/api/items", nil)
+		w := &discardWriter{header: make(http.Header)}
+		for pb.Next() {
+			h.ServeHTTP(w, r)
+		}
+	})
+}
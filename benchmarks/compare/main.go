@@ -0,0 +1,246 @@
+// Command compare runs the kube-shedder benchmarks against a base revision
+// and the working tree, and reports the change of each benchmark's median
+// time and allocations. It exits 1 if a benchmark got slower by more than
+// --threshold or allocates more, so it can gate performance-sensitive
+// changes such as a new LimitAlgorithm:
+//
+//	go run ./benchmarks/compare -base main
+//	go run ./benchmarks/compare -base v1.4.0 -bench 'Middleware|Decider' -count 10
+//
+// The base revision is checked out into a temporary git worktree. The
+// runs alternate between the two trees, one -count at a time, so drift
+// on the machine affects both alike. Revisions that predate a benchmark
+// cannot run it; the output of two earlier runs can be compared instead:
+//
+//	go test -run '^$' -bench . -benchmem -count 10 ./benchmarks/ > old.txt
+//	go run ./benchmarks/compare old.txt new.txt
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+func main() {
+	base := flag.String("base", "main", "Git revision to compare the working tree against")
+	bench := flag.String("bench", ".", "Benchmarks to run, as for go test -bench")
+	count := flag.Int("count", 6, "Runs of each benchmark per tree")
+	benchtime := flag.String("benchtime", "", "Run time of each benchmark, as for go test -benchtime")
+	pkg := flag.String("pkg", "./benchmarks/", "Package of the benchmarks")
+	threshold := flag.Float64("threshold", 0.1, "Slowdown of the median time reported as a regression")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [old.txt new.txt]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var oldOut, newOut []byte
+	var err error
+	switch flag.NArg() {
+	case 0:
+		args := []string{"test", "-run", "^$", "-bench", *bench, "-benchmem", "-count", "1", *pkg}
+		if *benchtime != "" {
+			args = append(args, "-benchtime", *benchtime)
+		}
+		oldOut, newOut, err = runBoth(ctx, *base, *count, args)
+	case 2:
+		if oldOut, err = os.ReadFile(flag.Arg(0)); err == nil {
+			newOut, err = os.ReadFile(flag.Arg(1))
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	rows := compare(parse(bytes.NewReader(oldOut)), parse(bytes.NewReader(newOut)), *threshold)
+	if len(rows) == 0 {
+		fmt.Fprintln(os.Stderr, "no benchmark results to compare")
+		os.Exit(2)
+	}
+	regressed := printRows(os.Stdout, rows)
+	if regressed > 0 {
+		fmt.Printf("\n%d of %d benchmarks regressed\n", regressed, len(rows))
+		os.Exit(1)
+	}
+}
+
+// runBoth runs go with args count times in a worktree of base and in the
+// working tree, alternating, and returns the output of each.
+func runBoth(ctx context.Context, base string, count int, args []string) (oldOut, newOut []byte, err error) {
+	dir, err := os.MkdirTemp("", "kube-shedder-compare-")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.RemoveAll(dir)
+	if out, err := exec.CommandContext(ctx, "git", "worktree", "add", "--detach", dir, base).CombinedOutput(); err != nil {
+		return nil, nil, fmt.Errorf("checking out %s: %v: %s", base, err, out)
+	}
+	defer exec.Command("git", "worktree", "remove", "--force", dir).Run()
+
+	var oldBuf, newBuf bytes.Buffer
+	for i := 0; i < count; i++ {
+		fmt.Fprintf(os.Stderr, "run %d/%d\n", i+1, count)
+		if err := goTest(ctx, dir, args, &oldBuf); err != nil {
+			return nil, nil, fmt.Errorf("running the benchmarks at %s: %w", base, err)
+		}
+		if err := goTest(ctx, "", args, &newBuf); err != nil {
+			return nil, nil, fmt.Errorf("running the benchmarks in the working tree: %w", err)
+		}
+	}
+	return oldBuf.Bytes(), newBuf.Bytes(), nil
+}
+
+// goTest runs go with args in dir, appending its output to out.
+func goTest(ctx context.Context, dir string, args []string, out io.Writer) error {
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
+	cmd.Stdout = out
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// samples are the measurements of one benchmark across runs.
+type samples struct {
+	ns, allocs []float64
+}
+
+// procsSuffix is the -GOMAXPROCS suffix go test appends to names.
+var procsSuffix = regexp.MustCompile(`-\d+$`)
+
+// parse reads go test -bench output, keyed by benchmark name without the
+// GOMAXPROCS suffix. Other lines are skipped.
+func parse(r io.Reader) map[string]*samples {
+	results := make(map[string]*samples)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		name := procsSuffix.ReplaceAllString(fields[0], "")
+		s := results[name]
+		if s == nil {
+			s = &samples{}
+			results[name] = s
+		}
+		// Values are followed by their unit: "123.4 ns/op 0 allocs/op".
+		for i := 2; i+1 < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				continue
+			}
+			switch fields[i+1] {
+			case "ns/op":
+				s.ns = append(s.ns, v)
+			case "allocs/op":
+				s.allocs = append(s.allocs, v)
+			}
+		}
+	}
+	return results
+}
+
+// row is the comparison of one benchmark.
+type row struct {
+	name                 string
+	oldNs, newNs         float64 // medians, or -1 if missing
+	oldAllocs, newAllocs float64
+	regressed            bool
+}
+
+// compare pairs the benchmarks of old and cur by name, sorted. A
+// benchmark regressed if its median time grew by more than threshold or
+// its median allocations grew at all; one missing on either side does
+// not.
+func compare(old, cur map[string]*samples, threshold float64) []row {
+	names := make(map[string]bool)
+	for name := range old {
+		names[name] = true
+	}
+	for name := range cur {
+		names[name] = true
+	}
+	rows := make([]row, 0, len(names))
+	for name := range names {
+		r := row{name: name, oldNs: -1, newNs: -1, oldAllocs: -1, newAllocs: -1}
+		if s := old[name]; s != nil {
+			r.oldNs, r.oldAllocs = median(s.ns), median(s.allocs)
+		}
+		if s := cur[name]; s != nil {
+			r.newNs, r.newAllocs = median(s.ns), median(s.allocs)
+		}
+		if r.oldNs > 0 && r.newNs >= 0 {
+			r.regressed = r.newNs > r.oldNs*(1+threshold) || r.oldAllocs >= 0 && r.newAllocs > r.oldAllocs
+		}
+		rows = append(rows, r)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].name < rows[j].name })
+	return rows
+}
+
+// median returns the median of values, or -1 if there are none.
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return -1
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// printRows writes rows as a table and returns how many regressed.
+func printRows(w io.Writer, rows []row) int {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "benchmark\told ns/op\tnew ns/op\tdelta\told allocs\tnew allocs\t")
+	regressed := 0
+	for _, r := range rows {
+		mark := ""
+		if r.regressed {
+			mark = "REGRESSED"
+			regressed++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", strings.TrimPrefix(r.name, "Benchmark"),
+			value(r.oldNs), value(r.newNs), delta(r.oldNs, r.newNs), value(r.oldAllocs), value(r.newAllocs), mark)
+	}
+	tw.Flush()
+	return regressed
+}
+
+// value formats a median, or "-" if it is missing.
+func value(v float64) string {
+	if v < 0 {
+		return "-"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// delta formats the relative change from old to cur.
+func delta(old, cur float64) string {
+	if old <= 0 || cur < 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%+.1f%%", (cur/old-1)*100)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	old := parse(strings.NewReader(`goos: linux
+BenchmarkMiddleware/Static-8   	 5000000	       200 ns/op	       0 B/op	       0 allocs/op
+BenchmarkMiddleware/Static-8   	 5000000	       210 ns/op	       0 B/op	       0 allocs/op
+BenchmarkMiddleware/Static-8   	 5000000	       900 ns/op	       0 B/op	       0 allocs/op
+BenchmarkMiddleware/Full-8     	 1000000	      1000 ns/op	      24 B/op	       1 allocs/op
+BenchmarkDecider/Header-8      	 1000000	       300 ns/op	       0 B/op	       0 allocs/op
+PASS
+`))
+	cur := parse(strings.NewReader(`BenchmarkMiddleware/Static-8   	 5000000	       205 ns/op	       0 B/op	       0 allocs/op
+BenchmarkMiddleware/Full-8     	 1000000	       990 ns/op	      48 B/op	       2 allocs/op
+BenchmarkDecider/Header-8      	 1000000	       400 ns/op	       0 B/op	       0 allocs/op
+BenchmarkDecider/Glob-8        	 1000000	       700 ns/op	       0 B/op	       0 allocs/op
+`))
+	if s := old["BenchmarkMiddleware/Static"]; s == nil || len(s.ns) != 3 || median(s.ns) != 210 {
+		t.Fatalf("expected 3 samples with a median of 210, got %+v", s)
+	}
+
+	rows := compare(old, cur, 0.1)
+	var names []string
+	regressed := make(map[string]bool)
+	for _, r := range rows {
+		names = append(names, r.name)
+		regressed[r.name] = r.regressed
+	}
+	if got := strings.Join(names, ","); got != "BenchmarkDecider/Glob,BenchmarkDecider/Header,BenchmarkMiddleware/Full,BenchmarkMiddleware/Static" {
+		t.Errorf("unexpected rows: %s", got)
+	}
+	if regressed["BenchmarkMiddleware/Static"] || regressed["BenchmarkDecider/Glob"] {
+		t.Errorf("expected no regression within the threshold or without a base, got %v", regressed)
+	}
+	if !regressed["BenchmarkDecider/Header"] || !regressed["BenchmarkMiddleware/Full"] {
+		t.Errorf("expected regressions for the slowdown and the extra allocation, got %v", regressed)
+	}
+
+	var out bytes.Buffer
+	if n := printRows(&out, rows); n != 2 || !strings.Contains(out.String(), "+33.3%") {
+		t.Errorf("expected 2 regressions and the slowdown in the table, got %d:\n%s", n, out.String())
+	}
+}
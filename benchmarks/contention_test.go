@@ -0,0 +1,95 @@
+package benchmarks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	shedder "github.com/sampath030/kube-shedder"
+)
+
+// parallelism are the goroutines per GOMAXPROCS serving requests at once
+// in BenchmarkContention.
+var parallelism = []int{1, 4, 16, 64}
+
+// BenchmarkContention measures the shared in-flight counter and the
+// per-client and per-tenant maps as more goroutines serve requests at
+// once. Limits are high enough that every request is admitted.
+func BenchmarkContention(b *testing.B) {
+	for _, tt := range []struct {
+		name string
+		cfg  shedder.Config
+	}{
+		{"Counter", shedder.Config{HardLimit: 1 << 30}},
+		{"SoftLimit", shedder.Config{HardLimit: 1 << 30, SoftLimit: 1 << 29, ShedHeader: lowPriority}},
+		{"Cost", shedder.Config{HardLimit: 1 << 30, Cost: func(*http.Request) int64 { return 3 }}},
+		{"ClientIP", shedder.Config{HardLimit: 1 << 30, ClientIP: &shedder.ClientIPConfig{MaxInflight: 1 << 20}}},
+		{"Tenant", shedder.Config{HardLimit: 1 << 30, Tenant: &shedder.TenantConfig{Key: shedder.TenantHeader("X-Tenant")}}},
+	} {
+		for _, p := range parallelism {
+			b.Run(fmt.Sprintf("%s/p=%d", tt.name, p), func(b *testing.B) {
+				h := shedder.New(tt.cfg).Middleware(noop)
+				b.SetParallelism(p)
+				b.ReportAllocs()
+				b.RunParallel(func(pb *testing.PB) {
+					r := newRequest()
+					w := &discardWriter{header: make(http.Header)}
+					for pb.Next() {
+						h.ServeHTTP(w, r)
+					}
+				})
+			})
+		}
+	}
+}
+
+// BenchmarkContention_Stats measures reading Stats, as a metrics exporter
+// or the status endpoint does, while requests are being served.
+func BenchmarkContention_Stats(b *testing.B) {
+	s := shedder.New(shedder.Config{HardLimit: 1 << 30, SoftLimit: 1 << 29, ShedHeader: lowPriority})
+	h := s.Middleware(noop)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		r := newRequest()
+		w := &discardWriter{header: make(http.Header)}
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				h.ServeHTTP(w, r)
+			}
+		}
+	}()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Stats()
+	}
+}
+
+// BenchmarkContention_Metrics measures recording request metrics for
+// ReportMetrics as more goroutines serve requests at once.
+func BenchmarkContention_Metrics(b *testing.B) {
+	for _, p := range parallelism {
+		b.Run(fmt.Sprintf("p=%d", p), func(b *testing.B) {
+			s := shedder.New(shedder.Config{HardLimit: 1 << 30})
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			s.ReportMetrics(ctx, shedder.MetricsSinkFunc(func(shedder.Metrics) {}), shedder.MetricsOptions{Interval: 100 * time.Millisecond})
+			h := s.Middleware(noop)
+			b.SetParallelism(p)
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				r := newRequest()
+				w := &discardWriter{header: make(http.Header)}
+				for pb.Next() {
+					h.ServeHTTP(w, r)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package benchmarks
+
+import (
+	"net/http"
+	"testing"
+
+	shedder "github.com/sampath030/kube-shedder"
+)
+
+// BenchmarkDecider measures the evaluation of each kind of ShedDecider on
+// every request, by keeping the shedder soft overloaded. None of them
+// matches, so the requests are admitted.
+func BenchmarkDecider(b *testing.B) {
+	for _, tt := range []struct {
+		name string
+		cfg  shedder.Config
+	}{
+		{"Func", shedder.Config{ShedDecider: func(r *http.Request) bool {
+			return r.Header.Get("X-Priority") == "low"
+		}}},
+		{"Header", shedder.Config{ShedHeader: lowPriority}},
+		{"PathsExact", shedder.Config{ShedPaths: &shedder.PathMatcher{Exact: []string{"/api/search/suggest", "/api/export"}}}},
+		{"PathsPrefix", shedder.Config{ShedPaths: &shedder.PathMatcher{Prefixes: []string{"/api/export/", "/api/reports/"}}}},
+		{"PathsGlob", shedder.Config{ShedPaths: &shedder.PathMatcher{Globs: []string{"/api/*/report", "/api/*/export/*"}}}},
+		{"Methods", shedder.Config{ShedMethods: shedder.MethodMatcher{"POST", "PUT"}}},
+		{"Query", shedder.Config{ShedQuery: []shedder.QueryMatcher{{Name: "prefetch", Value: "true"}}}},
+		{"Combined", shedder.Config{
+			ShedHeader:  lowPriority,
+			ShedPaths:   &shedder.PathMatcher{Prefixes: []string{"/api/export/"}},
+			ShedMethods: shedder.MethodMatcher{"POST"},
+		}},
+		{"V2", shedder.Config{ShedDeciderV2: func(r *http.Request, load shedder.LoadInfo) shedder.Decision {
+			if load.Utilization > 0.9 {
+				return shedder.DecisionShed
+			}
+			return shedder.DecisionDefault
+		}}},
+	} {
+		b.Run(tt.name, func(b *testing.B) {
+			tt.cfg.HardLimit = 1000
+			tt.cfg.SoftOverloadSignal = shedder.SignalFunc(func() bool { return true })
+			serve(b, shedder.New(tt.cfg).Middleware(noop))
+		})
+	}
+}
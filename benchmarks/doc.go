@@ -0,0 +1,13 @@
+// Package benchmarks holds the benchmarks of kube-shedder's public API:
+// middleware overhead per configuration, ShedDecider and matcher
+// evaluation under soft overload, and in-flight counter contention at
+// increasing parallelism. It has no code of its own; run them with:
+//
+//	go test -run '^$' -bench . -benchmem -count 10 ./benchmarks/
+//
+// To validate a performance-sensitive change, compare against a base
+// revision with the runner in benchmarks/compare, which runs the
+// benchmarks in both trees and fails on regressions:
+//
+//	go run ./benchmarks/compare -base main
+package benchmarks
@@ -0,0 +1,93 @@
+package benchmarks
+
+import (
+	"testing"
+	"time"
+
+	shedder "github.com/sampath030/kube-shedder"
+)
+
+// lowPriority sheds requests marked low priority under soft overload.
+var lowPriority = &shedder.HeaderMatcher{Name: "X-Priority", Value: "low"}
+
+// middlewareConfigs are the configurations whose overhead is measured,
+// from the bare counter to a pod using most features at once.
+var middlewareConfigs = []struct {
+	name string
+	cfg  func() shedder.Config
+}{
+	{"Static", func() shedder.Config { return shedder.Config{HardLimit: 1000} }},
+	{"SoftLimit", func() shedder.Config {
+		return shedder.Config{HardLimit: 1000, SoftLimit: 800, ShedHeader: lowPriority}
+	}},
+	{"Adaptive", func() shedder.Config {
+		return shedder.Config{HardLimit: 1000, LimitAlgorithm: shedder.NewCapacityEstimator(shedder.CapacityEstimatorConfig{})}
+	}},
+	{"CoarseClock", func() shedder.Config {
+		return shedder.Config{
+			HardLimit:      1000,
+			LimitAlgorithm: shedder.NewCapacityEstimator(shedder.CapacityEstimatorConfig{}),
+			CoarseClock:    time.Millisecond,
+		}
+	}},
+	{"FastPath", func() shedder.Config {
+		return shedder.Config{
+			HardLimit:      1000,
+			SoftLimit:      800,
+			FastPathBelow:  0.5,
+			ShedHeader:     lowPriority,
+			LimitAlgorithm: shedder.NewCapacityEstimator(shedder.CapacityEstimatorConfig{}),
+		}
+	}},
+	{"Full", func() shedder.Config {
+		return shedder.Config{
+			HardLimit:      1000,
+			SoftLimit:      800,
+			ShedHeader:     lowPriority,
+			LimitAlgorithm: shedder.NewCapacityEstimator(shedder.CapacityEstimatorConfig{}),
+			Deadline:       &shedder.DeadlineConfig{},
+			Routes:         map[string]shedder.RouteLimits{"/api/": {HardLimit: 500}},
+			ClientIP:       &shedder.ClientIPConfig{MaxInflight: 100},
+			Tenant:         &shedder.TenantConfig{Key: shedder.TenantHeader("X-Tenant")},
+			RetryAfter:     &shedder.RetryAfterConfig{},
+			ErrorRate:      &shedder.ErrorRateConfig{Threshold: 0.5, Window: time.Minute},
+		}
+	}},
+}
+
+// BenchmarkMiddleware measures the overhead the middleware adds to an
+// admitted request, against the bare handler.
+func BenchmarkMiddleware(b *testing.B) {
+	b.Run("Baseline", func(b *testing.B) { serve(b, noop) })
+	for _, tt := range middlewareConfigs {
+		b.Run(tt.name, func(b *testing.B) {
+			serve(b, shedder.New(tt.cfg()).Middleware(noop))
+		})
+	}
+}
+
+// BenchmarkMiddleware_Shed measures the cost of shedding a request, from
+// the decision to the written 503.
+func BenchmarkMiddleware_Shed(b *testing.B) {
+	for _, tt := range []struct {
+		name string
+		cfg  shedder.Config
+	}{
+		{"Plain", shedder.Config{HardLimit: 1000}},
+		{"ProblemJSON", shedder.Config{HardLimit: 1000, ProblemJSON: true}},
+		{"RateLimitHeaders", shedder.Config{HardLimit: 1000, RateLimitHeaders: shedder.RateLimitHeadersShed}},
+	} {
+		b.Run(tt.name, func(b *testing.B) {
+			tt.cfg.OverloadSignal = shedder.SignalFunc(func() bool { return true })
+			serve(b, shedder.New(tt.cfg).Middleware(noop))
+		})
+	}
+}
+
+// BenchmarkMiddleware_Nested measures a handler wrapped by a pod-wide
+// shedder and a child shedder sharing its counter.
+func BenchmarkMiddleware_Nested(b *testing.B) {
+	parent := shedder.New(shedder.Config{HardLimit: 1000})
+	child := parent.Child(shedder.Config{HardLimit: 500})
+	serve(b, parent.Middleware(child.Middleware(noop)))
+}
@@ -0,0 +1,52 @@
+package shedder
+
+import (
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ShedBodyPolicy controls the unread request body of shed requests.
+type ShedBodyPolicy int
+
+const (
+	// ShedBodyDefault leaves the body to net/http, which discards up to
+	// 256 KiB after the response to reuse the connection and closes it
+	// otherwise.
+	ShedBodyDefault ShedBodyPolicy = iota
+
+	// ShedBodyDiscard reads and discards up to Config.ShedBodyLimit bytes
+	// before responding, so clients that do not read the response until
+	// their upload completes see it, and closes the connection if more
+	// remain.
+	ShedBodyDiscard
+
+	// ShedBodyClose responds without reading the body and closes the
+	// connection, so no time or memory is spent on it.
+	ShedBodyClose
+)
+
+// defaultShedBodyLimit is the default Config.ShedBodyLimit.
+const defaultShedBodyLimit = 256 << 10
+
+// handleShedBody applies the ShedBody policy to a shed request before its
+// response is written.
+func (s *Shedder) handleShedBody(w http.ResponseWriter, r *http.Request) {
+	if s.shedBody == ShedBodyDefault || r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return
+	}
+	if s.shedBody == ShedBodyDiscard && !expectsContinue(r) {
+		n, _ := io.CopyN(io.Discard, r.Body, s.shedBodyLimit+1)
+		if n <= s.shedBodyLimit {
+			return
+		}
+	}
+	w.Header().Set("Connection", "close")
+}
+
+// expectsContinue reports whether the client waits for 100 Continue before
+// sending the body; reading it would ask for the body we are about to
+// reject.
+func expectsContinue(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Expect"), "100-continue")
+}
@@ -0,0 +1,71 @@
+package shedder
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// countingReader counts the bytes read from it.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestShedBody(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    ShedBodyPolicy
+		size      int
+		expect    string
+		wantRead  int
+		wantClose bool
+	}{
+		{"default", ShedBodyDefault, 100, "", 0, false},
+		{"discard small", ShedBodyDiscard, 100, "", 100, false},
+		{"discard large", ShedBodyDiscard, 2000, "", 1001, true},
+		{"discard expect continue", ShedBodyDiscard, 100, "100-continue", 0, true},
+		{"close", ShedBodyClose, 100, "", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(Config{HardLimit: 1, ShedBody: tt.policy, ShedBodyLimit: 1000})
+			body := &countingReader{r: bytes.NewReader(make([]byte, tt.size))}
+			r := httptest.NewRequest("POST", "/", body)
+			if tt.expect != "" {
+				r.Header.Set("Expect", tt.expect)
+			}
+
+			s.increment(1)
+			rec := httptest.NewRecorder()
+			s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rec, r)
+			s.decrement(1)
+
+			if rec.Code != http.StatusServiceUnavailable {
+				t.Fatalf("expected 503, got %d", rec.Code)
+			}
+			if body.n != tt.wantRead {
+				t.Errorf("expected %d bytes read, got %d", tt.wantRead, body.n)
+			}
+			if got := rec.Header().Get("Connection") == "close"; got != tt.wantClose {
+				t.Errorf("expected close %v, got %v", tt.wantClose, got)
+			}
+		})
+	}
+}
+
+func TestShedBody_NoBody(t *testing.T) {
+	s := New(Config{HardLimit: 1, ShedBody: ShedBodyClose})
+	rec := shedOne(t, s)
+	if got := rec.Header().Get("Connection"); got != "" {
+		t.Errorf("expected no Connection header without a body, got %q", got)
+	}
+}
@@ -0,0 +1,110 @@
+package shedder
+
+import (
+	"context"
+	"net/http"
+)
+
+// DegradationLevel tells handlers how much optional work to skip.
+type DegradationLevel int
+
+const (
+	// DegradationNormal means the pod has spare capacity: do all work.
+	DegradationNormal DegradationLevel = iota
+
+	// DegradationDegraded means load is high: skip expensive optional work
+	// such as recommendations or personalization.
+	DegradationDegraded
+
+	// DegradationCritical means the pod is close to shedding: serve only
+	// the essential response.
+	DegradationCritical
+)
+
+// String returns a human-readable representation of the level.
+func (l DegradationLevel) String() string {
+	switch l {
+	case DegradationNormal:
+		return "normal"
+	case DegradationDegraded:
+		return "degraded"
+	case DegradationCritical:
+		return "critical"
+	default:
+		return "unknown"
+	}
+}
+
+// BrownoutConfig configures degradation levels derived from load.
+type BrownoutConfig struct {
+	// DegradedAt is the fraction (0-1) of the hard limit in effect at or
+	// above which the level is DegradationDegraded. A firing
+	// SoftOverloadSignal also degrades. Defaults to 0.7.
+	DegradedAt float64
+
+	// CriticalAt is the fraction (0-1) of the hard limit at or above which
+	// the level is DegradationCritical. A firing OverloadSignal is also
+	// critical. Defaults to 0.9.
+	CriticalAt float64
+}
+
+// brownout maps load to degradation levels.
+type brownout struct {
+	degradedAt float64
+	criticalAt float64
+}
+
+// newBrownout returns a brownout for cfg with defaults applied.
+func newBrownout(cfg BrownoutConfig) *brownout {
+	if cfg.DegradedAt <= 0 || cfg.DegradedAt > 1 {
+		cfg.DegradedAt = 0.7
+	}
+	if cfg.CriticalAt <= 0 || cfg.CriticalAt > 1 {
+		cfg.CriticalAt = 0.9
+	}
+	return &brownout{degradedAt: cfg.DegradedAt, criticalAt: cfg.CriticalAt}
+}
+
+// degradationKey is the context key of a request's DegradationLevel.
+type degradationKey struct{}
+
+// DegradationFromContext returns the degradation level at which the
+// request was admitted, or DegradationNormal outside the middleware or
+// without Config.Brownout.
+func DegradationFromContext(ctx context.Context) DegradationLevel {
+	level, _ := ctx.Value(degradationKey{}).(DegradationLevel)
+	return level
+}
+
+// WithDegradation returns a copy of ctx carrying level, as the middleware
+// attaches it to admitted requests with Config.Brownout. Tests use it to
+// drive handlers' degradation logic at a given level.
+func WithDegradation(ctx context.Context, level DegradationLevel) context.Context {
+	return context.WithValue(ctx, degradationKey{}, level)
+}
+
+// Degradation returns the current degradation level, or
+// DegradationNormal if Brownout is not configured.
+func (s *Shedder) Degradation() DegradationLevel {
+	return s.degradation(s.inflight.Load())
+}
+
+// degradation returns the level for current in-flight units.
+func (s *Shedder) degradation(current int64) DegradationLevel {
+	if s.brownout == nil {
+		return DegradationNormal
+	}
+	used := float64(current) / float64(s.currentLimit())
+	switch {
+	case used >= s.brownout.criticalAt || s.signalOverloaded():
+		return DegradationCritical
+	case used >= s.brownout.degradedAt || s.softSignalOverloaded():
+		return DegradationDegraded
+	}
+	return DegradationNormal
+}
+
+// withDegradation attaches the degradation level for current to r.
+func (s *Shedder) withDegradation(r *http.Request, current int64) *http.Request {
+	return r.WithContext(WithDegradation(r.Context(), s.degradation(current)))
+}
@@ -0,0 +1,91 @@
+package shedder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDegradationLevel_String(t *testing.T) {
+	tests := []struct {
+		level DegradationLevel
+		want  string
+	}{
+		{DegradationNormal, "normal"},
+		{DegradationDegraded, "degraded"},
+		{DegradationCritical, "critical"},
+		{DegradationLevel(99), "unknown"},
+	}
+	for _, tt := range tests {
+		if got := tt.level.String(); got != tt.want {
+			t.Errorf("expected %q, got %q", tt.want, got)
+		}
+	}
+}
+
+func TestShedder_Degradation(t *testing.T) {
+	var soft, hard bool
+	s := New(Config{
+		HardLimit:          10,
+		Brownout:           &BrownoutConfig{DegradedAt: 0.5, CriticalAt: 0.8},
+		SoftOverloadSignal: SignalFunc(func() bool { return soft }),
+		OverloadSignal:     SignalFunc(func() bool { return hard }),
+	})
+
+	tests := []struct {
+		inflight   int64
+		soft, hard bool
+		want       DegradationLevel
+	}{
+		{4, false, false, DegradationNormal},
+		{5, false, false, DegradationDegraded},
+		{8, false, false, DegradationCritical},
+		{1, true, false, DegradationDegraded},
+		{1, false, true, DegradationCritical},
+	}
+	for _, tt := range tests {
+		soft, hard = tt.soft, tt.hard
+		if got := s.degradation(tt.inflight); got != tt.want {
+			t.Errorf("inflight %d soft %v hard %v: expected %v, got %v", tt.inflight, tt.soft, tt.hard, tt.want, got)
+		}
+	}
+
+	if got := New(Config{HardLimit: 1}).degradation(100); got != DegradationNormal {
+		t.Errorf("expected normal without Brownout, got %v", got)
+	}
+}
+
+func TestMiddleware_ExposesDegradation(t *testing.T) {
+	s := New(Config{HardLimit: 10, Brownout: &BrownoutConfig{}})
+	var got DegradationLevel
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = DegradationFromContext(r.Context())
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if got != DegradationNormal {
+		t.Errorf("expected normal on an idle pod, got %v", got)
+	}
+
+	for i := 0; i < 7; i++ {
+		s.increment(1)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if got != DegradationDegraded {
+		t.Errorf("expected degraded at 80%% load, got %v", got)
+	}
+	if st := s.Stats(); st.Degradation != "degraded" {
+		t.Errorf("expected Stats degradation degraded, got %q", st.Degradation)
+	}
+
+	if DegradationFromContext(context.Background()) != DegradationNormal {
+		t.Error("expected normal outside the middleware")
+	}
+}
+
+func TestWithDegradation(t *testing.T) {
+	if got := DegradationFromContext(WithDegradation(context.Background(), DegradationCritical)); got != DegradationCritical {
+		t.Errorf("expected the level set, got %v", got)
+	}
+}
@@ -0,0 +1,44 @@
+package shedder
+
+import "time"
+
+// ShedBudgetConfig configures a cap on soft shedding.
+type ShedBudgetConfig struct {
+	// MaxFraction is the largest fraction (0-1) of requests that may be
+	// soft-shed over Window. Required.
+	MaxFraction float64
+
+	// Window is the rolling window over which the fraction is computed.
+	// Defaults to 10s.
+	Window time.Duration
+}
+
+// shedBudget limits the fraction of requests shed by the soft limit.
+type shedBudget struct {
+	maxFraction float64
+	window      *window // total: arrivals, hits: soft sheds
+}
+
+// newShedBudget returns a budget for cfg with defaults applied.
+func newShedBudget(cfg ShedBudgetConfig) *shedBudget {
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+	return &shedBudget{maxFraction: cfg.MaxFraction, window: newWindow(cfg.Window, 10)}
+}
+
+// arrive counts a request reaching the middleware.
+func (b *shedBudget) arrive(now time.Time) {
+	b.window.add(now, 1, 0)
+}
+
+// spend reports whether one more soft shed fits the budget, and counts it
+// if so.
+func (b *shedBudget) spend(now time.Time) bool {
+	total, shed := b.window.sum(now)
+	if float64(shed+1) > b.maxFraction*float64(total) {
+		return false
+	}
+	b.window.add(now, 0, 1)
+	return true
+}
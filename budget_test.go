@@ -0,0 +1,60 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestShedBudget_Spend(t *testing.T) {
+	b := newShedBudget(ShedBudgetConfig{MaxFraction: 0.3})
+	now := time.Now()
+
+	if b.spend(now) {
+		t.Error("expected no budget without arrivals")
+	}
+	for i := 0; i < 10; i++ {
+		b.arrive(now)
+	}
+	for i := 0; i < 3; i++ {
+		if !b.spend(now) {
+			t.Fatalf("expected shed %d to fit a 30%% budget of 10 requests", i+1)
+		}
+	}
+	if b.spend(now) {
+		t.Error("expected the fourth shed to exceed the budget")
+	}
+
+	// The budget refills once the window has passed.
+	later := now.Add(11 * time.Second)
+	for i := 0; i < 10; i++ {
+		b.arrive(later)
+	}
+	if !b.spend(later) {
+		t.Error("expected budget to be available in a new window")
+	}
+}
+
+func TestMiddleware_ShedBudgetCapsSoftShedding(t *testing.T) {
+	s := New(Config{
+		HardLimit:   100,
+		SoftLimit:   1,
+		ShedDecider: func(r *http.Request) bool { return true }, // overly aggressive
+		ShedBudget:  &ShedBudgetConfig{MaxFraction: 0.3},
+	})
+	s.increment(1)
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	shed := 0
+	for i := 0; i < 100; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code == http.StatusServiceUnavailable {
+			shed++
+		}
+	}
+	if shed > 30 || shed < 25 {
+		t.Errorf("expected about 30 of 100 requests shed, got %d", shed)
+	}
+}
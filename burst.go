@@ -0,0 +1,67 @@
+package shedder
+
+import (
+	"sync"
+	"time"
+)
+
+// BurstConfig configures a token bucket that admits short bursts above the
+// hard limit.
+type BurstConfig struct {
+	// Size is both the bucket capacity and the maximum number of requests
+	// admitted above the hard limit at any moment. Required.
+	Size int64
+
+	// RefillPerSecond is the rate at which tokens are replenished.
+	// Defaults to Size per second.
+	RefillPerSecond float64
+}
+
+// burstBucket is a token bucket consulted only when the hard limit is
+// exceeded. Each request admitted above the limit consumes one token, so
+// brief spikes are absorbed while sustained overload drains the bucket and
+// is rejected.
+type burstBucket struct {
+	size   int64
+	refill float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// newBurstBucket returns a full bucket for cfg.
+func newBurstBucket(cfg BurstConfig, now time.Time) *burstBucket {
+	if cfg.RefillPerSecond <= 0 {
+		cfg.RefillPerSecond = float64(cfg.Size)
+	}
+	return &burstBucket{
+		size:   cfg.Size,
+		refill: cfg.RefillPerSecond,
+		tokens: float64(cfg.Size),
+		last:   now,
+	}
+}
+
+// allow reports whether a request arriving with current in-flight requests
+// over limit may be admitted, consuming a token if so.
+func (b *burstBucket) allow(current, limit int64, now time.Time) bool {
+	if current > limit+b.size {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.refill
+		if b.tokens > float64(b.size) {
+			b.tokens = float64(b.size)
+		}
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
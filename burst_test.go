@@ -0,0 +1,61 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBurstBucket_Allow(t *testing.T) {
+	now := time.Unix(1000, 0)
+	b := newBurstBucket(BurstConfig{Size: 2, RefillPerSecond: 1}, now)
+
+	if b.allow(13, 10, now) {
+		t.Error("should reject overshoot beyond Size")
+	}
+	if !b.allow(11, 10, now) || !b.allow(12, 10, now) {
+		t.Error("expected full bucket to admit two requests")
+	}
+	if b.allow(11, 10, now) {
+		t.Error("expected empty bucket to reject")
+	}
+
+	if !b.allow(11, 10, now.Add(time.Second)) {
+		t.Error("expected a token after one second of refill")
+	}
+	if b.allow(11, 10, now.Add(time.Second)) {
+		t.Error("expected bucket to be empty again")
+	}
+
+	// Refill is capped at Size.
+	later := now.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		if !b.allow(11, 10, later) {
+			t.Fatalf("expected token %d after long idle period", i+1)
+		}
+	}
+	if b.allow(11, 10, later) {
+		t.Error("refill must not exceed Size")
+	}
+}
+
+func TestMiddleware_BurstAboveHardLimit(t *testing.T) {
+	s := New(Config{HardLimit: 1, Burst: &BurstConfig{Size: 1, RefillPerSecond: 0.001}})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	s.increment(1)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected burst to absorb the overshoot, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 once the bucket is drained, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,258 @@
+package shedder
+
+import (
+	"net"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// RouteLimits are the in-flight limits of one entry of Config.Routes, in
+// cost units when Config.Cost is set.
+type RouteLimits struct {
+	// HardLimit sheds the route's requests while the route has more than
+	// this many in flight. 0 leaves the route bounded only by the global
+	// hard limit.
+	HardLimit int64 `json:"hard_limit,omitempty"`
+
+	// SoftLimit subjects the route's requests to ShedDecider (and retry
+	// shedding) while the route has more than this many in flight, even
+	// if the pod as a whole is below its soft limit. 0 disables it.
+	SoftLimit int64 `json:"soft_limit,omitempty"`
+}
+
+// MethodLimit is the in-flight limits shared by a class of HTTP methods,
+// in cost units when Config.Cost is set.
+type MethodLimit struct {
+	// Methods are the methods of the class, e.g. "POST", "PUT" and
+	// "DELETE". Methods are compared case-sensitively, as in net/http, and
+	// "HEAD" is not implied by "GET".
+	Methods []string `json:"methods"`
+
+	// HardLimit and SoftLimit behave as in RouteLimits, applied to the
+	// class's in-flight count.
+	HardLimit int64 `json:"hard_limit,omitempty"`
+	SoftLimit int64 `json:"soft_limit,omitempty"`
+}
+
+// RouteCeilingConfig configures fixed per-route in-flight maximums.
+type RouteCeilingConfig struct {
+	// Key maps a request to its route, e.g. the mux pattern or a
+	// normalized path. Required.
+	Key func(r *http.Request) string
+
+	// Max maps routes to their in-flight maximum, in cost units when
+	// Config.Cost is set. Routes without a positive entry are bounded only
+	// by the hard limit.
+	Max map[string]int64
+}
+
+// CeilingStats describes the in-flight usage of one fixed ceiling.
+type CeilingStats struct {
+	// Kind is what the ceiling applies to, e.g. "route".
+	Kind string `json:"kind"`
+
+	// Name identifies the ceiling within its kind, e.g. the route.
+	Name string `json:"name"`
+
+	Inflight  int64 `json:"inflight"`
+	HardLimit int64 `json:"hard_limit,omitempty"`
+	SoftLimit int64 `json:"soft_limit,omitempty"`
+}
+
+// maxCeilingSets bounds Shedder.ceilings: RouteCeilings, Routes,
+// MethodLimits and Hosts.
+const maxCeilingSets = 4
+
+// releaseCeilings returns cost to each non-nil ceiling of held.
+func releaseCeilings(held []*ceiling, cost int64) {
+	for _, c := range held {
+		if c != nil {
+			c.inflight.Add(-cost)
+		}
+	}
+}
+
+// ceilingSet enforces fixed in-flight maximums on groups of requests
+// alongside the global hard limit, so a noisy group is contained without
+// fragmenting the pod's capacity into silos: a request needs room both in
+// its group and in the pod. The set of groups is fixed, so counting is
+// lock-free.
+type ceilingSet struct {
+	kind     string
+	reason   ShedReason
+	resolve  func(r *http.Request) string
+	ceilings map[string]*ceiling
+}
+
+// ceiling tracks one group.
+type ceiling struct {
+	hard     int64 // 0 means no hard ceiling
+	soft     int64 // 0 means no soft ceiling
+	inflight atomic.Int64
+}
+
+// newCeiling returns a ceiling for l, or nil if l sets no limit.
+func newCeiling(l RouteLimits) *ceiling {
+	if l.HardLimit <= 0 && l.SoftLimit <= 0 {
+		return nil
+	}
+	return &ceiling{hard: max(l.HardLimit, 0), soft: max(l.SoftLimit, 0)}
+}
+
+// newRouteCeilings returns the ceilings of cfg.
+func newRouteCeilings(cfg RouteCeilingConfig) *ceilingSet {
+	cs := &ceilingSet{kind: "route", reason: ShedReasonRouteLimit, resolve: cfg.Key, ceilings: make(map[string]*ceiling)}
+	for route, max := range cfg.Max {
+		if max > 0 {
+			cs.ceilings[route] = &ceiling{hard: max}
+		}
+	}
+	return cs
+}
+
+// newRoutes returns the ceilings of Config.Routes. Keys ending in "/"
+// match paths with that prefix, keys containing "*" are path.Match globs
+// and other keys match exactly. An exact match wins over a glob, which
+// wins over a prefix; among globs and among prefixes the longest key wins.
+func newRoutes(routes map[string]RouteLimits) *ceilingSet {
+	cs := &ceilingSet{kind: "route", reason: ShedReasonRouteLimit, ceilings: make(map[string]*ceiling)}
+	var globs, prefixes []string
+	for pattern, l := range routes {
+		c := newCeiling(l)
+		if c == nil {
+			continue
+		}
+		cs.ceilings[pattern] = c
+		switch {
+		case strings.Contains(pattern, "*"):
+			globs = append(globs, pattern)
+		case strings.HasSuffix(pattern, "/"):
+			prefixes = append(prefixes, pattern)
+		}
+	}
+	byLength := func(keys []string) {
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) > len(keys[j])
+			}
+			return keys[i] < keys[j]
+		})
+	}
+	byLength(globs)
+	byLength(prefixes)
+
+	cs.resolve = func(r *http.Request) string {
+		p := r.URL.Path
+		if _, ok := cs.ceilings[p]; ok {
+			return p
+		}
+		for _, glob := range globs {
+			if ok, _ := path.Match(glob, p); ok {
+				return glob
+			}
+		}
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(p, prefix) {
+				return prefix
+			}
+		}
+		return ""
+	}
+	return cs
+}
+
+// newMethodLimits returns the ceilings of Config.MethodLimits, named by
+// their comma-separated methods. A method listed in several classes
+// belongs to the first.
+func newMethodLimits(limits []MethodLimit) *ceilingSet {
+	cs := &ceilingSet{kind: "method", reason: ShedReasonMethodLimit, ceilings: make(map[string]*ceiling)}
+	byMethod := make(map[string]string)
+	for _, ml := range limits {
+		c := newCeiling(RouteLimits{HardLimit: ml.HardLimit, SoftLimit: ml.SoftLimit})
+		if c == nil || len(ml.Methods) == 0 {
+			continue
+		}
+		name := strings.Join(ml.Methods, ",")
+		cs.ceilings[name] = c
+		for _, method := range ml.Methods {
+			if _, ok := byMethod[method]; !ok {
+				byMethod[method] = name
+			}
+		}
+	}
+	cs.resolve = func(r *http.Request) string {
+		return byMethod[r.Method]
+	}
+	return cs
+}
+
+// newHosts returns the ceilings of Config.Hosts. Hosts are compared
+// case-insensitively without the port; keys starting with "*." match any
+// subdomain, the longest such key winning, and an exact key wins over
+// them.
+func newHosts(hosts map[string]RouteLimits) *ceilingSet {
+	cs := &ceilingSet{kind: "host", reason: ShedReasonHostLimit, ceilings: make(map[string]*ceiling)}
+	exact := make(map[string]string)
+	var wildcards []string
+	for host, l := range hosts {
+		c := newCeiling(l)
+		if c == nil {
+			continue
+		}
+		cs.ceilings[host] = c
+		if strings.HasPrefix(host, "*.") {
+			wildcards = append(wildcards, host)
+		} else {
+			exact[strings.ToLower(host)] = host
+		}
+	}
+	sort.Slice(wildcards, func(i, j int) bool {
+		if len(wildcards[i]) != len(wildcards[j]) {
+			return len(wildcards[i]) > len(wildcards[j])
+		}
+		return wildcards[i] < wildcards[j]
+	})
+
+	cs.resolve = func(r *http.Request) string {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.ToLower(strings.TrimSuffix(host, "."))
+		if key, ok := exact[host]; ok {
+			return key
+		}
+		for _, pattern := range wildcards {
+			if matchDNSName(pattern, host) {
+				return pattern
+			}
+		}
+		return ""
+	}
+	return cs
+}
+
+// acquire counts cost units of r against its ceiling and reports whether
+// the group is over its hard and soft ceilings. The caller must release a
+// non-nil ceiling.
+func (cs *ceilingSet) acquire(r *http.Request, cost int64) (c *ceiling, hard, soft bool) {
+	c, ok := cs.ceilings[cs.resolve(r)]
+	if !ok {
+		return nil, false, false
+	}
+	current := c.inflight.Add(cost)
+	return c, c.hard > 0 && current > c.hard, c.soft > 0 && current > c.soft
+}
+
+// stats returns a snapshot of all ceilings, sorted by name.
+func (cs *ceilingSet) stats() []CeilingStats {
+	out := make([]CeilingStats, 0, len(cs.ceilings))
+	for name, c := range cs.ceilings {
+		out = append(out, CeilingStats{Kind: cs.kind, Name: name, Inflight: c.inflight.Load(), HardLimit: c.hard, SoftLimit: c.soft})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -0,0 +1,247 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouteCeilings_Acquire(t *testing.T) {
+	cs := newRouteCeilings(RouteCeilingConfig{
+		Key: func(r *http.Request) string { return r.URL.Path },
+		Max: map[string]int64{"/slow": 2, "/off": 0},
+	})
+	request := func(path string) *http.Request { return httptest.NewRequest("GET", path, nil) }
+
+	for i := 0; i < 2; i++ {
+		if _, over, _ := cs.acquire(request("/slow"), 1); over {
+			t.Fatalf("request %d: expected under the ceiling of 2", i+1)
+		}
+	}
+	if _, over, _ := cs.acquire(request("/slow"), 1); !over {
+		t.Error("expected third request to exceed the ceiling of 2")
+	}
+	if c, over, _ := cs.acquire(request("/fast"), 100); c != nil || over {
+		t.Error("expected a route without a ceiling to be unlimited")
+	}
+	if c, _, _ := cs.acquire(request("/off"), 1); c != nil {
+		t.Error("expected a zero ceiling to leave the route unlimited")
+	}
+
+	st := cs.stats()
+	if len(st) != 1 || st[0].Kind != "route" || st[0].Name != "/slow" || st[0].Inflight != 3 || st[0].HardLimit != 2 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestMiddleware_RouteCeilings(t *testing.T) {
+	s := New(Config{
+		HardLimit: 10,
+		RouteCeilings: &RouteCeilingConfig{
+			Key: func(r *http.Request) string { return r.URL.Path },
+			Max: map[string]int64{"/slow": 1},
+		},
+	})
+	var nested *httptest.ResponseRecorder
+	var handler http.Handler
+	handler = s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Nested") != "" {
+			nested = httptest.NewRecorder()
+			handler.ServeHTTP(nested, httptest.NewRequest("GET", r.Header.Get("X-Nested"), nil))
+		}
+	}))
+	request := func(path, nestedPath string) {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-Nested", nestedPath)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	request("/slow", "/slow")
+	if got := nested.Header().Get("X-Shed-Reason"); got != "route_limit" {
+		t.Errorf("expected second /slow request to be shed with route_limit, got %q", got)
+	}
+	request("/slow", "/fast")
+	if nested.Code != http.StatusOK {
+		t.Errorf("expected other routes to share the global budget, got %d", nested.Code)
+	}
+	if got := s.Stats().Ceilings[0].Inflight; got != 0 {
+		t.Errorf("expected ceiling slots released, got %d", got)
+	}
+}
+
+func TestRoutes_Resolve(t *testing.T) {
+	cs := newRoutes(map[string]RouteLimits{
+		"/api/":            {HardLimit: 10},
+		"/api/export/":     {HardLimit: 2},
+		"/api/*/report":    {HardLimit: 3},
+		"/api/export/full": {HardLimit: 1},
+		"/api/none/":       {},
+	})
+	tests := []struct{ path, want string }{
+		{"/api/export/full", "/api/export/full"},
+		{"/api/export/csv", "/api/export/"},
+		{"/api/users/report", "/api/*/report"},
+		{"/api/users", "/api/"},
+		{"/api/none/x", "/api/"},
+		{"/health", ""},
+	}
+	for _, tt := range tests {
+		if got := cs.resolve(httptest.NewRequest("GET", tt.path, nil)); got != tt.want {
+			t.Errorf("resolve(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestMiddleware_RoutesSoftLimit(t *testing.T) {
+	s := New(Config{
+		HardLimit:   100,
+		Routes:      map[string]RouteLimits{"/search/": {HardLimit: 5, SoftLimit: 1}},
+		ShedDecider: func(r *http.Request) bool { return r.Header.Get("X-Priority") == "low" },
+	})
+	var nested *httptest.ResponseRecorder
+	var handler http.Handler
+	handler = s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/search/outer" {
+			nested = httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/search/inner", nil)
+			req.Header.Set("X-Priority", r.Header.Get("X-Priority"))
+			handler.ServeHTTP(nested, req)
+		}
+	}))
+	request := func(priority string) {
+		req := httptest.NewRequest("GET", "/search/outer", nil)
+		req.Header.Set("X-Priority", priority)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	request("low")
+	if got := nested.Header().Get("X-Shed-Reason"); got != "soft_limit" {
+		t.Errorf("expected low-priority request over the route soft limit to be shed, got %q", got)
+	}
+	request("high")
+	if nested.Code != http.StatusOK {
+		t.Errorf("expected other requests over the route soft limit to be served, got %d", nested.Code)
+	}
+}
+
+func TestValidate_Routes(t *testing.T) {
+	cfg := Config{HardLimit: 10, Routes: map[string]RouteLimits{
+		"/a/":  {HardLimit: 2, SoftLimit: 2},
+		"/b/":  {HardLimit: -1},
+		"/[c/": {HardLimit: 1},
+	}}
+	err := cfg.Validate()
+	for _, want := range []string{`Routes["/a/"] SoftLimit`, `Routes["/b/"] limits`, `Routes["/[c/"] is not a valid pattern`} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error containing %q, got %v", want, err)
+		}
+	}
+}
+
+func TestMiddleware_MethodLimits(t *testing.T) {
+	s := New(Config{
+		HardLimit: 10,
+		MethodLimits: []MethodLimit{
+			{Methods: []string{"GET", "HEAD"}, HardLimit: 5},
+			{Methods: []string{"POST", "PUT", "DELETE"}, HardLimit: 1},
+		},
+	})
+	var nested *httptest.ResponseRecorder
+	var handler http.Handler
+	handler = s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if method := r.Header.Get("X-Nested"); method != "" {
+			nested = httptest.NewRecorder()
+			handler.ServeHTTP(nested, httptest.NewRequest(method, "/", nil))
+		}
+	}))
+	request := func(method, nestedMethod string) {
+		req := httptest.NewRequest(method, "/", nil)
+		req.Header.Set("X-Nested", nestedMethod)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	request("POST", "DELETE")
+	if got := nested.Header().Get("X-Shed-Reason"); got != "method_limit" {
+		t.Errorf("expected second mutation to be shed with method_limit, got %q", got)
+	}
+	request("POST", "GET")
+	if nested.Code != http.StatusOK {
+		t.Errorf("expected reads to be admitted while mutations are at their limit, got %d", nested.Code)
+	}
+
+	st := s.Stats().Ceilings
+	if len(st) != 2 || st[0].Kind != "method" || st[0].Name != "GET,HEAD" || st[1].Name != "POST,PUT,DELETE" {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestValidate_MethodLimits(t *testing.T) {
+	cfg := Config{HardLimit: 10, MethodLimits: []MethodLimit{
+		{Methods: []string{"GET"}, HardLimit: 5},
+		{Methods: []string{"GET", "POST"}, HardLimit: 2},
+		{HardLimit: 1},
+	}}
+	err := cfg.Validate()
+	for _, want := range []string{`method "GET" is in more than one`, "MethodLimits[2].Methods is empty"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error containing %q, got %v", want, err)
+		}
+	}
+}
+
+func TestHosts_Resolve(t *testing.T) {
+	cs := newHosts(map[string]RouteLimits{
+		"Shop.example.com": {HardLimit: 10},
+		"*.example.com":    {HardLimit: 5},
+		"*.eu.example.com": {HardLimit: 2},
+		"ignored.example":  {},
+	})
+	tests := []struct{ host, want string }{
+		{"shop.example.com", "Shop.example.com"},
+		{"SHOP.example.com:8443", "Shop.example.com"},
+		{"blog.example.com", "*.example.com"},
+		{"shop.eu.example.com.", "*.eu.example.com"},
+		{"example.com", ""},
+		{"ignored.example", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = tt.host
+		if got := cs.resolve(req); got != tt.want {
+			t.Errorf("resolve(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestMiddleware_Hosts(t *testing.T) {
+	s := New(Config{
+		HardLimit: 10,
+		Hosts:     map[string]RouteLimits{"a.example.com": {HardLimit: 1}, "b.example.com": {HardLimit: 1}},
+	})
+	var nested *httptest.ResponseRecorder
+	var handler http.Handler
+	handler = s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if host := r.Header.Get("X-Nested"); host != "" {
+			nested = httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Host = host
+			handler.ServeHTTP(nested, req)
+		}
+	}))
+	request := func(host, nestedHost string) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = host
+		req.Header.Set("X-Nested", nestedHost)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	request("a.example.com", "a.example.com")
+	if got := nested.Header().Get("X-Shed-Reason"); got != "host_limit" {
+		t.Errorf("expected second request to the host to be shed with host_limit, got %q", got)
+	}
+	request("a.example.com", "b.example.com")
+	if nested.Code != http.StatusOK {
+		t.Errorf("expected other hosts to be unaffected, got %d", nested.Code)
+	}
+}
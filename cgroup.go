@@ -0,0 +1,79 @@
+package shedder
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Auto can be used as Config.HardLimit to derive the limit at startup from
+// the container's CPU quota (or GOMAXPROCS when no quota is set) multiplied
+// by Config.PerCoreConcurrency. It is a sentinel value; other negative
+// limits remain invalid.
+const Auto int64 = math.MinInt64
+
+// DefaultPerCoreConcurrency is the number of concurrent requests allowed
+// per CPU core when HardLimit is Auto and PerCoreConcurrency is unset.
+const DefaultPerCoreConcurrency = 25
+
+// cgroupRoot is where the cgroup filesystem is mounted. Tests override it.
+var cgroupRoot = "/sys/fs/cgroup"
+
+// autoLimit computes a hard limit from the available CPU and perCore.
+func autoLimit(perCore float64) int64 {
+	if perCore <= 0 {
+		perCore = DefaultPerCoreConcurrency
+	}
+	cores, ok := cpuQuota()
+	if !ok {
+		cores = float64(runtime.GOMAXPROCS(0))
+	}
+	limit := int64(math.Ceil(cores * perCore))
+	if limit < 1 {
+		limit = 1
+	}
+	return limit
+}
+
+// cpuQuota returns the container's CPU limit in cores from cgroup v2 or v1,
+// and false if no quota is configured or the files cannot be read.
+func cpuQuota() (float64, bool) {
+	// cgroup v2: "<quota> <period>" or "max <period>".
+	if data, err := os.ReadFile(filepath.Join(cgroupRoot, "cpu.max")); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) == 2 && fields[0] != "max" {
+			return quotaRatio(fields[0], fields[1])
+		}
+		return 0, false
+	}
+
+	// cgroup v1: separate quota and period files; quota -1 means unlimited.
+	for _, dir := range []string{"cpu", "cpu,cpuacct"} {
+		quota, err := os.ReadFile(filepath.Join(cgroupRoot, dir, "cpu.cfs_quota_us"))
+		if err != nil {
+			continue
+		}
+		period, err := os.ReadFile(filepath.Join(cgroupRoot, dir, "cpu.cfs_period_us"))
+		if err != nil {
+			continue
+		}
+		return quotaRatio(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+	}
+	return 0, false
+}
+
+// quotaRatio parses quota and period and returns quota/period in cores.
+func quotaRatio(quota, period string) (float64, bool) {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		return 0, false
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0, false
+	}
+	return q / p, true
+}
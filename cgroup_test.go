@@ -0,0 +1,75 @@
+package shedder
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func withCgroupRoot(t *testing.T, files map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := cgroupRoot
+	cgroupRoot = dir
+	t.Cleanup(func() { cgroupRoot = old })
+}
+
+func TestCPUQuota(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		cores float64
+		ok    bool
+	}{
+		{"v2 quota", map[string]string{"cpu.max": "150000 100000\n"}, 1.5, true},
+		{"v2 unlimited", map[string]string{"cpu.max": "max 100000\n"}, 0, false},
+		{"v1 quota", map[string]string{
+			"cpu/cpu.cfs_quota_us":  "200000\n",
+			"cpu/cpu.cfs_period_us": "100000\n",
+		}, 2, true},
+		{"v1 unlimited", map[string]string{
+			"cpu,cpuacct/cpu.cfs_quota_us":  "-1\n",
+			"cpu,cpuacct/cpu.cfs_period_us": "100000\n",
+		}, 0, false},
+		{"none", nil, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withCgroupRoot(t, tt.files)
+			cores, ok := cpuQuota()
+			if cores != tt.cores || ok != tt.ok {
+				t.Errorf("cpuQuota() = %v, %v; want %v, %v", cores, ok, tt.cores, tt.ok)
+			}
+		})
+	}
+}
+
+func TestNew_AutoHardLimit(t *testing.T) {
+	withCgroupRoot(t, map[string]string{"cpu.max": "50000 100000\n"})
+
+	s := New(Config{HardLimit: Auto, PerCoreConcurrency: 40})
+	if s.hardLimit != 20 {
+		t.Errorf("expected hardLimit 20 for half a core at 40/core, got %d", s.hardLimit)
+	}
+}
+
+func TestNew_AutoHardLimitFallsBackToGOMAXPROCS(t *testing.T) {
+	withCgroupRoot(t, nil)
+
+	s := New(Config{HardLimit: Auto})
+	want := int64(runtime.GOMAXPROCS(0)) * DefaultPerCoreConcurrency
+	if s.hardLimit != want {
+		t.Errorf("expected hardLimit %d, got %d", want, s.hardLimit)
+	}
+}
@@ -0,0 +1,67 @@
+package shedder
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// inflightCounter is the in-flight count of a Shedder and its children.
+// It also knows the wait queues of every shedder sharing it, so a slot
+// freed anywhere in the family can wake a request queued anywhere.
+type inflightCounter struct {
+	atomic.Int64
+
+	mu     sync.Mutex
+	queues atomic.Pointer[[]*waitQueue]
+}
+
+// watch registers q to be woken whenever the count is decremented.
+func (c *inflightCounter) watch(q *waitQueue) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var queues []*waitQueue
+	if old := c.queues.Load(); old != nil {
+		queues = append(queues, *old...)
+	}
+	queues = append(queues, q)
+	c.queues.Store(&queues)
+}
+
+// notify wakes a queued request in each watching queue.
+func (c *inflightCounter) notify() {
+	queues := c.queues.Load()
+	if queues == nil {
+		return
+	}
+	for _, q := range *queues {
+		q.notify()
+	}
+}
+
+// Child returns a Shedder that shares s's in-flight count but applies the
+// limits, deciders and other settings of cfg on top of s's, so a sub-tree
+// of routes can have stricter rules while still counting toward the global
+// budget.
+//
+// The child's hard limit is capped at s's hard limit in effect, and s's
+// OverloadSignal and StartDraining also shed the child's requests. If
+// cfg.HardLimit is 0 the child inherits s's limit, which is useful when
+// only a stricter SoftLimit or ShedDecider is wanted.
+//
+// Requests served through the child's middleware count toward s's
+// in-flight count, and vice versa; wrap a handler in one or the other,
+// not both. The child keeps its own Stats counters, except for Inflight,
+// which is shared.
+func (s *Shedder) Child(cfg Config) *Shedder {
+	if cfg.HardLimit == 0 {
+		cfg.HardLimit = s.hardLimit
+	}
+	c := New(cfg)
+	c.parent = s
+	c.inflight = s.inflight
+	if c.queue != nil {
+		c.inflight.watch(c.queue)
+	}
+	return c
+}
@@ -0,0 +1,125 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestChild_SharesInflight(t *testing.T) {
+	parent := New(Config{HardLimit: 10})
+	child := parent.Child(Config{HardLimit: 2})
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := child.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+	go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	<-started
+
+	if parent.Inflight() != 1 || child.Inflight() != 1 {
+		t.Errorf("expected shared inflight 1, got parent %d child %d", parent.Inflight(), child.Inflight())
+	}
+	close(release)
+}
+
+func TestChild_StricterLimit(t *testing.T) {
+	parent := New(Config{HardLimit: 10})
+	child := parent.Child(Config{HardLimit: 2})
+
+	parent.increment(2)
+	rec := httptest.NewRecorder()
+	child.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+		ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected child to shed at its own limit, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	parent.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+		ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected parent to admit under its limit, got %d", rec.Code)
+	}
+}
+
+func TestChild_CappedByParent(t *testing.T) {
+	parent := New(Config{HardLimit: 2})
+	child := parent.Child(Config{HardLimit: 10})
+
+	if got := child.HardLimit(); got != 2 {
+		t.Errorf("expected child limit capped at 2, got %d", got)
+	}
+	parent.increment(3)
+	if !child.IsOverloaded() {
+		t.Error("expected child to be overloaded when the parent is")
+	}
+}
+
+func TestChild_InheritsHardLimit(t *testing.T) {
+	parent := New(Config{HardLimit: 5})
+	child := parent.Child(Config{SoftLimit: 1})
+
+	if got := child.HardLimit(); got != 5 {
+		t.Errorf("expected inherited limit 5, got %d", got)
+	}
+}
+
+func TestChild_ParentSignal(t *testing.T) {
+	parent := New(Config{HardLimit: 10, OverloadSignal: SignalFunc(func() bool { return true })})
+	child := parent.Child(Config{HardLimit: 10})
+
+	if !child.IsOverloaded() {
+		t.Error("expected the parent's overload signal to apply to the child")
+	}
+}
+
+func TestChild_ParentDraining(t *testing.T) {
+	parent := New(Config{HardLimit: 10})
+	child := parent.Child(Config{HardLimit: 10})
+	handler := child.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	parent.StartDraining()
+	if !child.IsDraining() {
+		t.Error("expected the child to drain with its parent")
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if got := rec.Header().Get("X-Shed-Reason"); rec.Code != http.StatusServiceUnavailable || got != "draining" {
+		t.Errorf("expected the child to shed new requests while draining, got %d %q", rec.Code, got)
+	}
+	if parent.Inflight() != 0 {
+		t.Errorf("expected nothing left in flight, got %d", parent.Inflight())
+	}
+}
+
+func TestChild_ParentReleaseWakesChildQueue(t *testing.T) {
+	parent := New(Config{HardLimit: 10})
+	child := parent.Child(Config{HardLimit: 1, Queue: &QueueConfig{Timeout: time.Second, MaxLength: 10}})
+
+	parent.increment(1)
+	done := make(chan int)
+	go func() {
+		rec := httptest.NewRecorder()
+		child.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+			ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		done <- rec.Code
+	}()
+
+	for child.Stats().Queued == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	parent.decrement(1)
+
+	select {
+	case code := <-done:
+		if code != http.StatusOK {
+			t.Errorf("expected queued request to be served, got %d", code)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("parent release did not wake the child's queue")
+	}
+}
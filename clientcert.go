@@ -0,0 +1,82 @@
+package shedder
+
+import (
+	"crypto/x509"
+	"net/http"
+	"strings"
+)
+
+// CertMatcher matches requests by the identity in their verified mTLS
+// client certificate. A request matches if its certificate matches any of
+// the listed names, URIs or organizational units.
+type CertMatcher struct {
+	// DNSNames match DNS SANs exactly; "*.example.com" matches any
+	// subdomain of example.com.
+	DNSNames []string
+
+	// URIPrefixes match URI SANs by prefix, e.g. a SPIFFE trust domain or
+	// namespace such as "spiffe://cluster.local/ns/payments/". A prefix
+	// only matches up to a path boundary, so "spiffe://cluster.local/ns/prod"
+	// does not match "spiffe://cluster.local/ns/production/...".
+	URIPrefixes []string
+
+	// OrganizationalUnits match the subject's OU exactly.
+	OrganizationalUnits []string
+}
+
+// verifiedClientCert returns the leaf of r's first verified client
+// certificate chain. Certificates the server did not verify (for example
+// with tls.RequestClientCert) are ignored so they cannot be spoofed.
+func verifiedClientCert(r *http.Request) (*x509.Certificate, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	return r.TLS.VerifiedChains[0][0], true
+}
+
+// Match reports whether r carries a verified client certificate matching
+// m. It can be used as a PriorityRule.Match or inside a ShedDecider.
+func (m CertMatcher) Match(r *http.Request) bool {
+	cert, ok := verifiedClientCert(r)
+	if !ok {
+		return false
+	}
+	for _, want := range m.DNSNames {
+		for _, name := range cert.DNSNames {
+			if matchDNSName(want, name) {
+				return true
+			}
+		}
+	}
+	for _, prefix := range m.URIPrefixes {
+		for _, uri := range cert.URIs {
+			if matchURIPrefix(prefix, uri.String()) {
+				return true
+			}
+		}
+	}
+	for _, want := range m.OrganizationalUnits {
+		for _, ou := range cert.Subject.OrganizationalUnit {
+			if ou == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchURIPrefix reports whether uri starts with prefix and the prefix
+// ends at a path boundary.
+func matchURIPrefix(prefix, uri string) bool {
+	rest, ok := strings.CutPrefix(uri, prefix)
+	return ok && (rest == "" || strings.HasSuffix(prefix, "/") || rest[0] == '/')
+}
+
+// matchDNSName matches name against pattern, which may start with "*."
+// to match any subdomain.
+func matchDNSName(pattern, name string) bool {
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok && strings.HasPrefix(suffix, ".") {
+		return strings.HasSuffix(strings.ToLower(name), strings.ToLower(suffix)) && len(name) > len(suffix)
+	}
+	return strings.EqualFold(pattern, name)
+}
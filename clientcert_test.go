@@ -0,0 +1,74 @@
+package shedder
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// withCert returns a request carrying cert as its verified or merely
+// presented client certificate.
+func withCert(cert *x509.Certificate, verified bool) *http.Request {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	if verified {
+		r.TLS.VerifiedChains = [][]*x509.Certificate{{cert}}
+	}
+	return r
+}
+
+func TestCertMatcher_Match(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://cluster.local/ns/payments/sa/api")
+	cert := &x509.Certificate{
+		DNSNames: []string{"api.payments.svc.cluster.local"},
+		URIs:     []*url.URL{spiffe},
+		Subject:  pkix.Name{OrganizationalUnit: []string{"platform"}},
+	}
+
+	tests := []struct {
+		name    string
+		matcher CertMatcher
+		req     *http.Request
+		want    bool
+	}{
+		{"dns exact", CertMatcher{DNSNames: []string{"api.payments.svc.cluster.local"}}, withCert(cert, true), true},
+		{"dns wildcard", CertMatcher{DNSNames: []string{"*.svc.cluster.local"}}, withCert(cert, true), true},
+		{"dns mismatch", CertMatcher{DNSNames: []string{"*.example.com"}}, withCert(cert, true), false},
+		{"uri prefix", CertMatcher{URIPrefixes: []string{"spiffe://cluster.local/ns/payments/"}}, withCert(cert, true), true},
+		{"uri other namespace", CertMatcher{URIPrefixes: []string{"spiffe://cluster.local/ns/web/"}}, withCert(cert, true), false},
+		{"uri prefix without slash", CertMatcher{URIPrefixes: []string{"spiffe://cluster.local/ns/payments"}}, withCert(cert, true), true},
+		{"uri prefix spoofed by a longer segment", CertMatcher{URIPrefixes: []string{"spiffe://cluster.local/ns/pay"}}, withCert(cert, true), false},
+		{"ou", CertMatcher{OrganizationalUnits: []string{"platform"}}, withCert(cert, true), true},
+		{"unverified", CertMatcher{OrganizationalUnits: []string{"platform"}}, withCert(cert, false), false},
+		{"plaintext", CertMatcher{OrganizationalUnits: []string{"platform"}}, httptest.NewRequest("GET", "/", nil), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.matcher.Match(tt.req); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMatchDNSName(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.example.com", "a.example.com", true},
+		{"*.example.com", "a.b.EXAMPLE.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", ".example.com", false},
+		{"Example.com", "example.com", true},
+	}
+	for _, tt := range tests {
+		if got := matchDNSName(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("%q vs %q: expected %v, got %v", tt.pattern, tt.name, tt.want, got)
+		}
+	}
+}
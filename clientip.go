@@ -0,0 +1,123 @@
+package shedder
+
+import (
+	"container/list"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ClientIPConfig configures per-client-IP concurrency caps.
+type ClientIPConfig struct {
+	// MaxInflight is the number of requests a single client IP may have
+	// in flight. Required.
+	MaxInflight int64
+
+	// TrustedProxies is the number of proxies in front of the service that
+	// append to X-Forwarded-For. The client IP is the address that many
+	// hops from the right of the header. Zero ignores X-Forwarded-For and
+	// uses the connection's RemoteAddr, which is the only safe choice when
+	// clients can reach the pod directly.
+	TrustedProxies int
+
+	// MaxClients bounds the number of tracked clients; the least recently
+	// seen idle clients are evicted first. Defaults to 10000.
+	MaxClients int
+}
+
+// clientIP returns the client address of r, trusting the given number of
+// proxy hops in X-Forwarded-For.
+func clientIP(r *http.Request, trustedProxies int) string {
+	if trustedProxies > 0 {
+		if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+			hops := strings.Split(strings.Join(xff, ","), ",")
+			i := len(hops) - trustedProxies
+			if i < 0 {
+				i = 0
+			}
+			if ip := strings.TrimSpace(hops[i]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// clientCaps counts in-flight requests per client IP in an LRU.
+type clientCaps struct {
+	cfg ClientIPConfig
+
+	mu      sync.Mutex
+	lru     list.List // of *clientState, most recently seen first
+	clients map[string]*list.Element
+}
+
+// clientState tracks one client IP.
+type clientState struct {
+	ip       string
+	inflight int64
+}
+
+// newClientCaps returns caps for cfg with defaults applied.
+func newClientCaps(cfg ClientIPConfig) *clientCaps {
+	if cfg.MaxClients <= 0 {
+		cfg.MaxClients = 10000
+	}
+	return &clientCaps{cfg: cfg, clients: make(map[string]*list.Element)}
+}
+
+// acquire counts r against its client and reports whether the client is
+// over its cap. The caller must release the returned state.
+func (cc *clientCaps) acquire(r *http.Request) (*clientState, bool) {
+	ip := clientIP(r, cc.cfg.TrustedProxies)
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	elem, ok := cc.clients[ip]
+	if ok {
+		cc.lru.MoveToFront(elem)
+	} else {
+		elem = cc.lru.PushFront(&clientState{ip: ip})
+		cc.clients[ip] = elem
+	}
+	cs := elem.Value.(*clientState)
+	cs.inflight++
+	if !ok {
+		cc.evict() // after counting the request, so cs is not idle
+	}
+	return cs, cs.inflight > cc.cfg.MaxInflight
+}
+
+// release returns a slot of cs.
+func (cc *clientCaps) release(cs *clientState) {
+	cc.mu.Lock()
+	cs.inflight--
+	cc.mu.Unlock()
+}
+
+// evict drops the least recently seen idle clients while over capacity.
+// Clients with requests in flight are kept so their counts stay accurate.
+// Must be called with cc.mu held.
+func (cc *clientCaps) evict() {
+	for elem := cc.lru.Back(); elem != nil && cc.lru.Len() > cc.cfg.MaxClients; {
+		prev := elem.Prev()
+		if cs := elem.Value.(*clientState); cs.inflight == 0 {
+			cc.lru.Remove(elem)
+			delete(cc.clients, cs.ip)
+		}
+		elem = prev
+	}
+}
+
+// tracked returns the number of tracked clients.
+func (cc *clientCaps) tracked() int {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.lru.Len()
+}
@@ -0,0 +1,108 @@
+package shedder
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		remote  string
+		xff     []string
+		trusted int
+		want    string
+	}{
+		{"remote addr", "10.0.0.1:1234", nil, 0, "10.0.0.1"},
+		{"xff ignored when untrusted", "10.0.0.1:1234", []string{"1.2.3.4"}, 0, "10.0.0.1"},
+		{"one trusted proxy", "10.0.0.1:1234", []string{"6.6.6.6, 1.2.3.4"}, 1, "1.2.3.4"},
+		{"two trusted proxies", "10.0.0.1:1234", []string{"1.2.3.4, 10.0.0.9"}, 2, "1.2.3.4"},
+		{"multiple headers", "10.0.0.1:1234", []string{"6.6.6.6", "1.2.3.4"}, 1, "1.2.3.4"},
+		{"fewer hops than trusted", "10.0.0.1:1234", []string{"1.2.3.4"}, 3, "1.2.3.4"},
+		{"ipv6 remote", "[::1]:1234", nil, 0, "::1"},
+		{"no port", "10.0.0.1", nil, 0, "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := clientIP(r, tt.trusted); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestClientCaps_EvictsIdleClients(t *testing.T) {
+	cc := newClientCaps(ClientIPConfig{MaxInflight: 1, MaxClients: 2})
+	request := func(ip string) *http.Request {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = ip + ":1"
+		return r
+	}
+
+	busy, _ := cc.acquire(request("10.0.0.1"))
+	for i := 2; i <= 4; i++ {
+		idle, _ := cc.acquire(request(fmt.Sprintf("10.0.0.%d", i)))
+		cc.release(idle)
+	}
+	if got := cc.tracked(); got != 2 {
+		t.Errorf("expected 2 tracked clients, got %d", got)
+	}
+
+	// The busy client was never evicted and is still at its cap.
+	if _, over := cc.acquire(request("10.0.0.1")); !over {
+		t.Error("expected the busy client to keep its in-flight count")
+	}
+	cc.release(busy)
+}
+
+func TestClientCaps_KeepsNewClientAtCapacity(t *testing.T) {
+	cc := newClientCaps(ClientIPConfig{MaxInflight: 1, MaxClients: 1})
+	request := func(ip string) *http.Request {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = ip + ":1"
+		return r
+	}
+
+	cc.acquire(request("10.0.0.1"))
+	if _, over := cc.acquire(request("10.0.0.2")); over {
+		t.Fatal("expected the new client's first request under its cap")
+	}
+	if _, over := cc.acquire(request("10.0.0.2")); !over {
+		t.Error("expected the new client's in-flight count to be kept and capped")
+	}
+}
+
+func TestMiddleware_ClientLimit(t *testing.T) {
+	s := New(Config{HardLimit: 10, ClientIP: &ClientIPConfig{MaxInflight: 1}})
+	var handler http.Handler
+	handler = s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/outer" {
+			// A nested request from the same client while one is in flight.
+			rec := httptest.NewRecorder()
+			inner := httptest.NewRequest("GET", "/", nil)
+			inner.RemoteAddr = r.RemoteAddr
+			handler.ServeHTTP(rec, inner)
+			w.Header().Set("X-Inner-Reason", rec.Header().Get("X-Shed-Reason"))
+		}
+	}))
+
+	req := httptest.NewRequest("GET", "/outer", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get("X-Inner-Reason"); got != "client_limit" {
+		t.Errorf("expected nested request to be shed with client_limit, got %q", got)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 once the client is idle, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,72 @@
+package shedder
+
+import "time"
+
+// Clock tells the time to a Shedder's time-dependent features: latency
+// measurement, Retry-After estimates, readiness hysteresis, warmup and
+// cooldown, burst refills, peaks, and the sliding windows behind
+// ErrorRate, Surge, ShedBudget, RouteCapacity and ShedDeciderV2. Its
+// timers bound Queue and PriorityLevels waits and fire Eviction. Set
+// Config.Clock to a fake in tests and simulations, such as
+// shedtest.Clock, to step through time without sleeping. The background
+// goroutines of Gossip, ReportMetrics and the watchers still use the
+// system clock.
+type Clock interface {
+	Now() time.Time
+
+	// After returns a channel the time is sent on after d, like
+	// time.After.
+	After(d time.Duration) <-chan time.Time
+
+	// NewTimer returns a Timer that sends the time on its channel after
+	// d, like time.NewTimer.
+	NewTimer(d time.Duration) Timer
+
+	// AfterFunc returns a Timer that calls f after d, like
+	// time.AfterFunc.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a single event scheduled on a Clock.
+type Timer interface {
+	// C returns the channel the time is sent on when the timer fires; it
+	// is nil for timers made by AfterFunc.
+	C() <-chan time.Time
+
+	// Stop prevents the timer from firing. It reports whether the timer
+	// was stopped before it fired.
+	Stop() bool
+}
+
+// systemClock is the Clock reading the system clock.
+type systemClock struct{}
+
+// Now returns time.Now().
+func (systemClock) Now() time.Time { return time.Now() }
+
+// After returns time.After(d).
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// NewTimer returns a timer of the system clock.
+func (systemClock) NewTimer(d time.Duration) Timer { return systemTimer{time.NewTimer(d)} }
+
+// AfterFunc returns a timer of the system clock calling f.
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return systemTimer{time.AfterFunc(d, f)}
+}
+
+// systemTimer is a Timer of the system clock.
+type systemTimer struct {
+	t *time.Timer
+}
+
+// C returns the timer's channel.
+func (t systemTimer) C() <-chan time.Time { return t.t.C }
+
+// Stop stops the timer.
+func (t systemTimer) Stop() bool { return t.t.Stop() }
+
+// now returns the current time of the shedder's Clock.
+func (s *Shedder) now() time.Time {
+	return s.clock.Now()
+}
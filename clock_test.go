@@ -0,0 +1,161 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when advanced. Its timers fire,
+// and AfterFunc functions run, within Advance.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers map[*fakeTimer]bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1000, 0), timers: make(map[*fakeTimer]bool)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	return c.schedule(d, &fakeTimer{c: c, ch: make(chan time.Time, 1)})
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	return c.schedule(d, &fakeTimer{c: c, f: f})
+}
+
+func (c *fakeClock) schedule(d time.Duration, t *fakeTimer) *fakeTimer {
+	c.mu.Lock()
+	t.when = c.now.Add(d)
+	c.timers[t] = true
+	c.mu.Unlock()
+	c.Advance(0)
+	return t
+}
+
+// pending returns the number of timers yet to fire.
+func (c *fakeClock) pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	var due []*fakeTimer
+	for t := range c.timers {
+		if !t.when.After(now) {
+			due = append(due, t)
+			delete(c.timers, t)
+		}
+	}
+	c.mu.Unlock()
+	for _, t := range due {
+		if t.f != nil {
+			t.f()
+		} else {
+			t.ch <- now
+		}
+	}
+}
+
+type fakeTimer struct {
+	c    *fakeClock
+	when time.Time
+	ch   chan time.Time
+	f    func()
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.ch }
+
+func (t *fakeTimer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	pending := t.c.timers[t]
+	delete(t.c.timers, t)
+	return pending
+}
+
+func TestShedder_ClockDrivesWarmupAndCooldown(t *testing.T) {
+	clock := newFakeClock()
+	s := New(Config{
+		HardLimit: 100,
+		Clock:     clock,
+		Warmup:    &WarmupConfig{Duration: time.Minute},
+		Cooldown:  &CooldownConfig{Duration: time.Minute},
+	})
+
+	if got := s.currentLimit(); got >= 100 {
+		t.Errorf("expected a reduced limit while warming up, got %d", got)
+	}
+	clock.Advance(time.Minute)
+	if got := s.currentLimit(); got != 100 {
+		t.Errorf("expected the full limit once warmed up, got %d", got)
+	}
+
+	s.cooldown.trigger(s.now())
+	clock.Advance(59 * time.Second)
+	if !s.IsCoolingDown() || s.currentLimit() != 80 {
+		t.Errorf("expected a reduced limit during the cooldown, got %d", s.currentLimit())
+	}
+	clock.Advance(time.Second)
+	if s.IsCoolingDown() || s.currentLimit() != 100 {
+		t.Errorf("expected the full limit after the cooldown, got %d", s.currentLimit())
+	}
+}
+
+func TestShedder_ClockDrivesErrorRateWindow(t *testing.T) {
+	clock := newFakeClock()
+	s := New(Config{
+		HardLimit: 10,
+		Clock:     clock,
+		ErrorRate: &ErrorRateConfig{Threshold: 0.5, MinRequests: 2, Window: 10 * time.Second},
+	})
+	failing := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	for i := 0; i < 4; i++ {
+		failing.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+	if !s.IsSoftOverloaded() {
+		t.Fatal("expected soft overload at 100% errors")
+	}
+
+	clock.Advance(11 * time.Second)
+	if s.IsSoftOverloaded() {
+		t.Error("expected the errors to age out of the window")
+	}
+}
+
+func TestMiddleware_ClockTimesRequests(t *testing.T) {
+	clock := newFakeClock()
+	algorithm := &latencyRecorder{latencies: make(chan time.Duration, 1)}
+	s := New(Config{HardLimit: 10, Clock: clock, LimitAlgorithm: algorithm, CoarseClock: time.Millisecond})
+	if s.coarse != nil {
+		t.Error("expected CoarseClock to be ignored with a Clock")
+	}
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clock.Advance(250 * time.Millisecond)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if got := <-algorithm.latencies; got != 250*time.Millisecond {
+		t.Errorf("expected a latency of 250ms on the fake clock, got %v", got)
+	}
+}
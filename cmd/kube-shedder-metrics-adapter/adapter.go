@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sampath030/kube-shedder/internal/fleet"
+	"github.com/sampath030/kube-shedder/internal/kube"
+)
+
+// groupVersion is the API group and version served by the adapter.
+const groupVersion = "external.metrics.k8s.io/v1beta1"
+
+// minRateInterval is the shortest interval shed rates are computed over,
+// so frequent queries from several HPAs do not make the rate noisy.
+const minRateInterval = 15 * time.Second
+
+// metrics maps each served metric to how it is computed.
+var metrics = map[string]func(a *adapter, sum fleet.Summary, stats []fleet.PodStats, now time.Time) float64{
+	// shedder_utilization is the fleet's in-flight requests as a fraction
+	// of its summed hard limits.
+	"shedder_utilization": func(a *adapter, sum fleet.Summary, _ []fleet.PodStats, _ time.Time) float64 {
+		return sum.Utilization()
+	},
+	// shedder_inflight is the fleet's total in-flight requests.
+	"shedder_inflight": func(a *adapter, sum fleet.Summary, _ []fleet.PodStats, _ time.Time) float64 {
+		return float64(sum.Inflight)
+	},
+	// shedder_shed_rate is the fleet's shed requests per second.
+	"shedder_shed_rate": func(a *adapter, _ fleet.Summary, stats []fleet.PodStats, now time.Time) float64 {
+		return a.shedRate(stats, now)
+	},
+}
+
+// adapter serves the external metrics API from the Stats of the pods an
+// HPA's metric selector matches.
+type adapter struct {
+	client *kube.Client
+	fetch  fleet.Fetcher
+	now    func() time.Time
+
+	mu   sync.Mutex
+	last map[string]shedSample // by namespace/pod
+}
+
+// shedSample is a pod's shed count at a point in time.
+type shedSample struct {
+	shed int64
+	at   time.Time
+}
+
+// shedRate returns the summed shed rate of stats at now. Each pod's rate
+// is measured against its sample from at least minRateInterval ago; a
+// pod's first query, or one after a restart, contributes 0.
+func (a *adapter) shedRate(stats []fleet.PodStats, now time.Time) float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var rate float64
+	for _, ps := range stats {
+		if ps.Err != nil {
+			continue
+		}
+		key := ps.Pod.Metadata.Namespace + "/" + ps.Pod.Metadata.Name
+		prev, ok := a.last[key]
+		if !ok || ps.Stats.Shed < prev.shed {
+			a.last[key] = shedSample{shed: ps.Stats.Shed, at: now}
+			continue
+		}
+		if elapsed := now.Sub(prev.at); elapsed > 0 {
+			rate += float64(ps.Stats.Shed-prev.shed) / elapsed.Seconds()
+		}
+		if now.Sub(prev.at) >= minRateInterval {
+			a.last[key] = shedSample{shed: ps.Stats.Shed, at: now}
+		}
+	}
+	return rate
+}
+
+func (a *adapter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rest, ok := strings.CutPrefix(r.URL.Path, "/apis/"+groupVersion)
+	if !ok || r.Method != http.MethodGet {
+		writeStatus(w, http.StatusNotFound, "not found")
+		return
+	}
+	if rest == "" || rest == "/" {
+		a.serveResources(w)
+		return
+	}
+
+	// /namespaces/{namespace}/{metric}
+	parts := strings.Split(strings.Trim(rest, "/"), "/")
+	if len(parts) != 3 || parts[0] != "namespaces" {
+		writeStatus(w, http.StatusNotFound, "not found")
+		return
+	}
+	namespace, metric := parts[1], parts[2]
+	compute, ok := metrics[metric]
+	if !ok {
+		writeStatus(w, http.StatusNotFound, fmt.Sprintf("unknown metric %q", metric))
+		return
+	}
+
+	selector := r.URL.Query().Get("labelSelector")
+	pods, err := a.client.ListPods(r.Context(), namespace, selector)
+	if err != nil {
+		writeStatus(w, http.StatusInternalServerError, fmt.Sprintf("listing pods: %v", err))
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	for i := range pods {
+		pods[i].Metadata.Namespace = namespace
+	}
+	stats := fleet.Collect(ctx, pods, a.fetch)
+	sum := fleet.Summarize(stats)
+	if sum.Reporting == 0 {
+		writeStatus(w, http.StatusNotFound, fmt.Sprintf("no pods matching %q reported stats", selector))
+		return
+	}
+
+	now := a.now()
+	writeJSON(w, map[string]any{
+		"kind":       "ExternalMetricValueList",
+		"apiVersion": groupVersion,
+		"metadata":   map[string]any{},
+		"items": []map[string]any{{
+			"metricName":   metric,
+			"metricLabels": map[string]string{},
+			"timestamp":    now.UTC().Format(time.RFC3339),
+			"value":        quantity(compute(a, sum, stats, now)),
+		}},
+	})
+}
+
+// serveResources serves the APIResourceList API discovery reads.
+func (a *adapter) serveResources(w http.ResponseWriter) {
+	var resources []map[string]any
+	for _, name := range []string{"shedder_inflight", "shedder_shed_rate", "shedder_utilization"} {
+		resources = append(resources, map[string]any{
+			"name":         name,
+			"singularName": "",
+			"namespaced":   true,
+			"kind":         "ExternalMetricValueList",
+			"verbs":        []string{"get"},
+		})
+	}
+	writeJSON(w, map[string]any{
+		"kind":         "APIResourceList",
+		"apiVersion":   "v1",
+		"groupVersion": groupVersion,
+		"resources":    resources,
+	})
+}
+
+// quantity formats v as a Kubernetes quantity in milli-units.
+func quantity(v float64) string {
+	return fmt.Sprintf("%dm", int64(math.Round(v*1000)))
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeStatus writes a meta/v1 Status error, as the API server expects
+// from aggregated APIs.
+func writeStatus(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{
+		"kind":       "Status",
+		"apiVersion": "v1",
+		"status":     "Failure",
+		"message":    message,
+		"code":       code,
+	})
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	shedder "github.com/sampath030/kube-shedder"
+	"github.com/sampath030/kube-shedder/internal/kube"
+)
+
+func newTestAdapter(t *testing.T, stats map[string]shedder.Stats) (*adapter, *time.Time) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/prod/pods" || r.URL.Query().Get("labelSelector") != "app=checkout" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"items":[
+			{"metadata":{"name":"a"},"status":{"phase":"Running","podIP":"10.0.0.1"}},
+			{"metadata":{"name":"b"},"status":{"phase":"Running","podIP":"10.0.0.2"}}]}`))
+	}))
+	t.Cleanup(api.Close)
+
+	now := time.Unix(1000, 0)
+	return &adapter{
+		client: &kube.Client{Host: api.URL},
+		fetch: func(ctx context.Context, pod kube.Pod) (shedder.Stats, error) {
+			return stats[pod.Metadata.Name], nil
+		},
+		now:  func() time.Time { return now },
+		last: make(map[string]shedSample),
+	}, &now
+}
+
+func query(t *testing.T, a *adapter, path string) (int, map[string]any) {
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, body
+}
+
+func metricValue(t *testing.T, body map[string]any) string {
+	items, _ := body["items"].([]any)
+	if len(items) != 1 {
+		t.Fatalf("expected one item, got %v", body)
+	}
+	return items[0].(map[string]any)["value"].(string)
+}
+
+func TestAdapter_Utilization(t *testing.T) {
+	a, _ := newTestAdapter(t, map[string]shedder.Stats{
+		"a": {Inflight: 50, HardLimit: 100},
+		"b": {Inflight: 100, HardLimit: 100},
+	})
+
+	code, body := query(t, a, "/apis/external.metrics.k8s.io/v1beta1/namespaces/prod/shedder_utilization?labelSelector=app%3Dcheckout")
+	if code != http.StatusOK || body["kind"] != "ExternalMetricValueList" {
+		t.Fatalf("unexpected response %d %v", code, body)
+	}
+	if got := metricValue(t, body); got != "750m" {
+		t.Errorf("expected utilization 750m, got %s", got)
+	}
+}
+
+func TestAdapter_ShedRate(t *testing.T) {
+	stats := map[string]shedder.Stats{"a": {Shed: 100}, "b": {Shed: 10}}
+	a, now := newTestAdapter(t, stats)
+	path := "/apis/external.metrics.k8s.io/v1beta1/namespaces/prod/shedder_shed_rate?labelSelector=app%3Dcheckout"
+
+	if _, body := query(t, a, path); metricValue(t, body) != "0m" {
+		t.Errorf("expected no rate on the first query, got %v", body)
+	}
+	*now = now.Add(20 * time.Second)
+	stats["a"] = shedder.Stats{Shed: 300}
+	stats["b"] = shedder.Stats{Shed: 2} // restarted
+	if _, body := query(t, a, path); metricValue(t, body) != "10000m" {
+		t.Errorf("expected 10 sheds/s, got %v", body)
+	}
+}
+
+func TestAdapter_Discovery(t *testing.T) {
+	a, _ := newTestAdapter(t, nil)
+	code, body := query(t, a, "/apis/external.metrics.k8s.io/v1beta1")
+	if resources, _ := body["resources"].([]any); code != http.StatusOK || len(resources) != len(metrics) {
+		t.Errorf("unexpected discovery response %d %v", code, body)
+	}
+
+	code, body = query(t, a, "/apis/external.metrics.k8s.io/v1beta1/namespaces/prod/cpu")
+	if code != http.StatusNotFound || body["kind"] != "Status" {
+		t.Errorf("expected 404 Status for an unknown metric, got %d %v", code, body)
+	}
+}
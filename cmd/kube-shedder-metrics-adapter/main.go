@@ -0,0 +1,58 @@
+// Command kube-shedder-metrics-adapter serves the Kubernetes external
+// metrics API (external.metrics.k8s.io/v1beta1) from the Stats of the
+// pods running kube-shedder, so HorizontalPodAutoscalers can scale on
+// real saturation instead of proxy CPU metrics.
+//
+// For each query it lists the pods matching the HPA metric's selector in
+// the queried namespace and fetches their StatusHandler directly. It
+// serves three metrics:
+//
+//   - shedder_utilization: summed in-flight requests over summed hard
+//     limits (e.g. target 0.7)
+//   - shedder_inflight: summed in-flight requests
+//   - shedder_shed_rate: shed requests per second
+//
+// Register it with an APIService for external.metrics.k8s.io pointing at
+// its Service. Its service account needs to list pods in the namespaces
+// it serves. The API server connects over TLS; the adapter does not
+// authenticate the API server itself, so restrict access to it with a
+// NetworkPolicy.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/sampath030/kube-shedder/internal/fleet"
+	"github.com/sampath030/kube-shedder/internal/kube"
+)
+
+func main() {
+	listen := flag.String("listen", ":6443", "Address to serve the metrics API on")
+	certFile := flag.String("tls-cert-file", "", "TLS certificate file (serves plain HTTP if empty)")
+	keyFile := flag.String("tls-private-key-file", "", "TLS private key file")
+	statusPort := flag.Int("status-port", 8080, "Port of the pods' status endpoint")
+	statusPath := flag.String("status-path", "/status", "Path of the pods' status endpoint")
+	flag.Parse()
+
+	client, err := kube.InCluster()
+	if err != nil {
+		log.Fatal(err)
+	}
+	a := &adapter{
+		client: client,
+		fetch:  fleet.HTTPFetcher(&http.Client{Timeout: 2 * time.Second}, *statusPort, *statusPath),
+		now:    time.Now,
+		last:   make(map[string]shedSample),
+	}
+	srv := &http.Server{Addr: *listen, Handler: a, ReadHeaderTimeout: 10 * time.Second}
+
+	log.Printf("Serving %s on %s", groupVersion, *listen)
+	if *certFile == "" {
+		log.Print("No TLS certificate configured; serving plain HTTP")
+		log.Fatal(srv.ListenAndServe())
+	}
+	log.Fatal(srv.ListenAndServeTLS(*certFile, *keyFile))
+}
@@ -0,0 +1,58 @@
+// Command kube-shedder-operator gives platform teams declarative control
+// over shedding policy. It reconciles ShedderPolicy resources
+// (shedder.sampath030.github.io/v1alpha1, defined in shedderpolicy.yaml),
+// each declaring the limits, shed matchers and priority class quotas of
+// one Deployment, into a ConfigMap that the Deployment's pods apply with
+// WatchConfigMap.
+//
+// It lists the policies every --interval, writes each one's
+// DynamicConfig to the "config.json" key of its ConfigMap, and records the
+// outcome in the policy's status. Policies that no pod could apply are
+// rejected there and leave the ConfigMap unchanged. ConfigMaps are owned
+// by their policy, so deleting a policy deletes its ConfigMap; the pods
+// then keep the configuration last applied.
+//
+// Its service account needs "list" on shedderpolicies, "patch" on
+// shedderpolicies/status, and "get", "create" and "update" on configmaps
+// in the namespaces it serves.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sampath030/kube-shedder/internal/kube"
+)
+
+func main() {
+	namespace := flag.String("namespace", "", "Namespace to reconcile policies in (all namespaces if empty)")
+	interval := flag.Duration("interval", 30*time.Second, "How often policies are reconciled")
+	flag.Parse()
+
+	client, err := kube.InCluster()
+	if err != nil {
+		log.Fatal(err)
+	}
+	o := &operator{client: client, namespace: *namespace}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Reconciling %s every %s", resource, *interval)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		if err := o.reconcileAll(ctx); err != nil {
+			log.Print(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
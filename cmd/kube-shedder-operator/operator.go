@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+
+	shedder "github.com/sampath030/kube-shedder"
+	"github.com/sampath030/kube-shedder/internal/kube"
+)
+
+// Identity of the ShedderPolicy resource.
+const (
+	group          = "shedder.sampath030.github.io"
+	version        = "v1alpha1"
+	kind           = "ShedderPolicy"
+	resource       = "shedderpolicies"
+	configKey      = "config.json" // the ConfigMapOptions.Key default
+	managedBy      = "kube-shedder-operator"
+	managedByLabel = "app.kubernetes.io/managed-by"
+)
+
+// ShedderPolicy declares the shedding policy of one Deployment.
+type ShedderPolicy struct {
+	Metadata kube.ObjectMeta `json:"metadata"`
+	Spec     PolicySpec      `json:"spec"`
+	Status   PolicyStatus    `json:"status"`
+}
+
+// PolicySpec is the desired policy. Omitted settings keep the values the
+// pods were started with.
+type PolicySpec struct {
+	// Deployment names the Deployment whose pods apply the policy.
+	Deployment string `json:"deployment"`
+
+	// ConfigMap names the ConfigMap the policy is written to, which the
+	// pods watch with WatchConfigMap. Defaults to "<deployment>-shedder".
+	ConfigMap string `json:"configMap,omitempty"`
+
+	HardLimit      *int64   `json:"hardLimit,omitempty"`
+	SoftLimit      *int64   `json:"softLimit,omitempty"`
+	SoftLimitRatio *float64 `json:"softLimitRatio,omitempty"`
+
+	// Shed replaces the pods' shed matchers.
+	Shed *shedder.ShedMatchers `json:"shed,omitempty"`
+
+	// ClassQuotas caps each priority class returned by the pods' Classify
+	// at a fraction of the hard limit, e.g. {"batch": 0.2}.
+	ClassQuotas map[string]float64 `json:"classQuotas,omitempty"`
+}
+
+// PolicyStatus reports the outcome of the last reconcile.
+type PolicyStatus struct {
+	ObservedGeneration int64  `json:"observedGeneration,omitempty"`
+	ConfigMap          string `json:"configMap,omitempty"`
+	Error              string `json:"error,omitempty"`
+}
+
+// ShedderPolicyList is a list of ShedderPolicies.
+type ShedderPolicyList struct {
+	Items []ShedderPolicy `json:"items"`
+}
+
+// configMapName returns the name of the ConfigMap p is written to.
+func (p *ShedderPolicy) configMapName() string {
+	if p.Spec.ConfigMap != "" {
+		return p.Spec.ConfigMap
+	}
+	return p.Spec.Deployment + "-shedder"
+}
+
+// config renders p as the JSON DynamicConfig the pods apply, and rejects
+// settings that no pod could apply.
+func (p *ShedderPolicy) config() ([]byte, error) {
+	if p.Spec.Deployment == "" {
+		return nil, errors.New("spec.deployment is required")
+	}
+	dc := shedder.DynamicConfig{
+		HardLimit:      p.Spec.HardLimit,
+		SoftLimit:      p.Spec.SoftLimit,
+		SoftLimitRatio: p.Spec.SoftLimitRatio,
+		Shed:           p.Spec.Shed,
+		ClassQuotas:    p.Spec.ClassQuotas,
+	}
+
+	// Settings are validated against the pods' own ones when applied; a
+	// scratch shedder without a hard limit of its own catches the rest.
+	scratch := shedder.New(shedder.Config{
+		HardLimit: math.MaxInt32,
+		Classify:  func(*http.Request) string { return "" },
+	})
+	if err := scratch.Apply(dc); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(dc, "", "  ")
+}
+
+// operator reconciles ShedderPolicies into ConfigMaps.
+type operator struct {
+	client *kube.Client
+
+	// namespace restricts the operator to one namespace; empty watches
+	// all of them.
+	namespace string
+}
+
+// policiesPath returns the API path of the ShedderPolicies in namespace,
+// or in all namespaces if it is empty.
+func policiesPath(namespace string) string {
+	if namespace == "" {
+		return "/apis/" + group + "/" + version + "/" + resource
+	}
+	return "/apis/" + group + "/" + version + "/namespaces/" + namespace + "/" + resource
+}
+
+// reconcileAll reconciles every ShedderPolicy, logging those that fail.
+func (o *operator) reconcileAll(ctx context.Context) error {
+	var list ShedderPolicyList
+	if err := o.client.Get(ctx, policiesPath(o.namespace), &list); err != nil {
+		return fmt.Errorf("listing %s: %w", resource, err)
+	}
+	for i := range list.Items {
+		p := &list.Items[i]
+		if err := o.reconcile(ctx, p); err != nil {
+			log.Printf("%s %s/%s: %v", kind, p.Metadata.Namespace, p.Metadata.Name, err)
+		}
+	}
+	return nil
+}
+
+// reconcile writes p's ConfigMap if it changed and records the outcome in
+// p's status.
+func (o *operator) reconcile(ctx context.Context, p *ShedderPolicy) error {
+	err := o.writeConfigMap(ctx, p)
+	status := PolicyStatus{ObservedGeneration: p.Metadata.Generation, ConfigMap: p.configMapName()}
+	if err != nil {
+		status.Error = err.Error()
+	}
+	if status != p.Status {
+		path := policiesPath(p.Metadata.Namespace) + "/" + p.Metadata.Name + "/status"
+		if perr := o.client.MergePatch(ctx, path, map[string]any{"status": status}, nil); perr != nil {
+			return errors.Join(err, fmt.Errorf("updating status: %w", perr))
+		}
+	}
+	return err
+}
+
+// writeConfigMap creates or updates p's ConfigMap. It refuses to take over
+// a ConfigMap it does not control, e.g. one written for another policy.
+func (o *operator) writeConfigMap(ctx context.Context, p *ShedderPolicy) error {
+	data, err := p.config()
+	if err != nil {
+		return err
+	}
+	name := p.configMapName()
+	cm, err := o.client.GetConfigMap(ctx, p.Metadata.Namespace, name)
+	var se *kube.StatusError
+	if errors.As(err, &se) && se.StatusCode == http.StatusNotFound {
+		return o.client.CreateConfigMap(ctx, &kube.ConfigMap{
+			Metadata: kube.ObjectMeta{
+				Name:            name,
+				Namespace:       p.Metadata.Namespace,
+				Labels:          map[string]string{managedByLabel: managedBy},
+				OwnerReferences: []kube.OwnerReference{p.ownerReference()},
+			},
+			Data: map[string]string{configKey: string(data)},
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	if !controlledBy(cm.Metadata, p.Metadata.UID) {
+		return fmt.Errorf("ConfigMap %s exists and is not controlled by this %s", name, kind)
+	}
+	if cm.Data[configKey] == string(data) {
+		return nil
+	}
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data[configKey] = string(data)
+	return o.client.UpdateConfigMap(ctx, cm)
+}
+
+// ownerReference makes p the controller of its ConfigMap, so deleting p
+// deletes it.
+func (p *ShedderPolicy) ownerReference() kube.OwnerReference {
+	return kube.OwnerReference{
+		APIVersion: group + "/" + version,
+		Kind:       kind,
+		Name:       p.Metadata.Name,
+		UID:        p.Metadata.UID,
+		Controller: true,
+	}
+}
+
+// controlledBy reports whether meta's controller is the object with uid.
+func controlledBy(meta kube.ObjectMeta, uid string) bool {
+	for _, ref := range meta.OwnerReferences {
+		if ref.Controller {
+			return ref.UID == uid
+		}
+	}
+	return false
+}
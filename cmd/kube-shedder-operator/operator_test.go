@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	shedder "github.com/sampath030/kube-shedder"
+	"github.com/sampath030/kube-shedder/internal/kube"
+)
+
+// fakeAPI serves ShedderPolicies and stores the ConfigMaps and statuses
+// written by the operator.
+type fakeAPI struct {
+	mu         sync.Mutex
+	policies   string
+	configMaps map[string]kube.ConfigMap
+	statuses   map[string]PolicyStatus
+	writes     int
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+
+	switch {
+	case r.URL.Path == policiesPath(""):
+		w.Write([]byte(f.policies))
+	case strings.HasSuffix(r.URL.Path, "/status") && r.Method == http.MethodPatch:
+		if ct := r.Header.Get("Content-Type"); ct != "application/merge-patch+json" {
+			http.Error(w, "unexpected content type "+ct, http.StatusUnsupportedMediaType)
+			return
+		}
+		var patch struct{ Status PolicyStatus }
+		json.Unmarshal(body, &patch)
+		f.statuses[strings.TrimSuffix(r.URL.Path, "/status")] = patch.Status
+	case strings.HasPrefix(r.URL.Path, "/api/v1/namespaces/prod/configmaps"):
+		name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/v1/namespaces/prod/configmaps"), "/")
+		switch r.Method {
+		case http.MethodGet:
+			cm, ok := f.configMaps[name]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			json.NewEncoder(w).Encode(cm)
+		case http.MethodPost, http.MethodPut:
+			var cm kube.ConfigMap
+			json.Unmarshal(body, &cm)
+			f.configMaps[cm.Metadata.Name] = cm
+			f.writes++
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestOperator(t *testing.T, policies string) (*operator, *fakeAPI) {
+	api := &fakeAPI{policies: policies, configMaps: make(map[string]kube.ConfigMap), statuses: make(map[string]PolicyStatus)}
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	return &operator{client: &kube.Client{Host: srv.URL}}, api
+}
+
+func TestOperator_Reconcile(t *testing.T) {
+	o, api := newTestOperator(t, `{"items":[{
+		"metadata":{"name":"checkout","namespace":"prod","uid":"u1","generation":3},
+		"spec":{"deployment":"checkout","hardLimit":200,"softLimit":150,
+			"shed":{"headers":[{"name":"X-Priority","value":"low"}]},
+			"classQuotas":{"batch":0.2}}}]}`)
+
+	for i := 0; i < 2; i++ {
+		if err := o.reconcileAll(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if api.writes != 1 {
+		t.Errorf("expected an unchanged policy to be written once, got %d writes", api.writes)
+	}
+
+	cm := api.configMaps["checkout-shedder"]
+	if !controlledBy(cm.Metadata, "u1") || cm.Metadata.Labels[managedByLabel] != managedBy {
+		t.Errorf("expected ConfigMap controlled by the policy, got %+v", cm.Metadata)
+	}
+	dc, err := shedder.ParseDynamicConfig([]byte(cm.Data[configKey]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *dc.HardLimit != 200 || *dc.SoftLimit != 150 || dc.Shed.Headers[0].Value != "low" || dc.ClassQuotas["batch"] != 0.2 {
+		t.Errorf("unexpected config %s", cm.Data[configKey])
+	}
+
+	status := api.statuses[policiesPath("prod")+"/checkout"]
+	if status.ObservedGeneration != 3 || status.ConfigMap != "checkout-shedder" || status.Error != "" {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func TestOperator_RejectsInvalidPolicy(t *testing.T) {
+	o, api := newTestOperator(t, `{"items":[{
+		"metadata":{"name":"checkout","namespace":"prod","uid":"u1"},
+		"spec":{"deployment":"checkout","hardLimit":100,"softLimit":120}}]}`)
+
+	o.reconcileAll(context.Background())
+	if len(api.configMaps) != 0 {
+		t.Error("expected no ConfigMap for an invalid policy")
+	}
+	if status := api.statuses[policiesPath("prod")+"/checkout"]; !strings.Contains(status.Error, "soft_limit (120)") {
+		t.Errorf("expected the error in the status, got %+v", status)
+	}
+}
+
+func TestOperator_ConfigMapNotControlled(t *testing.T) {
+	o, api := newTestOperator(t, `{"items":[{
+		"metadata":{"name":"checkout","namespace":"prod","uid":"u1"},
+		"spec":{"deployment":"checkout","hardLimit":100}}]}`)
+	api.configMaps["checkout-shedder"] = kube.ConfigMap{
+		Metadata: kube.ObjectMeta{Name: "checkout-shedder", Namespace: "prod"},
+		Data:     map[string]string{configKey: `{"hard_limit": 50}`},
+	}
+
+	o.reconcileAll(context.Background())
+	if api.writes != 0 {
+		t.Error("expected a ConfigMap the policy does not control to be left alone")
+	}
+	if status := api.statuses[policiesPath("prod")+"/checkout"]; !strings.Contains(status.Error, "not controlled") {
+		t.Errorf("expected the conflict in the status, got %+v", status)
+	}
+}
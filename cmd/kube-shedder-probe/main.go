@@ -0,0 +1,70 @@
+// Command kube-shedder-probe reads a shedder's state from the Unix socket
+// served by Shedder.ServeUnix, for containers that share the socket's
+// emptyDir volume. As an exec probe it exits 0 when the endpoint returns
+// 2xx and 1 otherwise, printing the response body:
+//
+//	readinessProbe:
+//	  exec:
+//	    command: ["kube-shedder-probe", "--socket", "/var/run/shedder/shedder.sock", "/ready"]
+//
+// An exporter can read the Stats JSON the same way with "/status".
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+func main() {
+	socket := flag.String("socket", "/var/run/shedder/shedder.sock", "Path of the shedder's Unix socket")
+	timeout := flag.Duration("timeout", time.Second, "How long to wait for the response")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] /health|/ready|/startup|/status\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	ok, err := probe(ctx, *socket, flag.Arg(0), os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// probe GETs path over the socket, copying the response body to out, and
+// reports whether the status was 2xx.
+func probe(ctx context.Context, socket, path string, out io.Writer) (bool, error) {
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://shedder"+path, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		return false, err
+	}
+	return resp.StatusCode >= 200 && resp.StatusCode < 300, nil
+}
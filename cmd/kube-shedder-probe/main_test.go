@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	shedder "github.com/sampath030/kube-shedder"
+)
+
+func TestProbe(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "shedder.sock")
+	s := shedder.New(shedder.Config{HardLimit: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.ServeUnix(ctx, socket); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if ok, err := probe(ctx, socket, "/ready", &out); !ok || err != nil {
+		t.Errorf("expected ready, got %v, %v: %s", ok, err, out.String())
+	}
+	s.StartDraining()
+	out.Reset()
+	if ok, err := probe(ctx, socket, "/ready", &out); ok || err != nil || out.Len() == 0 {
+		t.Errorf("expected not ready with a reason while draining, got %v, %v: %q", ok, err, out.String())
+	}
+	if _, err := probe(ctx, filepath.Join(t.TempDir(), "missing.sock"), "/ready", &out); err == nil {
+		t.Error("expected an error without a socket")
+	}
+}
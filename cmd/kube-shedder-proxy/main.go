@@ -0,0 +1,86 @@
+// Command kube-shedder-proxy is a sidecar that gives services written in
+// any language the shedder's protection without a library port. It
+// proxies to the application container on localhost, shedding with the
+// Config built by FromEnv from the SHEDDER_* variables, and serves on a
+// separate admin port:
+//
+//   - /health: liveness, always 200
+//   - /ready: readiness, 503 while overloaded or draining
+//   - /status: Stats as JSON
+//   - /metrics: Stats in the Prometheus text format
+//   - /drain: a preStop hook that drains in-flight requests
+//
+// Point the Service at the proxy's port and the probes at the admin port.
+// With --config-map, the proxy also applies the DynamicConfig in that
+// ConfigMap through WatchConfigMap. On SIGTERM it drains and shuts down.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	shedder "github.com/sampath030/kube-shedder"
+)
+
+func main() {
+	listen := flag.String("listen", ":8000", "Address to proxy on")
+	adminListen := flag.String("admin-listen", ":9090", "Address to serve the probe, status and metrics endpoints on")
+	upstreamURL := flag.String("upstream", "http://127.0.0.1:8080", "Application URL to proxy to")
+	envPrefix := flag.String("env-prefix", "SHEDDER", "Prefix of the environment variables read by FromEnv")
+	configMap := flag.String("config-map", "", "ConfigMap to apply the DynamicConfig from (optional)")
+	drainTimeout := flag.Duration("drain-timeout", 30*time.Second, "How long to wait for in-flight requests on shutdown")
+	flag.Parse()
+
+	upstream, err := url.Parse(*upstreamURL)
+	if err != nil {
+		log.Fatalf("invalid --upstream: %v", err)
+	}
+	cfg, err := shedder.FromEnv(*envPrefix)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s, err := shedder.NewWithError(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if *configMap != "" {
+		err := s.WatchConfigMap(ctx, *configMap, shedder.ConfigMapOptions{
+			ReloadOptions: shedder.ReloadOptions{OnReload: func(dc shedder.DynamicConfig, err error) {
+				if err != nil {
+					log.Printf("config rejected: %v", err)
+				}
+			}},
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	proxy := &http.Server{Addr: *listen, Handler: newProxy(s, upstream), ReadHeaderTimeout: 10 * time.Second}
+	admin := &http.Server{Addr: *adminListen, Handler: newAdminMux(s, *drainTimeout), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := admin.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+	go func() {
+		<-s.DrainOnSIGTERM(ctx)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), *drainTimeout)
+		defer cancel()
+		proxy.Shutdown(shutdownCtx)
+		admin.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("Proxying %s to %s (hard limit %d)", *listen, upstream, s.HardLimit())
+	if err := proxy.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
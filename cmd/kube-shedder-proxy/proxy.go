@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+
+	shedder "github.com/sampath030/kube-shedder"
+)
+
+// newProxy returns a handler that sheds with s and forwards admitted
+// requests to upstream.
+func newProxy(s *shedder.Shedder, upstream *url.URL) http.Handler {
+	rp := httputil.NewSingleHostReverseProxy(upstream)
+	rp.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		log.Printf("proxying %s %s: %v", r.Method, r.URL.Path, err)
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	return s.Middleware(rp)
+}
+
+// newAdminMux returns the probe, status and metrics endpoints, served on
+// their own port so they are never shed.
+func newAdminMux(s *shedder.Shedder, drainTimeout time.Duration) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/health", shedder.HealthHandler())
+	mux.Handle("/ready", s.ReadyHandler())
+	mux.Handle("/status", s.StatusHandler())
+	mux.Handle("/drain", s.DrainHandler(drainTimeout))
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeMetrics(w, s.Stats())
+	})
+	return mux
+}
+
+// writeMetrics writes st in the Prometheus text exposition format.
+func writeMetrics(w io.Writer, st shedder.Stats) {
+	metric := func(name, typ, help string, value any) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, typ, name, value)
+	}
+	boolValue := func(b bool) int {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	metric("shedder_inflight", "gauge", "Requests currently in flight.", st.Inflight)
+	metric("shedder_hard_limit", "gauge", "Hard limit currently in effect.", st.HardLimit)
+	metric("shedder_soft_limit", "gauge", "Soft limit currently in effect (0 if disabled).", st.SoftLimit)
+	metric("shedder_queued", "gauge", "Requests waiting for a slot.", st.Queued)
+	metric("shedder_overloaded", "gauge", "Whether the hard limit is reached.", boolValue(st.Overloaded))
+	metric("shedder_soft_overloaded", "gauge", "Whether the soft limit is reached.", boolValue(st.SoftOverloaded))
+	metric("shedder_draining", "gauge", "Whether the proxy is draining for shutdown.", boolValue(st.Draining))
+	metric("shedder_admitted_total", "counter", "Requests admitted.", st.Admitted)
+	metric("shedder_shed_total", "counter", "Requests shed.", st.Shed)
+	metric("shedder_peak_inflight", "gauge", "Maximum in-flight requests since start.", st.PeakInflight)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	shedder "github.com/sampath030/kube-shedder"
+)
+
+func TestProxy(t *testing.T) {
+	release := make(chan struct{})
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+		w.Write([]byte("app:" + r.URL.Path))
+	}))
+	defer app.Close()
+	upstream, _ := url.Parse(app.URL)
+
+	s := shedder.New(shedder.Config{HardLimit: 1})
+	proxy := httptest.NewServer(newProxy(s, upstream))
+	defer proxy.Close()
+
+	resp, err := http.Get(proxy.URL + "/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected request proxied, got %d", resp.StatusCode)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if resp, err := http.Get(proxy.URL + "/slow"); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	for s.Inflight() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	resp, err = http.Get(proxy.URL + "/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected request shed at the hard limit, got %d", resp.StatusCode)
+	}
+
+	close(release)
+	<-done
+}
+
+func TestAdminMux(t *testing.T) {
+	s := shedder.New(shedder.Config{HardLimit: 42})
+	mux := newAdminMux(s, 0)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/ready", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected ready, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := rec.Body.String()
+	for _, want := range []string{"# TYPE shedder_hard_limit gauge\nshedder_hard_limit 42\n", "shedder_shed_total 0\n", "shedder_overloaded 0\n"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected metrics to contain %q, got:\n%s", want, body)
+		}
+	}
+}
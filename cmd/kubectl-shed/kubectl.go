@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	shedder "github.com/sampath030/kube-shedder"
+	"github.com/sampath030/kube-shedder/internal/fleet"
+	"github.com/sampath030/kube-shedder/internal/kube"
+)
+
+// kubectl reads from the API server by running kubectl, so the plugin
+// uses the caller's kubeconfig, context and credentials as they are.
+type kubectl struct {
+	// flags are passed before every command, e.g. --context.
+	flags []string
+
+	// run runs kubectl with args and returns its standard output.
+	run func(ctx context.Context, args ...string) ([]byte, error)
+}
+
+// runKubectl runs the kubectl binary on the PATH.
+func runKubectl(ctx context.Context, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "kubectl", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("kubectl: %s", msg)
+		}
+		return nil, fmt.Errorf("kubectl: %w", err)
+	}
+	return out, nil
+}
+
+// getRaw GETs path from the API server.
+func (k *kubectl) getRaw(ctx context.Context, path string) ([]byte, error) {
+	return k.run(ctx, append(append([]string{}, k.flags...), "get", "--raw", path)...)
+}
+
+// namespace returns the namespace of the current context, or "default".
+func (k *kubectl) namespace(ctx context.Context) string {
+	out, err := k.run(ctx, append(append([]string{}, k.flags...), "config", "view", "--minify", "-o", "jsonpath={..namespace}")...)
+	if ns := strings.TrimSpace(string(out)); err == nil && ns != "" {
+		return ns
+	}
+	return "default"
+}
+
+// listPods lists the pods in namespace matching selector.
+func (k *kubectl) listPods(ctx context.Context, namespace, selector string) ([]kube.Pod, error) {
+	path := "/api/v1/namespaces/" + namespace + "/pods"
+	if selector != "" {
+		path += "?labelSelector=" + url.QueryEscape(selector)
+	}
+	out, err := k.getRaw(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	var list kube.PodList
+	if err := json.Unmarshal(out, &list); err != nil {
+		return nil, fmt.Errorf("decoding pods: %w", err)
+	}
+	return list.Items, nil
+}
+
+// fetcher returns a Fetcher that reads each pod's StatusHandler on port
+// and path through the API server's pod proxy, so it works from outside
+// the cluster.
+func (k *kubectl) fetcher(namespace string, port int, path string) fleet.Fetcher {
+	return func(ctx context.Context, pod kube.Pod) (shedder.Stats, error) {
+		out, err := k.getRaw(ctx, "/api/v1/namespaces/"+namespace+"/pods/"+pod.Metadata.Name+":"+strconv.Itoa(port)+"/proxy"+path)
+		if err != nil {
+			return shedder.Stats{}, err
+		}
+		return fleet.DecodeStats(http.StatusOK, bytes.NewReader(out))
+	}
+}
@@ -0,0 +1,83 @@
+// Command kubectl-shed is a kubectl plugin that shows the shed status of
+// every pod matching a selector, replacing ad-hoc loops over curl during
+// incidents:
+//
+//	kubectl shed -n prod -l app=checkout
+//
+// It reads each pod's StatusHandler through the API server's pod proxy,
+// samples it twice --interval apart to compute shed rates, and prints a
+// table of in-flight requests, limits, utilization, shed rates and
+// overload state with a fleet-wide total. It runs kubectl for every API
+// call, so it uses the current kubeconfig and context; --context and
+// --kubeconfig are passed through. The caller needs "list" on pods and
+// "get" on pods/proxy.
+//
+// Install it by putting the binary on the PATH.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/sampath030/kube-shedder/internal/fleet"
+)
+
+func main() {
+	fs := flag.NewFlagSet("kubectl shed", flag.ExitOnError)
+	namespace := fs.String("n", "", "Namespace (defaults to the current context's)")
+	selector := fs.String("l", "", "Label selector, e.g. app=checkout")
+	port := fs.Int("port", 8080, "Port of the pods' status endpoint")
+	path := fs.String("path", "/status", "Path of the pods' status endpoint")
+	interval := fs.Duration("interval", 5*time.Second, "Interval shed rates are sampled over (0 disables them)")
+	kubeContext := fs.String("context", "", "kubeconfig context to use")
+	kubeconfig := fs.String("kubeconfig", "", "Path to the kubeconfig file")
+	fs.Parse(os.Args[1:])
+
+	k := &kubectl{run: runKubectl}
+	if *kubeContext != "" {
+		k.flags = append(k.flags, "--context", *kubeContext)
+	}
+	if *kubeconfig != "" {
+		k.flags = append(k.flags, "--kubeconfig", *kubeconfig)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, k, *namespace, *selector, *port, *path, *interval); err != nil {
+		fmt.Fprintln(os.Stderr, "kubectl-shed:", err)
+		os.Exit(1)
+	}
+}
+
+// run collects the matching pods' stats and prints them.
+func run(ctx context.Context, k *kubectl, namespace, selector string, port int, path string, interval time.Duration) error {
+	if namespace == "" {
+		namespace = k.namespace(ctx)
+	}
+	pods, err := k.listPods(ctx, namespace, selector)
+	if err != nil {
+		return err
+	}
+	if len(pods) == 0 {
+		return fmt.Errorf("no pods in %s match %q", namespace, selector)
+	}
+	fetch := k.fetcher(namespace, port, path)
+
+	stats := fleet.Collect(ctx, pods, fetch)
+	var rates map[string]float64
+	if interval > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+		before := stats
+		stats = fleet.Collect(ctx, pods, fetch)
+		rates = shedRates(before, stats, interval)
+	}
+	return printTable(os.Stdout, stats, rates)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	shedder "github.com/sampath030/kube-shedder"
+	"github.com/sampath030/kube-shedder/internal/fleet"
+	"github.com/sampath030/kube-shedder/internal/kube"
+)
+
+func TestKubectl_ListAndFetch(t *testing.T) {
+	var calls []string
+	k := &kubectl{
+		flags: []string{"--context", "prod"},
+		run: func(ctx context.Context, args ...string) ([]byte, error) {
+			calls = append(calls, strings.Join(args, " "))
+			switch args[len(args)-1] {
+			case "/api/v1/namespaces/shop/pods?labelSelector=app%3Dcheckout":
+				return []byte(`{"items":[{"metadata":{"name":"a"},"status":{"phase":"Running","podIP":"10.0.0.1"}}]}`), nil
+			case "/api/v1/namespaces/shop/pods/a:8080/proxy/status":
+				return []byte(`{"inflight":7,"hard_limit":10}`), nil
+			}
+			return nil, errors.New("not found")
+		},
+	}
+
+	pods, err := k.listPods(context.Background(), "shop", "app=checkout")
+	if err != nil || len(pods) != 1 {
+		t.Fatalf("unexpected pods %+v, %v", pods, err)
+	}
+	st, err := k.fetcher("shop", 8080, "/status")(context.Background(), pods[0])
+	if err != nil || st.Inflight != 7 || st.HardLimit != 10 {
+		t.Errorf("unexpected stats %+v, %v", st, err)
+	}
+	if !strings.HasPrefix(calls[0], "--context prod get --raw ") {
+		t.Errorf("expected global flags before the command, got %q", calls[0])
+	}
+	if got := k.namespace(context.Background()); got != "default" {
+		t.Errorf("expected the default namespace without one in the context, got %q", got)
+	}
+}
+
+func TestPrintTable(t *testing.T) {
+	podStats := func(name string, st shedder.Stats, err error) fleet.PodStats {
+		return fleet.PodStats{Pod: kube.Pod{Metadata: kube.ObjectMeta{Name: name}}, Stats: st, Err: err}
+	}
+	before := []fleet.PodStats{
+		podStats("a", shedder.Stats{Shed: 10}, nil),
+		podStats("b", shedder.Stats{Shed: 50}, nil),
+	}
+	after := []fleet.PodStats{
+		podStats("a", shedder.Stats{Inflight: 90, HardLimit: 100, SoftLimit: 80, Shed: 60, SoftOverloaded: true}, nil),
+		podStats("b", shedder.Stats{Inflight: 10, HardLimit: 100, Shed: 2}, nil), // restarted
+		podStats("c", shedder.Stats{}, errors.New("connection refused")),
+	}
+	rates := shedRates(before, after, 5*time.Second)
+	if len(rates) != 1 || rates["a"] != 10 {
+		t.Errorf("expected only a's rate of 10/s, got %v", rates)
+	}
+
+	var buf bytes.Buffer
+	if err := printTable(&buf, after, rates); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected a header, three pods and a total, got:\n%s", buf.String())
+	}
+	for i, want := range [][]string{
+		{"a", "90", "100", "80", "90%", "10.0", "soft-overloaded"},
+		{"b", "10", "-", "ok"},
+		{"c", "error: connection refused"},
+		{"TOTAL (2/3)", "100", "200", "50%", "10.0", "0 overloaded, 1 soft"},
+	} {
+		for _, field := range want {
+			if !strings.Contains(lines[i+1], field) {
+				t.Errorf("expected row %q to contain %q", lines[i+1], field)
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sampath030/kube-shedder/internal/fleet"
+)
+
+// shedRates returns each pod's shed rate between the samples before and
+// after, taken interval apart, by pod name. Pods missing from either
+// sample, or restarted in between, have no rate.
+func shedRates(before, after []fleet.PodStats, interval time.Duration) map[string]float64 {
+	prev := make(map[string]int64, len(before))
+	for _, ps := range before {
+		if ps.Err == nil {
+			prev[ps.Pod.Metadata.Name] = ps.Stats.Shed
+		}
+	}
+	rates := make(map[string]float64, len(after))
+	for _, ps := range after {
+		shed, ok := prev[ps.Pod.Metadata.Name]
+		if ps.Err != nil || !ok || ps.Stats.Shed < shed {
+			continue
+		}
+		rates[ps.Pod.Metadata.Name] = float64(ps.Stats.Shed-shed) / interval.Seconds()
+	}
+	return rates
+}
+
+// state summarizes a pod's overload state in one word.
+func state(ps fleet.PodStats) string {
+	switch {
+	case ps.Err != nil:
+		return "error: " + ps.Err.Error()
+	case ps.Stats.Draining:
+		return "draining"
+	case ps.Stats.Overloaded:
+		return "overloaded"
+	case ps.Stats.SoftOverloaded:
+		return "soft-overloaded"
+	}
+	return "ok"
+}
+
+// printTable renders one row per pod and a total. rates may be nil if
+// shed rates were not sampled.
+func printTable(w io.Writer, stats []fleet.PodStats, rates map[string]float64) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "POD\tINFLIGHT\tHARD\tSOFT\tUTIL\tSHED/S\tADMITTED\tSHED\tSTATE")
+
+	rate := func(name string) string {
+		r, ok := rates[name]
+		if !ok {
+			return "-"
+		}
+		return fmt.Sprintf("%.1f", r)
+	}
+	for _, ps := range stats {
+		name := ps.Pod.Metadata.Name
+		if ps.Err != nil {
+			fmt.Fprintf(tw, "%s\t-\t-\t-\t-\t-\t-\t-\t%s\n", name, state(ps))
+			continue
+		}
+		st := ps.Stats
+		util := fleet.Summary{Inflight: st.Inflight, HardLimit: st.HardLimit}.Utilization()
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.0f%%\t%s\t%d\t%d\t%s\n",
+			name, st.Inflight, st.HardLimit, st.SoftLimit, util*100, rate(name), st.Admitted, st.Shed, state(ps))
+	}
+
+	sum := fleet.Summarize(stats)
+	total := "-"
+	if rates != nil {
+		var r float64
+		for _, v := range rates {
+			r += v
+		}
+		total = fmt.Sprintf("%.1f", r)
+	}
+	fmt.Fprintf(tw, "TOTAL (%d/%d)\t%d\t%d\t\t%.0f%%\t%s\t%d\t%d\t%d overloaded, %d soft\n",
+		sum.Reporting, sum.Pods, sum.Inflight, sum.HardLimit, sum.Utilization()*100, total,
+		sum.Admitted, sum.Shed, sum.Overloaded, sum.SoftOverloaded)
+	return tw.Flush()
+}
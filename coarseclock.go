@@ -0,0 +1,59 @@
+package shedder
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// minCoarseClock is the finest CoarseClock resolution; a finer ticker
+// would cost more than the time.Now calls it replaces.
+const minCoarseClock = 100 * time.Microsecond
+
+// coarseClock is a clock read with one atomic load, advanced by a
+// background goroutine every resolution, for CoarseClock.
+type coarseClock struct {
+	base    time.Time
+	elapsed atomic.Int64 // since base, in nanoseconds
+}
+
+var (
+	coarseClocksMu sync.Mutex
+	coarseClocks   = make(map[time.Duration]*coarseClock)
+)
+
+// sharedCoarseClock returns the clock of the given resolution, starting
+// it on first use. Shedders with the same resolution share one clock,
+// which runs for the life of the process.
+func sharedCoarseClock(resolution time.Duration) *coarseClock {
+	coarseClocksMu.Lock()
+	defer coarseClocksMu.Unlock()
+	if c := coarseClocks[resolution]; c != nil {
+		return c
+	}
+	c := &coarseClock{base: time.Now()}
+	coarseClocks[resolution] = c
+	go func() {
+		ticker := time.NewTicker(resolution)
+		defer ticker.Stop()
+		for range ticker.C {
+			c.elapsed.Store(int64(time.Since(c.base)))
+		}
+	}()
+	return c
+}
+
+// now returns the time as of the last tick. Like time.Now, it carries a
+// monotonic clock reading.
+func (c *coarseClock) now() time.Time {
+	return c.base.Add(time.Duration(c.elapsed.Load()))
+}
+
+// sampleNow returns the time admitted requests are timed with: the
+// CoarseClock, if configured.
+func (s *Shedder) sampleNow() time.Time {
+	if s.coarse != nil {
+		return s.coarse.now()
+	}
+	return s.now()
+}
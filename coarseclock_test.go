@@ -0,0 +1,47 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// latencyRecorder is a LimitAlgorithm that keeps the latencies it samples.
+type latencyRecorder struct {
+	latencies chan time.Duration
+}
+
+func (l *latencyRecorder) OnSample(latency time.Duration, inflight int64, didShed bool) int64 {
+	l.latencies <- latency
+	return 10
+}
+
+func TestShedder_CoarseClock(t *testing.T) {
+	c := sharedCoarseClock(time.Millisecond)
+	if sharedCoarseClock(time.Millisecond) != c {
+		t.Error("expected shedders with the same resolution to share a clock")
+	}
+	start := c.now()
+	time.Sleep(20 * time.Millisecond)
+	if d := c.now().Sub(start); d < 10*time.Millisecond || d > time.Second {
+		t.Errorf("expected the clock to advance about 20ms, got %v", d)
+	}
+
+	algorithm := &latencyRecorder{latencies: make(chan time.Duration, 1)}
+	s := New(Config{HardLimit: 10, LimitAlgorithm: algorithm, CoarseClock: time.Millisecond})
+	if s.coarse != c {
+		t.Fatal("expected the shedder to use the shared clock")
+	}
+	h := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if latency := <-algorithm.latencies; latency < 10*time.Millisecond || latency > time.Second {
+		t.Errorf("expected a latency of about 20ms, got %v", latency)
+	}
+
+	if New(Config{HardLimit: 10, CoarseClock: time.Millisecond}).coarse != nil {
+		t.Error("expected no clock when requests are not timed")
+	}
+}
@@ -0,0 +1,23 @@
+package shedder
+
+// config is the part of a Shedder's configuration that can change while
+// it runs: the soft limit, the ShedDecider and the class quotas. A stored
+// config is never modified; Apply and the setters store a modified copy,
+// so the request path reads a consistent config with one atomic load and
+// never locks. The hard limit is not part of it, since the LimitAlgorithm
+// replaces it after every request.
+type config struct {
+	softLimit int64
+	softRatio float64 // 0 unless SoftLimitRatio is set
+	decider   ShedDecider
+	quotas    *classQuotas // nil without ClassQuotas
+}
+
+// updateConfig stores a copy of the config in effect modified by update.
+func (s *Shedder) updateConfig(update func(c *config)) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	c := *s.config.Load()
+	update(&c)
+	s.config.Store(&c)
+}
@@ -0,0 +1,131 @@
+package shedder
+
+import (
+	"context"
+	"fmt"
+	"os/signal"
+	"time"
+
+	"github.com/sampath030/kube-shedder/internal/kube"
+)
+
+// ConfigMapOptions configures WatchConfigMap.
+type ConfigMapOptions struct {
+	ReloadOptions
+
+	// Namespace of the ConfigMap. Defaults to the pod's namespace.
+	Namespace string
+
+	// Key is the data key holding the JSON DynamicConfig. Defaults to
+	// "config.json".
+	Key string
+}
+
+// WatchConfigMap applies the JSON DynamicConfig stored in a ConfigMap and
+// re-applies it whenever it changes, by watching it through the Kubernetes
+// API with the pod's service account until ctx is done. Unlike WatchFile
+// on a ConfigMap volume, changes take effect as soon as the API server
+// reports them rather than after the kubelet sync period. The service
+// account needs "get" and "watch" on the ConfigMap:
+//
+//	rules:
+//	- apiGroups: [""]
+//	  resources: ["configmaps"]
+//	  resourceNames: ["my-shedder-config"]
+//	  verbs: ["get", "watch"]
+//
+// The watch resumes from the last version seen when the API server closes
+// it. If it fails, the ConfigMap is read again after Interval, or on
+// SIGHUP with ReloadOnSIGHUP, and watched from its current version;
+// failures are reported to OnReload.
+//
+// It returns an error, without watching, if the ConfigMap cannot be loaded
+// or applied initially.
+func (s *Shedder) WatchConfigMap(ctx context.Context, name string, opts ConfigMapOptions) error {
+	client, err := kube.InCluster()
+	if err != nil {
+		return err
+	}
+	if opts.Namespace == "" {
+		if opts.Namespace, err = kube.Namespace(); err != nil {
+			return err
+		}
+	}
+	return s.watchConfigMap(ctx, client, name, opts)
+}
+
+// watchConfigMap implements WatchConfigMap with the given client.
+func (s *Shedder) watchConfigMap(ctx context.Context, client *kube.Client, name string, opts ConfigMapOptions) error {
+	if opts.Key == "" {
+		opts.Key = "config.json"
+	}
+	w := &configMapWatcher{client: client, name: name, opts: opts}
+	w.rl = newReloader(s, &w.opts.ReloadOptions, func(ctx context.Context) ([]byte, error) {
+		cm, err := client.GetConfigMap(ctx, opts.Namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		w.version = cm.Metadata.ResourceVersion
+		return w.data(cm)
+	})
+	if err := w.rl.reload(ctx); err != nil {
+		return err
+	}
+	retry := w.opts.Interval
+	if retry < 0 {
+		retry = 10 * time.Second
+	}
+	signals := notifySIGHUP(opts.ReloadOptions)
+	kw := &kubeWatch{
+		list:    func(ctx context.Context) { w.rl.reload(ctx) },
+		watch:   w.watch,
+		report:  func(err error) { w.rl.report(DynamicConfig{}, err) },
+		retry:   retry,
+		signals: signals,
+	}
+	go func() {
+		if signals != nil {
+			defer signal.Stop(signals)
+		}
+		kw.run(ctx)
+	}()
+	return nil
+}
+
+// configMapWatcher applies a ConfigMap's changes from a watch.
+type configMapWatcher struct {
+	client *kube.Client
+	name   string
+	opts   ConfigMapOptions
+	rl     *reloader
+
+	// version is the ResourceVersion to resume watching from.
+	version string
+}
+
+// watch applies changes until the watch ends.
+func (w *configMapWatcher) watch(ctx context.Context) error {
+	return w.client.WatchConfigMap(ctx, w.opts.Namespace, w.name, w.version, func(ev kube.ConfigMapEvent) {
+		w.version = ev.Object.Metadata.ResourceVersion
+		switch ev.Type {
+		case "ADDED", "MODIFIED":
+			data, err := w.data(&ev.Object)
+			if err != nil {
+				w.rl.report(DynamicConfig{}, err)
+				return
+			}
+			w.rl.apply(data)
+		case "DELETED":
+			w.rl.report(DynamicConfig{}, fmt.Errorf("shedder: ConfigMap %s/%s was deleted", w.opts.Namespace, w.name))
+		}
+	})
+}
+
+// data returns the configuration stored in cm.
+func (w *configMapWatcher) data(cm *kube.ConfigMap) ([]byte, error) {
+	data, ok := cm.Data[w.opts.Key]
+	if !ok {
+		return nil, fmt.Errorf("shedder: ConfigMap %s/%s has no key %q", w.opts.Namespace, w.name, w.opts.Key)
+	}
+	return []byte(data), nil
+}
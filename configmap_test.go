@@ -0,0 +1,142 @@
+package shedder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sampath030/kube-shedder/internal/kube"
+)
+
+func TestShedder_WatchConfigMap(t *testing.T) {
+	var config atomic.Value
+	config.Store(`{"hard_limit": 50}`)
+	events := make(chan string)
+	versions := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v1/namespaces/prod/configmaps/shedder":
+			json.NewEncoder(w).Encode(kube.ConfigMap{
+				Metadata: kube.ObjectMeta{ResourceVersion: "3"},
+				Data:     map[string]string{"config.json": config.Load().(string)},
+			})
+		case r.URL.Path == "/api/v1/namespaces/prod/configmaps" && r.URL.Query().Get("watch") == "true":
+			versions <- r.URL.Query().Get("resourceVersion")
+			w.(http.Flusher).Flush()
+			for {
+				select {
+				case <-r.Context().Done():
+					return
+				case event := <-events:
+					w.Write([]byte(event + "\n"))
+					w.(http.Flusher).Flush()
+				}
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	s := New(Config{HardLimit: 100})
+	reloads := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := s.watchConfigMap(ctx, &kube.Client{Host: srv.URL}, "shedder", ConfigMapOptions{
+		Namespace: "prod",
+		ReloadOptions: ReloadOptions{
+			Interval: time.Hour,
+			OnReload: func(dc DynamicConfig, err error) { reloads <- err },
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-reloads
+	if s.HardLimit() != 50 {
+		t.Errorf("expected hard limit 50, got %d", s.HardLimit())
+	}
+	if v := <-versions; v != "3" {
+		t.Errorf("expected the watch to start from version 3, got %q", v)
+	}
+
+	events <- `{"type": "MODIFIED", "object": {"metadata": {"resourceVersion": "4"}, "data": {"config.json": "{\"hard_limit\": 80}"}}}`
+	if err := waitReload(t, reloads); err != nil {
+		t.Fatal(err)
+	}
+	if s.HardLimit() != 80 {
+		t.Errorf("expected hard limit 80, got %d", s.HardLimit())
+	}
+
+	// An expired version is relisted at once, not after Interval.
+	config.Store(`{"hard_limit": 60}`)
+	events <- `{"type": "ERROR", "object": {"kind": "Status", "code": 410, "message": "too old resource version"}}`
+	if err := waitReload(t, reloads); err != nil {
+		t.Fatal(err)
+	}
+	if s.HardLimit() != 60 {
+		t.Errorf("expected hard limit 60, got %d", s.HardLimit())
+	}
+	if v := <-versions; v != "3" {
+		t.Errorf("expected the watch to restart from the relisted version 3, got %q", v)
+	}
+}
+
+func TestShedder_WatchConfigMapFailedWatch(t *testing.T) {
+	var watches atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") == "true" {
+			watches.Add(1)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"data": {"config.json": "{\"hard_limit\": 50}"}}`))
+	}))
+	defer srv.Close()
+
+	s := New(Config{HardLimit: 100})
+	reloads := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := s.watchConfigMap(ctx, &kube.Client{Host: srv.URL}, "shedder", ConfigMapOptions{
+		Namespace: "prod",
+		ReloadOptions: ReloadOptions{
+			Interval: 5 * time.Millisecond,
+			OnReload: func(dc DynamicConfig, err error) { reloads <- err },
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-reloads
+	var se *kube.StatusError
+	if err := waitReload(t, reloads); !errors.As(err, &se) || se.StatusCode != http.StatusForbidden {
+		t.Errorf("expected the failed watch reported, got %v", err)
+	}
+	// The watch is retried after Interval.
+	waitReload(t, reloads)
+	if n := watches.Load(); n < 2 {
+		t.Errorf("expected the watch retried, got %d watches", n)
+	}
+}
+
+func TestShedder_WatchConfigMapMissingKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": {"other": "{}"}}`))
+	}))
+	defer srv.Close()
+
+	s := New(Config{HardLimit: 100})
+	err := s.watchConfigMap(context.Background(), &kube.Client{Host: srv.URL}, "shedder", ConfigMapOptions{Namespace: "prod"})
+	if err == nil || !strings.Contains(err.Error(), `no key "config.json"`) {
+		t.Errorf("expected missing key error, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package shedder
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// CooldownConfig configures the reduced limit kept after a hard overload.
+type CooldownConfig struct {
+	// Duration is how long the limit stays reduced after the most recent
+	// hard-limit shed beyond the full limit; sheds only caused by the
+	// reduction do not extend it. Required.
+	Duration time.Duration
+
+	// LimitFraction is the fraction (0-1) of the hard limit in effect
+	// during the cooldown. Defaults to 0.8.
+	LimitFraction float64
+}
+
+// cooldown keeps the effective hard limit reduced for a while after a hard
+// overload episode, so that the pod does not immediately re-overload.
+type cooldown struct {
+	duration int64 // nanoseconds
+	fraction float64
+	last     atomic.Int64 // unix nanoseconds of the last hard shed, 0 if none
+}
+
+// newCooldown returns a cooldown for cfg with defaults applied.
+func newCooldown(cfg CooldownConfig) *cooldown {
+	if cfg.LimitFraction <= 0 || cfg.LimitFraction > 1 {
+		cfg.LimitFraction = 0.8
+	}
+	return &cooldown{duration: int64(cfg.Duration), fraction: cfg.LimitFraction}
+}
+
+// trigger starts (or extends) the cooldown at now.
+func (c *cooldown) trigger(now time.Time) {
+	c.last.Store(now.UnixNano())
+}
+
+// active reports whether the cooldown is in effect at now.
+func (c *cooldown) active(now time.Time) bool {
+	last := c.last.Load()
+	return last != 0 && now.UnixNano()-last < c.duration
+}
+
+// scale returns limit reduced if the cooldown is active at now.
+func (c *cooldown) scale(limit int64, now time.Time) int64 {
+	if !c.active(now) {
+		return limit
+	}
+	scaled := int64(float64(limit) * c.fraction)
+	if scaled < 1 {
+		scaled = 1
+	}
+	return scaled
+}
+
+// triggerCooldown starts or extends the cooldown after a hard-limit shed of
+// a request arriving with current in flight, unless current is within the
+// limit without the cooldown reduction: such sheds are caused by the
+// cooldown itself, and letting them extend it would hold the limit reduced
+// for as long as load stays between the reduced and the full limit.
+func (s *Shedder) triggerCooldown(current int64) {
+	if current <= s.uncooledLimit() {
+		return
+	}
+	s.cooldown.trigger(s.now())
+}
+
+// IsCoolingDown reports whether the hard limit is reduced following a
+// recent hard overload.
+func (s *Shedder) IsCoolingDown() bool {
+	return s.cooldown != nil && s.cooldown.active(s.now())
+}
@@ -0,0 +1,90 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCooldown_Scale(t *testing.T) {
+	c := newCooldown(CooldownConfig{Duration: 10 * time.Second})
+	now := time.Unix(1000, 0)
+
+	if got := c.scale(100, now); got != 100 {
+		t.Errorf("expected full limit before any overload, got %d", got)
+	}
+
+	c.trigger(now)
+	if got := c.scale(100, now.Add(5*time.Second)); got != 80 {
+		t.Errorf("expected default 80%% limit during cooldown, got %d", got)
+	}
+	if got := c.scale(100, now.Add(10*time.Second)); got != 100 {
+		t.Errorf("expected full limit after cooldown, got %d", got)
+	}
+}
+
+func TestMiddleware_HardShedStartsCooldown(t *testing.T) {
+	s := New(Config{
+		HardLimit: 10,
+		Cooldown:  &CooldownConfig{Duration: time.Hour, LimitFraction: 0.5},
+	})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for i := 0; i < 10; i++ {
+		s.increment(1)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 above hard limit, got %d", rec.Code)
+	}
+
+	if !s.IsCoolingDown() {
+		t.Error("expected cooldown after hard shed")
+	}
+	if got := s.currentLimit(); got != 5 {
+		t.Errorf("expected reduced limit 5, got %d", got)
+	}
+}
+
+func TestMiddleware_CooldownEndsUnderSteadyLoad(t *testing.T) {
+	clock := newFakeClock()
+	s := New(Config{
+		HardLimit: 10,
+		Clock:     clock,
+		Cooldown:  &CooldownConfig{Duration: 10 * time.Second, LimitFraction: 0.5},
+	})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func() int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		return rec.Code
+	}
+
+	// Overload beyond the configured limit starts the cooldown.
+	for i := 0; i < 10; i++ {
+		s.increment(1)
+	}
+	if serve() != http.StatusServiceUnavailable || !s.IsCoolingDown() {
+		t.Fatal("expected a hard shed to start the cooldown")
+	}
+
+	// Load back at the configured limit is shed by the reduced limit
+	// during the cooldown, which must not extend it.
+	s.decrement(1)
+	for elapsed := time.Duration(0); elapsed < 10*time.Second; elapsed += time.Second {
+		if code := serve(); code != http.StatusServiceUnavailable {
+			t.Fatalf("at %v: expected 503 at the reduced limit, got %d", elapsed, code)
+		}
+		clock.Advance(time.Second)
+	}
+	if s.IsCoolingDown() || s.currentLimit() != 10 {
+		t.Errorf("expected the cooldown to end after its Duration, limit %d", s.currentLimit())
+	}
+	if code := serve(); code != http.StatusOK {
+		t.Errorf("expected requests admitted at the configured limit, got %d", code)
+	}
+}
@@ -0,0 +1,96 @@
+package shedder
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync/atomic"
+)
+
+// CostBucket assigns Cost to requests whose body is at least MinBytes.
+type CostBucket struct {
+	MinBytes int64
+	Cost     int64
+}
+
+// DefaultContentLengthBuckets count uploads of 1 MiB, 10 MiB and 100 MiB
+// or more as 2, 4 and 8 units.
+var DefaultContentLengthBuckets = []CostBucket{
+	{MinBytes: 1 << 20, Cost: 2},
+	{MinBytes: 10 << 20, Cost: 4},
+	{MinBytes: 100 << 20, Cost: 8},
+}
+
+// ContentLengthCost returns a Config.Cost function that weighs requests
+// by their Content-Length: a request costs the Cost of the largest bucket
+// its body reaches, or 1 if it reaches none. Requests of unknown length,
+// such as chunked uploads, cost as much as the largest bucket. With no
+// buckets, DefaultContentLengthBuckets is used.
+func ContentLengthCost(buckets ...CostBucket) func(r *http.Request) int64 {
+	if len(buckets) == 0 {
+		buckets = DefaultContentLengthBuckets
+	}
+	sorted := append([]CostBucket(nil), buckets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].MinBytes < sorted[j].MinBytes })
+
+	return func(r *http.Request) int64 {
+		if r.ContentLength < 0 {
+			return sorted[len(sorted)-1].Cost
+		}
+		cost := int64(1)
+		for _, b := range sorted {
+			if r.ContentLength < b.MinBytes {
+				break
+			}
+			cost = b.Cost
+		}
+		return cost
+	}
+}
+
+// requestCost returns the number of in-flight units r counts for.
+func (s *Shedder) requestCost(r *http.Request) int64 {
+	if s.cost == nil {
+		return 1
+	}
+	cost := s.cost(r)
+	if cost < 1 {
+		return 1
+	}
+	if limit := s.currentLimit(); cost > limit {
+		return limit
+	}
+	return cost
+}
+
+// costReportKey is the context key of a request's *costReport.
+type costReportKey struct{}
+
+// costReport holds the cost of an admitted request: the a-priori estimate
+// until the handler reports the actual value.
+type costReport struct {
+	cost atomic.Int64
+}
+
+// withCostReport attaches a report initialized to cost to r.
+func withCostReport(r *http.Request, cost int64) (*costReport, *http.Request) {
+	report := &costReport{}
+	report.cost.Store(cost)
+	return report, r.WithContext(context.WithValue(r.Context(), costReportKey{}, report))
+}
+
+// ReportCost records the actual cost of the request whose context is ctx,
+// e.g. rows scanned or CPU milliseconds in units comparable to Config.Cost.
+// It replaces the a-priori estimate in the samples fed to a
+// CostLimitAlgorithm and in per-route throughput, so adaptive limits and
+// route capacity learn from real resource usage. It is a no-op outside
+// the middleware, when nothing consumes the cost, or for cost < 1, and
+// reports whether the cost was recorded.
+func ReportCost(ctx context.Context, cost int64) bool {
+	report, ok := ctx.Value(costReportKey{}).(*costReport)
+	if !ok || cost < 1 {
+		return false
+	}
+	report.cost.Store(cost)
+	return true
+}
@@ -0,0 +1,162 @@
+package shedder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShedder_RequestCost(t *testing.T) {
+	s := New(Config{HardLimit: 10, Cost: func(r *http.Request) int64 {
+		switch r.URL.Path {
+		case "/export":
+			return 4
+		case "/huge":
+			return 100
+		case "/free":
+			return 0
+		}
+		return 1
+	}})
+
+	tests := []struct {
+		path string
+		want int64
+	}{
+		{"/", 1},
+		{"/export", 4},
+		{"/huge", 10}, // capped to the hard limit
+		{"/free", 1},  // at least one unit
+	}
+	for _, tt := range tests {
+		if got := s.requestCost(httptest.NewRequest("GET", tt.path, nil)); got != tt.want {
+			t.Errorf("%s: expected cost %d, got %d", tt.path, tt.want, got)
+		}
+	}
+
+	if got := New(Config{HardLimit: 10}).requestCost(httptest.NewRequest("GET", "/", nil)); got != 1 {
+		t.Errorf("expected default cost 1, got %d", got)
+	}
+}
+
+func TestMiddleware_CostCountsAgainstLimit(t *testing.T) {
+	s := New(Config{HardLimit: 10, Cost: func(r *http.Request) int64 {
+		if r.URL.Path == "/export" {
+			return 4
+		}
+		return 1
+	}})
+
+	var inflight int64
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inflight = s.Inflight()
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/export", nil))
+	if inflight != 4 {
+		t.Errorf("expected 4 units in flight during an export, got %d", inflight)
+	}
+	if s.Inflight() != 0 {
+		t.Errorf("expected all units released, got %d", s.Inflight())
+	}
+
+	// With 7 units in use an export no longer fits, but a cheap request does.
+	s.increment(7)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/export", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected export to be shed, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected cheap request to be admitted, got %d", rec.Code)
+	}
+}
+
+func TestContentLengthCost(t *testing.T) {
+	cost := ContentLengthCost(CostBucket{MinBytes: 1000, Cost: 5}, CostBucket{MinBytes: 100, Cost: 2})
+
+	tests := []struct {
+		length int64
+		want   int64
+	}{
+		{0, 1},
+		{99, 1},
+		{100, 2},
+		{999, 2},
+		{1000, 5},
+		{1 << 30, 5},
+		{-1, 5}, // unknown length
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/", nil)
+		r.ContentLength = tt.length
+		if got := cost(r); got != tt.want {
+			t.Errorf("length %d: expected cost %d, got %d", tt.length, tt.want, got)
+		}
+	}
+}
+
+func TestContentLengthCost_Defaults(t *testing.T) {
+	cost := ContentLengthCost()
+	r := httptest.NewRequest("POST", "/", nil)
+	r.ContentLength = 20 << 20
+	if got := cost(r); got != 4 {
+		t.Errorf("expected cost 4 for a 20 MiB upload, got %d", got)
+	}
+}
+
+// costRecorder is a CostLimitAlgorithm that records the last cost.
+type costRecorder struct {
+	StaticLimit
+	last atomic.Int64
+}
+
+func (c *costRecorder) OnCostSample(latency time.Duration, inflight, cost int64, didShed bool) int64 {
+	c.last.Store(cost)
+	return int64(c.StaticLimit)
+}
+
+func TestMiddleware_ReportCostFeedsAlgorithm(t *testing.T) {
+	algo := &costRecorder{StaticLimit: 10}
+	s := New(Config{HardLimit: 10, LimitAlgorithm: algo, Cost: func(r *http.Request) int64 { return 2 }})
+
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/report" && !ReportCost(r.Context(), 7) {
+			t.Error("expected ReportCost to record inside the middleware")
+		}
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if got := algo.last.Load(); got != 2 {
+		t.Errorf("expected the estimated cost 2, got %d", got)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/report", nil))
+	if got := algo.last.Load(); got != 7 {
+		t.Errorf("expected the reported cost 7, got %d", got)
+	}
+}
+
+func TestMiddleware_ReportCostWeightsRouteThroughput(t *testing.T) {
+	s := New(Config{HardLimit: 10, RouteCapacity: &RouteCapacityConfig{
+		Key: func(r *http.Request) string { return "api" },
+	}})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ReportCost(r.Context(), 5)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if got := s.routes.route(httptest.NewRequest("GET", "/", nil)).completions.Load(); got != 5 {
+		t.Errorf("expected 5 completed units, got %d", got)
+	}
+}
+
+func TestReportCost_OutsideMiddleware(t *testing.T) {
+	if ReportCost(context.Background(), 3) {
+		t.Error("expected ReportCost to be a no-op without the middleware")
+	}
+}
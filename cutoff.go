@@ -0,0 +1,93 @@
+package shedder
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// PriorityCutoffConfig configures shedding by a numeric request priority
+// with a cutoff that rises with load.
+type PriorityCutoffConfig struct {
+	// Header carries the request priority. Defaults to X-Priority.
+	// Ignored if Priority is set.
+	Header string
+
+	// Priority optionally returns the request priority instead of Header,
+	// e.g. from a context value set by authentication middleware.
+	Priority func(r *http.Request) (int, bool)
+
+	// Min and Max bound priorities; values outside are clamped. Defaults
+	// to 0 and 9.
+	Min, Max int
+
+	// Default is the priority of requests without a valid one. Defaults
+	// to Min, so unlabelled traffic goes first.
+	Default *int
+
+	// StartAt is the fraction (0-1) of the hard limit at which the cutoff
+	// starts rising from Min. At the hard limit only Max is admitted.
+	// Defaults to 0.5.
+	StartAt float64
+}
+
+// priorityCutoff sheds requests below a load-dependent priority.
+type priorityCutoff struct {
+	priority func(r *http.Request) (int, bool)
+	min, max int
+	def      int
+	startAt  float64
+}
+
+// newPriorityCutoff returns a cutoff for cfg with defaults applied.
+func newPriorityCutoff(cfg PriorityCutoffConfig) *priorityCutoff {
+	if cfg.Min == 0 && cfg.Max == 0 {
+		cfg.Max = 9
+	}
+	if cfg.Max < cfg.Min {
+		cfg.Min, cfg.Max = cfg.Max, cfg.Min
+	}
+	if cfg.StartAt <= 0 || cfg.StartAt >= 1 {
+		cfg.StartAt = 0.5
+	}
+	pc := &priorityCutoff{priority: cfg.Priority, min: cfg.Min, max: cfg.Max, def: cfg.Min, startAt: cfg.StartAt}
+	if cfg.Default != nil {
+		pc.def = *cfg.Default
+	}
+	if pc.priority == nil {
+		header := cfg.Header
+		if header == "" {
+			header = "X-Priority"
+		}
+		pc.priority = func(r *http.Request) (int, bool) {
+			p, err := strconv.Atoi(r.Header.Get(header))
+			return p, err == nil
+		}
+	}
+	return pc
+}
+
+// cutoff returns the lowest priority admitted at current of limit units.
+func (pc *priorityCutoff) cutoff(current, limit int64) int {
+	frac := (float64(current)/float64(limit) - pc.startAt) / (1 - pc.startAt)
+	switch {
+	case frac <= 0:
+		return pc.min
+	case frac >= 1:
+		return pc.max
+	}
+	return pc.min + int(frac*float64(pc.max-pc.min))
+}
+
+// shed reports whether r's priority is below the cutoff.
+func (pc *priorityCutoff) shed(r *http.Request, current, limit int64) bool {
+	p, ok := pc.priority(r)
+	if !ok {
+		p = pc.def
+	}
+	if p < pc.min {
+		p = pc.min
+	} else if p > pc.max {
+		p = pc.max
+	}
+	return p < pc.cutoff(current, limit)
+}
@@ -0,0 +1,85 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestPriorityCutoff_Cutoff(t *testing.T) {
+	pc := newPriorityCutoff(PriorityCutoffConfig{})
+	tests := []struct {
+		current int64
+		want    int
+	}{
+		{0, 0},
+		{50, 0},
+		{75, 4},
+		{90, 7},
+		{100, 9},
+		{150, 9},
+	}
+	for _, tt := range tests {
+		if got := pc.cutoff(tt.current, 100); got != tt.want {
+			t.Errorf("current %d: expected cutoff %d, got %d", tt.current, tt.want, got)
+		}
+	}
+}
+
+func TestPriorityCutoff_Shed(t *testing.T) {
+	high := 5
+	pc := newPriorityCutoff(PriorityCutoffConfig{Default: &high})
+	request := func(priority string) *http.Request {
+		r := httptest.NewRequest("GET", "/", nil)
+		if priority != "" {
+			r.Header.Set("X-Priority", priority)
+		}
+		return r
+	}
+
+	tests := []struct {
+		priority string
+		current  int64
+		want     bool
+	}{
+		{"0", 40, false}, // below StartAt nothing is shed
+		{"3", 75, true},
+		{"4", 75, false},
+		{"8", 100, true},
+		{"9", 100, false},
+		{"42", 100, false}, // clamped to Max
+		{"", 75, false},    // Default 5
+		{"low", 90, true},  // invalid uses Default 5
+	}
+	for _, tt := range tests {
+		if got := pc.shed(request(tt.priority), tt.current, 100); got != tt.want {
+			t.Errorf("priority %q at %d: expected shed %v, got %v", tt.priority, tt.current, tt.want, got)
+		}
+	}
+}
+
+func TestMiddleware_PriorityCutoff(t *testing.T) {
+	s := New(Config{HardLimit: 10, PriorityCutoff: &PriorityCutoffConfig{
+		Priority: func(r *http.Request) (int, bool) {
+			p, err := strconv.Atoi(r.URL.Query().Get("p"))
+			return p, err == nil
+		},
+	}})
+	for i := 0; i < 8; i++ {
+		s.increment(1)
+	}
+	// 9 of 10 in flight: cutoff is 7.
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/?p=6", nil))
+	if got := rec.Header().Get("X-Shed-Reason"); got != "priority_cutoff" {
+		t.Errorf("expected X-Shed-Reason priority_cutoff, got %q", got)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/?p=7", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected priority 7 to be admitted, got %d", rec.Code)
+	}
+}
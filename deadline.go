@@ -0,0 +1,148 @@
+package shedder
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// DeadlineConfig configures deadline-aware admission.
+type DeadlineConfig struct {
+	// Margin multiplies the latency estimate before it is compared with a
+	// request's remaining time. Defaults to 1.
+	Margin float64
+
+	// Smoothing is the weight (0-1) of each new latency sample in the
+	// exponentially weighted moving average. Defaults to 0.1.
+	Smoothing float64
+
+	// MinSamples is the number of completed requests required before the
+	// estimate is trusted. Defaults to 20.
+	MinSamples int64
+}
+
+// deadlineAdmission sheds requests that cannot finish before their
+// deadline, based on a moving average of recent handler latency.
+type deadlineAdmission struct {
+	margin     float64
+	smoothing  float64
+	minSamples int64
+
+	ewma    atomic.Uint64 // float64 bits, nanoseconds
+	samples atomic.Int64
+}
+
+// newDeadlineAdmission returns an admission check for cfg with defaults
+// applied.
+func newDeadlineAdmission(cfg DeadlineConfig) *deadlineAdmission {
+	if cfg.Margin <= 0 {
+		cfg.Margin = 1
+	}
+	if cfg.Smoothing <= 0 || cfg.Smoothing > 1 {
+		cfg.Smoothing = 0.1
+	}
+	if cfg.MinSamples <= 0 {
+		cfg.MinSamples = 20
+	}
+	return &deadlineAdmission{margin: cfg.Margin, smoothing: cfg.Smoothing, minSamples: cfg.MinSamples}
+}
+
+// observe adds a handler latency sample to the moving average.
+func (d *deadlineAdmission) observe(latency time.Duration) {
+	sample := float64(latency)
+	if d.samples.Add(1) == 1 {
+		d.ewma.Store(math.Float64bits(sample))
+		return
+	}
+	for {
+		old := d.ewma.Load()
+		next := math.Float64frombits(old) + d.smoothing*(sample-math.Float64frombits(old))
+		if d.ewma.CompareAndSwap(old, math.Float64bits(next)) {
+			return
+		}
+	}
+}
+
+// estimate returns the expected handler latency, if enough samples exist.
+func (d *deadlineAdmission) estimate() (time.Duration, bool) {
+	if d.samples.Load() < d.minSamples {
+		return 0, false
+	}
+	return time.Duration(math.Float64frombits(d.ewma.Load()) * d.margin), true
+}
+
+// tooLate reports whether r cannot finish before its deadline: it is
+// already past it, or the remaining time is below the latency estimate.
+func (d *deadlineAdmission) tooLate(r *http.Request, now time.Time) bool {
+	deadline, ok := requestDeadline(r, now)
+	if !ok {
+		return false
+	}
+	remaining := deadline.Sub(now)
+	if remaining <= 0 {
+		return true
+	}
+	est, ok := d.estimate()
+	return ok && remaining < est
+}
+
+// requestDeadline returns the earliest of the context deadline and the
+// deadlines carried by the X-Request-Timeout and grpc-timeout headers.
+// Header timeouts are measured from now, the request's arrival.
+func requestDeadline(r *http.Request, now time.Time) (time.Time, bool) {
+	deadline, ok := r.Context().Deadline()
+	earlier := func(timeout time.Duration, valid bool) {
+		if valid && (!ok || now.Add(timeout).Before(deadline)) {
+			deadline, ok = now.Add(timeout), true
+		}
+	}
+	if v := r.Header.Get("X-Request-Timeout"); v != "" {
+		earlier(parseRequestTimeout(v))
+	}
+	if v := r.Header.Get("Grpc-Timeout"); v != "" {
+		earlier(parseGRPCTimeout(v))
+	}
+	return deadline, ok
+}
+
+// parseRequestTimeout parses an X-Request-Timeout value: a Go duration
+// such as "250ms" or a number of seconds such as "1.5".
+func parseRequestTimeout(v string) (time.Duration, bool) {
+	if d, err := time.ParseDuration(v); err == nil {
+		return d, true
+	}
+	secs, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(secs) || math.IsInf(secs, 0) {
+		return 0, false
+	}
+	return time.Duration(secs * float64(time.Second)), true
+}
+
+// grpcTimeoutUnits maps grpc-timeout unit suffixes to durations.
+var grpcTimeoutUnits = map[byte]time.Duration{
+	'H': time.Hour,
+	'M': time.Minute,
+	'S': time.Second,
+	'm': time.Millisecond,
+	'u': time.Microsecond,
+	'n': time.Nanosecond,
+}
+
+// parseGRPCTimeout parses a grpc-timeout value: up to eight digits and a
+// unit, e.g. "100m" for 100 milliseconds.
+func parseGRPCTimeout(v string) (time.Duration, bool) {
+	if len(v) < 2 || len(v) > 9 {
+		return 0, false
+	}
+	unit, ok := grpcTimeoutUnits[v[len(v)-1]]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(v[:len(v)-1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
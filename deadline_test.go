@@ -0,0 +1,121 @@
+package shedder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRequestTimeout(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"250ms", 250 * time.Millisecond, true},
+		{"1.5", 1500 * time.Millisecond, true},
+		{"2", 2 * time.Second, true},
+		{"soon", 0, false},
+		{"NaN", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRequestTimeout(tt.in)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("%q: expected %v, %v; got %v, %v", tt.in, tt.want, tt.ok, got, ok)
+		}
+	}
+}
+
+func TestParseGRPCTimeout(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"100m", 100 * time.Millisecond, true},
+		{"2S", 2 * time.Second, true},
+		{"1H", time.Hour, true},
+		{"500u", 500 * time.Microsecond, true},
+		{"m", 0, false},
+		{"100x", 0, false},
+		{"123456789S", 0, false}, // more than eight digits
+		{"-1S", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseGRPCTimeout(tt.in)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("%q: expected %v, %v; got %v, %v", tt.in, tt.want, tt.ok, got, ok)
+		}
+	}
+}
+
+func TestRequestDeadline_Earliest(t *testing.T) {
+	now := time.Now()
+	ctx, cancel := context.WithDeadline(context.Background(), now.Add(time.Second))
+	defer cancel()
+
+	r := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	r.Header.Set("X-Request-Timeout", "500ms")
+	r.Header.Set("grpc-timeout", "200m")
+
+	deadline, ok := requestDeadline(r, now)
+	if !ok || !deadline.Equal(now.Add(200*time.Millisecond)) {
+		t.Errorf("expected the grpc-timeout deadline, got %v (ok=%v)", deadline.Sub(now), ok)
+	}
+
+	if _, ok := requestDeadline(httptest.NewRequest("GET", "/", nil), now); ok {
+		t.Error("expected no deadline without context deadline or headers")
+	}
+}
+
+func TestDeadlineAdmission_TooLate(t *testing.T) {
+	d := newDeadlineAdmission(DeadlineConfig{MinSamples: 2})
+	now := time.Now()
+	request := func(timeout string) *http.Request {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Request-Timeout", timeout)
+		return r
+	}
+
+	if d.tooLate(request("10ms"), now) {
+		t.Error("expected no shedding before enough samples")
+	}
+	if !d.tooLate(request("0s"), now) {
+		t.Error("expected an expired request to be shed without samples")
+	}
+
+	d.observe(100 * time.Millisecond)
+	d.observe(100 * time.Millisecond)
+	if !d.tooLate(request("50ms"), now) {
+		t.Error("expected a request with less time than the estimate to be shed")
+	}
+	if d.tooLate(request("150ms"), now) {
+		t.Error("expected a request with enough time to be admitted")
+	}
+	if d.tooLate(httptest.NewRequest("GET", "/", nil), now) {
+		t.Error("expected a request without a deadline to be admitted")
+	}
+}
+
+func TestMiddleware_ShedsRequestsPastDeadline(t *testing.T) {
+	clock := newFakeClock()
+	s := New(Config{HardLimit: 10, Clock: clock, Deadline: &DeadlineConfig{MinSamples: 1}})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clock.Advance(20 * time.Millisecond)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("grpc-timeout", "5m")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("X-Shed-Reason"); got != "deadline" {
+		t.Errorf("expected X-Shed-Reason deadline, got %q", got)
+	}
+}
@@ -0,0 +1,62 @@
+package shedder
+
+import "net/http"
+
+// Any returns a decider that sheds a request when at least one of the
+// given deciders does, evaluated in order until one matches. Nil deciders
+// are ignored; with none the result never sheds.
+func Any(deciders ...ShedDecider) ShedDecider {
+	deciders = compactDeciders(deciders)
+	if len(deciders) == 1 {
+		return deciders[0]
+	}
+	return func(r *http.Request) bool {
+		for _, d := range deciders {
+			if d(r) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// All returns a decider that sheds a request only when every given
+// decider does, evaluated in order until one declines. Nil deciders are
+// ignored; with none the result never sheds.
+func All(deciders ...ShedDecider) ShedDecider {
+	deciders = compactDeciders(deciders)
+	if len(deciders) == 1 {
+		return deciders[0]
+	}
+	return func(r *http.Request) bool {
+		if len(deciders) == 0 {
+			return false
+		}
+		for _, d := range deciders {
+			if !d(r) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Not returns a decider that sheds exactly the requests d keeps, e.g.
+// Not(mesh.Match) to shed traffic from outside the mesh. A nil d never
+// sheds, so Not(nil) sheds every request.
+func Not(d ShedDecider) ShedDecider {
+	return func(r *http.Request) bool {
+		return d == nil || !d(r)
+	}
+}
+
+// compactDeciders returns deciders without nil entries.
+func compactDeciders(deciders []ShedDecider) []ShedDecider {
+	out := make([]ShedDecider, 0, len(deciders))
+	for _, d := range deciders {
+		if d != nil {
+			out = append(out, d)
+		}
+	}
+	return out
+}
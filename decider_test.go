@@ -0,0 +1,71 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDeciderCombinators(t *testing.T) {
+	yes := func(*http.Request) bool { return true }
+	no := func(*http.Request) bool { return false }
+	req := httptest.NewRequest("GET", "/", nil)
+
+	tests := []struct {
+		name string
+		d    ShedDecider
+		want bool
+	}{
+		{"Any empty", Any(), false},
+		{"Any nil", Any(nil), false},
+		{"Any one", Any(no, yes), true},
+		{"Any none", Any(no, nil, no), false},
+		{"All empty", All(), false},
+		{"All every", All(yes, nil, yes), true},
+		{"All one declines", All(yes, no), false},
+		{"Not yes", Not(yes), false},
+		{"Not no", Not(no), true},
+		{"Not nil", Not(nil), true},
+		{"nested", Any(All(yes, Not(no)), no), true},
+	}
+	for _, tt := range tests {
+		if got := tt.d(req); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAny_ShortCircuits(t *testing.T) {
+	called := false
+	d := Any(func(*http.Request) bool { return true }, func(*http.Request) bool { called = true; return true })
+	d(httptest.NewRequest("GET", "/", nil))
+	if called {
+		t.Error("expected Any to stop at the first match")
+	}
+}
+
+func TestMiddleware_ComposedDecider(t *testing.T) {
+	mesh := MethodMatcher{"GET"}
+	s := New(Config{
+		HardLimit:   10,
+		SoftLimit:   1,
+		ShedDecider: All(Not(mesh.Match), (&PathMatcher{Prefixes: []string{"/batch/"}}).Match),
+	})
+	s.increment(1)
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, tt := range []struct {
+		method, path string
+		want         int
+	}{
+		{"POST", "/batch/run", http.StatusServiceUnavailable},
+		{"GET", "/batch/run", http.StatusOK},
+		{"POST", "/api", http.StatusOK},
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s %s: got %d, want %d", tt.method, tt.path, rec.Code, tt.want)
+		}
+	}
+}
@@ -45,6 +45,34 @@
 //	    },
 //	})
 //
+// # Subsystems
+//
+// Beyond the hard and soft limits, the package has these subsystems, each
+// documented on its Config field, method or function:
+//
+//   - Limits: LimitAlgorithm and CapacityEstimator adapt the hard limit,
+//     Auto and ScaleByResources size it, and Warmup, Cooldown and Burst
+//     shape it over time.
+//   - Overload signals: OverloadSignal, SoftOverloadSignal, ErrorRate,
+//     Surge and the runtime signals such as NewGCSignal.
+//   - Admission: ClientIP, Tenant, ClassQuotas, Routes, RouteCeilings,
+//     MethodLimits, Hosts, Deadline and PriorityCutoff cap parts of the
+//     traffic, and Cost weighs requests.
+//   - Queueing: Queue waits for a free slot, and PriorityLevels share the
+//     limit among levels that queue on their own.
+//   - Cancellation: Preemption and Eviction cancel running requests.
+//   - Reload: Apply changes a DynamicConfig at runtime, as WatchFile,
+//     WatchURL, WatchConfigMap and PollLimits do from their sources.
+//   - Fleet: WatchPeers and Gossip follow the other replicas, and
+//     SharedBudget leases a budget shared among them.
+//   - Observability: Stats, StatusHandler, ReportMetrics and the
+//     RateLimitHeaders and TelemetryHeaders response headers.
+//   - Lifecycle: ReadyHandler, StartupHandler and the draining of
+//     StartDraining and DrainOnSIGTERM.
+//
+// The shedtest package fakes a Shedder and its Clock for tests, and the
+// simulation package runs one against modelled load.
+//
 // # Integration with Kubernetes
 //
 // Important: Use SEPARATE endpoints for liveness and readiness probes.
@@ -0,0 +1,126 @@
+package shedder
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ResourceScaling describes how to scale configured limits by the pod's
+// resource requests, as exposed by the Kubernetes Downward API. It lets the
+// same limits be used across differently-sized resource classes.
+//
+// Expose the requests as environment variables or volume files, e.g.:
+//
+//	env:
+//	- name: CPU_REQUEST
+//	  valueFrom:
+//	    resourceFieldRef:
+//	      resource: requests.cpu
+//	      divisor: 1m
+//	- name: MEMORY_REQUEST
+//	  valueFrom:
+//	    resourceFieldRef:
+//	      resource: requests.memory
+type ResourceScaling struct {
+	// ReferenceCPUMillis is the CPU request, in millicores, that the
+	// configured limits were tuned for. Zero disables CPU scaling.
+	ReferenceCPUMillis int64
+
+	// ReferenceMemoryBytes is the memory request, in bytes, that the
+	// configured limits were tuned for. Zero disables memory scaling.
+	ReferenceMemoryBytes int64
+
+	// CPUEnv names the environment variable holding the CPU request in
+	// millicores (divisor 1m). Defaults to "CPU_REQUEST".
+	CPUEnv string
+
+	// CPUFile is a Downward API volume file holding the CPU request in
+	// millicores. It is read when the environment variable is not set.
+	CPUFile string
+
+	// MemoryEnv names the environment variable holding the memory request
+	// in bytes. Defaults to "MEMORY_REQUEST".
+	MemoryEnv string
+
+	// MemoryFile is a Downward API volume file holding the memory request
+	// in bytes. It is read when the environment variable is not set.
+	MemoryFile string
+}
+
+// ScaleByResources returns cfg with HardLimit and SoftLimit multiplied by
+// the ratio of the pod's actual resource requests to the reference values.
+// When both CPU and memory references are set, the smaller ratio is used,
+// since the scarcer resource bounds capacity. An Auto HardLimit is left
+// unchanged because it already follows the CPU quota.
+func ScaleByResources(cfg Config, rs ResourceScaling) (Config, error) {
+	if rs.ReferenceCPUMillis <= 0 && rs.ReferenceMemoryBytes <= 0 {
+		return cfg, errors.New("shedder: ResourceScaling needs a CPU or memory reference")
+	}
+	if rs.CPUEnv == "" {
+		rs.CPUEnv = "CPU_REQUEST"
+	}
+	if rs.MemoryEnv == "" {
+		rs.MemoryEnv = "MEMORY_REQUEST"
+	}
+
+	factor := math.Inf(1)
+	if rs.ReferenceCPUMillis > 0 {
+		cpu, err := downwardValue(rs.CPUEnv, rs.CPUFile)
+		if err != nil {
+			return cfg, fmt.Errorf("shedder: reading CPU request: %w", err)
+		}
+		factor = math.Min(factor, cpu/float64(rs.ReferenceCPUMillis))
+	}
+	if rs.ReferenceMemoryBytes > 0 {
+		mem, err := downwardValue(rs.MemoryEnv, rs.MemoryFile)
+		if err != nil {
+			return cfg, fmt.Errorf("shedder: reading memory request: %w", err)
+		}
+		factor = math.Min(factor, mem/float64(rs.ReferenceMemoryBytes))
+	}
+
+	if cfg.HardLimit != Auto {
+		cfg.HardLimit = scaleLimit(cfg.HardLimit, factor)
+	}
+	if cfg.SoftLimit > 0 {
+		cfg.SoftLimit = scaleLimit(cfg.SoftLimit, factor)
+	}
+	return cfg, nil
+}
+
+// downwardValue reads a positive number from the environment variable or,
+// if unset, from the file.
+func downwardValue(env, file string) (float64, error) {
+	raw, ok := os.LookupEnv(env)
+	if !ok {
+		if file == "" {
+			return 0, fmt.Errorf("environment variable %s is not set", env)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return 0, err
+		}
+		raw = string(data)
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q: %w", raw, err)
+	}
+	if v <= 0 {
+		return 0, fmt.Errorf("value %q must be positive", raw)
+	}
+	return v, nil
+}
+
+// scaleLimit multiplies limit by factor, keeping it at least 1.
+func scaleLimit(limit int64, factor float64) int64 {
+	scaled := int64(math.Round(float64(limit) * factor))
+	if scaled < 1 {
+		scaled = 1
+	}
+	return scaled
+}
@@ -0,0 +1,70 @@
+package shedder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScaleByResources_CPU(t *testing.T) {
+	t.Setenv("CPU_REQUEST", "2000")
+
+	cfg, err := ScaleByResources(Config{HardLimit: 100, SoftLimit: 80}, ResourceScaling{ReferenceCPUMillis: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.HardLimit != 200 || cfg.SoftLimit != 160 {
+		t.Errorf("expected limits doubled, got hard=%d soft=%d", cfg.HardLimit, cfg.SoftLimit)
+	}
+}
+
+func TestScaleByResources_UsesScarcerResource(t *testing.T) {
+	t.Setenv("CPU_REQUEST", "2000")
+	memFile := filepath.Join(t.TempDir(), "mem_request")
+	if err := os.WriteFile(memFile, []byte("536870912\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := ScaleByResources(Config{HardLimit: 100}, ResourceScaling{
+		ReferenceCPUMillis:   1000,
+		ReferenceMemoryBytes: 1 << 30,
+		MemoryEnv:            "SHEDDER_TEST_UNSET_MEMORY",
+		MemoryFile:           memFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.HardLimit != 50 {
+		t.Errorf("expected memory ratio 0.5 to win, got hard=%d", cfg.HardLimit)
+	}
+}
+
+func TestScaleByResources_Errors(t *testing.T) {
+	if _, err := ScaleByResources(Config{HardLimit: 10}, ResourceScaling{}); err == nil {
+		t.Error("expected error without references")
+	}
+
+	_, err := ScaleByResources(Config{HardLimit: 10}, ResourceScaling{
+		ReferenceCPUMillis: 1000,
+		CPUEnv:             "SHEDDER_TEST_UNSET_CPU",
+	})
+	if err == nil {
+		t.Error("expected error when the CPU request is unavailable")
+	}
+
+	t.Setenv("CPU_REQUEST", "abc")
+	if _, err := ScaleByResources(Config{HardLimit: 10}, ResourceScaling{ReferenceCPUMillis: 1000}); err == nil {
+		t.Error("expected error for a malformed CPU request")
+	}
+}
+
+func TestScaleByResources_KeepsAuto(t *testing.T) {
+	t.Setenv("CPU_REQUEST", "100")
+	cfg, err := ScaleByResources(Config{HardLimit: Auto}, ResourceScaling{ReferenceCPUMillis: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.HardLimit != Auto {
+		t.Errorf("expected Auto to be preserved, got %d", cfg.HardLimit)
+	}
+}
@@ -0,0 +1,70 @@
+package shedder
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// drainPollInterval is how often WaitForDrain checks the in-flight count.
+const drainPollInterval = 10 * time.Millisecond
+
+// StartDraining puts the shedder into draining mode for a graceful
+// shutdown: the readiness endpoint returns 503, so Kubernetes stops
+// routing new traffic to the pod, and the middleware sheds every new
+// request other than ExemptPaths with ShedReasonDraining and a
+// "Connection: close" header. Requests already in flight are unaffected.
+// Draining cannot be undone. A shedder's children drain with it.
+func (s *Shedder) StartDraining() {
+	s.draining.Store(true)
+}
+
+// IsDraining reports whether StartDraining has been called on the shedder
+// or, for a Child shedder, on its parent.
+func (s *Shedder) IsDraining() bool {
+	return s.draining.Load() || s.parent != nil && s.parent.IsDraining()
+}
+
+// WaitForDrain blocks until the shedder is draining and has no requests
+// in flight, or until ctx is done, in which case it returns ctx.Err().
+// It does not start draining itself.
+func (s *Shedder) WaitForDrain(ctx context.Context) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		if s.IsDraining() && s.Inflight() <= 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// DrainOnSIGTERM starts draining when the process receives SIGTERM, which
+// kubelet sends when a pod is terminated. The returned channel is closed
+// once draining has started and no requests are left in flight, at which
+// point the server can be shut down. The SIGTERM subscription is taken
+// before DrainOnSIGTERM returns and is released when ctx is done.
+func (s *Shedder) DrainOnSIGTERM(ctx context.Context) <-chan struct{} {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM)
+	drained := make(chan struct{})
+	go func() {
+		defer signal.Stop(signals)
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+		}
+		s.StartDraining()
+		if s.WaitForDrain(ctx) == nil {
+			close(drained)
+		}
+	}()
+	return drained
+}
@@ -0,0 +1,70 @@
+package shedder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestShedder_Draining(t *testing.T) {
+	s := New(Config{HardLimit: 10, ExemptPaths: &PathMatcher{Exact: []string{"/metrics"}}})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	s.StartDraining()
+	if !s.IsDraining() || !s.Stats().Draining {
+		t.Fatal("expected shedder to be draining")
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("X-Shed-Reason") != "draining" {
+		t.Errorf("expected request to be shed with draining, got %d %q", rec.Code, rec.Header().Get("X-Shed-Reason"))
+	}
+	if rec.Header().Get("Connection") != "close" {
+		t.Error("expected Connection: close while draining")
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected exempt path to be served while draining, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.ReadyHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/ready", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected readiness to fail while draining, got %d", rec.Code)
+	}
+}
+
+func TestShedder_WaitForDrain(t *testing.T) {
+	s := New(Config{HardLimit: 10})
+	s.increment(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.WaitForDrain(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected WaitForDrain to wait for draining to start, got %v", err)
+	}
+
+	s.StartDraining()
+	done := make(chan error, 1)
+	go func() { done <- s.WaitForDrain(context.Background()) }()
+	select {
+	case <-done:
+		t.Fatal("expected WaitForDrain to block while requests are in flight")
+	case <-time.After(3 * drainPollInterval):
+	}
+
+	s.decrement(1)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WaitForDrain did not return after the last request finished")
+	}
+}
@@ -0,0 +1,33 @@
+//go:build unix
+
+package shedder
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestShedder_DrainOnSIGTERM(t *testing.T) {
+	s := New(Config{HardLimit: 10})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	drained := s.DrainOnSIGTERM(ctx)
+	if s.IsDraining() {
+		t.Fatal("expected draining to wait for SIGTERM")
+	}
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-drained:
+	case <-time.After(time.Second):
+		t.Fatal("expected drain to complete after SIGTERM")
+	}
+	if !s.IsDraining() {
+		t.Error("expected shedder to be draining")
+	}
+}
@@ -0,0 +1,28 @@
+package shedder
+
+import (
+	"context"
+	"net/http"
+)
+
+// dryRunKey is the context key marking requests that would have been shed.
+type dryRunKey struct{}
+
+// IsDryRun reports whether ctx belongs to a request that would have been
+// shed but was served because the shedder runs in DryRun mode. OnShed
+// callbacks use it to tell real sheds from would-be sheds.
+func IsDryRun(ctx context.Context) bool {
+	v, _ := ctx.Value(dryRunKey{}).(bool)
+	return v
+}
+
+// wouldShed records a shed decision that DryRun mode suppressed and
+// returns the request marked for IsDryRun.
+func (s *Shedder) wouldShed(r *http.Request, reason ShedReason) *http.Request {
+	s.dryRunShed.Add(1)
+	r = r.WithContext(context.WithValue(r.Context(), dryRunKey{}, true))
+	if s.onShed != nil {
+		s.onShed(r, reason)
+	}
+	return r
+}
@@ -0,0 +1,68 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware_DryRunServesWouldBeSheds(t *testing.T) {
+	var reasons []ShedReason
+	var dryRun []bool
+
+	s := New(Config{
+		HardLimit: 1,
+		DryRun:    true,
+		OnShed: func(r *http.Request, reason ShedReason) {
+			reasons = append(reasons, reason)
+			dryRun = append(dryRun, IsDryRun(r.Context()))
+		},
+	})
+
+	var served bool
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = IsDryRun(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	s.increment(1)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 in dry run, got %d", rec.Code)
+	}
+	if !served {
+		t.Error("expected handler to see the dry-run marker")
+	}
+	if len(reasons) != 1 || reasons[0] != ShedReasonHardLimit || !dryRun[0] {
+		t.Errorf("expected one dry-run hard_limit OnShed call, got %v %v", reasons, dryRun)
+	}
+
+	st := s.Stats()
+	if st.DryRunShed != 1 || st.Shed != 0 || st.Admitted != 1 {
+		t.Errorf("unexpected stats in dry run: %+v", st)
+	}
+}
+
+func TestIsDryRun_FalseForRealSheds(t *testing.T) {
+	var dryRun bool
+	s := New(Config{
+		HardLimit: 1,
+		OnShed: func(r *http.Request, reason ShedReason) {
+			dryRun = IsDryRun(r.Context())
+		},
+	})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	s.increment(1)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 when not in dry run, got %d", rec.Code)
+	}
+	if dryRun {
+		t.Error("real sheds must not be marked as dry run")
+	}
+}
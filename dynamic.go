@@ -0,0 +1,127 @@
+package shedder
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// DynamicConfig holds the settings that can be changed on a running
+// Shedder with Apply. Its JSON form is what the reloaders read, e.g.:
+//
+//	{"hard_limit": 200, "soft_limit": 150,
+//	 "shed": {"headers": [{"name": "X-Priority", "value": "low"}]}}
+//
+// Nil fields keep their current values.
+type DynamicConfig struct {
+	HardLimit      *int64   `json:"hard_limit,omitempty"`
+	SoftLimit      *int64   `json:"soft_limit,omitempty"`
+	SoftLimitRatio *float64 `json:"soft_limit_ratio,omitempty"`
+
+	// Shed, if set, replaces the shed matchers and any Config.ShedDecider.
+	// An empty value disables soft shedding.
+	Shed *ShedMatchers `json:"shed,omitempty"`
+
+	// ClassQuotas, if set, replaces Config.ClassQuotas, e.g.
+	// {"batch": 0.2}. It requires Config.Classify; an empty value removes
+	// all quotas.
+	ClassQuotas map[string]float64 `json:"class_quotas,omitempty"`
+}
+
+// ParseDynamicConfig parses the JSON form of a DynamicConfig. Unknown
+// fields are rejected so that typos do not go unnoticed.
+func ParseDynamicConfig(data []byte) (DynamicConfig, error) {
+	var dc DynamicConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&dc); err != nil {
+		return DynamicConfig{}, fmt.Errorf("shedder: parsing config: %w", err)
+	}
+	return dc, nil
+}
+
+// Apply validates dc against the shedder's current settings and applies
+// it. Nothing is changed if dc is invalid. It is safe for concurrent use.
+func (s *Shedder) Apply(dc DynamicConfig) error {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	prev := s.config.Load()
+
+	hard := s.limit.Load()
+	if dc.HardLimit != nil {
+		hard = *dc.HardLimit
+	}
+	soft := prev.softLimit
+	if dc.SoftLimit != nil {
+		soft = *dc.SoftLimit
+	}
+	ratio := prev.softRatio
+	if dc.SoftLimitRatio != nil {
+		ratio = *dc.SoftLimitRatio
+	} else if dc.SoftLimit != nil {
+		ratio = 0
+	}
+
+	var errs []error
+	if hard <= 0 {
+		errs = append(errs, fmt.Errorf("shedder: hard_limit must be > 0, got %d", hard))
+	}
+	if soft < 0 {
+		errs = append(errs, fmt.Errorf("shedder: soft_limit must not be negative, got %d", soft))
+	}
+	if ratio == 0 && soft > 0 && soft >= hard {
+		errs = append(errs, fmt.Errorf("shedder: soft_limit (%d) must be below hard_limit (%d)", soft, hard))
+	}
+	if ratio < 0 || ratio >= 1 {
+		errs = append(errs, fmt.Errorf("shedder: soft_limit_ratio must be in [0, 1), got %v", ratio))
+	}
+	if dc.Shed != nil {
+		for i, m := range dc.Shed.Headers {
+			if m.Name == "" {
+				errs = append(errs, fmt.Errorf("shedder: shed.headers[%d].name is empty", i))
+			}
+		}
+		for i, m := range dc.Shed.Query {
+			if m.Name == "" {
+				errs = append(errs, fmt.Errorf("shedder: shed.query[%d].name is empty", i))
+			}
+		}
+	}
+	if dc.ClassQuotas != nil {
+		if s.classify == nil && len(dc.ClassQuotas) > 0 {
+			errs = append(errs, errors.New("shedder: class_quotas requires Config.Classify"))
+		}
+		for class, f := range dc.ClassQuotas {
+			if f <= 0 || f > 1 {
+				errs = append(errs, fmt.Errorf("shedder: class_quotas[%q] must be in (0, 1], got %v", class, f))
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	next := *prev
+	next.softLimit, next.softRatio = soft, ratio
+	if dc.Shed != nil {
+		next.decider = dc.Shed.decider()
+	}
+	if dc.ClassQuotas != nil {
+		next.quotas = nil
+		if len(dc.ClassQuotas) > 0 {
+			next.quotas = newClassQuotas(dc.ClassQuotas, s.classify, prev.quotas)
+		}
+	}
+	if dc.HardLimit != nil {
+		s.SetHardLimit(hard)
+	}
+	s.config.Store(&next)
+	return nil
+}
+
+// decider returns the ShedDecider in effect: the one last applied with
+// Apply, or else the configured one.
+func (s *Shedder) decider() ShedDecider {
+	return s.config.Load().decider
+}
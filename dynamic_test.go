@@ -0,0 +1,208 @@
+package shedder
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseDynamicConfig(t *testing.T) {
+	dc, err := ParseDynamicConfig([]byte(`{
+		"hard_limit": 200,
+		"soft_limit": 150,
+		"shed": {
+			"headers": [{"name": "X-Client", "regexp": "^mobile-", "keep": true}, {"name": "X-Priority", "value": "low"}],
+			"paths": {"prefixes": ["/export/"]},
+			"methods": ["HEAD"],
+			"query": [{"name": "prefetch", "present": true}]
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *dc.HardLimit != 200 || *dc.SoftLimit != 150 || dc.SoftLimitRatio != nil {
+		t.Errorf("unexpected limits: %+v", dc)
+	}
+	if dc.Shed.Headers[0].Regexp.String() != "^mobile-" || !dc.Shed.Headers[0].Keep {
+		t.Errorf("unexpected header matcher: %+v", dc.Shed.Headers[0])
+	}
+	if dc.Shed.Paths.Prefixes[0] != "/export/" || dc.Shed.Methods[0] != "HEAD" || !dc.Shed.Query[0].Present {
+		t.Errorf("unexpected matchers: %+v", dc.Shed)
+	}
+
+	for _, bad := range []string{`{"hard_limt": 1}`, `{"shed": {"headers": [{"name": "X", "regexp": "("}]}}`, `not json`} {
+		if _, err := ParseDynamicConfig([]byte(bad)); err == nil {
+			t.Errorf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestDynamicConfig_MarshalOmitsUnset(t *testing.T) {
+	limit := int64(200)
+	data, err := json.Marshal(DynamicConfig{HardLimit: &limit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != `{"hard_limit":200}` {
+		t.Errorf("expected only hard_limit, got %s", got)
+	}
+}
+
+func TestShedder_Apply(t *testing.T) {
+	s := New(Config{HardLimit: 100, SoftLimit: 80, ShedDecider: func(r *http.Request) bool { return true }})
+
+	hard, soft := int64(50), int64(40)
+	if err := s.Apply(DynamicConfig{HardLimit: &hard, SoftLimit: &soft}); err != nil {
+		t.Fatal(err)
+	}
+	if s.HardLimit() != 50 || s.SoftLimit() != 40 {
+		t.Errorf("expected limits 50/40, got %d/%d", s.HardLimit(), s.SoftLimit())
+	}
+	if !s.decider()(httptest.NewRequest("GET", "/", nil)) {
+		t.Error("expected configured decider kept without shed matchers")
+	}
+
+	err := s.Apply(DynamicConfig{
+		Shed: &ShedMatchers{Paths: &PathMatcher{Prefixes: []string{"/export/"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decide := s.decider()
+	if !decide(httptest.NewRequest("GET", "/export/a", nil)) || decide(httptest.NewRequest("GET", "/", nil)) {
+		t.Error("expected applied matchers to replace the decider")
+	}
+
+	if err := s.Apply(DynamicConfig{Shed: &ShedMatchers{}}); err != nil {
+		t.Fatal(err)
+	}
+	if s.decider() != nil {
+		t.Error("expected empty matchers to disable soft shedding")
+	}
+}
+
+func TestShedder_ApplyInvalid(t *testing.T) {
+	s := New(Config{HardLimit: 100, SoftLimit: 80})
+
+	soft, zero := int64(120), int64(0)
+	err := s.Apply(DynamicConfig{
+		HardLimit: &zero,
+		SoftLimit: &soft,
+		Shed:      &ShedMatchers{Headers: []HeaderMatcher{{Value: "low"}}},
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, want := range []string{"hard_limit must be > 0", "soft_limit (120)", "shed.headers[0].name is empty"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to mention %q, got %v", want, err)
+		}
+	}
+	if s.HardLimit() != 100 || s.SoftLimit() != 80 || s.decider() != nil {
+		t.Error("expected nothing applied on error")
+	}
+}
+
+func TestShedder_ApplySoftLimitRatio(t *testing.T) {
+	s := New(Config{HardLimit: 100, SoftLimit: 80})
+
+	ratio := 0.5
+	if err := s.Apply(DynamicConfig{SoftLimitRatio: &ratio}); err != nil {
+		t.Fatal(err)
+	}
+	if s.SoftLimit() != 50 {
+		t.Errorf("expected soft limit 50, got %d", s.SoftLimit())
+	}
+
+	soft := int64(70)
+	if err := s.Apply(DynamicConfig{SoftLimit: &soft}); err != nil {
+		t.Fatal(err)
+	}
+	if s.SoftLimit() != 70 {
+		t.Errorf("expected an absolute soft limit to replace the ratio, got %d", s.SoftLimit())
+	}
+}
+
+func TestShedder_ApplyClassQuotas(t *testing.T) {
+	s := New(Config{
+		HardLimit:   10,
+		Classify:    func(r *http.Request) string { return r.Header.Get("X-Class") },
+		ClassQuotas: map[string]float64{"batch": 0.5},
+	})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Class", "batch")
+	s.config.Load().quotas.acquire(req, 1, 10)
+
+	dc, err := ParseDynamicConfig([]byte(`{"class_quotas": {"batch": 0.2, "export": 0.1}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Apply(dc); err != nil {
+		t.Fatal(err)
+	}
+	st := s.Stats().ClassQuotas
+	if len(st) != 2 || st[0].Class != "batch" || st[0].Limit != 2 || st[0].Inflight != 1 {
+		t.Errorf("expected replaced quotas to keep in-flight counts, got %+v", st)
+	}
+
+	if err := s.Apply(DynamicConfig{ClassQuotas: map[string]float64{}}); err != nil {
+		t.Fatal(err)
+	}
+	if st := s.Stats().ClassQuotas; len(st) != 0 {
+		t.Errorf("expected empty class_quotas to remove quotas, got %+v", st)
+	}
+
+	if err := New(Config{HardLimit: 10}).Apply(DynamicConfig{ClassQuotas: map[string]float64{"batch": 0.2}}); err == nil {
+		t.Error("expected class_quotas without Classify to be rejected")
+	}
+	if err := s.Apply(DynamicConfig{ClassQuotas: map[string]float64{"batch": 2}}); err == nil {
+		t.Error("expected a fraction above 1 to be rejected")
+	}
+	if err := s.Apply(DynamicConfig{ClassQuotas: map[string]float64{"batch": 0}}); err == nil {
+		t.Error("expected a fraction of 0 to be rejected")
+	}
+}
+
+func TestShedder_ApplyConsistent(t *testing.T) {
+	s := New(Config{HardLimit: 100, SoftLimit: 20})
+	low := ShedMatchers{Headers: []HeaderMatcher{{Name: "X-Priority", Value: "low"}}}
+	soft, ratio := int64(20), 0.5
+	// Each config pairs a soft limit with a decider; readers must never see
+	// one config's soft limit with the other's decider.
+	configs := []DynamicConfig{
+		{SoftLimit: &soft, Shed: &ShedMatchers{}}, // 20, sheds nothing
+		{SoftLimitRatio: &ratio, Shed: &low},      // 50, sheds low priority
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			if err := s.Apply(configs[i%2]); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Priority", "low")
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		c := s.config.Load()
+		soft := c.softLimit
+		if c.softRatio > 0 {
+			soft = int64(c.softRatio * 100)
+		}
+		shed := c.decider != nil && c.decider(r)
+		if (soft == 20 && shed) || (soft == 50 && !shed) {
+			t.Fatalf("read a torn config: soft limit %d with shed=%v", soft, shed)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package shedder
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FromEnv builds a Config from environment variables named prefix + "_" +
+// setting, so limits can be tuned per deployment in the pod spec:
+//
+//	SHEDDER_HARD_LIMIT        int, or "auto" for Auto
+//	SHEDDER_PER_CORE_CONCURRENCY float
+//	SHEDDER_SOFT_LIMIT        int
+//	SHEDDER_SOFT_LIMIT_RATIO  float
+//	SHEDDER_FAST_PATH_BELOW   float
+//	SHEDDER_SHED_HEADER       "Name=Value", or "Name" to match presence
+//	SHEDDER_SHED_PATHS        comma-separated paths (see below)
+//	SHEDDER_SHED_METHODS      comma-separated methods
+//	SHEDDER_SHED_QUERY        comma-separated "name=value" or "name"
+//	SHEDDER_EXEMPT_PATHS      comma-separated paths (see below)
+//	SHEDDER_QUEUE_TIMEOUT     duration, enables queueing
+//	SHEDDER_QUEUE_MAX_LENGTH  int
+//	SHEDDER_RETRY_AFTER_JITTER duration
+//	SHEDDER_COARSE_CLOCK      duration
+//	SHEDDER_DRY_RUN, SHEDDER_PROBLEM_JSON, SHEDDER_CLOSE_ON_HARD_SHED,
+//	SHEDDER_TELEMETRY_HEADERS, SHEDDER_ENVOY_OVERLOADED  bool
+//
+// Paths ending in "*" match by prefix, paths containing other glob
+// characters match as globs, and other paths match exactly. Unset
+// variables leave the setting at its zero value; callbacks such as
+// ShedDecider can be added to the returned Config in code. Pass the
+// result to NewWithError to validate it.
+func FromEnv(prefix string) (Config, error) {
+	e := envReader{prefix: prefix}
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		e.prefix += "_"
+	}
+
+	var cfg Config
+	if raw, ok := e.lookup("HARD_LIMIT"); ok && strings.EqualFold(raw, "auto") {
+		cfg.HardLimit = Auto
+	} else {
+		cfg.HardLimit = e.int("HARD_LIMIT")
+	}
+	cfg.PerCoreConcurrency = e.float("PER_CORE_CONCURRENCY")
+	cfg.SoftLimit = e.int("SOFT_LIMIT")
+	cfg.SoftLimitRatio = e.float("SOFT_LIMIT_RATIO")
+	cfg.FastPathBelow = e.float("FAST_PATH_BELOW")
+
+	if raw, ok := e.lookup("SHED_HEADER"); ok {
+		name, value, hasValue := strings.Cut(raw, "=")
+		cfg.ShedHeader = &HeaderMatcher{Name: strings.TrimSpace(name), Value: strings.TrimSpace(value), Present: !hasValue}
+	}
+	cfg.ShedPaths = e.paths("SHED_PATHS")
+	for _, method := range e.list("SHED_METHODS") {
+		cfg.ShedMethods = append(cfg.ShedMethods, strings.ToUpper(method))
+	}
+	for _, param := range e.list("SHED_QUERY") {
+		name, value, hasValue := strings.Cut(param, "=")
+		cfg.ShedQuery = append(cfg.ShedQuery, QueryMatcher{Name: name, Value: value, Present: !hasValue})
+	}
+	cfg.ExemptPaths = e.paths("EXEMPT_PATHS")
+
+	if timeout := e.duration("QUEUE_TIMEOUT"); timeout > 0 {
+		cfg.Queue = &QueueConfig{Timeout: timeout, MaxLength: int(e.int("QUEUE_MAX_LENGTH"))}
+	}
+	cfg.RetryAfterJitter = e.duration("RETRY_AFTER_JITTER")
+	cfg.CoarseClock = e.duration("COARSE_CLOCK")
+	cfg.DryRun = e.bool("DRY_RUN")
+	cfg.ProblemJSON = e.bool("PROBLEM_JSON")
+	cfg.CloseOnHardShed = e.bool("CLOSE_ON_HARD_SHED")
+	cfg.TelemetryHeaders = e.bool("TELEMETRY_HEADERS")
+	cfg.EnvoyOverloaded = e.bool("ENVOY_OVERLOADED")
+
+	return cfg, errors.Join(e.errs...)
+}
+
+// envReader reads prefixed environment variables, collecting parse errors.
+type envReader struct {
+	prefix string
+	errs   []error
+}
+
+// lookup returns the trimmed value of the variable for name, if set and
+// not empty.
+func (e *envReader) lookup(name string) (string, bool) {
+	raw, ok := os.LookupEnv(e.prefix + name)
+	raw = strings.TrimSpace(raw)
+	return raw, ok && raw != ""
+}
+
+// parseEnv parses the variable for name with fn, recording any error.
+func parseEnv[T any](e *envReader, name string, fn func(string) (T, error)) T {
+	var zero T
+	raw, ok := e.lookup(name)
+	if !ok {
+		return zero
+	}
+	v, err := fn(raw)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("shedder: %s%s: invalid value %q", e.prefix, name, raw))
+		return zero
+	}
+	return v
+}
+
+func (e *envReader) int(name string) int64 {
+	return parseEnv(e, name, func(s string) (int64, error) { return strconv.ParseInt(s, 10, 64) })
+}
+
+func (e *envReader) float(name string) float64 {
+	return parseEnv(e, name, func(s string) (float64, error) { return strconv.ParseFloat(s, 64) })
+}
+
+func (e *envReader) bool(name string) bool {
+	return parseEnv(e, name, strconv.ParseBool)
+}
+
+func (e *envReader) duration(name string) time.Duration {
+	return parseEnv(e, name, time.ParseDuration)
+}
+
+// list returns the non-empty comma-separated entries of the variable.
+func (e *envReader) list(name string) []string {
+	raw, _ := e.lookup(name)
+	var out []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// paths returns a PathMatcher for the comma-separated paths of the
+// variable, or nil if it is unset.
+func (e *envReader) paths(name string) *PathMatcher {
+	items := e.list(name)
+	if len(items) == 0 {
+		return nil
+	}
+	m := &PathMatcher{}
+	for _, p := range items {
+		switch {
+		case strings.HasSuffix(p, "*") && !strings.ContainsAny(p[:len(p)-1], "*?["):
+			m.Prefixes = append(m.Prefixes, p[:len(p)-1])
+		case strings.ContainsAny(p, "*?["):
+			m.Globs = append(m.Globs, p)
+		default:
+			m.Exact = append(m.Exact, p)
+		}
+	}
+	return m
+}
@@ -0,0 +1,89 @@
+package shedder
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("SHEDDER_HARD_LIMIT", "200")
+	t.Setenv("SHEDDER_SOFT_LIMIT", " 150 ")
+	t.Setenv("SHEDDER_SHED_HEADER", "X-Priority=low")
+	t.Setenv("SHEDDER_SHED_PATHS", "/api/search/suggest, /api/export/*, /api/*/report")
+	t.Setenv("SHEDDER_SHED_METHODS", "get,HEAD")
+	t.Setenv("SHEDDER_SHED_QUERY", "prefetch=true,preview")
+	t.Setenv("SHEDDER_EXEMPT_PATHS", "/internal/drain")
+	t.Setenv("SHEDDER_QUEUE_TIMEOUT", "250ms")
+	t.Setenv("SHEDDER_DRY_RUN", "true")
+	t.Setenv("SHEDDER_ENVOY_OVERLOADED", "1")
+
+	cfg, err := FromEnv("SHEDDER")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.HardLimit != 200 || cfg.SoftLimit != 150 || !cfg.DryRun || !cfg.EnvoyOverloaded {
+		t.Errorf("unexpected settings: hard=%d soft=%d dryRun=%v envoyOverloaded=%v", cfg.HardLimit, cfg.SoftLimit, cfg.DryRun, cfg.EnvoyOverloaded)
+	}
+	if *cfg.ShedHeader != (HeaderMatcher{Name: "X-Priority", Value: "low"}) {
+		t.Errorf("unexpected header matcher: %+v", cfg.ShedHeader)
+	}
+	wantPaths := &PathMatcher{Exact: []string{"/api/search/suggest"}, Prefixes: []string{"/api/export/"}, Globs: []string{"/api/*/report"}}
+	if !reflect.DeepEqual(cfg.ShedPaths, wantPaths) {
+		t.Errorf("expected paths %+v, got %+v", wantPaths, cfg.ShedPaths)
+	}
+	if !reflect.DeepEqual(cfg.ShedMethods, MethodMatcher{"GET", "HEAD"}) {
+		t.Errorf("unexpected methods: %v", cfg.ShedMethods)
+	}
+	wantQuery := []QueryMatcher{{Name: "prefetch", Value: "true"}, {Name: "preview", Present: true}}
+	if !reflect.DeepEqual(cfg.ShedQuery, wantQuery) {
+		t.Errorf("expected query %+v, got %+v", wantQuery, cfg.ShedQuery)
+	}
+	if cfg.ExemptPaths == nil || cfg.ExemptPaths.Exact[0] != "/internal/drain" {
+		t.Errorf("unexpected exempt paths: %+v", cfg.ExemptPaths)
+	}
+	if cfg.Queue == nil || cfg.Queue.Timeout != 250*time.Millisecond {
+		t.Errorf("unexpected queue: %+v", cfg.Queue)
+	}
+
+	s, err := NewWithError(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.decider()(httptest.NewRequest("GET", "/?prefetch=true", nil)) {
+		t.Error("expected env matchers to build a decider")
+	}
+}
+
+func TestFromEnv_Auto(t *testing.T) {
+	t.Setenv("APP_HARD_LIMIT", "auto")
+	t.Setenv("APP_SHED_HEADER", "X-Best-Effort")
+
+	cfg, err := FromEnv("APP_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.HardLimit != Auto {
+		t.Errorf("expected Auto, got %d", cfg.HardLimit)
+	}
+	if !cfg.ShedHeader.Present || cfg.ShedHeader.Name != "X-Best-Effort" {
+		t.Errorf("expected presence matcher, got %+v", cfg.ShedHeader)
+	}
+}
+
+func TestFromEnv_Errors(t *testing.T) {
+	t.Setenv("SHEDDER_HARD_LIMIT", "lots")
+	t.Setenv("SHEDDER_QUEUE_TIMEOUT", "5")
+
+	_, err := FromEnv("SHEDDER")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, want := range []string{`SHEDDER_HARD_LIMIT: invalid value "lots"`, `SHEDDER_QUEUE_TIMEOUT: invalid value "5"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to mention %q, got %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package envoy
+
+import (
+	"context"
+	"net/http"
+
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	shedder "github.com/sampath030/kube-shedder"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/genproto/googleapis/rpc/status"
+)
+
+// AuthorizationServer implements Envoy's external authorization service
+// (envoy.service.auth.v3.Authorization) with a Shedder. Requests the
+// shedder admits are allowed; shed ones are denied with the response the
+// middleware would have written, i.e. 503 with Retry-After and
+// X-Shed-Reason. Register it on a gRPC server:
+//
+//	authv3.RegisterAuthorizationServer(grpcServer, envoy.NewAuthorizationServer(s))
+type AuthorizationServer struct {
+	authv3.UnimplementedAuthorizationServer
+	shedder *shedder.Shedder
+}
+
+// NewAuthorizationServer returns an AuthorizationServer deciding with s.
+func NewAuthorizationServer(s *shedder.Shedder) *AuthorizationServer {
+	return &AuthorizationServer{shedder: s}
+}
+
+// Check admits or sheds the request described by req.
+func (a *AuthorizationServer) Check(ctx context.Context, req *authv3.CheckRequest) (*authv3.CheckResponse, error) {
+	attrs := req.GetAttributes()
+	h := attrs.GetRequest().GetHttp()
+
+	header := make(http.Header, len(h.GetHeaders()))
+	for name, value := range h.GetHeaders() {
+		if name != "" && name[0] != ':' {
+			header.Set(name, value)
+		}
+	}
+	if len(header) == 0 {
+		header = headerFromMap(h.GetHeaderMap())
+	}
+	path := h.GetPath()
+	if path == "" {
+		path = "/"
+	}
+	r := newRequest(h.GetMethod(), h.GetHost(), path, header, attrs.GetSource().GetAddress()).WithContext(ctx)
+
+	sr := admit(a.shedder, r)
+	if sr == nil {
+		return &authv3.CheckResponse{
+			Status:       &status.Status{Code: int32(code.Code_OK)},
+			HttpResponse: &authv3.CheckResponse_OkResponse{OkResponse: &authv3.OkHttpResponse{}},
+		}, nil
+	}
+	return &authv3.CheckResponse{
+		Status: &status.Status{Code: int32(code.Code_UNAVAILABLE), Message: sr.header.Get("X-Shed-Reason")},
+		HttpResponse: &authv3.CheckResponse_DeniedResponse{DeniedResponse: &authv3.DeniedHttpResponse{
+			Status:  &typev3.HttpStatus{Code: typev3.StatusCode(sr.code)},
+			Headers: headerOptions(sr.header),
+			Body:    sr.body.String(),
+		}},
+	}, nil
+}
@@ -0,0 +1,75 @@
+package envoy
+
+import (
+	"context"
+	"testing"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	shedder "github.com/sampath030/kube-shedder"
+	"google.golang.org/genproto/googleapis/rpc/code"
+)
+
+func checkRequest(headers map[string]string) *authv3.CheckRequest {
+	return &authv3.CheckRequest{Attributes: &authv3.AttributeContext{
+		Source: &authv3.AttributeContext_Peer{Address: &corev3.Address{Address: &corev3.Address_SocketAddress{
+			SocketAddress: &corev3.SocketAddress{Address: "10.0.0.7", PortSpecifier: &corev3.SocketAddress_PortValue{PortValue: 51000}},
+		}}},
+		Request: &authv3.AttributeContext_Request{Http: &authv3.AttributeContext_HttpRequest{
+			Method:  "GET",
+			Host:    "checkout",
+			Path:    "/api/cart?id=1",
+			Headers: headers,
+		}},
+	}}
+}
+
+func TestAuthorizationServer_Check(t *testing.T) {
+	s := shedder.New(shedder.Config{
+		HardLimit:          10,
+		SoftLimit:          5,
+		SoftOverloadSignal: shedder.SignalFunc(func() bool { return true }),
+		ShedHeader:         &shedder.HeaderMatcher{Name: "X-Priority", Value: "low"},
+	})
+	a := NewAuthorizationServer(s)
+
+	resp, err := a.Check(context.Background(), checkRequest(map[string]string{"x-priority": "high"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetStatus().GetCode() != int32(code.Code_OK) || resp.GetOkResponse() == nil {
+		t.Errorf("expected high priority allowed, got %v", resp)
+	}
+
+	resp, err = a.Check(context.Background(), checkRequest(map[string]string{"x-priority": "low"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	denied := resp.GetDeniedResponse()
+	if resp.GetStatus().GetCode() != int32(code.Code_UNAVAILABLE) || denied.GetStatus().GetCode() != 503 {
+		t.Fatalf("expected low priority denied with 503, got %v", resp)
+	}
+	headers := map[string]string{}
+	for _, h := range denied.GetHeaders() {
+		headers[h.GetHeader().GetKey()] = string(h.GetHeader().GetRawValue())
+	}
+	if headers["X-Shed-Reason"] != "soft_limit" || headers["Retry-After"] == "" {
+		t.Errorf("expected shed headers, got %v", headers)
+	}
+	if st := s.Stats(); st.Inflight != 0 || st.Shed != 1 {
+		t.Errorf("expected no request left in flight and one shed, got %+v", st)
+	}
+}
+
+func TestAuthorizationServer_Draining(t *testing.T) {
+	s := shedder.New(shedder.Config{HardLimit: 10})
+	s.StartDraining()
+
+	resp, err := NewAuthorizationServer(s).Check(context.Background(), checkRequest(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetDeniedResponse().GetStatus().GetCode() != 503 || resp.GetStatus().GetMessage() != "draining" {
+		t.Errorf("expected draining pod to deny, got %v", resp)
+	}
+}
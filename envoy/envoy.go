@@ -0,0 +1,148 @@
+// Package envoy serves the shedder's admission decisions to Envoy, so
+// Istio and Envoy sidecar users can shed in the mesh layer instead of in
+// application middleware. It is a separate module so the shedder itself
+// does not depend on gRPC.
+//
+// AuthorizationServer implements the ext_authz service. Envoy does not
+// report when a request it authorized finishes, so a Shedder used only
+// there cannot count in-flight requests: serve it from the application
+// process with the Shedder its middleware uses, or configure
+// OverloadSignal and SoftOverloadSignal, e.g. from cgroup CPU pressure.
+//
+// Processor implements the ext_proc service, whose stream lasts as long
+// as the request, so it counts in-flight requests itself and can also
+// rewrite priorities and annotate responses.
+package envoy
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	shedder "github.com/sampath030/kube-shedder"
+)
+
+// shedResponse is the response the shedder wrote for a shed request.
+type shedResponse struct {
+	code   int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (sr *shedResponse) Header() http.Header { return sr.header }
+
+func (sr *shedResponse) Write(p []byte) (int, error) {
+	if sr.code == 0 {
+		sr.code = http.StatusOK
+	}
+	return sr.body.Write(p)
+}
+
+func (sr *shedResponse) WriteHeader(code int) {
+	if sr.code == 0 {
+		sr.code = code
+	}
+}
+
+// admit runs r through s's middleware and returns the response it was
+// shed with, or nil if it was admitted.
+func admit(s *shedder.Shedder, r *http.Request) *shedResponse {
+	sr, release := hold(s, r)
+	if release == nil {
+		return sr
+	}
+	release()
+	return nil
+}
+
+// hold runs r through s's middleware. If r is admitted, it keeps r's slot
+// until release is called, and sr holds the headers the middleware set
+// for the response, e.g. TelemetryHeaders. Otherwise release is nil and
+// sr is the response r was shed with.
+func hold(s *shedder.Shedder, r *http.Request) (sr *shedResponse, release func()) {
+	sr = &shedResponse{header: make(http.Header)}
+	admitted, done, finished := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(finished)
+		s.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			close(admitted)
+			<-done
+		})).ServeHTTP(sr, r)
+	}()
+
+	select {
+	case <-admitted:
+		return sr, func() {
+			close(done)
+			<-finished
+		}
+	case <-finished:
+		if sr.code == 0 {
+			sr.code = http.StatusServiceUnavailable
+		}
+		return sr, nil
+	}
+}
+
+// newRequest builds the request Envoy is asking about. path may carry a
+// query string; peer is the downstream address, if known.
+func newRequest(method, host, path string, header http.Header, peer *corev3.Address) *http.Request {
+	u, err := url.ParseRequestURI(path)
+	if err != nil {
+		u = &url.URL{Path: path}
+	}
+	r := &http.Request{
+		Method:     method,
+		URL:        u,
+		RequestURI: path,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Host:       host,
+		Body:       http.NoBody,
+	}
+	if sa := peer.GetSocketAddress(); sa != nil {
+		r.RemoteAddr = net.JoinHostPort(sa.GetAddress(), strconv.Itoa(int(sa.GetPortValue())))
+	}
+	return r
+}
+
+// headerFromMap converts Envoy's header map, whose keys are lower-case,
+// into an http.Header. Pseudo-headers such as ":path" are skipped.
+func headerFromMap(hm *corev3.HeaderMap) http.Header {
+	h := make(http.Header)
+	for _, hv := range hm.GetHeaders() {
+		if strings.HasPrefix(hv.GetKey(), ":") {
+			continue
+		}
+		value := hv.GetValue()
+		if raw := hv.GetRawValue(); len(raw) > 0 {
+			value = string(raw)
+		}
+		h.Add(hv.GetKey(), value)
+	}
+	return h
+}
+
+// headerOptions converts h into Envoy header mutations.
+func headerOptions(h http.Header) []*corev3.HeaderValueOption {
+	var opts []*corev3.HeaderValueOption
+	for name, values := range h {
+		for i, v := range values {
+			action := corev3.HeaderValueOption_OVERWRITE_IF_EXISTS_OR_ADD
+			if i > 0 {
+				action = corev3.HeaderValueOption_APPEND_IF_EXISTS_OR_ADD
+			}
+			opts = append(opts, &corev3.HeaderValueOption{
+				Header:       &corev3.HeaderValue{Key: name, RawValue: []byte(v)},
+				AppendAction: action,
+			})
+		}
+	}
+	return opts
+}
@@ -0,0 +1,148 @@
+package envoy
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	shedder "github.com/sampath030/kube-shedder"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ProcessorOptions configures a Processor.
+type ProcessorOptions struct {
+	// Classify, if set, assigns each request a priority class, which is
+	// written to ClassHeader before the shedder sees the request, so a
+	// Config.Classify or ShedHeader reading that header, and the upstream
+	// service, see the class assigned here rather than one sent by the
+	// client.
+	Classify func(r *http.Request) string
+
+	// ClassHeader is the request header Classify's result is written to.
+	// Defaults to "X-Priority".
+	ClassHeader string
+}
+
+// Processor implements Envoy's external processing service
+// (envoy.service.ext_proc.v3.ExternalProcessor) with a Shedder. Unlike an
+// AuthorizationServer, it can rewrite the request's priority before
+// admission and annotate admitted responses with the headers the
+// middleware sets, such as TelemetryHeaders and RateLimitHeaders. Shed
+// requests get an immediate response, as from the middleware.
+//
+// Each admitted request holds its in-flight slot until Envoy closes the
+// processing stream at the end of the request, so the shedder counts
+// in-flight requests accurately without running in the application. The
+// filter must send request headers; sending response headers enables the
+// annotations.
+type Processor struct {
+	extprocv3.UnimplementedExternalProcessorServer
+	shedder *shedder.Shedder
+	opts    ProcessorOptions
+}
+
+// NewProcessor returns a Processor deciding with s.
+func NewProcessor(s *shedder.Shedder, opts ProcessorOptions) *Processor {
+	if opts.ClassHeader == "" {
+		opts.ClassHeader = "X-Priority"
+	}
+	return &Processor{shedder: s, opts: opts}
+}
+
+// Process handles the processing stream of one request.
+func (p *Processor) Process(stream extprocv3.ExternalProcessor_ProcessServer) error {
+	var release func()
+	defer func() {
+		if release != nil {
+			release()
+		}
+	}()
+	var annotations http.Header
+
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) || status.Code(err) == codes.Canceled {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var resp *extprocv3.ProcessingResponse
+		switch v := req.GetRequest().(type) {
+		case *extprocv3.ProcessingRequest_RequestHeaders:
+			if release != nil {
+				return status.Error(codes.FailedPrecondition, "request headers received twice")
+			}
+			resp, annotations, release = p.requestHeaders(stream, v.RequestHeaders)
+		case *extprocv3.ProcessingRequest_ResponseHeaders:
+			resp = &extprocv3.ProcessingResponse{Response: &extprocv3.ProcessingResponse_ResponseHeaders{
+				ResponseHeaders: &extprocv3.HeadersResponse{Response: &extprocv3.CommonResponse{
+					HeaderMutation: &extprocv3.HeaderMutation{SetHeaders: headerOptions(annotations)},
+				}},
+			}}
+		case *extprocv3.ProcessingRequest_RequestBody:
+			resp = &extprocv3.ProcessingResponse{Response: &extprocv3.ProcessingResponse_RequestBody{RequestBody: &extprocv3.BodyResponse{}}}
+		case *extprocv3.ProcessingRequest_ResponseBody:
+			resp = &extprocv3.ProcessingResponse{Response: &extprocv3.ProcessingResponse_ResponseBody{ResponseBody: &extprocv3.BodyResponse{}}}
+		case *extprocv3.ProcessingRequest_RequestTrailers:
+			resp = &extprocv3.ProcessingResponse{Response: &extprocv3.ProcessingResponse_RequestTrailers{RequestTrailers: &extprocv3.TrailersResponse{}}}
+		case *extprocv3.ProcessingRequest_ResponseTrailers:
+			resp = &extprocv3.ProcessingResponse{Response: &extprocv3.ProcessingResponse_ResponseTrailers{ResponseTrailers: &extprocv3.TrailersResponse{}}}
+		default:
+			return status.Errorf(codes.Unimplemented, "unexpected processing request %T", v)
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// requestHeaders admits or sheds the request whose headers are h. An
+// admitted request holds its slot until release is called; annotations
+// are the headers to add to its response.
+func (p *Processor) requestHeaders(stream extprocv3.ExternalProcessor_ProcessServer, h *extprocv3.HttpHeaders) (resp *extprocv3.ProcessingResponse, annotations http.Header, release func()) {
+	var method, host, path string
+	for _, hv := range h.GetHeaders().GetHeaders() {
+		value := hv.GetValue()
+		if raw := hv.GetRawValue(); len(raw) > 0 {
+			value = string(raw)
+		}
+		switch hv.GetKey() {
+		case ":method":
+			method = value
+		case ":authority":
+			host = value
+		case ":path":
+			path = value
+		}
+	}
+	if path == "" {
+		path = "/"
+	}
+	r := newRequest(method, host, path, headerFromMap(h.GetHeaders()), nil).WithContext(stream.Context())
+
+	mutation := &extprocv3.HeaderMutation{}
+	if p.opts.Classify != nil {
+		r.Header.Set(p.opts.ClassHeader, p.opts.Classify(r))
+		mutation.SetHeaders = headerOptions(http.Header{p.opts.ClassHeader: r.Header.Values(p.opts.ClassHeader)})
+	}
+
+	sr, release := hold(p.shedder, r)
+	if release == nil {
+		return &extprocv3.ProcessingResponse{Response: &extprocv3.ProcessingResponse_ImmediateResponse{
+			ImmediateResponse: &extprocv3.ImmediateResponse{
+				Status:  &typev3.HttpStatus{Code: typev3.StatusCode(sr.code)},
+				Headers: &extprocv3.HeaderMutation{SetHeaders: headerOptions(sr.header)},
+				Body:    sr.body.Bytes(),
+				Details: "shed_" + sr.header.Get("X-Shed-Reason"),
+			},
+		}}, nil, nil
+	}
+	return &extprocv3.ProcessingResponse{Response: &extprocv3.ProcessingResponse_RequestHeaders{
+		RequestHeaders: &extprocv3.HeadersResponse{Response: &extprocv3.CommonResponse{HeaderMutation: mutation}},
+	}}, sr.header, release
+}
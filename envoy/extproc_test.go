@@ -0,0 +1,106 @@
+package envoy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	shedder "github.com/sampath030/kube-shedder"
+	"google.golang.org/grpc"
+)
+
+// fakeStream is the processing stream of one request.
+type fakeStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	recv chan *extprocv3.ProcessingRequest
+	sent chan *extprocv3.ProcessingResponse
+}
+
+func newFakeStream(t *testing.T, p *Processor) (*fakeStream, <-chan error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	fs := &fakeStream{ctx: ctx, recv: make(chan *extprocv3.ProcessingRequest), sent: make(chan *extprocv3.ProcessingResponse, 1)}
+	errc := make(chan error, 1)
+	go func() { errc <- p.Process(fs) }()
+	return fs, errc
+}
+
+func (fs *fakeStream) Context() context.Context { return fs.ctx }
+
+func (fs *fakeStream) Send(resp *extprocv3.ProcessingResponse) error {
+	fs.sent <- resp
+	return nil
+}
+
+func (fs *fakeStream) Recv() (*extprocv3.ProcessingRequest, error) {
+	req, ok := <-fs.recv
+	if !ok {
+		return nil, io.EOF
+	}
+	return req, nil
+}
+
+func (fs *fakeStream) requestHeaders(headers map[string]string) *extprocv3.ProcessingResponse {
+	hm := &corev3.HeaderMap{}
+	for k, v := range headers {
+		hm.Headers = append(hm.Headers, &corev3.HeaderValue{Key: k, RawValue: []byte(v)})
+	}
+	fs.recv <- &extprocv3.ProcessingRequest{Request: &extprocv3.ProcessingRequest_RequestHeaders{
+		RequestHeaders: &extprocv3.HttpHeaders{Headers: hm},
+	}}
+	return <-fs.sent
+}
+
+func mutations(m *extprocv3.HeaderMutation) map[string]string {
+	out := map[string]string{}
+	for _, h := range m.GetSetHeaders() {
+		out[h.GetHeader().GetKey()] = string(h.GetHeader().GetRawValue())
+	}
+	return out
+}
+
+func TestProcessor(t *testing.T) {
+	s := shedder.New(shedder.Config{HardLimit: 1, TelemetryHeaders: true})
+	p := NewProcessor(s, ProcessorOptions{
+		Classify: func(r *http.Request) string {
+			if r.URL.Path == "/api/export" {
+				return "low"
+			}
+			return "high"
+		},
+	})
+
+	first, errc := newFakeStream(t, p)
+	resp := first.requestHeaders(map[string]string{":method": "GET", ":path": "/api/cart", "x-priority": "low"})
+	if got := mutations(resp.GetRequestHeaders().GetResponse().GetHeaderMutation())["X-Priority"]; got != "high" {
+		t.Errorf("expected the class header rewritten to high, got %q (%v)", got, resp)
+	}
+	if s.Inflight() != 1 {
+		t.Fatalf("expected the admitted request to hold its slot, got %d in flight", s.Inflight())
+	}
+
+	second, _ := newFakeStream(t, p)
+	resp = second.requestHeaders(map[string]string{":method": "GET", ":path": "/api/export"})
+	ir := resp.GetImmediateResponse()
+	if ir.GetStatus().GetCode() != 503 || mutations(ir.GetHeaders())["X-Shed-Reason"] != "hard_limit" || ir.GetDetails() != "shed_hard_limit" {
+		t.Errorf("expected the second request shed at the hard limit, got %v", resp)
+	}
+
+	first.recv <- &extprocv3.ProcessingRequest{Request: &extprocv3.ProcessingRequest_ResponseHeaders{ResponseHeaders: &extprocv3.HttpHeaders{}}}
+	resp = <-first.sent
+	if got := mutations(resp.GetResponseHeaders().GetResponse().GetHeaderMutation())["X-Shedder-Inflight"]; got != "1" {
+		t.Errorf("expected the response annotated with telemetry headers, got %v", resp)
+	}
+
+	close(first.recv)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if s.Inflight() != 0 {
+		t.Errorf("expected the slot released with the stream, got %d in flight", s.Inflight())
+	}
+}
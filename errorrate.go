@@ -0,0 +1,146 @@
+package shedder
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrorRateConfig configures error-rate feedback shedding.
+type ErrorRateConfig struct {
+	// Threshold is the fraction (0-1) of 5xx responses from the wrapped
+	// handler above which the shedder enters soft overload. Required.
+	Threshold float64
+
+	// Window is the sliding window over which the error fraction is
+	// computed. Defaults to 10s.
+	Window time.Duration
+
+	// MinRequests is the number of completed requests required in the
+	// window before the error fraction is trusted. Defaults to 20.
+	MinRequests int64
+}
+
+// errorRateTracker records response status codes and reports soft
+// overload when the fraction of server errors exceeds the threshold.
+type errorRateTracker struct {
+	threshold   float64
+	minRequests int64
+	window      *window
+	clock       Clock
+}
+
+// newErrorRateTracker returns a tracker for cfg with defaults applied.
+func newErrorRateTracker(cfg ErrorRateConfig, clock Clock) *errorRateTracker {
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = 20
+	}
+	return &errorRateTracker{
+		threshold:   cfg.Threshold,
+		minRequests: cfg.MinRequests,
+		window:      newWindow(cfg.Window, 10),
+		clock:       clock,
+	}
+}
+
+// record counts one completed response.
+func (t *errorRateTracker) record(status int) {
+	var hit int64
+	if status >= 500 {
+		hit = 1
+	}
+	t.window.add(t.clock.Now(), 1, hit)
+}
+
+// Overloaded implements OverloadSignal.
+func (t *errorRateTracker) Overloaded() bool {
+	total, errors := t.window.sum(t.clock.Now())
+	if total < t.minRequests {
+		return false
+	}
+	return float64(errors)/float64(total) > t.threshold
+}
+
+// statusWriter wraps an http.ResponseWriter to capture the status code.
+// It is pooled, so tracking completions does not allocate per request,
+// and forwards Flush, Hijack and Push, so streaming, WebSocket and HTTP/2
+// push handlers keep working behind it.
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+var statusWriterPool = sync.Pool{New: func() any { return new(statusWriter) }}
+
+// newStatusWriter returns a pooled statusWriter wrapping w. The caller
+// must call free once the handler has returned.
+func newStatusWriter(w http.ResponseWriter) *statusWriter {
+	sw := statusWriterPool.Get().(*statusWriter)
+	sw.ResponseWriter = w
+	return sw
+}
+
+// free returns w to the pool. w must not be used afterwards.
+func (w *statusWriter) free() {
+	*w = statusWriter{}
+	statusWriterPool.Put(w)
+}
+
+// WriteHeader records the status code and forwards it.
+func (w *statusWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write records an implicit 200 status and forwards the data.
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush forwards to the underlying writer if it supports flushing.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack forwards to the underlying writer if it supports hijacking.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+// Push forwards to the underlying writer if it supports HTTP/2 server
+// push.
+func (w *statusWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap returns the underlying writer for http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// status returns the recorded status code, defaulting to 200 when the
+// handler wrote nothing.
+func (w *statusWriter) status() int {
+	if w.code == 0 {
+		return http.StatusOK
+	}
+	return w.code
+}
@@ -0,0 +1,152 @@
+package shedder
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestErrorRateTracker(t *testing.T) {
+	tr := newErrorRateTracker(ErrorRateConfig{Threshold: 0.5, MinRequests: 4}, newFakeClock())
+
+	tr.record(http.StatusInternalServerError)
+	tr.record(http.StatusBadGateway)
+	tr.record(http.StatusServiceUnavailable)
+	if tr.Overloaded() {
+		t.Error("should not trip before MinRequests")
+	}
+
+	tr.record(http.StatusOK)
+	if !tr.Overloaded() {
+		t.Error("expected overload at 75% errors")
+	}
+
+	for i := 0; i < 4; i++ {
+		tr.record(http.StatusNotFound)
+	}
+	if tr.Overloaded() {
+		t.Error("4xx responses must not count as errors")
+	}
+}
+
+func TestMiddleware_ErrorRateEntersSoftOverload(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+
+	s := New(Config{
+		HardLimit:  10,
+		ErrorRate:  &ErrorRateConfig{Threshold: 0.5, MinRequests: 2},
+		ShedHeader: &HeaderMatcher{Name: "X-Priority", Value: "low"},
+	})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+
+	for i := 0; i < 2; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+	if !s.IsSoftOverloaded() {
+		t.Fatal("expected soft overload after repeated 5xx")
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Priority", "low")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected low priority request to be shed, got %d", rec.Code)
+	}
+}
+
+func TestMiddleware_ErrorRateCountsPanics(t *testing.T) {
+	s := New(Config{
+		HardLimit: 10,
+		ErrorRate: &ErrorRateConfig{Threshold: 0.5, MinRequests: 1},
+	})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	func() {
+		defer func() { recover() }()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+
+	if !s.errorRate.Overloaded() {
+		t.Error("expected a panic to count as a server error")
+	}
+	if s.Inflight() != 0 {
+		t.Errorf("expected inflight 0 after panic, got %d", s.Inflight())
+	}
+}
+
+func TestStatusWriter_DefaultsTo200(t *testing.T) {
+	sw := &statusWriter{ResponseWriter: httptest.NewRecorder()}
+	if sw.status() != http.StatusOK {
+		t.Errorf("expected 200 when nothing was written, got %d", sw.status())
+	}
+	sw.WriteHeader(http.StatusTeapot)
+	sw.WriteHeader(http.StatusOK)
+	if sw.status() != http.StatusTeapot {
+		t.Errorf("expected first status to win, got %d", sw.status())
+	}
+}
+
+// streamingWriter is a ResponseWriter supporting flushing, hijacking and
+// server push, recording which were used.
+type streamingWriter struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+	pushed   string
+}
+
+func (w *streamingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = true
+	return nil, nil, nil
+}
+
+func (w *streamingWriter) Push(target string, opts *http.PushOptions) error {
+	w.pushed = target
+	return nil
+}
+
+func TestStatusWriter_Interfaces(t *testing.T) {
+	s := New(Config{HardLimit: 10, ErrorRate: &ErrorRateConfig{Threshold: 0.5}})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(*statusWriter); !ok {
+			t.Fatalf("expected the status writer, got %T", w)
+		}
+		w.(http.Flusher).Flush()
+		if err := w.(http.Pusher).Push("/app.js", nil); err != nil {
+			t.Errorf("unexpected push error: %v", err)
+		}
+		if _, _, err := w.(http.Hijacker).Hijack(); err != nil {
+			t.Errorf("unexpected hijack error: %v", err)
+		}
+	}))
+	w := &streamingWriter{ResponseRecorder: httptest.NewRecorder()}
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if !w.Flushed || !w.hijacked || w.pushed != "/app.js" {
+		t.Errorf("expected flush, hijack and push forwarded, got flushed=%v hijacked=%v pushed=%q", w.Flushed, w.hijacked, w.pushed)
+	}
+
+	sw := newStatusWriter(httptest.NewRecorder())
+	if _, _, err := sw.Hijack(); err != http.ErrNotSupported {
+		t.Errorf("expected ErrNotSupported without a Hijacker, got %v", err)
+	}
+	if err := sw.Push("/app.js", nil); err != http.ErrNotSupported {
+		t.Errorf("expected ErrNotSupported without a Pusher, got %v", err)
+	}
+	sw.WriteHeader(http.StatusTeapot)
+	sw.free()
+	if sw = newStatusWriter(httptest.NewRecorder()); sw.code != 0 {
+		t.Errorf("expected a reset writer from the pool, got code %d", sw.code)
+	}
+}
@@ -0,0 +1,154 @@
+package shedder
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CapacityEstimatorConfig configures a CapacityEstimator.
+type CapacityEstimatorConfig struct {
+	// Window is the length of one measurement window. Defaults to 10s.
+	Window time.Duration
+
+	// History is the number of completed windows considered when picking
+	// the best observed throughput. Defaults to 6.
+	History int
+
+	// Headroom multiplies the estimate to leave room for variance.
+	// Defaults to 1.0 (no headroom).
+	Headroom float64
+
+	// MinLimit is the smallest limit the estimator recommends.
+	// Defaults to 1.
+	MinLimit int64
+
+	// Clock tells the time windows are measured by. Defaults to the system
+	// clock; set it to the shedder's Config.Clock in tests.
+	Clock Clock
+}
+
+// CapacityEstimator derives a sustainable concurrency from measured
+// throughput and latency using Little's Law (L = λW).
+//
+// Completions are grouped into fixed windows. For each window the
+// estimator computes throughput λ (completions per second) and mean
+// latency W; the recommendation is λW of the highest-throughput window in
+// the recent history, i.e. the concurrency at which the pod achieved its
+// best throughput.
+//
+// Set it as Config.CapacityEstimator to expose the recommendation through
+// Stats, and additionally as Config.LimitAlgorithm to let it drive the hard
+// limit.
+type CapacityEstimator struct {
+	cfg CapacityEstimatorConfig
+
+	count       atomic.Int64
+	latencySum  atomic.Int64 // nanoseconds
+	windowStart atomic.Int64 // unix nanoseconds
+
+	mu             sync.Mutex
+	windows        []capacityWindow // ring of completed windows
+	next           int
+	recommendation atomic.Int64
+}
+
+// capacityWindow is the measurement of one completed window.
+type capacityWindow struct {
+	throughput float64       // completions per second
+	latency    time.Duration // mean latency
+}
+
+// NewCapacityEstimator returns a CapacityEstimator with defaults applied.
+func NewCapacityEstimator(cfg CapacityEstimatorConfig) *CapacityEstimator {
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+	if cfg.History <= 0 {
+		cfg.History = 6
+	}
+	if cfg.Headroom <= 0 {
+		cfg.Headroom = 1.0
+	}
+	if cfg.MinLimit <= 0 {
+		cfg.MinLimit = 1
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = systemClock{}
+	}
+	e := &CapacityEstimator{cfg: cfg, windows: make([]capacityWindow, 0, cfg.History)}
+	e.windowStart.Store(cfg.Clock.Now().UnixNano())
+	return e
+}
+
+// Observe records one completed request and its latency.
+func (e *CapacityEstimator) Observe(latency time.Duration) {
+	e.observeAt(e.cfg.Clock.Now(), latency)
+}
+
+// observeAt records a completion at the given time.
+func (e *CapacityEstimator) observeAt(now time.Time, latency time.Duration) {
+	e.maybeRoll(now)
+	e.count.Add(1)
+	e.latencySum.Add(int64(latency))
+}
+
+// maybeRoll closes the current window if it has elapsed.
+func (e *CapacityEstimator) maybeRoll(now time.Time) {
+	start := e.windowStart.Load()
+	elapsed := now.UnixNano() - start
+	if elapsed < int64(e.cfg.Window) || !e.mu.TryLock() {
+		return
+	}
+	defer e.mu.Unlock()
+	if e.windowStart.Load() != start {
+		return // another goroutine rolled the window
+	}
+
+	count := e.count.Swap(0)
+	sum := e.latencySum.Swap(0)
+	e.windowStart.Store(now.UnixNano())
+	if count == 0 {
+		return
+	}
+
+	w := capacityWindow{
+		throughput: float64(count) / time.Duration(elapsed).Seconds(),
+		latency:    time.Duration(sum / count),
+	}
+	if len(e.windows) < e.cfg.History {
+		e.windows = append(e.windows, w)
+	} else {
+		e.windows[e.next] = w
+	}
+	e.next = (e.next + 1) % e.cfg.History
+
+	best := e.windows[0]
+	for _, c := range e.windows[1:] {
+		if c.throughput > best.throughput {
+			best = c
+		}
+	}
+	limit := int64(math.Ceil(best.throughput * best.latency.Seconds() * e.cfg.Headroom))
+	if limit < e.cfg.MinLimit {
+		limit = e.cfg.MinLimit
+	}
+	e.recommendation.Store(limit)
+}
+
+// Recommendation returns the recommended concurrency limit, or 0 until the
+// first window with completed requests has been measured.
+func (e *CapacityEstimator) Recommendation() int64 {
+	return e.recommendation.Load()
+}
+
+// OnSample implements LimitAlgorithm. Admitted requests are observed and
+// the current recommendation is returned; until one is available the
+// previous limit is kept.
+func (e *CapacityEstimator) OnSample(latency time.Duration, inflight int64, didShed bool) int64 {
+	if !didShed {
+		e.Observe(latency)
+	}
+	return e.Recommendation()
+}
@@ -0,0 +1,87 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCapacityEstimator_LittlesLaw(t *testing.T) {
+	e := NewCapacityEstimator(CapacityEstimatorConfig{Window: time.Second})
+	start := time.Unix(0, e.windowStart.Load())
+
+	if e.Recommendation() != 0 {
+		t.Errorf("expected no recommendation before the first window, got %d", e.Recommendation())
+	}
+
+	// 100 completions/s at 50ms mean latency => L = 5.
+	for i := 0; i < 100; i++ {
+		e.observeAt(start.Add(time.Duration(i)*time.Millisecond), 50*time.Millisecond)
+	}
+	e.observeAt(start.Add(time.Second), 50*time.Millisecond)
+
+	if got := e.Recommendation(); got != 5 {
+		t.Errorf("expected recommendation 5, got %d", got)
+	}
+}
+
+func TestCapacityEstimator_KeepsBestWindow(t *testing.T) {
+	e := NewCapacityEstimator(CapacityEstimatorConfig{Window: time.Second, Headroom: 2})
+	start := time.Unix(0, e.windowStart.Load())
+
+	// Window 1: 200/s at 100ms => 20, doubled by headroom => 40.
+	for i := 0; i < 200; i++ {
+		e.observeAt(start, 100*time.Millisecond)
+	}
+	// Window 2: a quieter period must not lower the estimate.
+	e.observeAt(start.Add(time.Second), time.Millisecond)
+	e.observeAt(start.Add(2*time.Second), time.Millisecond)
+
+	if got := e.Recommendation(); got != 40 {
+		t.Errorf("expected recommendation 40, got %d", got)
+	}
+}
+
+func TestCapacityEstimator_MinLimit(t *testing.T) {
+	e := NewCapacityEstimator(CapacityEstimatorConfig{Window: time.Second, MinLimit: 3})
+	start := time.Unix(0, e.windowStart.Load())
+
+	e.observeAt(start, time.Millisecond)
+	e.observeAt(start.Add(time.Second), time.Millisecond)
+
+	if got := e.Recommendation(); got != 3 {
+		t.Errorf("expected MinLimit 3, got %d", got)
+	}
+}
+
+func TestCapacityEstimator_AsLimitAlgorithm(t *testing.T) {
+	e := NewCapacityEstimator(CapacityEstimatorConfig{})
+	if got := e.OnSample(time.Millisecond, 1, false); got != 0 {
+		t.Errorf("expected 0 (keep previous limit) without an estimate, got %d", got)
+	}
+	if e.count.Load() != 1 {
+		t.Errorf("expected admitted sample to be observed, got %d", e.count.Load())
+	}
+	e.OnSample(0, 1, true)
+	if e.count.Load() != 1 {
+		t.Error("shed samples should not be observed")
+	}
+}
+
+func TestMiddleware_FeedsCapacityEstimator(t *testing.T) {
+	e := NewCapacityEstimator(CapacityEstimatorConfig{})
+	s := New(Config{HardLimit: 10, CapacityEstimator: e})
+
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if e.count.Load() != 1 {
+		t.Errorf("expected 1 observation, got %d", e.count.Load())
+	}
+	if s.currentLimit() != 10 {
+		t.Errorf("estimator alone must not change the limit, got %d", s.currentLimit())
+	}
+}
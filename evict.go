@@ -0,0 +1,53 @@
+package shedder
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ErrEvicted is the context cause of a request canceled for exceeding
+// EvictionConfig.MaxAge; see context.Cause.
+var ErrEvicted = errors.New("shedder: request evicted")
+
+// EvictionConfig configures eviction of long-running requests.
+type EvictionConfig struct {
+	// MaxAge is how long a request may be in flight before its context is
+	// canceled and its slot reclaimed. Required.
+	MaxAge time.Duration
+
+	// OnEvict is an optional callback invoked when a request is evicted,
+	// with its age at eviction.
+	OnEvict func(r *http.Request, age time.Duration)
+}
+
+// eviction cancels requests older than maxAge.
+type eviction struct {
+	maxAge  time.Duration
+	onEvict func(r *http.Request, age time.Duration)
+}
+
+// evict cancels ar with ErrEvicted and returns its in-flight slot right
+// away, so a stuck handler that ignores cancellation no longer holds it.
+// Per-route, tenant, client and priority-level slots are returned when
+// the handler does.
+func (s *Shedder) evict(ar *activeRequest) {
+	if !s.active.cancelRequest(ar, ErrEvicted) {
+		return
+	}
+	if ar.release() {
+		s.decrement(ar.cost)
+	}
+	s.evicted.Add(1)
+	if s.eviction.onEvict != nil {
+		s.eviction.onEvict(ar.r, s.now().Sub(ar.start))
+	}
+}
+
+// release returns a request's in-flight slot unless eviction already
+// reclaimed it. ar is nil for untracked requests.
+func (s *Shedder) release(cost int64, ar *activeRequest) {
+	if ar == nil || ar.release() {
+		s.decrement(cost)
+	}
+}
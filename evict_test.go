@@ -0,0 +1,135 @@
+package shedder
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMiddleware_EvictsLongRunningRequests(t *testing.T) {
+	clock := newFakeClock()
+	evictedAge := make(chan time.Duration, 1)
+	s := New(Config{HardLimit: 10, Clock: clock, Eviction: &EvictionConfig{
+		MaxAge:  20 * time.Millisecond,
+		OnEvict: func(r *http.Request, age time.Duration) { evictedAge <- age },
+	}})
+
+	var cause error
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clock.Advance(19 * time.Millisecond)
+		if r.Context().Err() != nil {
+			t.Error("expected the request to run until MaxAge")
+		}
+		clock.Advance(time.Millisecond)
+		<-r.Context().Done()
+		cause = context.Cause(r.Context())
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if !errors.Is(cause, ErrEvicted) {
+		t.Errorf("expected ErrEvicted cause, got %v", cause)
+	}
+	if age := <-evictedAge; age != 20*time.Millisecond {
+		t.Errorf("expected OnEvict with age 20ms, got %v", age)
+	}
+	if st := s.Stats(); st.Evicted != 1 || st.Inflight != 0 {
+		t.Errorf("expected 1 eviction and no in-flight requests, got %d and %d", st.Evicted, st.Inflight)
+	}
+}
+
+func TestMiddleware_EvictionReclaimsStuckSlot(t *testing.T) {
+	s := New(Config{HardLimit: 1, Eviction: &EvictionConfig{MaxAge: 10 * time.Millisecond}})
+
+	stuck := make(chan struct{})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/stuck" {
+			<-stuck // ignores cancellation
+		}
+	}))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/stuck", nil))
+	}()
+
+	for s.Stats().Evicted != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected the reclaimed slot to admit a request, got %d", rec.Code)
+	}
+
+	close(stuck)
+	wg.Wait()
+	if s.Inflight() != 0 {
+		t.Errorf("expected the slot to be returned exactly once, got inflight %d", s.Inflight())
+	}
+}
+
+func TestMiddleware_FastRequestsNotEvicted(t *testing.T) {
+	clock := newFakeClock()
+	s := New(Config{HardLimit: 10, Clock: clock, Eviction: &EvictionConfig{MaxAge: 10 * time.Millisecond}})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if n := clock.pending(); n != 0 {
+		t.Errorf("expected the eviction timer to be stopped, got %d pending", n)
+	}
+	clock.Advance(20 * time.Millisecond)
+	if st := s.Stats(); st.Evicted != 0 || st.Inflight != 0 {
+		t.Errorf("expected no eviction and no in-flight requests, got %d and %d", st.Evicted, st.Inflight)
+	}
+}
+
+func TestEvict_FinishedRequest(t *testing.T) {
+	var evicted atomic.Int64
+	s := New(Config{HardLimit: 10, Eviction: &EvictionConfig{
+		MaxAge:  time.Hour,
+		OnEvict: func(r *http.Request, age time.Duration) { evicted.Add(1) },
+	}})
+
+	// An eviction timer that fired as its request finished runs after
+	// deregister.
+	s.increment(1)
+	r, ar := s.track(httptest.NewRequest("GET", "/", nil), s.now(), 1)
+	s.active.deregister(ar)
+	s.release(1, ar)
+	s.evict(ar)
+	if st := s.Stats(); st.Evicted != 0 || evicted.Load() != 0 || st.Inflight != 0 {
+		t.Errorf("expected a finished request not to be evicted, got %d evictions, %d callbacks and inflight %d", st.Evicted, evicted.Load(), st.Inflight)
+	}
+	if !errors.Is(context.Cause(r.Context()), context.Canceled) {
+		t.Errorf("expected the context canceled on completion, got %v", context.Cause(r.Context()))
+	}
+
+	// Racing timers and completions evict each request at most once and
+	// return each slot exactly once.
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		s.increment(1)
+		_, ar := s.track(httptest.NewRequest("GET", "/", nil), s.now(), 1)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			s.evict(ar)
+		}()
+		go func() {
+			defer wg.Done()
+			s.active.deregister(ar)
+			s.release(1, ar)
+		}()
+	}
+	wg.Wait()
+	if st := s.Stats(); st.Evicted != evicted.Load() || st.Inflight != 0 {
+		t.Errorf("expected one callback per eviction and no in-flight requests, got %d evictions, %d callbacks and inflight %d", st.Evicted, evicted.Load(), st.Inflight)
+	}
+}
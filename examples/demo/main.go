@@ -45,11 +45,7 @@ func main() {
 	mux.Handle("/api/", s.Middleware(http.HandlerFunc(apiHandler)))
 
 	// Status endpoint (no shedding)
-	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintf(w, "inflight: %d\n", s.Inflight())
-		fmt.Fprintf(w, "overloaded: %v\n", s.IsOverloaded())
-		fmt.Fprintf(w, "soft_overloaded: %v\n", s.IsSoftOverloaded())
-	})
+	mux.Handle("/status", s.StatusHandler())
 
 	addr := fmt.Sprintf(":%d", *port)
 	log.Printf("Starting server on %s (hardLimit=%d, softLimit=%d)",
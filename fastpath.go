@@ -0,0 +1,15 @@
+package shedder
+
+// fastPath reports whether a request arriving with current in-flight
+// requests is far enough below the limits to take the FastPathBelow fast
+// path.
+func (s *Shedder) fastPath(current int64) bool {
+	if s.fastPathBelow <= 0 {
+		return false
+	}
+	limit := s.currentSoftLimit()
+	if limit <= 0 {
+		limit = s.currentLimit()
+	}
+	return float64(current) < s.fastPathBelow*float64(limit)
+}
@@ -0,0 +1,53 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestShedder_FastPath(t *testing.T) {
+	algorithm := &halvingLimit{}
+	algorithm.limit.Store(10)
+	s := New(Config{
+		HardLimit:      10,
+		SoftLimit:      8,
+		FastPathBelow:  0.5,
+		LimitAlgorithm: algorithm,
+		ShedDeciderV2:  func(*http.Request, LoadInfo) Decision { return DecisionShed },
+	})
+	h := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		return rec
+	}
+
+	// 3 in flight with this one: below half the soft limit.
+	s.increment(2)
+	if rec := serve(); rec.Code != http.StatusOK {
+		t.Errorf("expected the fast path to skip ShedDeciderV2, got %d", rec.Code)
+	}
+	if n := algorithm.samples.Load(); n != 0 {
+		t.Errorf("expected no latency samples on the fast path, got %d", n)
+	}
+	if st := s.Stats(); st.PeakInflight != 0 {
+		t.Errorf("expected no peak recorded on the fast path, got %d", st.PeakInflight)
+	}
+
+	s.increment(1)
+	if rec := serve(); rec.Code != http.StatusServiceUnavailable || rec.Header().Get("X-Shed-Reason") != "soft_limit" {
+		t.Errorf("expected ShedDeciderV2 consulted at half the soft limit, got %d %q", rec.Code, rec.Header().Get("X-Shed-Reason"))
+	}
+	s.decrement(3)
+
+	s = New(Config{HardLimit: 10, FastPathBelow: 0.5, OverloadSignal: SignalFunc(func() bool { return true })})
+	if !s.fastPath(4) || s.fastPath(5) {
+		t.Error("expected the fast path below half the hard limit without a soft limit")
+	}
+	rec := httptest.NewRecorder()
+	s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("X-Shed-Reason") != "overload_signal" {
+		t.Errorf("expected OverloadSignal checked on the fast path, got %d %q", rec.Code, rec.Header().Get("X-Shed-Reason"))
+	}
+}
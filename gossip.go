@@ -0,0 +1,233 @@
+package shedder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// GossipOptions configures Gossip.
+type GossipOptions struct {
+	// Listen is the UDP address summaries are received on. Defaults to
+	// ":7946".
+	Listen string
+
+	// Peers returns the addresses of the pods to send summaries to, e.g.
+	// GossipDNS resolving a headless Service. Addresses without a port use
+	// Listen's. Required.
+	Peers func(ctx context.Context) ([]string, error)
+
+	// PodName identifies the pod's summaries, so it ignores its own.
+	// Defaults to the POD_NAME environment variable, else the hostname.
+	PodName string
+
+	// Interval is how often the pod sends its summary and resolves Peers.
+	// Summaries not refreshed within three intervals are forgotten.
+	// Defaults to 1s.
+	Interval time.Duration
+
+	// SoftAbove enters soft overload while the fleet's utilization, the
+	// mean of the pods' in-flight requests over their hard limits, is at
+	// least this fraction. Defaults to 0.8.
+	SoftAbove float64
+
+	// OnError, if set, is called when Peers fails or a summary cannot be
+	// sent.
+	OnError func(err error)
+}
+
+// GossipDNS returns a GossipOptions.Peers resolving host, e.g. the
+// headless Service "checkout-gossip.prod.svc.cluster.local". Set the
+// Service's publishNotReadyAddresses, or saturated pods drop out of the
+// fleet's utilization when they turn unready.
+func GossipDNS(host string) func(ctx context.Context) ([]string, error) {
+	return func(ctx context.Context) ([]string, error) {
+		return net.DefaultResolver.LookupHost(ctx, host)
+	}
+}
+
+// loadSummary is the datagram the pods exchange.
+type loadSummary struct {
+	Pod       string `json:"pod"`
+	Inflight  int64  `json:"inflight"`
+	HardLimit int64  `json:"hard_limit"`
+}
+
+// utilization returns the summary's in-flight requests over its limit.
+func (ls loadSummary) utilization() float64 {
+	if ls.HardLimit <= 0 {
+		return 0
+	}
+	return float64(ls.Inflight) / float64(ls.HardLimit)
+}
+
+// gossip holds the fleet's utilization computed from the summaries the
+// pod received, for the middleware to read without locking.
+type gossip struct {
+	softAbove float64
+
+	utilization atomic.Uint64 // float64 bits
+	peers       atomic.Int64
+
+	mu       sync.Mutex
+	received map[string]receivedSummary // by pod
+}
+
+// receivedSummary is a summary and when it arrived.
+type receivedSummary struct {
+	loadSummary
+	at time.Time
+}
+
+// overloaded reports whether the pod has peers and the fleet is at
+// least softAbove utilized.
+func (g *gossip) overloaded() bool {
+	return g.peers.Load() > 0 && math.Float64frombits(g.utilization.Load()) >= g.softAbove
+}
+
+// receive records a summary arriving at now.
+func (g *gossip) receive(ls loadSummary, now time.Time) {
+	g.mu.Lock()
+	g.received[ls.Pod] = receivedSummary{ls, now}
+	g.mu.Unlock()
+}
+
+// update forgets summaries older than maxAge and recomputes the fleet's
+// utilization from the rest and the pod's own summary.
+func (g *gossip) update(self loadSummary, now time.Time, maxAge time.Duration) {
+	g.mu.Lock()
+	total, n := self.utilization(), 1
+	for pod, rs := range g.received {
+		if now.Sub(rs.at) > maxAge {
+			delete(g.received, pod)
+			continue
+		}
+		total += rs.utilization()
+		n++
+	}
+	g.mu.Unlock()
+	g.utilization.Store(math.Float64bits(total / float64(n)))
+	g.peers.Store(int64(n - 1))
+}
+
+// Gossip exchanges load summaries over UDP with the other pods of the
+// Deployment, until ctx is done, so each pod can factor the fleet's
+// saturation into its shedding: while the fleet's utilization is at least
+// SoftAbove and it has heard from a peer, the pod is soft overloaded and
+// sheds as configured for its soft limit (ShedDecider or ShedHeader),
+// since traffic it sheds would only land on pods as loaded as itself.
+// Each pod sends a summary of a few dozen bytes per Interval to every
+// peer.
+//
+// Summaries are unauthenticated UDP datagrams: anything that can reach
+// Listen can forge them and make the fleet shed. Keep gossip on a trusted
+// network and close the port to other workloads with a NetworkPolicy.
+//
+// It returns an error if Listen cannot be bound.
+func (s *Shedder) Gossip(ctx context.Context, opts GossipOptions) error {
+	if opts.Peers == nil {
+		return errors.New("shedder: GossipOptions.Peers is required")
+	}
+	if opts.Listen == "" {
+		opts.Listen = ":7946"
+	}
+	conn, err := net.ListenPacket("udp", opts.Listen)
+	if err != nil {
+		return err
+	}
+	s.runGossip(ctx, conn, opts)
+	return nil
+}
+
+// runGossip implements Gossip over conn, which it closes when ctx is done.
+func (s *Shedder) runGossip(ctx context.Context, conn net.PacketConn, opts GossipOptions) {
+	if opts.PodName == "" {
+		opts.PodName = podName()
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	if opts.SoftAbove <= 0 {
+		opts.SoftAbove = 0.8
+	}
+	_, port, _ := net.SplitHostPort(conn.LocalAddr().String())
+	report := func(err error) {
+		if err != nil && opts.OnError != nil {
+			opts.OnError(err)
+		}
+	}
+
+	g := &gossip{softAbove: opts.SoftAbove, received: make(map[string]receivedSummary)}
+	s.gossip.Store(g)
+
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return // closed
+			}
+			var ls loadSummary
+			if json.Unmarshal(buf[:n], &ls) != nil || ls.Pod == "" || ls.Pod == opts.PodName {
+				continue
+			}
+			g.receive(ls, time.Now())
+		}
+	}()
+
+	go func() {
+		defer conn.Close()
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		for {
+			self := loadSummary{Pod: opts.PodName, Inflight: s.Inflight(), HardLimit: s.currentLimit()}
+			g.update(self, time.Now(), 3*opts.Interval)
+			report(sendSummary(ctx, conn, port, self, opts.Peers))
+
+			select {
+			case <-ctx.Done():
+				s.gossip.CompareAndSwap(g, nil)
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// sendSummary sends self to every address returned by peers, using port
+// for addresses without one.
+func sendSummary(ctx context.Context, conn net.PacketConn, port string, self loadSummary, peers func(ctx context.Context) ([]string, error)) error {
+	addrs, err := peers(ctx)
+	if err != nil {
+		return err
+	}
+	msg, err := json.Marshal(self)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, addr := range addrs {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, port)
+		}
+		ua, err := net.ResolveUDPAddr("udp", addr)
+		if err == nil {
+			_, err = conn.WriteTo(msg, ua)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// gossipOverloaded reports whether Gossip found the fleet saturated.
+func (s *Shedder) gossipOverloaded() bool {
+	g := s.gossip.Load()
+	return g != nil && g.overloaded()
+}
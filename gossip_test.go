@@ -0,0 +1,66 @@
+package shedder
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestShedder_Gossip(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var pods [2]*Shedder
+	var conns [2]net.PacketConn
+	for i := range pods {
+		pods[i] = New(Config{HardLimit: 10, ShedDecider: func(r *http.Request) bool { return true }})
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns[i] = conn
+	}
+	for i, s := range pods {
+		peer := conns[1-i].LocalAddr().String()
+		s.runGossip(ctx, conns[i], GossipOptions{
+			PodName:  []string{"a", "b"}[i],
+			Interval: 5 * time.Millisecond,
+			Peers:    func(context.Context) ([]string, error) { return []string{peer}, nil },
+		})
+	}
+
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s: %+v", what, pods[0].Stats())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// The pod alone at 90% is not overloaded without a saturated fleet.
+	pods[0].increment(9)
+	waitFor("a peer", func() bool { return pods[0].Stats().GossipPeers == 1 })
+	if pods[0].IsSoftOverloaded() {
+		t.Errorf("expected no soft overload with an idle peer, got %+v", pods[0].Stats())
+	}
+
+	pods[1].increment(8)
+	waitFor("soft overload", pods[0].IsSoftOverloaded)
+	rec := httptest.NewRecorder()
+	pods[0].Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("X-Shed-Reason") != "soft_limit" {
+		t.Errorf("expected soft shedding with the fleet saturated, got %d %q", rec.Code, rec.Header().Get("X-Shed-Reason"))
+	}
+
+	pods[1].decrement(8)
+	waitFor("the fleet to recover", func() bool { return !pods[0].IsSoftOverloaded() })
+
+	cancel()
+	waitFor("the peer to be forgotten", func() bool { return pods[0].Stats().GossipPeers == 0 })
+}
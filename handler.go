@@ -1,8 +1,11 @@
 package shedder
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 )
 
 // ReadyHandler returns an http.Handler that implements a Kubernetes
@@ -10,30 +13,122 @@ import (
 //
 // Returns:
 //   - 200 OK when in-flight requests <= HardLimit
-//   - 503 Service Unavailable when in-flight requests > HardLimit
+//   - 503 Service Unavailable when in-flight requests > HardLimit or
+//     Config.OverloadSignal reports overload (subject to the
+//     Config.Readiness thresholds and hold durations), or the shedder is
+//     draining
+//
+// Overload does not make the pod unready while WatchPeers finds it one of
+// the last ready pods of its Service.
 func (s *Shedder) ReadyHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		inflight := s.Inflight()
+		limit := s.currentLimit()
 
-		if inflight > s.hardLimit {
-			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := s.checkReady(inflight, limit); err != nil {
 			w.WriteHeader(http.StatusServiceUnavailable)
-			fmt.Fprintf(w, "not ready: inflight=%d, hardLimit=%d", inflight, s.hardLimit)
+			fmt.Fprint(w, err)
 			return
 		}
-
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, "ready: inflight=%d, hardLimit=%d", inflight, s.hardLimit)
+		fmt.Fprintf(w, "ready: inflight=%d, hardLimit=%d", inflight, limit)
 	})
 }
 
+// ReadyChecker returns the readiness check of ReadyHandler as a function
+// returning nil when ready and the reason otherwise. Its type converts to
+// controller-runtime's healthz.Checker, so operators can register the
+// overload state with their existing health endpoints:
+//
+//	mgr.AddReadyzCheck("shedder", s.ReadyChecker())
+func (s *Shedder) ReadyChecker() func(r *http.Request) error {
+	return func(r *http.Request) error {
+		return s.checkReady(s.Inflight(), s.currentLimit())
+	}
+}
+
+// checkReady returns why the pod is not ready with inflight requests under
+// limit, or nil if it is ready.
+func (s *Shedder) checkReady(inflight, limit int64) error {
+	if s.IsDraining() {
+		return fmt.Errorf("not ready: draining, inflight=%d", inflight)
+	}
+	if overloaded, signal := s.readinessOverloaded(inflight, limit); overloaded && !s.lastStanding() {
+		if signal {
+			return fmt.Errorf("not ready: overload signal, inflight=%d, hardLimit=%d", inflight, limit)
+		}
+		return fmt.Errorf("not ready: inflight=%d, hardLimit=%d", inflight, limit)
+	}
+	return nil
+}
+
+// readinessOverloaded reports whether inflight requests under limit or the
+// OverloadSignal make the pod unready, applying Config.Readiness if
+// configured, and whether the signal fired.
+func (s *Shedder) readinessOverloaded(inflight, limit int64) (overloaded, signal bool) {
+	if s.readiness != nil {
+		signal = s.signalOverloaded()
+		return s.readiness.overloaded(inflight, limit, signal, s.now()), signal
+	}
+	if inflight > limit {
+		return true, false
+	}
+	signal = s.signalOverloaded()
+	return signal, signal
+}
+
 // ReadyHandlerFunc is a convenience function that returns the readiness
 // handler as an http.HandlerFunc.
 func (s *Shedder) ReadyHandlerFunc() http.HandlerFunc {
 	return s.ReadyHandler().ServeHTTP
 }
 
+// StatusHandler returns an http.Handler that serves the shedder's Stats as
+// JSON. It always returns 200 OK and is intended for dashboards, capacity
+// planning and incident tooling, not for probes.
+func (s *Shedder) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(s.Stats())
+	})
+}
+
+// DrainHandler returns an http.Handler for a Kubernetes preStop httpGet
+// hook: it calls StartDraining and, if timeout > 0, blocks until no
+// requests are left in flight, timeout elapses or the hook request is
+// canceled. kubelet sends SIGTERM only after the hook returns, so the
+// server keeps serving in-flight requests in the meantime.
+//
+// Returns:
+//   - 200 OK once drained, or right away if timeout is 0
+//   - 503 Service Unavailable if requests are still in flight at the
+//     timeout
+//
+// Mount it outside the shedder's middleware (and not on an ExemptPaths
+// path), or the hook request would wait for itself.
+func (s *Shedder) DrainHandler(timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.StartDraining()
+		if timeout > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			err := s.WaitForDrain(ctx)
+			cancel()
+			if err != nil {
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				fmt.Fprintf(w, "drain timed out: inflight=%d", s.Inflight())
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "draining: inflight=%d", s.Inflight())
+	})
+}
+
 // HealthHandler returns a simple health check handler that always returns 200 OK.
 // This is suitable for Kubernetes liveness probes.
 func HealthHandler() http.Handler {
@@ -1,10 +1,12 @@
 package shedder
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestReadyHandler_Returns200WhenUnderLimit(t *testing.T) {
@@ -31,9 +33,9 @@ func TestReadyHandler_Returns503WhenOverLimit(t *testing.T) {
 	s := New(Config{HardLimit: 2})
 
 	// Simulate 3 in-flight requests
-	s.increment()
-	s.increment()
-	s.increment()
+	s.increment(1)
+	s.increment(1)
+	s.increment(1)
 
 	handler := s.ReadyHandler()
 	req := httptest.NewRequest("GET", "/ready", nil)
@@ -53,8 +55,8 @@ func TestReadyHandler_ReturnsAtLimit(t *testing.T) {
 	s := New(Config{HardLimit: 2})
 
 	// At exactly hard limit
-	s.increment()
-	s.increment()
+	s.increment(1)
+	s.increment(1)
 
 	handler := s.ReadyHandler()
 	req := httptest.NewRequest("GET", "/ready", nil)
@@ -70,8 +72,8 @@ func TestReadyHandler_ReturnsAtLimit(t *testing.T) {
 
 func TestReadyHandler_ReturnsInflightInfo(t *testing.T) {
 	s := New(Config{HardLimit: 100})
-	s.increment()
-	s.increment()
+	s.increment(1)
+	s.increment(1)
 
 	handler := s.ReadyHandler()
 	req := httptest.NewRequest("GET", "/ready", nil)
@@ -138,3 +140,85 @@ func TestHealthHandler_AlwaysReturns200(t *testing.T) {
 		}
 	}
 }
+
+func TestStatusHandler_ServesJSON(t *testing.T) {
+	s := New(Config{HardLimit: 10, SoftLimit: 5})
+	s.increment(1)
+
+	rec := httptest.NewRecorder()
+	s.StatusHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/status", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", rec.Code)
+	}
+	if rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("expected application/json, got %s", rec.Header().Get("Content-Type"))
+	}
+
+	var st Stats
+	if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if st.Inflight != 1 || st.HardLimit != 10 || st.SoftLimit != 5 {
+		t.Errorf("unexpected status: %+v", st)
+	}
+}
+
+func TestDrainHandler_Immediate(t *testing.T) {
+	s := New(Config{HardLimit: 10})
+	s.increment(1)
+
+	rec := httptest.NewRecorder()
+	s.DrainHandler(0).ServeHTTP(rec, httptest.NewRequest("GET", "/drain", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", rec.Code)
+	}
+	if !s.IsDraining() {
+		t.Error("expected shedder to be draining")
+	}
+}
+
+func TestDrainHandler_WaitsForInflight(t *testing.T) {
+	s := New(Config{HardLimit: 10})
+	s.increment(1)
+	time.AfterFunc(3*drainPollInterval, func() { s.decrement(1) })
+
+	rec := httptest.NewRecorder()
+	s.DrainHandler(time.Second).ServeHTTP(rec, httptest.NewRequest("GET", "/drain", nil))
+
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "inflight=0") {
+		t.Errorf("expected 200 once drained, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDrainHandler_Timeout(t *testing.T) {
+	s := New(Config{HardLimit: 10})
+	s.increment(2)
+
+	rec := httptest.NewRecorder()
+	s.DrainHandler(3*drainPollInterval).ServeHTTP(rec, httptest.NewRequest("GET", "/drain", nil))
+
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "inflight=2") {
+		t.Errorf("expected 503 at the timeout, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestReadyChecker(t *testing.T) {
+	s := New(Config{HardLimit: 1})
+	check := s.ReadyChecker()
+	req := httptest.NewRequest("GET", "/readyz", nil)
+
+	if err := check(req); err != nil {
+		t.Errorf("expected ready, got %v", err)
+	}
+	s.increment(2)
+	if err := check(req); err == nil || !strings.Contains(err.Error(), "inflight=2") {
+		t.Errorf("expected not ready error, got %v", err)
+	}
+	s.decrement(2)
+	s.StartDraining()
+	if err := check(req); err == nil || !strings.Contains(err.Error(), "draining") {
+		t.Errorf("expected draining error, got %v", err)
+	}
+}
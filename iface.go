@@ -0,0 +1,89 @@
+package shedder
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Interface is the behavior of a Shedder that applications depend on. Accept
+// it instead of *Shedder to inject a Noop or a fake shedder in tests, or an
+// alternative implementation in unusual environments.
+type Interface interface {
+	// Middleware wraps next with load shedding.
+	Middleware(next http.Handler) http.Handler
+
+	// MiddlewareFunc returns Middleware as a function for middleware chains.
+	MiddlewareFunc() func(http.Handler) http.Handler
+
+	// ReadyHandler serves the readiness probe.
+	ReadyHandler() http.Handler
+
+	// StatusHandler serves Stats as JSON.
+	StatusHandler() http.Handler
+
+	// Inflight returns the in-flight count.
+	Inflight() int64
+
+	// IsOverloaded and IsSoftOverloaded report the overload state.
+	IsOverloaded() bool
+	IsSoftOverloaded() bool
+
+	// HardLimit and SoftLimit return the limits in effect.
+	HardLimit() int64
+	SoftLimit() int64
+
+	// Stats returns a snapshot of the shedder's state.
+	Stats() Stats
+}
+
+var _ Interface = (*Shedder)(nil)
+
+// Noop is an Interface that never sheds and is always ready, for tests and
+// for environments where shedding is disabled. Its zero value is ready to
+// use.
+type Noop struct{}
+
+var _ Interface = Noop{}
+
+// Middleware returns next unchanged.
+func (Noop) Middleware(next http.Handler) http.Handler { return next }
+
+// MiddlewareFunc returns a middleware that returns its handler unchanged.
+func (n Noop) MiddlewareFunc() func(http.Handler) http.Handler { return n.Middleware }
+
+// ReadyHandler always returns 200 OK.
+func (Noop) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "ready: shedding disabled")
+	})
+}
+
+// StatusHandler serves the zero Stats as JSON.
+func (Noop) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(Stats{})
+	})
+}
+
+// Inflight returns 0.
+func (Noop) Inflight() int64 { return 0 }
+
+// IsOverloaded returns false.
+func (Noop) IsOverloaded() bool { return false }
+
+// IsSoftOverloaded returns false.
+func (Noop) IsSoftOverloaded() bool { return false }
+
+// HardLimit returns 0, meaning no limit.
+func (Noop) HardLimit() int64 { return 0 }
+
+// SoftLimit returns 0, meaning no limit.
+func (Noop) SoftLimit() int64 { return 0 }
+
+// Stats returns the zero Stats.
+func (Noop) Stats() Stats { return Stats{} }
@@ -0,0 +1,46 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// serveThrough serves one request through sh's middleware and returns the
+// response code.
+func serveThrough(sh Interface) int {
+	rec := httptest.NewRecorder()
+	sh.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	return rec.Code
+}
+
+func TestInterface_Implementations(t *testing.T) {
+	for name, sh := range map[string]Interface{"shedder": New(Config{HardLimit: 10}), "noop": Noop{}} {
+		if code := serveThrough(sh); code != http.StatusTeapot {
+			t.Errorf("%s: expected request served, got %d", name, code)
+		}
+		rec := httptest.NewRecorder()
+		sh.ReadyHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/ready", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: expected ready, got %d", name, rec.Code)
+		}
+	}
+}
+
+func TestNoop(t *testing.T) {
+	var n Noop
+	if n.Inflight() != 0 || n.IsOverloaded() || n.IsSoftOverloaded() || n.HardLimit() != 0 || n.Stats().Admitted != 0 {
+		t.Error("expected Noop to report an idle shedder")
+	}
+	rec := httptest.NewRecorder()
+	n.StatusHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/status", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected status response: %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	next := http.NotFoundHandler()
+	if got := n.MiddlewareFunc()(next); got == nil {
+		t.Error("expected MiddlewareFunc to return the handler")
+	}
+}
@@ -0,0 +1,137 @@
+// Package fleet collects shedder Stats from the pods of a workload, for
+// the tools that report on many pods at once.
+package fleet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	shedder "github.com/sampath030/kube-shedder"
+	"github.com/sampath030/kube-shedder/internal/kube"
+)
+
+// maxConcurrency bounds the status requests Collect has in flight.
+const maxConcurrency = 16
+
+// Fetcher fetches the Stats of one pod, e.g. from its StatusHandler.
+type Fetcher func(ctx context.Context, pod kube.Pod) (shedder.Stats, error)
+
+// HTTPFetcher returns a Fetcher that GETs the StatusHandler served on
+// port and path of each pod's IP. It needs network access to the pods,
+// i.e. it runs in the cluster.
+func HTTPFetcher(client *http.Client, port int, path string) Fetcher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(ctx context.Context, pod kube.Pod) (shedder.Stats, error) {
+		u := "http://" + net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(port)) + path
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return shedder.Stats{}, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return shedder.Stats{}, err
+		}
+		defer resp.Body.Close()
+		return DecodeStats(resp.StatusCode, resp.Body)
+	}
+}
+
+// DecodeStats decodes a StatusHandler response with the given status code.
+func DecodeStats(statusCode int, body io.Reader) (shedder.Stats, error) {
+	if statusCode != http.StatusOK {
+		return shedder.Stats{}, fmt.Errorf("status endpoint returned %d", statusCode)
+	}
+	var st shedder.Stats
+	if err := json.NewDecoder(io.LimitReader(body, 1<<20)).Decode(&st); err != nil {
+		return shedder.Stats{}, fmt.Errorf("decoding status: %w", err)
+	}
+	return st, nil
+}
+
+// PodStats is the outcome of fetching one pod's Stats.
+type PodStats struct {
+	Pod   kube.Pod
+	Stats shedder.Stats
+	Err   error
+}
+
+// Collect fetches the Stats of every running pod concurrently and returns
+// them sorted by pod name. Pods that are not running are skipped.
+func Collect(ctx context.Context, pods []kube.Pod, fetch Fetcher) []PodStats {
+	var out []PodStats
+	for _, pod := range pods {
+		if pod.Running() {
+			out = append(out, PodStats{Pod: pod})
+		}
+	}
+
+	sem := make(chan struct{}, maxConcurrency)
+	var wg sync.WaitGroup
+	for i := range out {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(ps *PodStats) {
+			defer func() { <-sem; wg.Done() }()
+			ps.Stats, ps.Err = fetch(ctx, ps.Pod)
+		}(&out[i])
+	}
+	wg.Wait()
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Pod.Metadata.Name < out[j].Pod.Metadata.Name })
+	return out
+}
+
+// Summary aggregates the Stats of the pods that reported them.
+type Summary struct {
+	// Pods is the number of pods collected; Reporting of them returned
+	// Stats.
+	Pods, Reporting int
+
+	// Overloaded and SoftOverloaded count the reporting pods in each state.
+	Overloaded, SoftOverloaded int
+
+	// Inflight, HardLimit, Admitted and Shed are summed over the
+	// reporting pods.
+	Inflight, HardLimit int64
+	Admitted, Shed      int64
+}
+
+// Summarize aggregates stats.
+func Summarize(stats []PodStats) Summary {
+	sum := Summary{Pods: len(stats)}
+	for _, ps := range stats {
+		if ps.Err != nil {
+			continue
+		}
+		sum.Reporting++
+		if ps.Stats.Overloaded {
+			sum.Overloaded++
+		}
+		if ps.Stats.SoftOverloaded {
+			sum.SoftOverloaded++
+		}
+		sum.Inflight += ps.Stats.Inflight
+		sum.HardLimit += ps.Stats.HardLimit
+		sum.Admitted += ps.Stats.Admitted
+		sum.Shed += ps.Stats.Shed
+	}
+	return sum
+}
+
+// Utilization returns the fleet's in-flight requests as a fraction of its
+// summed hard limits, or 0 if no pod reported.
+func (s Summary) Utilization() float64 {
+	if s.HardLimit <= 0 {
+		return 0
+	}
+	return float64(s.Inflight) / float64(s.HardLimit)
+}
@@ -0,0 +1,76 @@
+package fleet
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	shedder "github.com/sampath030/kube-shedder"
+	"github.com/sampath030/kube-shedder/internal/kube"
+)
+
+func pod(name, ip, phase string) kube.Pod {
+	return kube.Pod{Metadata: kube.ObjectMeta{Name: name}, Status: kube.PodStatus{Phase: phase, PodIP: ip}}
+}
+
+func TestCollectAndSummarize(t *testing.T) {
+	pods := []kube.Pod{
+		pod("c", "10.0.0.3", "Running"),
+		pod("a", "10.0.0.1", "Running"),
+		pod("b", "10.0.0.2", "Running"),
+		pod("d", "", "Pending"),
+	}
+	fetch := func(ctx context.Context, p kube.Pod) (shedder.Stats, error) {
+		switch p.Metadata.Name {
+		case "a":
+			return shedder.Stats{Inflight: 30, HardLimit: 100, Admitted: 10, Shed: 1}, nil
+		case "b":
+			return shedder.Stats{Inflight: 120, HardLimit: 100, Overloaded: true, Admitted: 5, Shed: 4}, nil
+		}
+		return shedder.Stats{}, errors.New("connection refused")
+	}
+
+	stats := Collect(context.Background(), pods, fetch)
+	if len(stats) != 3 || stats[0].Pod.Metadata.Name != "a" || stats[2].Err == nil {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	sum := Summarize(stats)
+	want := Summary{Pods: 3, Reporting: 2, Overloaded: 1, Inflight: 150, HardLimit: 200, Admitted: 15, Shed: 5}
+	if sum != want {
+		t.Errorf("Summarize = %+v, want %+v", sum, want)
+	}
+	if got := sum.Utilization(); got != 0.75 {
+		t.Errorf("Utilization = %v, want 0.75", got)
+	}
+	if got := (Summary{}).Utilization(); got != 0 {
+		t.Errorf("expected 0 utilization without pods, got %v", got)
+	}
+}
+
+func TestHTTPFetcher(t *testing.T) {
+	s := shedder.New(shedder.Config{HardLimit: 42})
+	srv := httptest.NewServer(s.StatusHandler())
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+	p, _ := strconv.Atoi(port)
+
+	st, err := HTTPFetcher(nil, p, "/status")(context.Background(), pod("a", host, "Running"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.HardLimit != 42 {
+		t.Errorf("expected hard limit 42, got %d", st.HardLimit)
+	}
+
+	if _, err := DecodeStats(http.StatusNotFound, nil); err == nil {
+		t.Error("expected an error for a non-200 status")
+	}
+}
@@ -0,0 +1,475 @@
+// Package kube is a minimal Kubernetes API client for the few calls the
+// shedder and its tools make. It avoids a dependency on client-go.
+package kube
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Service account files mounted into every pod.
+const (
+	tokenFile     = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	caFile        = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	namespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// ErrNotInCluster is returned by InCluster outside a Kubernetes pod.
+var ErrNotInCluster = errors.New("kube: not running in a cluster")
+
+// Client performs authenticated requests against the API server.
+type Client struct {
+	// Host is the API server base URL, e.g. "https://10.0.0.1:443".
+	Host string
+
+	// Token returns the bearer token for each request. The in-cluster
+	// token is re-read because kubelet rotates it.
+	Token func() (string, error)
+
+	HTTPClient *http.Client
+}
+
+// InCluster returns a client using the pod's service account.
+func InCluster() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, ErrNotInCluster
+	}
+	ca, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("kube: reading CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("kube: no certificates in CA file")
+	}
+	return &Client{
+		Host: "https://" + net.JoinHostPort(host, port),
+		Token: func() (string, error) {
+			token, err := os.ReadFile(tokenFile)
+			return strings.TrimSpace(string(token)), err
+		},
+		HTTPClient: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}},
+		},
+	}, nil
+}
+
+// Namespace returns the pod's namespace from its service account.
+func Namespace() (string, error) {
+	ns, err := os.ReadFile(namespaceFile)
+	if err != nil {
+		return "", fmt.Errorf("kube: reading namespace: %w", err)
+	}
+	return strings.TrimSpace(string(ns)), nil
+}
+
+// StatusError is returned for non-2xx API responses.
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("kube: API returned %d: %s", e.StatusCode, e.Message)
+}
+
+// Get fetches path (e.g. "/api/v1/namespaces/default/configmaps/x") and
+// decodes the JSON response into out.
+func (c *Client) Get(ctx context.Context, path string, out any) error {
+	return c.Do(ctx, http.MethodGet, path, nil, out)
+}
+
+// Do sends a request with an optional JSON body and decodes the JSON
+// response into out, if non-nil.
+func (c *Client) Do(ctx context.Context, method, path string, body, out any) error {
+	return c.do(ctx, method, path, "application/json", body, out)
+}
+
+// MergePatch applies a JSON merge patch to the object at path and decodes
+// the updated object into out, if non-nil.
+func (c *Client) MergePatch(ctx context.Context, path string, patch, out any) error {
+	return c.do(ctx, http.MethodPatch, path, "application/merge-patch+json", patch, out)
+}
+
+// do sends a request with an optional body of the given content type.
+func (c *Client) do(ctx context.Context, method, path, contentType string, body, out any) error {
+	req, err := c.newRequest(ctx, method, path, contentType, body)
+	if err != nil {
+		return err
+	}
+	resp, err := send(c.httpClient(), req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// newRequest builds an authenticated request with an optional body of the
+// given content type.
+func (c *Client) newRequest(ctx context.Context, method, path, contentType string, body any) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = strings.NewReader(string(data))
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.Host+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.Token != nil {
+		token, err := c.Token()
+		if err != nil {
+			return nil, fmt.Errorf("kube: reading token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// httpClient returns the client's HTTPClient or http.DefaultClient.
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+// send sends req and returns the response, or a StatusError for a non-2xx
+// status.
+func send(httpClient *http.Client, req *http.Request) (*http.Response, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return nil, &StatusError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	return resp, nil
+}
+
+// ObjectMeta is the subset of object metadata the shedder uses.
+type ObjectMeta struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace,omitempty"`
+	UID             string            `json:"uid,omitempty"`
+	ResourceVersion string            `json:"resourceVersion,omitempty"`
+	Generation      int64             `json:"generation,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	OwnerReferences []OwnerReference  `json:"ownerReferences,omitempty"`
+}
+
+// OwnerReference makes an object garbage-collected with its owner.
+type OwnerReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	UID        string `json:"uid"`
+	Controller bool   `json:"controller,omitempty"`
+}
+
+// ConfigMap is the subset of a core/v1 ConfigMap the shedder uses.
+type ConfigMap struct {
+	APIVersion string            `json:"apiVersion,omitempty"`
+	Kind       string            `json:"kind,omitempty"`
+	Metadata   ObjectMeta        `json:"metadata"`
+	Data       map[string]string `json:"data"`
+}
+
+// GetConfigMap fetches a ConfigMap.
+func (c *Client) GetConfigMap(ctx context.Context, namespace, name string) (*ConfigMap, error) {
+	var cm ConfigMap
+	if err := c.Get(ctx, "/api/v1/namespaces/"+namespace+"/configmaps/"+name, &cm); err != nil {
+		return nil, err
+	}
+	return &cm, nil
+}
+
+// CreateConfigMap creates cm in its namespace.
+func (c *Client) CreateConfigMap(ctx context.Context, cm *ConfigMap) error {
+	cm.APIVersion, cm.Kind = "v1", "ConfigMap"
+	return c.Do(ctx, http.MethodPost, "/api/v1/namespaces/"+cm.Metadata.Namespace+"/configmaps", cm, nil)
+}
+
+// UpdateConfigMap replaces cm, which must carry the ResourceVersion it
+// was read at; a concurrent change makes it fail with 409 Conflict.
+func (c *Client) UpdateConfigMap(ctx context.Context, cm *ConfigMap) error {
+	cm.APIVersion, cm.Kind = "v1", "ConfigMap"
+	return c.Do(ctx, http.MethodPut, "/api/v1/namespaces/"+cm.Metadata.Namespace+"/configmaps/"+cm.Metadata.Name, cm, nil)
+}
+
+// ConfigMapEvent is a change to a watched ConfigMap. Type is "ADDED",
+// "MODIFIED", "DELETED" or "BOOKMARK"; a bookmark only carries the
+// ConfigMap's ResourceVersion.
+type ConfigMapEvent struct {
+	Type   string
+	Object ConfigMap
+}
+
+// WatchConfigMap watches the named ConfigMap for changes after
+// resourceVersion and calls fn with each event, until the API server ends
+// the watch after a few minutes, returning nil, or ctx is done. Resuming
+// from a resourceVersion the API server no longer has fails with a 410
+// Gone StatusError; get the ConfigMap again to continue from its current
+// version.
+func (c *Client) WatchConfigMap(ctx context.Context, namespace, name, resourceVersion string, fn func(ConfigMapEvent)) error {
+	query := url.Values{"fieldSelector": {"metadata.name=" + name}}
+	return c.watch(ctx, "/api/v1/namespaces/"+namespace+"/configmaps", query, resourceVersion, func(typ string, object []byte) error {
+		var cm ConfigMap
+		if err := json.Unmarshal(object, &cm); err != nil {
+			return err
+		}
+		fn(ConfigMapEvent{Type: typ, Object: cm})
+		return nil
+	})
+}
+
+// watch watches the collection at path, filtered by query, for changes
+// after resourceVersion and calls fn with each event's type and object, as
+// described for WatchConfigMap.
+func (c *Client) watch(ctx context.Context, path string, query url.Values, resourceVersion string, fn func(typ string, object []byte) error) error {
+	query.Set("watch", "true")
+	query.Set("resourceVersion", resourceVersion)
+	query.Set("allowWatchBookmarks", "true")
+	req, err := c.newRequest(ctx, http.MethodGet, path+"?"+query.Encode(), "", nil)
+	if err != nil {
+		return err
+	}
+	// The watch outlives the client's timeout, which bounds whole requests.
+	httpClient := c.httpClient()
+	if httpClient.Timeout > 0 {
+		streaming := *httpClient
+		streaming.Timeout = 0
+		httpClient = &streaming
+	}
+	resp, err := send(httpClient, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var event struct {
+			Type   string          `json:"type"`
+			Object json.RawMessage `json:"object"`
+		}
+		if err := dec.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if event.Type == "ERROR" {
+			var status struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}
+			if err := json.Unmarshal(event.Object, &status); err != nil {
+				return err
+			}
+			return &StatusError{StatusCode: status.Code, Message: status.Message}
+		}
+		if err := fn(event.Type, event.Object); err != nil {
+			return err
+		}
+	}
+}
+
+// Pod is the subset of a core/v1 Pod the shedder uses.
+type Pod struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Status   PodStatus  `json:"status"`
+}
+
+// PodStatus is the subset of a Pod's status the shedder uses.
+type PodStatus struct {
+	Phase      string         `json:"phase"`
+	PodIP      string         `json:"podIP,omitempty"`
+	Conditions []PodCondition `json:"conditions,omitempty"`
+}
+
+// PodCondition is a condition of a Pod, e.g. Ready.
+type PodCondition struct {
+	Type   string `json:"type"`
+	Status string `json:"status"`
+}
+
+// Running reports whether the pod is running and has an IP.
+func (p *Pod) Running() bool {
+	return p.Status.Phase == "Running" && p.Status.PodIP != ""
+}
+
+// Ready reports whether the pod's Ready condition is true.
+func (p *Pod) Ready() bool {
+	for _, c := range p.Status.Conditions {
+		if c.Type == "Ready" {
+			return c.Status == "True"
+		}
+	}
+	return false
+}
+
+// PodList is a list of Pods.
+type PodList struct {
+	Items []Pod `json:"items"`
+}
+
+// ListPods lists the pods in namespace matching the label selector, e.g.
+// "app=checkout"; an empty selector matches all pods.
+func (c *Client) ListPods(ctx context.Context, namespace, selector string) ([]Pod, error) {
+	path := "/api/v1/namespaces/" + namespace + "/pods"
+	if selector != "" {
+		path += "?labelSelector=" + url.QueryEscape(selector)
+	}
+	var list PodList
+	if err := c.Get(ctx, path, &list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// EndpointSlice is the subset of a discovery/v1 EndpointSlice the shedder
+// uses.
+type EndpointSlice struct {
+	Metadata  ObjectMeta `json:"metadata"`
+	Endpoints []Endpoint `json:"endpoints"`
+}
+
+// Endpoint is one backend of an EndpointSlice.
+type Endpoint struct {
+	Addresses  []string           `json:"addresses"`
+	Conditions EndpointConditions `json:"conditions"`
+	TargetRef  *ObjectReference   `json:"targetRef,omitempty"`
+}
+
+// EndpointConditions are the conditions of an Endpoint.
+type EndpointConditions struct {
+	// Ready is nil if unknown, which consumers treat as ready.
+	Ready *bool `json:"ready,omitempty"`
+}
+
+// IsReady reports whether the endpoint is ready.
+func (c EndpointConditions) IsReady() bool {
+	return c.Ready == nil || *c.Ready
+}
+
+// ObjectReference refers to another object, e.g. an endpoint's Pod.
+type ObjectReference struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// ListMeta is the metadata of a list.
+type ListMeta struct {
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+// EndpointSliceList is a list of EndpointSlices.
+type EndpointSliceList struct {
+	Metadata ListMeta        `json:"metadata"`
+	Items    []EndpointSlice `json:"items"`
+}
+
+// ListEndpointSlices lists the EndpointSlices of the Service in namespace.
+// The list's ResourceVersion is where WatchEndpointSlices continues from.
+func (c *Client) ListEndpointSlices(ctx context.Context, namespace, service string) (*EndpointSliceList, error) {
+	path := "/apis/discovery.k8s.io/v1/namespaces/" + namespace + "/endpointslices?labelSelector=" +
+		url.QueryEscape(serviceNameLabel+"="+service)
+	var list EndpointSliceList
+	if err := c.Get(ctx, path, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// EndpointSliceEvent is a change to a watched EndpointSlice, typed like a
+// ConfigMapEvent.
+type EndpointSliceEvent struct {
+	Type   string
+	Object EndpointSlice
+}
+
+// WatchEndpointSlices watches the EndpointSlices of the Service in
+// namespace for changes after resourceVersion, as WatchConfigMap does for
+// a ConfigMap.
+func (c *Client) WatchEndpointSlices(ctx context.Context, namespace, service, resourceVersion string, fn func(EndpointSliceEvent)) error {
+	query := url.Values{"labelSelector": {serviceNameLabel + "=" + service}}
+	return c.watch(ctx, "/apis/discovery.k8s.io/v1/namespaces/"+namespace+"/endpointslices", query, resourceVersion, func(typ string, object []byte) error {
+		var slice EndpointSlice
+		if err := json.Unmarshal(object, &slice); err != nil {
+			return err
+		}
+		fn(EndpointSliceEvent{Type: typ, Object: slice})
+		return nil
+	})
+}
+
+// serviceNameLabel links an EndpointSlice to its Service.
+const serviceNameLabel = "kubernetes.io/service-name"
+
+// Node is the subset of a Node the shedder uses.
+type Node struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Status   NodeStatus `json:"status"`
+}
+
+// NodeStatus is the subset of a Node's status the shedder uses.
+type NodeStatus struct {
+	Conditions []NodeCondition `json:"conditions,omitempty"`
+}
+
+// NodeCondition is a condition of a Node, e.g. MemoryPressure.
+type NodeCondition struct {
+	Type   string `json:"type"`
+	Status string `json:"status"`
+}
+
+// Condition reports whether the node's condition of type t is true.
+func (n *Node) Condition(t string) bool {
+	for _, c := range n.Status.Conditions {
+		if c.Type == t {
+			return c.Status == "True"
+		}
+	}
+	return false
+}
+
+// GetNode returns the named Node.
+func (c *Client) GetNode(ctx context.Context, name string) (*Node, error) {
+	var node Node
+	if err := c.Get(ctx, "/api/v1/nodes/"+name, &node); err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
@@ -0,0 +1,161 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient_GetConfigMap(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("expected bearer token, got %q", got)
+		}
+		if r.URL.Path != "/api/v1/namespaces/prod/configmaps/shedder" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"metadata":{"name":"shedder","resourceVersion":"7"},"data":{"config.json":"{}"}}`))
+	}))
+	defer srv.Close()
+
+	c := &Client{Host: srv.URL, Token: func() (string, error) { return "secret", nil }}
+	cm, err := c.GetConfigMap(context.Background(), "prod", "shedder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cm.Metadata.ResourceVersion != "7" || cm.Data["config.json"] != "{}" {
+		t.Errorf("unexpected ConfigMap: %+v", cm)
+	}
+
+	_, err = c.GetConfigMap(context.Background(), "prod", "missing")
+	var se *StatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 StatusError, got %v", err)
+	}
+}
+
+func TestClient_WatchConfigMap(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/api/v1/namespaces/prod/configmaps" || q.Get("watch") != "true" ||
+			q.Get("fieldSelector") != "metadata.name=shedder" {
+			http.NotFound(w, r)
+			return
+		}
+		if q.Get("resourceVersion") != "7" {
+			return
+		}
+		w.Write([]byte(`{"type":"MODIFIED","object":{"metadata":{"name":"shedder","resourceVersion":"8"},"data":{"config.json":"{}"}}}
+{"type":"BOOKMARK","object":{"metadata":{"resourceVersion":"9"}}}
+{"type":"ERROR","object":{"kind":"Status","code":410,"message":"too old resource version"}}
+`))
+	}))
+	defer srv.Close()
+
+	c := &Client{Host: srv.URL, HTTPClient: &http.Client{Timeout: time.Second}}
+	var events []ConfigMapEvent
+	err := c.WatchConfigMap(context.Background(), "prod", "shedder", "7", func(ev ConfigMapEvent) {
+		events = append(events, ev)
+	})
+	var se *StatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusGone {
+		t.Errorf("expected 410 StatusError, got %v", err)
+	}
+	if len(events) != 2 || events[0].Type != "MODIFIED" || events[0].Object.Data["config.json"] != "{}" ||
+		events[1].Type != "BOOKMARK" || events[1].Object.Metadata.ResourceVersion != "9" {
+		t.Errorf("unexpected events: %+v", events)
+	}
+
+	// A watch the API server closes ends without an error.
+	err = c.WatchConfigMap(context.Background(), "prod", "shedder", "9", func(ConfigMapEvent) {})
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestInCluster_NotInCluster(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	if _, err := InCluster(); !errors.Is(err, ErrNotInCluster) {
+		t.Errorf("expected ErrNotInCluster, got %v", err)
+	}
+}
+
+func TestClient_ListPods(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/prod/pods" || r.URL.Query().Get("labelSelector") != "app=checkout,tier in (web)" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"items":[
+			{"metadata":{"name":"a"},"status":{"phase":"Running","podIP":"10.0.0.1","conditions":[{"type":"Ready","status":"True"}]}},
+			{"metadata":{"name":"b"},"status":{"phase":"Pending"}}]}`))
+	}))
+	defer srv.Close()
+
+	c := &Client{Host: srv.URL}
+	pods, err := c.ListPods(context.Background(), "prod", "app=checkout,tier in (web)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 2 || !pods[0].Running() || !pods[0].Ready() || pods[1].Running() || pods[1].Ready() {
+		t.Errorf("unexpected pods: %+v", pods)
+	}
+}
+
+func TestClient_ListEndpointSlices(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apis/discovery.k8s.io/v1/namespaces/prod/endpointslices" ||
+			r.URL.Query().Get("labelSelector") != "kubernetes.io/service-name=checkout" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"metadata":{"resourceVersion":"12"},"items":[{"metadata":{"name":"checkout-abc"},"endpoints":[
+			{"addresses":["10.0.0.1"],"conditions":{"ready":true},"targetRef":{"kind":"Pod","name":"a"}},
+			{"addresses":["10.0.0.2"],"conditions":{"ready":false},"targetRef":{"kind":"Pod","name":"b"}},
+			{"addresses":["10.0.0.3"],"conditions":{}}]}]}`))
+	}))
+	defer srv.Close()
+
+	list, err := (&Client{Host: srv.URL}).ListEndpointSlices(context.Background(), "prod", "checkout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Metadata.ResourceVersion != "12" {
+		t.Errorf("expected list version 12, got %q", list.Metadata.ResourceVersion)
+	}
+	eps := list.Items[0].Endpoints
+	if len(eps) != 3 || !eps[0].Conditions.IsReady() || eps[1].Conditions.IsReady() || !eps[2].Conditions.IsReady() || eps[0].TargetRef.Name != "a" {
+		t.Errorf("unexpected endpoints: %+v", eps)
+	}
+}
+
+func TestClient_WatchEndpointSlices(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/apis/discovery.k8s.io/v1/namespaces/prod/endpointslices" || q.Get("watch") != "true" ||
+			q.Get("labelSelector") != "kubernetes.io/service-name=checkout" || q.Get("resourceVersion") != "12" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"type":"ADDED","object":{"metadata":{"name":"checkout-abc","resourceVersion":"13"},"endpoints":[{"addresses":["10.0.0.1"]}]}}
+{"type":"DELETED","object":{"metadata":{"name":"checkout-abc","resourceVersion":"14"}}}
+`))
+	}))
+	defer srv.Close()
+
+	var events []EndpointSliceEvent
+	err := (&Client{Host: srv.URL}).WatchEndpointSlices(context.Background(), "prod", "checkout", "12", func(ev EndpointSliceEvent) {
+		events = append(events, ev)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Type != "ADDED" || len(events[0].Object.Endpoints) != 1 ||
+		events[1].Type != "DELETED" || events[1].Object.Metadata.Name != "checkout-abc" {
+		t.Errorf("unexpected events: %+v", events)
+	}
+}
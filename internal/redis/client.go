@@ -0,0 +1,263 @@
+// Package redis is a minimal Redis client for the few commands the
+// shedder's shared concurrency budget sends. It speaks RESP2 over a small
+// connection pool and avoids a dependency on a full client library.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Error is an error reply from the server, e.g. "NOSCRIPT No matching
+// script".
+type Error string
+
+func (e Error) Error() string { return "redis: " + string(e) }
+
+// Prefix returns the error's code, e.g. "NOSCRIPT".
+func (e Error) Prefix() string {
+	code, _, _ := strings.Cut(string(e), " ")
+	return code
+}
+
+// ErrClosed is returned by Do after Close.
+var ErrClosed = errors.New("redis: client closed")
+
+// Options configures a Client.
+type Options struct {
+	// Addr is the server's host:port.
+	Addr string
+
+	// Username and Password authenticate each connection with AUTH, if
+	// Password is set. Username requires Redis 6 ACLs.
+	Username string
+	Password string
+
+	// DB is selected on each connection if non-zero.
+	DB int
+
+	// TLSConfig, if set, makes connections use TLS.
+	TLSConfig *tls.Config
+
+	// PoolSize is the number of idle connections kept. Defaults to 16.
+	PoolSize int
+
+	// DialTimeout bounds connecting and authenticating when the context
+	// has no earlier deadline. Defaults to 1s.
+	DialTimeout time.Duration
+}
+
+// Client sends commands over pooled connections. It is safe for
+// concurrent use.
+type Client struct {
+	opts Options
+
+	mu     sync.Mutex
+	idle   []*conn
+	closed bool
+}
+
+// conn is one connection to the server.
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// New returns a client for opts. Connections are made on demand.
+func New(opts Options) *Client {
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = 16
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = time.Second
+	}
+	return &Client{opts: opts}
+}
+
+// Do sends the command args and returns its reply: a string for simple
+// and bulk strings, an int64 for integers, a []any for arrays and nil for
+// null replies. Error replies are returned as Error.
+func (c *Client) Do(ctx context.Context, args ...string) (any, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := cn.do(ctx, args)
+	var rerr Error
+	if err != nil && !errors.As(err, &rerr) {
+		// The connection's state is unknown after an I/O error.
+		cn.Close()
+		return nil, err
+	}
+	c.put(cn)
+	return reply, err
+}
+
+// Close closes the idle connections. Connections in use are closed when
+// their command completes.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	idle := c.idle
+	c.idle, c.closed = nil, true
+	c.mu.Unlock()
+	for _, cn := range idle {
+		cn.Close()
+	}
+	return nil
+}
+
+// get returns an idle connection or dials a new one.
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if n := len(c.idle); n > 0 {
+		cn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return cn, nil
+	}
+	c.mu.Unlock()
+	return c.dial(ctx)
+}
+
+// put returns cn to the pool, or closes it if the pool is full.
+func (c *Client) put(cn *conn) {
+	c.mu.Lock()
+	if !c.closed && len(c.idle) < c.opts.PoolSize {
+		c.idle = append(c.idle, cn)
+		cn = nil
+	}
+	c.mu.Unlock()
+	if cn != nil {
+		cn.Close()
+	}
+}
+
+// dial connects and authenticates a new connection.
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.DialTimeout)
+	defer cancel()
+
+	var nc net.Conn
+	var err error
+	if c.opts.TLSConfig != nil {
+		d := &tls.Dialer{Config: c.opts.TLSConfig}
+		nc, err = d.DialContext(ctx, "tcp", c.opts.Addr)
+	} else {
+		var d net.Dialer
+		nc, err = d.DialContext(ctx, "tcp", c.opts.Addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+
+	var setup [][]string
+	if c.opts.Password != "" {
+		if c.opts.Username != "" {
+			setup = append(setup, []string{"AUTH", c.opts.Username, c.opts.Password})
+		} else {
+			setup = append(setup, []string{"AUTH", c.opts.Password})
+		}
+	}
+	if c.opts.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.opts.DB)})
+	}
+	for _, args := range setup {
+		if _, err := cn.do(ctx, args); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+// do writes args as a command and reads its reply.
+func (cn *conn) do(ctx context.Context, args []string) (any, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		cn.SetDeadline(deadline)
+	} else {
+		cn.SetDeadline(time.Time{})
+	}
+	fmt.Fprintf(cn.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(cn.w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := cn.w.Flush(); err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	return readReply(cn.r)
+}
+
+// readReply reads one RESP2 reply.
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, Error(body)
+	case ':':
+		n, err := strconv.ParseInt(body, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed integer %q", body)
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed bulk length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed array length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			// Errors inside arrays, e.g. from EXEC, are returned as items.
+			item, err := readReply(r)
+			var rerr Error
+			if errors.As(err, &rerr) {
+				item = rerr
+			} else if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply type %q", kind)
+	}
+}
@@ -0,0 +1,108 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeServer answers each command with reply(args), written verbatim.
+func fakeServer(t *testing.T, reply func(args []string) string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer nc.Close()
+				r := bufio.NewReader(nc)
+				for {
+					cmd, err := readReply(r)
+					if err != nil {
+						return
+					}
+					var args []string
+					for _, arg := range cmd.([]any) {
+						args = append(args, arg.(string))
+					}
+					nc.Write([]byte(reply(args)))
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestClient_Do(t *testing.T) {
+	var authed atomic.Bool
+	addr := fakeServer(t, func(args []string) string {
+		switch args[0] {
+		case "AUTH":
+			authed.Store(args[1] == "app" && args[2] == "secret")
+			return "+OK\r\n"
+		case "SELECT":
+			return "+OK\r\n"
+		case "ECHO":
+			return "$" + strconv.Itoa(len(args[1])) + "\r\n" + args[1] + "\r\n"
+		case "INCR":
+			return ":42\r\n"
+		case "GET":
+			return "$-1\r\n"
+		case "MULTI":
+			return "*3\r\n:1\r\n$2\r\nhi\r\n-ERR oops\r\n"
+		default:
+			return "-ERR unknown command\r\n"
+		}
+	})
+
+	c := New(Options{Addr: addr, Username: "app", Password: "secret", DB: 2})
+	defer c.Close()
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		args []string
+		want any
+	}{
+		{[]string{"ECHO", "hello"}, "hello"},
+		{[]string{"INCR", "n"}, int64(42)},
+		{[]string{"GET", "missing"}, nil},
+		{[]string{"MULTI"}, []any{int64(1), "hi", Error("ERR oops")}},
+	} {
+		got, err := c.Do(ctx, tt.args...)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: expected %#v, got %#v", tt.args, tt.want, got)
+		}
+	}
+	if !authed.Load() {
+		t.Error("expected the connection authenticated with the username and password")
+	}
+
+	_, err := c.Do(ctx, "BOGUS")
+	var rerr Error
+	if !errors.As(err, &rerr) || rerr.Prefix() != "ERR" {
+		t.Errorf("expected an ERR reply, got %v", err)
+	}
+	if got, err := c.Do(ctx, "INCR", "n"); err != nil || got != int64(42) {
+		t.Errorf("expected the connection reusable after an error reply, got %v, %v", got, err)
+	}
+
+	c.Close()
+	if _, err := c.Do(ctx, "INCR", "n"); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
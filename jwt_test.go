@@ -0,0 +1,118 @@
+package shedder
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testJWT returns an unsigned token carrying payload.
+func testJWT(payload string) string {
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"none"}`)) + "." + enc.EncodeToString([]byte(payload)) + ".sig"
+}
+
+func TestBearerClaims(t *testing.T) {
+	tests := []struct {
+		name   string
+		auth   string
+		claim  string
+		want   string
+		wantOK bool
+	}{
+		{"string claim", "Bearer " + testJWT(`{"tenant":"acme"}`), "tenant", "acme", true},
+		{"number claim", "bearer " + testJWT(`{"org":42}`), "org", "42", true},
+		{"missing claim", "Bearer " + testJWT(`{}`), "tenant", "", true},
+		{"no header", "", "tenant", "", false},
+		{"basic auth", "Basic dXNlcjpwYXNz", "tenant", "", false},
+		{"not a jwt", "Bearer opaque-token", "tenant", "", false},
+		{"bad payload", "Bearer a.!!!.c", "tenant", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			claims, ok := bearerClaims(r, UnverifiedClaims)
+			if ok != tt.wantOK {
+				t.Fatalf("expected ok=%v, got %v", tt.wantOK, ok)
+			}
+			if got := claimString(claims, tt.claim); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// rejectAll is a ClaimsParser that fails every token.
+func rejectAll(token string) (map[string]any, error) {
+	return nil, errors.New("invalid signature")
+}
+
+func TestClassifyClaim(t *testing.T) {
+	classes := map[string]string{"enterprise": "critical", "free": "best-effort"}
+	request := func(payload string) *http.Request {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", "Bearer "+testJWT(payload))
+		return r
+	}
+
+	classify := ClassifyClaim(UnverifiedClaims, "tier", classes, "standard")
+	tests := []struct {
+		payload string
+		want    string
+	}{
+		{`{"tier":"enterprise"}`, "critical"},
+		{`{"tier":"free"}`, "best-effort"},
+		{`{"tier":"pro"}`, "standard"},
+		{`{}`, "standard"},
+	}
+	for _, tt := range tests {
+		if got := classify(request(tt.payload)); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.payload, tt.want, got)
+		}
+	}
+
+	verified := ClassifyClaim(rejectAll, "tier", classes, "standard")
+	if got := verified(request(`{"tier":"enterprise"}`)); got != "standard" {
+		t.Errorf("expected unverifiable token to get the default, got %q", got)
+	}
+}
+
+func TestShedClaim(t *testing.T) {
+	decide := ShedClaim(UnverifiedClaims, "tier", "free", "")
+
+	paid := httptest.NewRequest("GET", "/", nil)
+	paid.Header.Set("Authorization", "Bearer "+testJWT(`{"tier":"pro"}`))
+	if decide(paid) {
+		t.Error("expected paid tier to be kept")
+	}
+	free := httptest.NewRequest("GET", "/", nil)
+	free.Header.Set("Authorization", "Bearer "+testJWT(`{"tier":"free"}`))
+	if !decide(free) {
+		t.Error("expected free tier to be shed")
+	}
+	if !decide(httptest.NewRequest("GET", "/", nil)) {
+		t.Error("expected anonymous request to be shed")
+	}
+}
+
+func TestClaims_RequireParser(t *testing.T) {
+	for name, build := range map[string]func(){
+		"ShedClaim":     func() { ShedClaim(nil, "tier", "free") },
+		"ClassifyClaim": func() { ClassifyClaim(nil, "tier", nil, "standard") },
+		"TenantClaim":   func() { TenantClaim(nil, "org") },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic without a ClaimsParser", name)
+				}
+			}()
+			build()
+		}()
+	}
+}
@@ -0,0 +1,91 @@
+package shedder
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/sampath030/kube-shedder/internal/kube"
+)
+
+// kubeWatch keeps a Kubernetes watch running until ctx is done, relisting
+// when the watch's version expires or the watch fails.
+type kubeWatch struct {
+	// list reads the current state and records the version to watch
+	// from. It reports its own errors.
+	list func(ctx context.Context)
+
+	// watch applies changes after the recorded version until the watch
+	// ends, recording the version of each.
+	watch func(ctx context.Context) error
+
+	// report is called with the error of each failed watch.
+	report func(err error)
+
+	// retry is the delay before relisting after a failed watch.
+	retry time.Duration
+
+	// resync, if positive, relists this often in case the watch missed a
+	// change.
+	resync time.Duration
+
+	// signals, if non-nil, relists whenever a signal arrives.
+	signals <-chan os.Signal
+}
+
+// run watches until ctx is done.
+func (kw *kubeWatch) run(ctx context.Context) {
+	var resync <-chan time.Time
+	if kw.resync > 0 {
+		ticker := time.NewTicker(kw.resync)
+		defer ticker.Stop()
+		resync = ticker.C
+	}
+	for {
+		relist, err := kw.watchUntil(ctx, resync)
+		if ctx.Err() != nil {
+			return
+		}
+		var se *kube.StatusError
+		switch {
+		case relist:
+		case err == nil:
+			// The API server ended the watch; resume it.
+			continue
+		case errors.As(err, &se) && se.StatusCode == http.StatusGone:
+			// The version expired; relist without waiting.
+		default:
+			kw.report(err)
+			timer := time.NewTimer(kw.retry)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			case <-kw.signals:
+				timer.Stop()
+			}
+		}
+		kw.list(ctx)
+	}
+}
+
+// watchUntil watches until the watch ends, returning its error, or a
+// relist is due.
+func (kw *kubeWatch) watchUntil(ctx context.Context, resync <-chan time.Time) (relist bool, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- kw.watch(ctx) }()
+	select {
+	case err := <-done:
+		return false, err
+	case <-kw.signals:
+	case <-resync:
+	}
+	cancel()
+	<-done
+	return true, nil
+}
@@ -0,0 +1,38 @@
+package shedder
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestKubeWatch_Resync(t *testing.T) {
+	lists := make(chan struct{}, 10)
+	kw := &kubeWatch{
+		list: func(context.Context) { lists <- struct{}{} },
+		watch: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		report: func(err error) { t.Errorf("unexpected error %v", err) },
+		retry:  time.Hour,
+		resync: 5 * time.Millisecond,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		kw.run(ctx)
+		close(done)
+	}()
+
+	// A watch that never ends is still interrupted to relist.
+	for i := 0; i < 2; i++ {
+		select {
+		case <-lists:
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for a resync")
+		}
+	}
+	cancel()
+	<-done
+}
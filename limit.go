@@ -0,0 +1,164 @@
+package shedder
+
+import (
+	"fmt"
+	"time"
+)
+
+// LimitAlgorithm computes the hard limit from observed request samples.
+// It allows the limit to adapt at runtime (for example, AIMD or gradient
+// based controllers) without changing the middleware.
+//
+// Implementations must be safe for concurrent use: OnSample is called from
+// request goroutines after every admitted or shed request.
+type LimitAlgorithm interface {
+	// OnSample records a finished request and returns the new hard limit.
+	//
+	// latency is the time spent in the wrapped handler (zero for shed
+	// requests), inflight is the in-flight count observed when the request
+	// arrived, and didShed reports whether the request was rejected.
+	// Returned values <= 0 are ignored and the previous limit is kept.
+	OnSample(latency time.Duration, inflight int64, didShed bool) int64
+}
+
+// CostLimitAlgorithm is a LimitAlgorithm that also uses the cost of each
+// request: the value reported by the handler via ReportCost, or else the
+// Config.Cost estimate (1 without one). When the configured algorithm
+// implements it, OnCostSample is called instead of OnSample.
+type CostLimitAlgorithm interface {
+	LimitAlgorithm
+	OnCostSample(latency time.Duration, inflight, cost int64, didShed bool) int64
+}
+
+// StaticLimit is a LimitAlgorithm that always returns the same limit.
+// It is used when Config.LimitAlgorithm is nil. A StaticLimit set
+// explicitly is never sampled, so Config.HardLimit stays the limit;
+// Validate rejects one that differs from it.
+type StaticLimit int64
+
+// OnSample returns the static limit.
+func (l StaticLimit) OnSample(latency time.Duration, inflight int64, didShed bool) int64 {
+	return int64(l)
+}
+
+// isStatic reports whether the algorithm never changes the limit, in which
+// case the middleware skips latency measurement entirely.
+func isStatic(a LimitAlgorithm) bool {
+	_, ok := a.(StaticLimit)
+	return ok
+}
+
+// currentLimit returns the hard limit currently in effect: the value from
+// the limit algorithm, capped by the parent's limit for a Child shedder and
+// reduced while warming up or cooling down.
+func (s *Shedder) currentLimit() int64 {
+	limit := s.uncooledLimit()
+	if s.cooldown != nil {
+		limit = s.cooldown.scale(limit, s.now())
+	}
+	return limit
+}
+
+// uncooledLimit returns the hard limit currently in effect without the
+// cooldown reduction.
+func (s *Shedder) uncooledLimit() int64 {
+	limit := s.limit.Load()
+	if s.parent != nil {
+		limit = min(limit, s.parent.currentLimit())
+	}
+	if p := s.peers.Load(); p != nil {
+		limit = p.scale(limit)
+	}
+	if np := s.nodePressure.Load(); np != nil {
+		limit = np.scale(limit)
+	}
+	if s.warmup != nil {
+		limit = s.warmup.scale(limit, s.now())
+	}
+	return limit
+}
+
+// currentSoftLimit returns the soft limit currently in effect, or 0 if soft
+// limiting is disabled. A SoftLimitRatio tracks the current hard limit.
+func (s *Shedder) currentSoftLimit() int64 {
+	c := s.config.Load()
+	if ratio := c.softRatio; ratio > 0 {
+		if soft := int64(ratio * float64(s.currentLimit())); soft > 0 {
+			return soft
+		}
+		return 1
+	}
+	return c.softLimit
+}
+
+// sample feeds a request outcome to the capacity estimator and the limit
+// algorithm, and stores the resulting limit.
+func (s *Shedder) sample(latency time.Duration, inflight, cost int64, didShed bool) {
+	if s.estimator != nil && !didShed && s.algorithm != LimitAlgorithm(s.estimator) {
+		s.estimator.Observe(latency)
+	}
+	if s.static {
+		return
+	}
+	var next int64
+	if ca, ok := s.algorithm.(CostLimitAlgorithm); ok {
+		next = ca.OnCostSample(latency, inflight, cost, didShed)
+	} else {
+		next = s.algorithm.OnSample(latency, inflight, didShed)
+	}
+	if next > 0 {
+		s.limit.Store(next)
+	}
+}
+
+// HardLimit returns the hard limit currently in effect, including any
+// warmup or cooldown reduction.
+func (s *Shedder) HardLimit() int64 {
+	return s.currentLimit()
+}
+
+// SoftLimit returns the soft limit currently in effect, or 0 if soft
+// limiting is disabled.
+func (s *Shedder) SoftLimit() int64 {
+	return s.currentSoftLimit()
+}
+
+// SetHardLimit changes the hard limit of a running shedder, e.g. during an
+// incident when the configured value turns out to be wrong. It is safe for
+// concurrent use. With an adaptive LimitAlgorithm the new value lasts only
+// until the algorithm's next adjustment, since the algorithm keeps its own
+// state and is not reseeded; remove the algorithm to pin the limit.
+func (s *Shedder) SetHardLimit(limit int64) error {
+	if limit <= 0 {
+		return fmt.Errorf("shedder: hard limit must be > 0, got %d", limit)
+	}
+	old := s.limit.Swap(limit)
+	if s.queue != nil {
+		// Wake queued requests that fit under a raised limit.
+		for i := old; i < limit && s.queue.length.Load() > 0; i++ {
+			s.queue.notify()
+		}
+	}
+	return nil
+}
+
+// SetSoftLimit changes the soft limit of a running shedder; 0 disables soft
+// limiting. It replaces any SoftLimitRatio. It is safe for concurrent use.
+func (s *Shedder) SetSoftLimit(limit int64) error {
+	if limit < 0 {
+		return fmt.Errorf("shedder: soft limit must not be negative, got %d", limit)
+	}
+	s.updateConfig(func(c *config) { c.softLimit, c.softRatio = limit, 0 })
+	return nil
+}
+
+// SetSoftLimitRatio changes the soft limit to a fraction of the current hard
+// limit, as Config.SoftLimitRatio does; 0 reverts to the absolute soft
+// limit. It is safe for concurrent use.
+func (s *Shedder) SetSoftLimitRatio(ratio float64) error {
+	if ratio < 0 || ratio >= 1 {
+		return fmt.Errorf("shedder: soft limit ratio must be in [0, 1), got %v", ratio)
+	}
+	s.updateConfig(func(c *config) { c.softRatio = ratio })
+	return nil
+}
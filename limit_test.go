@@ -0,0 +1,206 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// halvingLimit halves the limit on every shed and grows it by one otherwise.
+type halvingLimit struct {
+	limit   atomic.Int64
+	samples atomic.Int32
+	sheds   atomic.Int32
+}
+
+func (h *halvingLimit) OnSample(latency time.Duration, inflight int64, didShed bool) int64 {
+	h.samples.Add(1)
+	if didShed {
+		h.sheds.Add(1)
+		return h.limit.Add(-h.limit.Load() / 2)
+	}
+	return h.limit.Add(1)
+}
+
+func TestStaticLimit_OnSample(t *testing.T) {
+	l := StaticLimit(42)
+	if got := l.OnSample(time.Second, 100, true); got != 42 {
+		t.Errorf("expected 42, got %d", got)
+	}
+}
+
+func TestNew_DefaultsToStaticLimit(t *testing.T) {
+	s := New(Config{HardLimit: 10})
+	if !s.static {
+		t.Error("expected static limit when LimitAlgorithm is nil")
+	}
+	if s.currentLimit() != 10 {
+		t.Errorf("expected current limit 10, got %d", s.currentLimit())
+	}
+}
+
+func TestMiddleware_LimitAlgorithmAdjustsLimit(t *testing.T) {
+	algo := &halvingLimit{}
+	algo.limit.Store(4)
+	s := New(Config{HardLimit: 4, LimitAlgorithm: algo})
+
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if algo.samples.Load() != 1 {
+		t.Errorf("expected 1 sample, got %d", algo.samples.Load())
+	}
+	if s.currentLimit() != 5 {
+		t.Errorf("expected limit 5 after admitted request, got %d", s.currentLimit())
+	}
+
+	// Fill the pod past the adapted limit so the next request is shed.
+	for i := 0; i < 5; i++ {
+		s.increment(1)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", rec.Code)
+	}
+	if algo.sheds.Load() != 1 {
+		t.Errorf("expected 1 shed sample, got %d", algo.sheds.Load())
+	}
+	if s.currentLimit() != 3 {
+		t.Errorf("expected limit 3 after shed, got %d", s.currentLimit())
+	}
+	if !s.IsOverloaded() {
+		t.Error("should be overloaded against the adapted limit")
+	}
+}
+
+func TestShedder_IgnoresNonPositiveLimits(t *testing.T) {
+	algo := &halvingLimit{}
+	s := New(Config{HardLimit: 10, LimitAlgorithm: algo})
+
+	s.sample(0, 1, 1, true) // algorithm returns 0
+	if s.currentLimit() != 10 {
+		t.Errorf("expected limit to stay 10, got %d", s.currentLimit())
+	}
+}
+
+func TestShedder_SoftLimitRatioTracksHardLimit(t *testing.T) {
+	algo := &halvingLimit{}
+	algo.limit.Store(10)
+	s := New(Config{HardLimit: 10, SoftLimit: 3, SoftLimitRatio: 0.8, LimitAlgorithm: algo})
+
+	if got := s.currentSoftLimit(); got != 8 {
+		t.Errorf("expected soft limit 8 (ratio wins over SoftLimit), got %d", got)
+	}
+
+	s.sample(0, 1, 1, true) // limit halves to 5
+	if got := s.currentSoftLimit(); got != 4 {
+		t.Errorf("expected soft limit 4 after hard limit dropped to 5, got %d", got)
+	}
+
+	for i := 0; i < 5; i++ {
+		s.increment(1)
+	}
+	if !s.IsSoftOverloaded() {
+		t.Error("expected soft overload above the ratio-derived soft limit")
+	}
+}
+
+func TestShedder_SoftLimitRatioIgnoresInvalid(t *testing.T) {
+	for _, ratio := range []float64{-0.5, 1, 1.5} {
+		s := New(Config{HardLimit: 10, SoftLimit: 3, SoftLimitRatio: ratio})
+		if got := s.currentSoftLimit(); got != 3 {
+			t.Errorf("ratio %v: expected SoftLimit 3 to apply, got %d", ratio, got)
+		}
+	}
+}
+
+func TestShedder_SetLimits(t *testing.T) {
+	s := New(Config{HardLimit: 100, SoftLimit: 80})
+
+	if err := s.SetHardLimit(50); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetSoftLimit(40); err != nil {
+		t.Fatal(err)
+	}
+	if s.HardLimit() != 50 || s.SoftLimit() != 40 {
+		t.Errorf("expected limits 50/40, got %d/%d", s.HardLimit(), s.SoftLimit())
+	}
+
+	if err := s.SetSoftLimitRatio(0.5); err != nil {
+		t.Fatal(err)
+	}
+	if s.SoftLimit() != 25 {
+		t.Errorf("expected ratio soft limit 25, got %d", s.SoftLimit())
+	}
+	if err := s.SetSoftLimit(30); err != nil {
+		t.Fatal(err)
+	}
+	if s.SoftLimit() != 30 {
+		t.Errorf("expected SetSoftLimit to replace the ratio, got %d", s.SoftLimit())
+	}
+
+	for _, err := range []error{s.SetHardLimit(0), s.SetSoftLimit(-1), s.SetSoftLimitRatio(1)} {
+		if err == nil {
+			t.Error("expected error for invalid limit")
+		}
+	}
+	if s.HardLimit() != 50 || s.SoftLimit() != 30 {
+		t.Errorf("expected invalid values to be ignored, got %d/%d", s.HardLimit(), s.SoftLimit())
+	}
+}
+
+func TestShedder_SetHardLimitAdaptive(t *testing.T) {
+	s := New(Config{HardLimit: 100, LimitAlgorithm: fixedLimit(20)})
+	if err := s.SetHardLimit(50); err != nil {
+		t.Fatal(err)
+	}
+	if s.HardLimit() != 50 {
+		t.Errorf("expected limit 50 after SetHardLimit, got %d", s.HardLimit())
+	}
+
+	// The algorithm's next sample replaces the override.
+	s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if s.HardLimit() != 20 {
+		t.Errorf("expected the algorithm's limit 20 after a sample, got %d", s.HardLimit())
+	}
+}
+
+func TestShedder_SetHardLimitWakesQueue(t *testing.T) {
+	s := New(Config{HardLimit: 1, Queue: &QueueConfig{Timeout: 5 * time.Second, MaxLength: 10}})
+	s.increment(1)
+	defer s.decrement(1)
+
+	done := make(chan int, 2)
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for i := 0; i < 2; i++ {
+		go func() {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+			done <- rec.Code
+		}()
+	}
+	for s.Stats().Queued != 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	s.SetHardLimit(3)
+	for i := 0; i < 2; i++ {
+		select {
+		case code := <-done:
+			if code != http.StatusOK {
+				t.Errorf("expected queued request served, got %d", code)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("queued request not woken by the raised limit")
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package shedder
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ShedDeciderV2 decides whether to shed a request given a snapshot of the
+// shedder's load, so it can make graduated decisions based on how
+// overloaded the pod actually is, e.g. shed batch traffic above 70%
+// utilization and free-tier traffic above 90%.
+//
+// Unlike ShedDecider it is consulted for every request that passed the
+// hard limit checks, not only in soft overload, so it must be cheap.
+type ShedDeciderV2 func(r *http.Request, load LoadInfo) Decision
+
+// Decision is the outcome of a ShedDeciderV2.
+type Decision int
+
+const (
+	// DecisionDefault leaves the request to the soft limit and
+	// ShedDecider, as if no ShedDeciderV2 were configured.
+	DecisionDefault Decision = iota
+
+	// DecisionAdmit admits the request without the soft limit checks.
+	DecisionAdmit
+
+	// DecisionShed sheds the request with ShedReasonSoftLimit, unless the
+	// ShedBudget is spent.
+	DecisionShed
+)
+
+// LoadInfo is a snapshot of a shedder's load passed to a ShedDeciderV2.
+type LoadInfo struct {
+	// Inflight is the in-flight count including the request being decided.
+	Inflight int64
+
+	// HardLimit and SoftLimit are the limits currently in effect
+	// (SoftLimit is 0 if disabled).
+	HardLimit int64
+	SoftLimit int64
+
+	// Utilization is Inflight / HardLimit; it exceeds 1 during a Burst.
+	Utilization float64
+
+	// SoftOverloaded reports whether the soft limit is exceeded or the
+	// SoftOverloadSignal fires.
+	SoftOverloaded bool
+
+	// P50, P90 and P99 are handler latency percentiles over the last 10
+	// seconds, accurate to about 10%. They are 0 until requests have
+	// completed.
+	P50, P90, P99 time.Duration
+}
+
+// loadInfo returns the LoadInfo for a request arriving with current
+// in-flight requests under limit.
+func (s *Shedder) loadInfo(current, limit int64) LoadInfo {
+	soft := s.currentSoftLimit()
+	load := LoadInfo{
+		Inflight:       current,
+		HardLimit:      limit,
+		SoftLimit:      soft,
+		Utilization:    float64(current) / float64(limit),
+		SoftOverloaded: (soft > 0 && current > soft) || s.softSignalOverloaded(),
+	}
+	if s.latencies != nil {
+		p := s.latencies.percentiles(s.now())
+		load.P50, load.P90, load.P99 = p[0], p[1], p[2]
+	}
+	return load
+}
+
+const (
+	// latencySpan is the window latency percentiles are computed over,
+	// split into latencySlots slots.
+	latencySpan  = 10 * time.Second
+	latencySlots = 10
+
+	// latencyBuckets log-linear buckets at four per octave of
+	// microseconds cover latencies up to about 9 hours.
+	latencyBuckets = 4 * 45
+
+	// percentileRefresh bounds how often percentiles are recomputed.
+	percentileRefresh = 100 * time.Millisecond
+)
+
+// latencyHistogram records handler latencies in a sliding window of
+// histograms, lock-free like window. Percentiles are cached for
+// percentileRefresh, since computing them reads every bucket.
+type latencyHistogram struct {
+	width int64 // slot width in nanoseconds
+	slots [latencySlots]latencySlot
+
+	mu      sync.Mutex
+	expires atomic.Int64 // unix nanoseconds
+	cached  atomic.Pointer[[3]time.Duration]
+}
+
+type latencySlot struct {
+	epoch  atomic.Int64
+	counts [latencyBuckets]atomic.Int64
+}
+
+// newLatencyHistogram returns an empty histogram.
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{width: int64(latencySpan / latencySlots)}
+}
+
+// latencyBucket returns the bucket index of latency.
+func latencyBucket(latency time.Duration) int {
+	us := float64(latency) / float64(time.Microsecond)
+	i := int(4 * math.Log2(us+1))
+	return min(max(i, 0), latencyBuckets-1)
+}
+
+// bucketLatency returns a representative latency of bucket i, the
+// geometric middle of its range.
+func bucketLatency(i int) time.Duration {
+	us := math.Exp2((float64(i)+0.5)/4) - 1
+	return time.Duration(us * float64(time.Microsecond))
+}
+
+// observe records a handler latency at now.
+func (h *latencyHistogram) observe(now time.Time, latency time.Duration) {
+	epoch := now.UnixNano() / h.width
+	slot := &h.slots[epoch%latencySlots]
+	if old := slot.epoch.Load(); old != epoch && slot.epoch.CompareAndSwap(old, epoch) {
+		for i := range slot.counts {
+			slot.counts[i].Store(0)
+		}
+	}
+	slot.counts[latencyBucket(latency)].Add(1)
+}
+
+// percentiles returns the p50, p90 and p99 latencies over the window
+// ending at now.
+func (h *latencyHistogram) percentiles(now time.Time) [3]time.Duration {
+	if p := h.cached.Load(); p != nil && now.UnixNano() < h.expires.Load() {
+		return *p
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if p := h.cached.Load(); p != nil && now.UnixNano() < h.expires.Load() {
+		return *p
+	}
+
+	var counts [latencyBuckets]int64
+	var total int64
+	epoch := now.UnixNano() / h.width
+	for i := range h.slots {
+		slot := &h.slots[i]
+		if e := slot.epoch.Load(); e <= epoch-latencySlots || e > epoch {
+			continue
+		}
+		for b := range slot.counts {
+			n := slot.counts[b].Load()
+			counts[b] += n
+			total += n
+		}
+	}
+
+	var p [3]time.Duration
+	if total > 0 {
+		targets := [3]float64{0.5, 0.9, 0.99}
+		var seen int64
+		next := 0
+		for b := 0; b < latencyBuckets && next < len(targets); b++ {
+			seen += counts[b]
+			for next < len(targets) && float64(seen) >= targets[next]*float64(total) {
+				p[next] = bucketLatency(b)
+				next++
+			}
+		}
+	}
+	h.cached.Store(&p)
+	h.expires.Store(now.Add(percentileRefresh).UnixNano())
+	return p
+}
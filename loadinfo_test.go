@@ -0,0 +1,98 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogram_Percentiles(t *testing.T) {
+	h := newLatencyHistogram()
+	now := time.Now()
+	for i := 0; i < 90; i++ {
+		h.observe(now, 10*time.Millisecond)
+	}
+	for i := 0; i < 9; i++ {
+		h.observe(now, 100*time.Millisecond)
+	}
+	h.observe(now, time.Second)
+
+	p := h.percentiles(now)
+	within := func(got, want time.Duration) bool {
+		return got > want*9/10 && got < want*11/10
+	}
+	if !within(p[0], 10*time.Millisecond) || !within(p[1], 10*time.Millisecond) || !within(p[2], 100*time.Millisecond) {
+		t.Errorf("unexpected percentiles %v", p)
+	}
+
+	if p := h.percentiles(now.Add(latencySpan + percentileRefresh)); p != [3]time.Duration{} {
+		t.Errorf("expected samples to expire with the window, got %v", p)
+	}
+}
+
+func TestLatencyHistogram_Cached(t *testing.T) {
+	h := newLatencyHistogram()
+	now := time.Now()
+	h.observe(now, time.Millisecond)
+	first := h.percentiles(now)
+	h.observe(now, time.Second)
+	h.observe(now, time.Second)
+	if got := h.percentiles(now.Add(percentileRefresh / 2)); got != first {
+		t.Errorf("expected cached percentiles %v, got %v", first, got)
+	}
+	if got := h.percentiles(now.Add(percentileRefresh)); got == first {
+		t.Error("expected percentiles to be recomputed after the refresh interval")
+	}
+}
+
+func TestMiddleware_ShedDeciderV2(t *testing.T) {
+	var seen LoadInfo
+	s := New(Config{
+		HardLimit: 10,
+		SoftLimit: 5,
+		ShedDecider: func(r *http.Request) bool {
+			return true
+		},
+		ShedDeciderV2: func(r *http.Request, load LoadInfo) Decision {
+			seen = load
+			switch r.URL.Path {
+			case "/batch":
+				if load.Utilization > 0.3 {
+					return DecisionShed
+				}
+			case "/critical":
+				return DecisionAdmit
+			}
+			return DecisionDefault
+		},
+	})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(path string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec.Code
+	}
+
+	if code := serve("/batch"); code != http.StatusOK {
+		t.Errorf("expected batch request at low utilization to be served, got %d", code)
+	}
+	s.increment(3)
+	if code := serve("/batch"); code != http.StatusServiceUnavailable {
+		t.Errorf("expected batch request at 40%% utilization to be shed, got %d", code)
+	}
+	if seen.Inflight != 4 || seen.HardLimit != 10 || seen.SoftLimit != 5 || seen.Utilization != 0.4 || seen.SoftOverloaded {
+		t.Errorf("unexpected load %+v", seen)
+	}
+
+	s.increment(3)
+	if code := serve("/critical"); code != http.StatusOK {
+		t.Errorf("expected DecisionAdmit to skip the soft limit, got %d", code)
+	}
+	if code := serve("/other"); code != http.StatusServiceUnavailable {
+		t.Errorf("expected DecisionDefault to fall through to ShedDecider, got %d", code)
+	}
+	if !seen.SoftOverloaded {
+		t.Errorf("expected soft overload, got %+v", seen)
+	}
+}
@@ -0,0 +1,204 @@
+package shedder
+
+import (
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// HeaderMatcher defines a header name and value to match for shedding.
+// Exactly one of Present, Regexp and Value is used, in that order.
+type HeaderMatcher struct {
+	Name string `json:"name"` // Header name, e.g., "X-Priority"
+
+	// Value is the header value to match, e.g., "low". A trailing "*"
+	// matches by prefix ("mobile-*") and a leading "*" by suffix ("*-beta");
+	// wildcards only match requests carrying the header.
+	Value string `json:"value,omitempty"`
+
+	// Regexp, if set, matches the header value instead of Value, e.g.
+	// regexp.MustCompile(`^mobile-ios-[0-2]\.`).
+	Regexp *regexp.Regexp `json:"regexp,omitempty"`
+
+	// Present matches any request carrying the header, whatever its value.
+	Present bool `json:"present,omitempty"`
+
+	// Keep, in Config.ShedHeaders, exempts matching requests from
+	// shedding instead of shedding them.
+	Keep bool `json:"keep,omitempty"`
+}
+
+// Match reports whether r's header matches m. It can be used as a
+// ShedDecider or a PriorityRule.Match.
+func (m *HeaderMatcher) Match(r *http.Request) bool {
+	values, present := r.Header[http.CanonicalHeaderKey(m.Name)]
+	if m.Present {
+		return present
+	}
+	if !present {
+		// As with Header.Get, a missing header has the empty value.
+		return m.Regexp == nil && m.Value == ""
+	}
+	var value string
+	if len(values) > 0 {
+		value = values[0]
+	}
+	if m.Regexp != nil {
+		return m.Regexp.MatchString(value)
+	}
+	return matchWildcard(m.Value, value)
+}
+
+// matchWildcard matches value against pattern, which may start or end
+// with "*" to match by suffix or prefix.
+func matchWildcard(pattern, value string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(value, prefix)
+	}
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+		return strings.HasSuffix(value, suffix)
+	}
+	return pattern == value
+}
+
+// PathMatcher matches requests by URL path. A request matches if its path
+// matches any of the entries.
+type PathMatcher struct {
+	// Exact paths, e.g. "/api/search/suggest".
+	Exact []string `json:"exact,omitempty"`
+
+	// Prefixes match paths starting with them, e.g. "/api/export/".
+	Prefixes []string `json:"prefixes,omitempty"`
+
+	// Globs match paths with path.Match syntax, where "*" does not cross
+	// "/", e.g. "/api/*/export".
+	Globs []string `json:"globs,omitempty"`
+}
+
+// Match reports whether r's URL path matches m. It can be used as a
+// ShedDecider or a PriorityRule.Match.
+func (m *PathMatcher) Match(r *http.Request) bool {
+	p := r.URL.Path
+	for _, exact := range m.Exact {
+		if p == exact {
+			return true
+		}
+	}
+	for _, prefix := range m.Prefixes {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	for _, glob := range m.Globs {
+		if ok, _ := path.Match(glob, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// MethodMatcher matches requests whose HTTP method is in the list. Methods
+// are compared case-sensitively, as in net/http.
+type MethodMatcher []string
+
+// Match reports whether r's method is in m. It can be used as a
+// ShedDecider or a PriorityRule.Match.
+func (m MethodMatcher) Match(r *http.Request) bool {
+	for _, method := range m {
+		if r.Method == method {
+			return true
+		}
+	}
+	return false
+}
+
+// QueryMatcher matches requests by a URL query parameter.
+type QueryMatcher struct {
+	Name string `json:"name"` // Parameter name, e.g. "prefetch"
+
+	// Value is the parameter value to match, e.g. "true", with the same
+	// "*" wildcards as HeaderMatcher.Value. Any of the parameter's values
+	// may match.
+	Value string `json:"value,omitempty"`
+
+	// Present matches any request carrying the parameter, whatever its
+	// value.
+	Present bool `json:"present,omitempty"`
+}
+
+// Match reports whether r's query matches m. It can be used as a
+// ShedDecider or a PriorityRule.Match.
+func (m *QueryMatcher) Match(r *http.Request) bool {
+	if r.URL.RawQuery == "" {
+		return false
+	}
+	values, present := r.URL.Query()[m.Name]
+	if m.Present {
+		return present
+	}
+	for _, v := range values {
+		if matchWildcard(m.Value, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// ShedMatchers selects the requests to shed under soft overload by header,
+// path, method or query parameter. A request is shed if the first matching
+// header entry has no Keep set, or if any other matcher matches.
+type ShedMatchers struct {
+	Headers []HeaderMatcher `json:"headers,omitempty"`
+	Paths   *PathMatcher    `json:"paths,omitempty"`
+	Methods MethodMatcher   `json:"methods,omitempty"`
+	Query   []QueryMatcher  `json:"query,omitempty"`
+}
+
+// matcherDecider returns a decider shedding requests that any of cfg's
+// matchers select, or nil if none is configured.
+func matcherDecider(cfg Config) ShedDecider {
+	m := ShedMatchers{Headers: cfg.ShedHeaders, Paths: cfg.ShedPaths, Methods: cfg.ShedMethods, Query: cfg.ShedQuery}
+	if cfg.ShedHeader != nil {
+		m.Headers = append([]HeaderMatcher{*cfg.ShedHeader}, cfg.ShedHeaders...)
+	}
+	return m.decider()
+}
+
+// decider returns a decider for m, or nil if m is empty.
+func (m ShedMatchers) decider() ShedDecider {
+	var deciders []ShedDecider
+	switch {
+	case len(m.Headers) == 1 && !m.Headers[0].Keep:
+		deciders = append(deciders, m.Headers[0].Match)
+	case len(m.Headers) > 0:
+		deciders = append(deciders, headerDecider(m.Headers))
+	}
+	if m.Paths != nil {
+		deciders = append(deciders, m.Paths.Match)
+	}
+	if len(m.Methods) > 0 {
+		deciders = append(deciders, m.Methods.Match)
+	}
+	for i := range m.Query {
+		deciders = append(deciders, m.Query[i].Match)
+	}
+
+	if len(deciders) == 0 {
+		return nil
+	}
+	return Any(deciders...)
+}
+
+// headerDecider returns a decider evaluating matchers in order; the first
+// match sheds the request unless it has Keep.
+func headerDecider(matchers []HeaderMatcher) ShedDecider {
+	return func(r *http.Request) bool {
+		for i := range matchers {
+			if matchers[i].Match(r) {
+				return !matchers[i].Keep
+			}
+		}
+		return false
+	}
+}
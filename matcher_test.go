@@ -0,0 +1,213 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestHeaderMatcher_Match(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher HeaderMatcher
+		header  *string
+		want    bool
+	}{
+		{"exact", HeaderMatcher{Name: "X-Client", Value: "web"}, ptr("web"), true},
+		{"exact mismatch", HeaderMatcher{Name: "X-Client", Value: "web"}, ptr("web2"), false},
+		{"empty value matches missing header", HeaderMatcher{Name: "X-Client"}, nil, true},
+		{"prefix", HeaderMatcher{Name: "X-Client", Value: "mobile-*"}, ptr("mobile-ios-3.2.1"), true},
+		{"prefix mismatch", HeaderMatcher{Name: "X-Client", Value: "mobile-*"}, ptr("web"), false},
+		{"suffix", HeaderMatcher{Name: "X-Client", Value: "*-beta"}, ptr("web-beta"), true},
+		{"wildcard needs header", HeaderMatcher{Name: "X-Client", Value: "*"}, nil, false},
+		{"wildcard any value", HeaderMatcher{Name: "X-Client", Value: "*"}, ptr(""), true},
+		{"regexp", HeaderMatcher{Name: "X-Client", Regexp: regexp.MustCompile(`^mobile-ios-[0-2]\.`)}, ptr("mobile-ios-2.9.0"), true},
+		{"regexp mismatch", HeaderMatcher{Name: "X-Client", Regexp: regexp.MustCompile(`^mobile-ios-[0-2]\.`)}, ptr("mobile-ios-3.2.1"), false},
+		{"regexp needs header", HeaderMatcher{Name: "X-Client", Regexp: regexp.MustCompile(`.*`)}, nil, false},
+		{"present", HeaderMatcher{Name: "X-Client", Present: true}, ptr(""), true},
+		{"absent", HeaderMatcher{Name: "X-Client", Present: true}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.header != nil {
+				r.Header.Set("X-Client", *tt.header)
+			}
+			if got := tt.matcher.Match(r); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHeaderDecider_FirstMatchWins(t *testing.T) {
+	decide := headerDecider([]HeaderMatcher{
+		{Name: "X-Client", Value: "mobile-ios-3.*", Keep: true},
+		{Name: "X-Client", Value: "mobile-*"},
+		{Name: "X-Priority", Value: "low"},
+	})
+
+	tests := []struct {
+		client, priority string
+		want             bool
+	}{
+		{"mobile-ios-3.2.1", "low", false}, // kept by the first entry
+		{"mobile-android-1.0", "", true},
+		{"web", "low", true},
+		{"web", "high", false}, // no match
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Client", tt.client)
+		if tt.priority != "" {
+			r.Header.Set("X-Priority", tt.priority)
+		}
+		if got := decide(r); got != tt.want {
+			t.Errorf("%s/%s: expected %v, got %v", tt.client, tt.priority, tt.want, got)
+		}
+	}
+}
+
+func TestNew_ShedHeaders(t *testing.T) {
+	s := New(Config{
+		HardLimit:   10,
+		SoftLimit:   5,
+		ShedHeader:  &HeaderMatcher{Name: "X-Internal", Present: true, Keep: true},
+		ShedHeaders: []HeaderMatcher{{Name: "X-Priority", Value: "low"}},
+	})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Priority", "low")
+	if !s.decider()(r) {
+		t.Error("expected low priority request to be shed")
+	}
+	r.Header.Set("X-Internal", "1")
+	if s.decider()(r) {
+		t.Error("expected ShedHeader to be evaluated first")
+	}
+}
+
+func TestPathMatcher_Match(t *testing.T) {
+	m := &PathMatcher{
+		Exact:    []string{"/api/search/suggest"},
+		Prefixes: []string{"/api/export/"},
+		Globs:    []string{"/api/*/report"},
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/api/search/suggest", true},
+		{"/api/search/suggest/more", false},
+		{"/api/export/", true},
+		{"/api/export/orders.csv", true},
+		{"/api/exports", false},
+		{"/api/orders/report", true},
+		{"/api/orders/2024/report", false},
+		{"/api/orders", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.path, nil)
+		if got := m.Match(r); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestNew_ShedPathsAndHeader(t *testing.T) {
+	s := New(Config{
+		HardLimit:  10,
+		SoftLimit:  5,
+		ShedHeader: &HeaderMatcher{Name: "X-Priority", Value: "low"},
+		ShedPaths:  &PathMatcher{Prefixes: []string{"/export/"}},
+	})
+
+	tests := []struct {
+		path, priority string
+		want           bool
+	}{
+		{"/export/a", "", true},
+		{"/orders", "low", true},
+		{"/orders", "", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.path, nil)
+		if tt.priority != "" {
+			r.Header.Set("X-Priority", tt.priority)
+		}
+		if got := s.decider()(r); got != tt.want {
+			t.Errorf("%s/%s: expected %v, got %v", tt.path, tt.priority, tt.want, got)
+		}
+	}
+}
+
+func TestMethodMatcher_Match(t *testing.T) {
+	m := MethodMatcher{"GET", "HEAD"}
+	for method, want := range map[string]bool{"GET": true, "HEAD": true, "POST": false, "get": false} {
+		r := httptest.NewRequest(method, "/", nil)
+		if got := m.Match(r); got != want {
+			t.Errorf("%s: expected %v, got %v", method, want, got)
+		}
+	}
+}
+
+func TestMiddleware_ShedMethods(t *testing.T) {
+	s := New(Config{
+		HardLimit:   10,
+		SoftLimit:   1,
+		ShedMethods: MethodMatcher{"GET"},
+	})
+	s.increment(1) // one request in flight, so the next exceeds SoftLimit
+	defer s.decrement(1)
+
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for method, want := range map[string]int{"GET": http.StatusServiceUnavailable, "POST": http.StatusOK} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, "/", nil))
+		if rec.Code != want {
+			t.Errorf("%s: expected %d, got %d", method, want, rec.Code)
+		}
+	}
+}
+
+func TestQueryMatcher_Match(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher QueryMatcher
+		target  string
+		want    bool
+	}{
+		{"value", QueryMatcher{Name: "prefetch", Value: "true"}, "/?prefetch=true", true},
+		{"value mismatch", QueryMatcher{Name: "prefetch", Value: "true"}, "/?prefetch=false", false},
+		{"any of several values", QueryMatcher{Name: "mode", Value: "lazy"}, "/?mode=eager&mode=lazy", true},
+		{"wildcard", QueryMatcher{Name: "client", Value: "bot-*"}, "/?client=bot-crawler", true},
+		{"missing", QueryMatcher{Name: "prefetch", Value: "true"}, "/?other=true", false},
+		{"no query", QueryMatcher{Name: "prefetch", Present: true}, "/", false},
+		{"present", QueryMatcher{Name: "prefetch", Present: true}, "/?prefetch", true},
+		{"empty value", QueryMatcher{Name: "prefetch"}, "/?prefetch=", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.matcher.Match(httptest.NewRequest("GET", tt.target, nil)); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNew_ShedQuery(t *testing.T) {
+	s := New(Config{
+		HardLimit: 10,
+		SoftLimit: 5,
+		ShedQuery: []QueryMatcher{{Name: "prefetch", Value: "true"}, {Name: "preview", Present: true}},
+	})
+	for target, want := range map[string]bool{"/?prefetch=true": true, "/?preview": true, "/?page=2": false} {
+		if got := s.decider()(httptest.NewRequest("GET", target, nil)); got != want {
+			t.Errorf("%s: expected %v, got %v", target, want, got)
+		}
+	}
+}
+
+func ptr(s string) *string { return &s }
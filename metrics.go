@@ -0,0 +1,157 @@
+package shedder
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics are a shedder's request metrics aggregated over one interval of
+// ReportMetrics.
+type Metrics struct {
+	// Start and End bound the interval.
+	Start, End time.Time
+
+	// Admitted counts the requests admitted in the interval, and Shed the
+	// requests shed, by reason. Reasons without sheds are left out.
+	Admitted int64
+	Shed     map[ShedReason]int64
+
+	// Completed counts the admitted requests that finished in the
+	// interval, and LatencySum and MaxLatency sum up their latencies.
+	// Requests on the FastPathBelow fast path are not counted.
+	Completed  int64
+	LatencySum time.Duration
+	MaxLatency time.Duration
+
+	// Inflight, HardLimit and SoftLimit are the shedder's state at End.
+	Inflight  int64
+	HardLimit int64
+	SoftLimit int64
+}
+
+// MetricsSink receives the metrics aggregated by ReportMetrics. Flush is
+// called from a single goroutine, never from the request path, so it may
+// block on I/O or locks.
+type MetricsSink interface {
+	Flush(m Metrics)
+}
+
+// MetricsSinkFunc adapts an ordinary function to the MetricsSink
+// interface.
+type MetricsSinkFunc func(m Metrics)
+
+// Flush calls f(m).
+func (f MetricsSinkFunc) Flush(m Metrics) {
+	f(m)
+}
+
+// MetricsOptions configures ReportMetrics.
+type MetricsOptions struct {
+	// Interval is how often the metrics are flushed to the sink. Defaults
+	// to 10s.
+	Interval time.Duration
+}
+
+// metricsShard accumulates the metrics of some of the requests between
+// flushes. Requests are spread over the shards so concurrent updates do
+// not contend on one cache line.
+type metricsShard struct {
+	admitted   atomic.Int64
+	shed       [numShedReasons]atomic.Int64
+	completed  atomic.Int64
+	latencySum atomic.Int64
+	maxLatency atomic.Int64
+
+	_ [64]byte // keeps neighboring shards off this one's cache lines
+}
+
+// metricsAggregator accumulates request metrics for ReportMetrics.
+type metricsAggregator struct {
+	shards []metricsShard // a power of two
+	start  time.Time
+}
+
+// newMetricsAggregator returns an aggregator with about one shard per
+// processor.
+func newMetricsAggregator(now time.Time) *metricsAggregator {
+	n := 1
+	for n < runtime.GOMAXPROCS(0) {
+		n *= 2
+	}
+	return &metricsAggregator{shards: make([]metricsShard, n), start: now}
+}
+
+// shard returns the shard for a request, picked by a counter value that
+// differs between concurrent requests.
+func (a *metricsAggregator) shard(n int64) *metricsShard {
+	return &a.shards[uint64(n)&uint64(len(a.shards)-1)]
+}
+
+// complete records a request that finished after latency.
+func (a *metricsAggregator) complete(n int64, latency time.Duration) {
+	sh := a.shard(n)
+	sh.completed.Add(1)
+	sh.latencySum.Add(int64(latency))
+	for {
+		old := sh.maxLatency.Load()
+		if int64(latency) <= old || sh.maxLatency.CompareAndSwap(old, int64(latency)) {
+			return
+		}
+	}
+}
+
+// collect returns the metrics accumulated since the last collect, ending
+// at now, and resets the shards.
+func (a *metricsAggregator) collect(now time.Time) Metrics {
+	m := Metrics{Start: a.start, End: now, Shed: make(map[ShedReason]int64)}
+	a.start = now
+	for i := range a.shards {
+		sh := &a.shards[i]
+		m.Admitted += sh.admitted.Swap(0)
+		for reason := range sh.shed {
+			if n := sh.shed[reason].Swap(0); n > 0 {
+				m.Shed[ShedReason(reason)] += n
+			}
+		}
+		m.Completed += sh.completed.Swap(0)
+		m.LatencySum += time.Duration(sh.latencySum.Swap(0))
+		m.MaxLatency = max(m.MaxLatency, time.Duration(sh.maxLatency.Swap(0)))
+	}
+	return m
+}
+
+// ReportMetrics aggregates the shedder's request metrics and flushes them
+// to sink every Interval until ctx is done, then once more. Requests only
+// update per-processor counters, so a sink that is slow or takes locks,
+// such as StatsD over UDP or a mutex-guarded registry, cannot slow them
+// down. While it runs, admitted requests are timed for their latencies,
+// even with a static limit. Calling it again replaces the previous sink.
+func (s *Shedder) ReportMetrics(ctx context.Context, sink MetricsSink, opts MetricsOptions) {
+	if opts.Interval <= 0 {
+		opts.Interval = 10 * time.Second
+	}
+	a := newMetricsAggregator(time.Now())
+	s.metrics.Store(a)
+
+	flush := func(now time.Time) {
+		m := a.collect(now)
+		m.Inflight, m.HardLimit, m.SoftLimit = s.Inflight(), s.currentLimit(), s.currentSoftLimit()
+		sink.Flush(m)
+	}
+	go func() {
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				s.metrics.CompareAndSwap(a, nil)
+				flush(time.Now())
+				return
+			case now := <-ticker.C:
+				flush(now)
+			}
+		}
+	}()
+}
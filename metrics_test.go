@@ -0,0 +1,77 @@
+package shedder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestShedder_ReportMetrics(t *testing.T) {
+	s := New(Config{HardLimit: 2})
+	flushed := make(chan Metrics, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.ReportMetrics(ctx, MetricsSinkFunc(func(m Metrics) { flushed <- m }), MetricsOptions{Interval: time.Hour})
+
+	h := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(2 * time.Millisecond)
+	}))
+	for i := 0; i < 3; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+	s.increment(2)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	s.decrement(2)
+
+	cancel()
+	var m Metrics
+	select {
+	case m = <-flushed:
+	case <-time.After(time.Second):
+		t.Fatal("expected a final flush when ctx is done")
+	}
+	if m.Admitted != 3 || m.Completed != 3 || len(m.Shed) != 1 || m.Shed[ShedReasonHardLimit] != 1 {
+		t.Errorf("expected 3 admitted and completed and 1 shed over the hard limit, got %+v", m)
+	}
+	if m.LatencySum < 6*time.Millisecond || m.MaxLatency < 2*time.Millisecond || m.MaxLatency > m.LatencySum {
+		t.Errorf("expected the latencies of 2ms requests, got sum %v max %v", m.LatencySum, m.MaxLatency)
+	}
+	if m.Inflight != 0 || m.HardLimit != 2 || !m.End.After(m.Start) {
+		t.Errorf("unexpected state: %+v", m)
+	}
+	if s.metrics.Load() != nil {
+		t.Error("expected the aggregator removed when ctx is done")
+	}
+}
+
+func TestShedder_ReportMetricsSlowSink(t *testing.T) {
+	s := New(Config{HardLimit: 100})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	release := make(chan struct{})
+	defer close(release)
+	s.ReportMetrics(ctx, MetricsSinkFunc(func(Metrics) { <-release }), MetricsOptions{Interval: time.Millisecond})
+
+	serve := serveFunc(s)
+	time.Sleep(5 * time.Millisecond) // the sink is now blocked
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 1000; i++ {
+			serve()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected requests not to wait for a blocked sink")
+	}
+
+	if raceEnabled {
+		return
+	}
+	if allocs := testing.AllocsPerRun(1000, serve); allocs != 0 {
+		t.Errorf("expected recording metrics not to allocate, got %v allocs/op", allocs)
+	}
+}
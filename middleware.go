@@ -2,44 +2,345 @@ package shedder
 
 import (
 	"net/http"
+	"time"
 )
 
 // Middleware returns an http.Handler that wraps the given handler with
 // load shedding logic.
 //
 // The middleware:
-//  1. Increments the in-flight counter
-//  2. Checks if HardLimit is exceeded - if so, returns 503 immediately
-//  3. If SoftLimit is exceeded and ShedDecider returns true, returns 503
-//  4. Otherwise, calls the wrapped handler
-//  5. Decrements the in-flight counter when done (even on panic)
+//  1. Serves requests on ExemptPaths right away and, while draining, sheds
+//     all others; otherwise increments the in-flight counter by the
+//     request's Cost (default 1). Requests arriving below FastPathBelow
+//     skip steps 3 and 5 to 7, and only OverloadSignal is checked in
+//     step 4
+//  2. If ClientIP is configured and the request's client IP is over its
+//     cap, Tenant is configured and the request's tenant holds more than
+//     its share of the hard limit, the request's class is over its
+//     ClassQuotas share, or the request's route, method class or host is
+//     at its RouteCeilings, Routes, MethodLimits or Hosts hard limit,
+//     returns 503. Over one of their soft limits, the request is shed as
+//     in step 7 regardless of the pod's soft limit
+//  3. If Deadline is configured and the request cannot finish before its
+//     deadline, returns 503
+//  4. Checks if HardLimit is exceeded (beyond any Burst allowance) or
+//     OverloadSignal fires - if so, returns 503 immediately, or, with a
+//     Queue configured, waits for a free slot before giving up
+//  5. If RouteCapacity is configured and the request's route exceeds its
+//     share of a contested hard limit, returns 503
+//  6. If PriorityCutoff is configured and the request's priority is
+//     below the cutoff for the current load, returns 503 unless the
+//     ShedBudget is spent
+//  7. If ShedDeciderV2 decides to shed, or SoftLimit is exceeded (or
+//     SoftOverloadSignal fires) and the request is a Retry or ShedDecider
+//     returns true, returns 503 unless the ShedBudget is spent
+//  8. If PriorityLevels are configured, takes a slot of the request's
+//     level, queueing for one if the level allows it. Requests of a level
+//     with unused Reserved slots skip the hard limit check in step 4, as
+//     do Preemption preemptors that cancel a preemptible request
+//  9. If SharedBudget is configured, takes a lease of the fleet's budget
+//     for matching requests, returning 503 if it is spent
+//  10. Otherwise, calls the wrapped handler
+//  11. Decrements the in-flight counter when done (even on panic)
+//  12. Reports the outcome to the LimitAlgorithm and CapacityEstimator,
+//     if configured, and to ReportMetrics, if running, unless the request
+//     took the fast path
+//
+// In DryRun mode, steps 2 to 9 are evaluated and recorded but the request
+// is always served.
+//
+// Shed responses share their header values across requests to avoid
+// allocating under overload. Middleware wrapping it may replace them,
+// e.g. with Header.Set, but must not modify them in place.
 func (s *Shedder) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.exempt != nil && s.exempt.Match(r) {
+			s.serveExempt(next, w, r)
+			return
+		}
+		if s.IsDraining() {
+			s.shed(w, r, ShedReasonDraining)
+			return
+		}
+
+		cost := s.requestCost(r)
+
+		// Take a priority level's units before any other slot, so requests
+		// queued for a level hold nothing that other requests, readiness
+		// or Inflight would count.
+		var level *priorityLevel
+		var reason ShedReason
+		var shed bool
+		if s.priority != nil {
+			level = s.priority.level(r)
+			if reason, shed = s.priority.acquire(r.Context(), level, cost, !s.dryRun); shed && s.dryRun {
+				// Served anyway, so account for the units.
+				s.priority.forceAcquire(level, cost)
+			}
+			if !shed || s.dryRun {
+				defer s.priority.release(level, cost)
+			}
+		}
+
 		// Increment before checking limits
-		current := s.increment()
+		current := s.increment(cost)
 
-		// Always decrement when we're done (handles panics too)
-		defer s.decrement()
+		// Always decrement when we're done (handles panics too), unless
+		// eviction reclaimed the slot first
+		var tracked *activeRequest
+		defer func() { s.release(cost, tracked) }()
 
-		// Check hard limit
-		if current > s.hardLimit {
-			s.shed(w, r, ShedReasonHardLimit)
-			return
+		now := s.now()
+		fast := s.fastPath(current)
+		if !fast {
+			s.peaks.observe(current, now)
+		}
+		if s.surge != nil {
+			s.surge.record(now, current)
+		}
+		if s.budget != nil {
+			s.budget.arrive(now)
 		}
+		retry := s.retries != nil && s.retries.observe(r, now)
 
-		// Check soft limit
-		if s.softLimit > 0 && current > s.softLimit {
-			if s.shedDecider != nil && s.shedDecider(r) {
-				s.shed(w, r, ShedReasonSoftLimit)
+		var route *routeState
+		if s.routes != nil {
+			route = s.routes.acquire(r, s.currentLimit(), now)
+			defer route.inflight.Add(-1)
+		}
+
+		if s.clients != nil {
+			client, over := s.clients.acquire(r)
+			defer s.clients.release(client)
+			if over && !shed {
+				reason, shed = ShedReasonClientLimit, true
+			}
+		}
+		if s.tenants != nil {
+			tenant, over := s.tenants.acquire(r, cost, s.currentLimit())
+			defer s.tenants.release(tenant, cost)
+			if over && !shed {
+				reason, shed = ShedReasonTenantLimit, true
+			}
+		}
+		if quotas := s.config.Load().quotas; quotas != nil {
+			quota, over := quotas.acquire(r, cost, s.currentLimit())
+			if quota != nil {
+				defer quota.inflight.Add(-cost)
+			}
+			if over && !shed {
+				reason, shed = ShedReasonClassQuota, true
+			}
+		}
+		var held [maxCeilingSets]*ceiling // released by one defer, as defers in loops allocate
+		for i, cs := range s.ceilings {
+			c, over, softOver := cs.acquire(r, cost)
+			held[i] = c
+			if over && !shed {
+				reason, shed = cs.reason, true
+			} else if softOver && !shed {
+				reason, shed = s.softShed(r, retry)
+			}
+		}
+		if len(s.ceilings) > 0 {
+			defer releaseCeilings(held[:len(s.ceilings)], cost)
+		}
+		if !shed && fast {
+			if s.signalOverloaded() {
+				reason, shed = ShedReasonOverloadSignal, true
+			}
+		} else if !shed {
+			reason, shed = s.admit(r, current, route, retry)
+		}
+		if shed && reason == ShedReasonHardLimit && level != nil && s.priority.hasReserve(level) {
+			// Reserved slots are guaranteed even above the hard limit.
+			reason, shed = 0, false
+		}
+		if shed && reason == ShedReasonHardLimit && s.preemption != nil && !s.dryRun && s.preempt(r) {
+			reason, shed = 0, false
+		}
+		if shed && reason == ShedReasonHardLimit && s.queue != nil && !s.dryRun {
+			current, reason, shed = s.waitForSlot(r, current, cost, route, retry)
+		}
+		if s.shared != nil && !shed {
+			lease, spent := s.shared.acquire(r)
+			if lease != "" {
+				defer s.shared.release(lease)
+			}
+			if spent {
+				reason, shed = ShedReasonSharedBudget, true
+			}
+		}
+		if shed {
+			if reason == ShedReasonHardLimit && s.cooldown != nil {
+				s.triggerCooldown(current)
+			}
+			if !s.dryRun {
+				s.shed(w, r, reason)
+				s.sample(0, current, cost, true)
 				return
 			}
+			r = s.wouldShed(r, reason)
 		}
 
 		// Serve the request
-		next.ServeHTTP(w, r)
+		admitted := s.admitted.Add(1)
+		metrics := s.metrics.Load()
+		if metrics != nil {
+			metrics.shard(admitted).admitted.Add(1)
+		}
+		if s.rateLimitHeaders == RateLimitHeadersAll {
+			s.setRateLimitHeaders(w.Header(), max(s.currentLimit()-current, 0), 0)
+		}
+		if s.telemetryHeaders {
+			s.setTelemetryHeaders(w.Header(), current)
+		}
+		if s.brownout != nil {
+			r = s.withDegradation(r, current)
+		}
+		if s.backoff != nil {
+			defer func() { s.backoff.complete(s.now(), cost) }()
+		}
+		if s.active != nil {
+			r, tracked = s.track(r, now, cost)
+			defer s.active.deregister(tracked)
+		}
+		var report *costReport
+		if route != nil || s.costSamples && !fast {
+			report, r = withCostReport(r, cost)
+		}
+		if route != nil {
+			defer func() { route.completions.Add(report.cost.Load()) }()
+		}
+		timed := (s.timed || metrics != nil) && !fast
+		if !timed && s.errorRate == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		s.serveTracked(next, w, r, current, cost, report, timed)
 	})
 }
 
+// serveExempt serves a request on an ExemptPaths path without any
+// admission checks. It still occupies an in-flight slot.
+func (s *Shedder) serveExempt(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	cost := s.requestCost(r)
+	s.peaks.observe(s.increment(cost), s.now())
+	defer s.decrement(cost)
+
+	admitted := s.admitted.Add(1)
+	if metrics := s.metrics.Load(); metrics != nil {
+		metrics.shard(admitted).admitted.Add(1)
+	}
+	next.ServeHTTP(w, r)
+}
+
+// admit decides whether a request arriving with current in-flight requests
+// should be shed, and why. route is nil unless RouteCapacity is configured;
+// retry reports whether the request was detected as a retry.
+func (s *Shedder) admit(r *http.Request, current int64, route *routeState, retry bool) (ShedReason, bool) {
+	if s.deadline != nil && s.deadline.tooLate(r, s.now()) {
+		return ShedReasonDeadline, true
+	}
+
+	// Check hard limit
+	limit := s.currentLimit()
+	if current > limit && (s.burst == nil || !s.burst.allow(current, limit, s.now())) {
+		return ShedReasonHardLimit, true
+	}
+	if s.signalOverloaded() {
+		return ShedReasonOverloadSignal, true
+	}
+	if route != nil && s.routes.exceeded(route, current, limit) {
+		return ShedReasonRouteLimit, true
+	}
+
+	if s.cutoff != nil && s.cutoff.shed(r, current, limit) && (s.budget == nil || s.budget.spend(s.now())) {
+		return ShedReasonPriorityCutoff, true
+	}
+
+	if s.deciderV2 != nil {
+		switch s.deciderV2(r, s.loadInfo(current, limit)) {
+		case DecisionAdmit:
+			return 0, false
+		case DecisionShed:
+			if s.budget == nil || s.budget.spend(s.now()) {
+				return ShedReasonSoftLimit, true
+			}
+			return 0, false
+		}
+	}
+
+	// Check soft limit
+	if soft := s.currentSoftLimit(); (soft > 0 && current > soft) || s.softSignalOverloaded() || s.envoyOverloadHint(r) {
+		return s.softShed(r, retry)
+	}
+	return 0, false
+}
+
+// envoyOverloadHint reports whether r carries x-envoy-overloaded and
+// EnvoyOverloaded is set.
+func (s *Shedder) envoyOverloadHint(r *http.Request) bool {
+	return s.envoyOverloaded && r.Header.Get("X-Envoy-Overloaded") != ""
+}
+
+// softShed decides whether a request arriving under soft overload should
+// be shed: retries first, then requests selected by the ShedDecider, as
+// long as the ShedBudget allows.
+func (s *Shedder) softShed(r *http.Request, retry bool) (ShedReason, bool) {
+	if retry && (s.budget == nil || s.budget.spend(s.now())) {
+		return ShedReasonRetry, true
+	}
+	if decide := s.decider(); decide != nil && decide(r) && (s.budget == nil || s.budget.spend(s.now())) {
+		return ShedReasonSoftLimit, true
+	}
+	return 0, false
+}
+
+// serveTracked serves an admitted request while measuring its latency and
+// response status for the features that need them.
+// cost is the request's estimated cost, replaced by the one reported to
+// report, if non-nil. The request is timed and sampled only if timed.
+func (s *Shedder) serveTracked(next http.Handler, w http.ResponseWriter, r *http.Request, inflight, cost int64, report *costReport, timed bool) {
+	var start time.Time
+	if timed {
+		start = s.sampleNow()
+	}
+
+	if s.errorRate != nil {
+		sw := newStatusWriter(w)
+		w = sw
+		defer func() {
+			status := sw.status()
+			sw.free()
+			if p := recover(); p != nil {
+				s.errorRate.record(http.StatusInternalServerError)
+				panic(p)
+			}
+			s.errorRate.record(status)
+		}()
+	}
+	next.ServeHTTP(w, r)
+
+	if timed {
+		end := s.sampleNow()
+		latency := end.Sub(start)
+		if s.deadline != nil {
+			s.deadline.observe(latency)
+		}
+		if s.latencies != nil {
+			s.latencies.observe(end, latency)
+		}
+		if metrics := s.metrics.Load(); metrics != nil {
+			metrics.complete(inflight, latency)
+		}
+		if report != nil {
+			cost = report.cost.Load()
+		}
+		s.sample(latency, inflight, cost, false)
+	}
+}
+
 // MiddlewareFunc is a convenience wrapper that returns a function
 // suitable for use with middleware chains that expect func(http.Handler) http.Handler.
 func (s *Shedder) MiddlewareFunc() func(http.Handler) http.Handler {
@@ -48,13 +349,38 @@ func (s *Shedder) MiddlewareFunc() func(http.Handler) http.Handler {
 	}
 }
 
-// shed writes a 503 response and invokes the OnShed callback if configured.
+// WriteShed sheds r with reason as the middleware does: it writes the
+// configured shed response with its Retry-After and X-Shed-Reason
+// headers, counts the request in Stats.Shed and calls OnShed. Handlers
+// that decide to shed on their own, e.g. when a dependency is overloaded,
+// use it to respond like the middleware, as does shedtest.Fake.
+func (s *Shedder) WriteShed(w http.ResponseWriter, r *http.Request, reason ShedReason) {
+	s.shed(w, r, reason)
+}
+
+// shed writes the shed response and invokes the OnShed callback if configured.
 func (s *Shedder) shed(w http.ResponseWriter, r *http.Request, reason ShedReason) {
+	shed := s.shedCount.Add(1)
+	if metrics := s.metrics.Load(); metrics != nil {
+		metrics.shard(shed).shed[reason].Add(1)
+	}
 	if s.onShed != nil {
 		s.onShed(r, reason)
 	}
 
-	w.Header().Set("Retry-After", "1")
-	w.Header().Set("X-Shed-Reason", reason.String())
-	http.Error(w, "Service Unavailable: load shedding active", http.StatusServiceUnavailable)
+	retryAfter := s.retryAfter(r, reason)
+	h := w.Header()
+	h["Retry-After"] = secondsValue(retryAfter)
+	h["X-Shed-Reason"] = reasonValue(reason)
+	if reason == ShedReasonDraining || s.closeOnHardShed && reason.overCapacity() {
+		h["Connection"] = closeValue
+	}
+	if s.envoyOverloaded {
+		h["X-Envoy-Overloaded"] = trueValue
+	}
+	s.handleShedBody(w, r)
+	if s.rateLimitHeaders != RateLimitHeadersOff {
+		s.setRateLimitHeaders(w.Header(), 0, retryAfter)
+	}
+	s.writeShedResponse(w, r, reason, retryAfter)
 }
@@ -340,3 +340,52 @@ func TestMiddleware_ConcurrentRequests(t *testing.T) {
 		t.Errorf("expected max 5 concurrent requests, got %d", maxActive.Load())
 	}
 }
+
+func TestMiddleware_ExemptPaths(t *testing.T) {
+	s := New(Config{
+		HardLimit:   1,
+		ExemptPaths: &PathMatcher{Exact: []string{"/internal/drain"}, Prefixes: []string{"/webhooks/"}},
+	})
+	s.increment(1) // at the hard limit
+	defer s.decrement(1)
+
+	var inflight int64
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inflight = s.Inflight()
+	}))
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/internal/drain", http.StatusOK},
+		{"/webhooks/github", http.StatusOK},
+		{"/api", http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.path, tt.want, rec.Code)
+		}
+	}
+	if inflight != 2 {
+		t.Errorf("expected exempt request to count in flight, got %d", inflight)
+	}
+	if s.Inflight() != 1 {
+		t.Errorf("expected in-flight count restored to 1, got %d", s.Inflight())
+	}
+}
+
+func TestShedder_WriteShed(t *testing.T) {
+	var reasons []ShedReason
+	s := New(Config{HardLimit: 10, OnShed: func(r *http.Request, reason ShedReason) { reasons = append(reasons, reason) }})
+	rec := httptest.NewRecorder()
+	s.WriteShed(rec, httptest.NewRequest("GET", "/", nil), ShedReasonSoftLimit)
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("X-Shed-Reason") != "soft_limit" || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("expected the middleware's shed response, got %d %v", rec.Code, rec.Header())
+	}
+	if s.Stats().Shed != 1 || len(reasons) != 1 {
+		t.Errorf("expected the shed counted and OnShed called, got %d %v", s.Stats().Shed, reasons)
+	}
+}
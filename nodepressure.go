@@ -0,0 +1,156 @@
+package shedder
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/sampath030/kube-shedder/internal/kube"
+)
+
+// NodePressureOptions configures WatchNodePressure.
+type NodePressureOptions struct {
+	// NodeName is the node the pod runs on. Defaults to the NODE_NAME
+	// environment variable, set from the Downward API:
+	//
+	//	env:
+	//	- name: NODE_NAME
+	//	  valueFrom:
+	//	    fieldRef:
+	//	      fieldPath: spec.nodeName
+	NodeName string
+
+	// Conditions are the node conditions that mean pressure. Defaults to
+	// MemoryPressure and PIDPressure.
+	Conditions []string
+
+	// Interval is how often the node is read. Defaults to 10s.
+	Interval time.Duration
+
+	// LimitFactor multiplies the hard limit in effect while the node is
+	// under pressure. Defaults to 0.5; 1 only enters soft overload.
+	LimitFactor float64
+
+	// OnChange, if set, is called whenever the conditions under pressure
+	// change, with the error if the node could not be read.
+	OnChange func(pressures []string, err error)
+}
+
+// nodePressure tracks the pressure conditions of the pod's node for
+// WatchNodePressure.
+type nodePressure struct {
+	factor float64
+
+	pressures atomic.Pointer[[]string] // nil or empty without pressure
+}
+
+// underPressure reports whether any condition is under pressure.
+func (np *nodePressure) underPressure() bool {
+	p := np.pressures.Load()
+	return p != nil && len(*p) > 0
+}
+
+// scale returns limit reduced while the node is under pressure.
+func (np *nodePressure) scale(limit int64) int64 {
+	if np.factor < 1 && np.underPressure() {
+		return max(int64(float64(limit)*np.factor), 1)
+	}
+	return limit
+}
+
+// WatchNodePressure follows the conditions of the pod's node, by polling
+// the Node with the pod's service account until ctx is done, and tightens
+// shedding while the node reports pressure: the hard limit is multiplied
+// by LimitFactor and the pod is soft overloaded. Pod-local counters can
+// look healthy while the node is running out of memory or PIDs, and the
+// kubelet evicts pods soon after. If the node cannot be read, the last
+// conditions seen stay in effect. The service account needs "get" on
+// Nodes, which is cluster-scoped:
+//
+//	kind: ClusterRole
+//	rules:
+//	- apiGroups: [""]
+//	  resources: ["nodes"]
+//	  verbs: ["get"]
+//
+// It returns an error, without watching, if the node cannot be read
+// initially.
+func (s *Shedder) WatchNodePressure(ctx context.Context, opts NodePressureOptions) error {
+	client, err := kube.InCluster()
+	if err != nil {
+		return err
+	}
+	return s.watchNodePressure(ctx, client, opts)
+}
+
+// watchNodePressure implements WatchNodePressure with the given client.
+func (s *Shedder) watchNodePressure(ctx context.Context, client *kube.Client, opts NodePressureOptions) error {
+	if opts.NodeName == "" {
+		opts.NodeName = os.Getenv("NODE_NAME")
+	}
+	if opts.NodeName == "" {
+		return errors.New("shedder: NodePressureOptions.NodeName or NODE_NAME is required")
+	}
+	if len(opts.Conditions) == 0 {
+		opts.Conditions = []string{"MemoryPressure", "PIDPressure"}
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 10 * time.Second
+	}
+	if opts.LimitFactor <= 0 {
+		opts.LimitFactor = 0.5
+	}
+	np := &nodePressure{factor: opts.LimitFactor}
+
+	var prev []string
+	poll := func() error {
+		node, err := client.GetNode(ctx, opts.NodeName)
+		if err != nil {
+			if opts.OnChange != nil {
+				opts.OnChange(prev, err)
+			}
+			return err
+		}
+		pressures := []string{}
+		for _, c := range opts.Conditions {
+			if node.Condition(c) {
+				pressures = append(pressures, c)
+			}
+		}
+		np.pressures.Store(&pressures)
+		if opts.OnChange != nil && (prev == nil || !slices.Equal(pressures, prev)) {
+			opts.OnChange(pressures, nil)
+		}
+		prev = pressures
+		return nil
+	}
+	if err := poll(); err != nil {
+		return err
+	}
+	s.nodePressure.Store(np)
+
+	go func() {
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				s.nodePressure.CompareAndSwap(np, nil)
+				return
+			case <-ticker.C:
+				poll()
+			}
+		}
+	}()
+	return nil
+}
+
+// nodeUnderPressure reports whether WatchNodePressure found the pod's
+// node under pressure.
+func (s *Shedder) nodeUnderPressure() bool {
+	np := s.nodePressure.Load()
+	return np != nil && np.underPressure()
+}
@@ -0,0 +1,70 @@
+package shedder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sampath030/kube-shedder/internal/kube"
+)
+
+func TestShedder_WatchNodePressure(t *testing.T) {
+	var conditions atomic.Value
+	conditions.Store(`[{"type":"Ready","status":"True"},{"type":"MemoryPressure","status":"False"}]`)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/nodes/node-1" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"metadata":{"name":"node-1"},"status":{"conditions":` + conditions.Load().(string) + `}}`))
+	}))
+	defer srv.Close()
+
+	t.Setenv("NODE_NAME", "node-1")
+	s := New(Config{HardLimit: 10})
+	changes := make(chan []string, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := s.watchNodePressure(ctx, &kube.Client{Host: srv.URL}, NodePressureOptions{
+		Interval: 5 * time.Millisecond,
+		OnChange: func(pressures []string, err error) { changes <- pressures },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := <-changes; len(got) != 0 {
+		t.Errorf("expected no pressure, got %v", got)
+	}
+	if st := s.Stats(); st.HardLimit != 10 || st.SoftOverloaded {
+		t.Errorf("expected the limits unchanged without pressure, got %+v", st)
+	}
+
+	conditions.Store(`[{"type":"MemoryPressure","status":"True"},{"type":"DiskPressure","status":"True"}]`)
+	select {
+	case got := <-changes:
+		if len(got) != 1 || got[0] != "MemoryPressure" {
+			t.Fatalf("expected only the watched MemoryPressure, got %v", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the pressure to change")
+	}
+	if st := s.Stats(); st.HardLimit != 5 || !st.SoftOverloaded || len(st.NodePressure) != 1 {
+		t.Errorf("expected a halved limit and soft overload under pressure, got %+v", st)
+	}
+
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for s.currentLimit() != 10 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the limit restored when ctx is done")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := New(Config{HardLimit: 10}).watchNodePressure(context.Background(), &kube.Client{Host: srv.URL}, NodePressureOptions{NodeName: "missing"}); err == nil {
+		t.Error("expected an error for a node that cannot be read")
+	}
+}
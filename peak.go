@@ -0,0 +1,77 @@
+package shedder
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultPeakHalfLife is the half-life of the decaying peak when
+// Config.PeakHalfLife is unset.
+const DefaultPeakHalfLife = time.Minute
+
+// peakTracker records the maximum in-flight count since start (or the last
+// reset) and an exponentially decaying peak.
+//
+// The decaying peak at time t is max_e(n_e * 2^(-(t-t_e)/h)) over all
+// observations e. Taking log2 turns it into 2^(max_e(k_e) - t/h) with
+// k_e = log2(n_e) + t_e/h, so only the running maximum of k has to be
+// stored, which can be updated lock-free.
+type peakTracker struct {
+	halfLife float64 // seconds
+	base     time.Time
+	max      atomic.Int64
+	maxK     atomic.Uint64 // float64 bits
+}
+
+// newPeakTracker returns a tracker with the given half-life.
+func newPeakTracker(halfLife time.Duration, now time.Time) *peakTracker {
+	if halfLife <= 0 {
+		halfLife = DefaultPeakHalfLife
+	}
+	p := &peakTracker{halfLife: halfLife.Seconds(), base: now}
+	p.maxK.Store(math.Float64bits(math.Inf(-1)))
+	return p
+}
+
+// observe records an in-flight count at now.
+func (p *peakTracker) observe(n int64, now time.Time) {
+	for {
+		old := p.max.Load()
+		if n <= old || p.max.CompareAndSwap(old, n) {
+			break
+		}
+	}
+	if n <= 0 {
+		return
+	}
+	k := math.Log2(float64(n)) + now.Sub(p.base).Seconds()/p.halfLife
+	for {
+		old := p.maxK.Load()
+		if k <= math.Float64frombits(old) || p.maxK.CompareAndSwap(old, math.Float64bits(k)) {
+			return
+		}
+	}
+}
+
+// decayed returns the decaying peak at now.
+func (p *peakTracker) decayed(now time.Time) float64 {
+	k := math.Float64frombits(p.maxK.Load())
+	if math.IsInf(k, -1) {
+		return 0
+	}
+	return math.Exp2(k - now.Sub(p.base).Seconds()/p.halfLife)
+}
+
+// reset clears both peaks, restarting them from the in-flight count n.
+func (p *peakTracker) reset(n int64, now time.Time) {
+	p.max.Store(0)
+	p.maxK.Store(math.Float64bits(math.Inf(-1)))
+	p.observe(n, now)
+}
+
+// ResetPeaks clears the peak in-flight watermarks reported by Stats,
+// restarting them from the current in-flight count.
+func (s *Shedder) ResetPeaks() {
+	s.peaks.reset(s.Inflight(), s.now())
+}
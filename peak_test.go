@@ -0,0 +1,53 @@
+package shedder
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPeakTracker_MaxAndDecay(t *testing.T) {
+	start := time.Unix(1000, 0)
+	p := newPeakTracker(time.Minute, start)
+
+	p.observe(10, start)
+	p.observe(4, start)
+
+	if got := p.max.Load(); got != 10 {
+		t.Errorf("expected peak 10, got %d", got)
+	}
+	if got := p.decayed(start); math.Abs(got-10) > 1e-9 {
+		t.Errorf("expected decayed peak 10 at observation time, got %v", got)
+	}
+	if got := p.decayed(start.Add(time.Minute)); math.Abs(got-5) > 1e-9 {
+		t.Errorf("expected decayed peak 5 after one half-life, got %v", got)
+	}
+
+	// A later, smaller observation wins once the old peak has decayed below it.
+	p.observe(4, start.Add(2*time.Minute))
+	if got := p.decayed(start.Add(2 * time.Minute)); math.Abs(got-4) > 1e-9 {
+		t.Errorf("expected decayed peak 4, got %v", got)
+	}
+	if got := p.max.Load(); got != 10 {
+		t.Errorf("all-time peak must not decay, got %d", got)
+	}
+}
+
+func TestPeakTracker_Reset(t *testing.T) {
+	start := time.Unix(1000, 0)
+	p := newPeakTracker(0, start)
+	p.observe(10, start)
+
+	p.reset(2, start)
+	if got := p.max.Load(); got != 2 {
+		t.Errorf("expected peak 2 after reset, got %d", got)
+	}
+	if got := p.decayed(start); math.Abs(got-2) > 1e-9 {
+		t.Errorf("expected decayed peak 2 after reset, got %v", got)
+	}
+
+	p.reset(0, start)
+	if got := p.decayed(start); got != 0 {
+		t.Errorf("expected decayed peak 0 after reset with no load, got %v", got)
+	}
+}
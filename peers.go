@@ -0,0 +1,241 @@
+package shedder
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/sampath030/kube-shedder/internal/kube"
+)
+
+// PeerOptions configures WatchPeers.
+type PeerOptions struct {
+	// Namespace of the Service. Defaults to the pod's namespace.
+	Namespace string
+
+	// PodName identifies this pod among the Service's endpoints. Defaults
+	// to the POD_NAME environment variable, else the hostname, which is
+	// the pod name unless the pod spec overrides it.
+	PodName string
+
+	// Interval is the delay before the EndpointSlices are read again
+	// after the watch on them fails. Defaults to 5s.
+	Interval time.Duration
+
+	// Resync is how often the EndpointSlices are read again in case the
+	// watch missed a change. Defaults to 5m; a negative value disables
+	// resyncing.
+	Resync time.Duration
+
+	// LastN makes the pod one of the last ready pods when fewer than LastN
+	// other endpoints of the Service are ready. Defaults to 1, i.e. no
+	// other endpoint is ready.
+	LastN int
+
+	// LimitBoost multiplies the hard limit in effect while the pod is one
+	// of the last ready pods, e.g. 1.5, since the requests it would shed
+	// have nowhere else to go. Values <= 1 leave the limit unchanged.
+	LimitBoost float64
+
+	// OnChange, if set, is called whenever the number of ready peers
+	// changes, with the error if the EndpointSlices could not be read or
+	// watched.
+	OnChange func(readyPeers int, lastStanding bool, err error)
+}
+
+// peers tracks the ready peers of the pod's Service for WatchPeers.
+type peers struct {
+	lastN int
+	boost float64
+
+	ready        atomic.Int64
+	lastStanding atomic.Bool
+}
+
+// scale returns limit boosted while the pod is one of the last ready pods.
+func (p *peers) scale(limit int64) int64 {
+	if p.boost > 1 && p.lastStanding.Load() {
+		return int64(float64(limit) * p.boost)
+	}
+	return limit
+}
+
+// update records the number of ready peers and reports whether the pod
+// is now one of the last ready pods.
+func (p *peers) update(ready int) bool {
+	p.ready.Store(int64(ready))
+	last := ready < p.lastN
+	p.lastStanding.Store(last)
+	return last
+}
+
+// WatchPeers follows the ready endpoints of service, by watching its
+// EndpointSlices with the pod's service account until ctx is done, to
+// avoid the "last pod standing" collapse in which every pod of an
+// overloaded Service turns unready at once and the Service has no
+// endpoints left. While the pod is one of the last LastN ready pods it
+// stays ready under overload (draining still makes it unready) and its
+// hard limit is multiplied by LimitBoost. If the EndpointSlices cannot be
+// read or watched, the pod behaves as if it had ready peers until they
+// are read again after Interval. The service account needs "list" and
+// "watch" on EndpointSlices:
+//
+//	rules:
+//	- apiGroups: ["discovery.k8s.io"]
+//	  resources: ["endpointslices"]
+//	  verbs: ["list", "watch"]
+//
+// It returns an error, without watching, if the EndpointSlices cannot be
+// read initially.
+func (s *Shedder) WatchPeers(ctx context.Context, service string, opts PeerOptions) error {
+	client, err := kube.InCluster()
+	if err != nil {
+		return err
+	}
+	if opts.Namespace == "" {
+		if opts.Namespace, err = kube.Namespace(); err != nil {
+			return err
+		}
+	}
+	return s.watchPeers(ctx, client, service, opts)
+}
+
+// watchPeers implements WatchPeers with the given client.
+func (s *Shedder) watchPeers(ctx context.Context, client *kube.Client, service string, opts PeerOptions) error {
+	if opts.PodName == "" {
+		opts.PodName = podName()
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 5 * time.Second
+	}
+	if opts.Resync == 0 {
+		opts.Resync = 5 * time.Minute
+	}
+	if opts.LastN <= 0 {
+		opts.LastN = 1
+	}
+	w := &peerWatcher{
+		client:  client,
+		service: service,
+		opts:    opts,
+		p:       &peers{lastN: opts.LastN, boost: opts.LimitBoost},
+		prev:    -1,
+	}
+	if err := w.list(ctx); err != nil {
+		return err
+	}
+	s.peers.Store(w.p)
+
+	kw := &kubeWatch{
+		list:   func(ctx context.Context) { w.list(ctx) },
+		watch:  w.watch,
+		report: w.update,
+		retry:  opts.Interval,
+		resync: opts.Resync,
+	}
+	go func() {
+		kw.run(ctx)
+		s.peers.CompareAndSwap(w.p, nil)
+	}()
+	return nil
+}
+
+// peerWatcher counts the ready peers in the EndpointSlices of the pod's
+// Service as they change.
+type peerWatcher struct {
+	client  *kube.Client
+	service string
+	opts    PeerOptions
+	p       *peers
+
+	slices  map[string]kube.EndpointSlice // by name
+	version string                        // to resume watching from
+	prev    int                           // ready peers last reported
+}
+
+// list reads the EndpointSlices.
+func (w *peerWatcher) list(ctx context.Context) error {
+	list, err := w.client.ListEndpointSlices(ctx, w.opts.Namespace, w.service)
+	if err != nil {
+		w.update(err)
+		return err
+	}
+	w.version = list.Metadata.ResourceVersion
+	w.slices = make(map[string]kube.EndpointSlice, len(list.Items))
+	for _, slice := range list.Items {
+		w.slices[slice.Metadata.Name] = slice
+	}
+	w.update(nil)
+	return nil
+}
+
+// watch applies changes to the EndpointSlices until the watch ends.
+func (w *peerWatcher) watch(ctx context.Context) error {
+	return w.client.WatchEndpointSlices(ctx, w.opts.Namespace, w.service, w.version, func(ev kube.EndpointSliceEvent) {
+		w.version = ev.Object.Metadata.ResourceVersion
+		switch ev.Type {
+		case "ADDED", "MODIFIED":
+			w.slices[ev.Object.Metadata.Name] = ev.Object
+		case "DELETED":
+			delete(w.slices, ev.Object.Metadata.Name)
+		default:
+			return
+		}
+		w.update(nil)
+	})
+}
+
+// update recounts the ready peers, or, if the EndpointSlices could not be
+// read, assumes the pod has ready peers, and reports changes to OnChange.
+func (w *peerWatcher) update(err error) {
+	// Without knowing the peers, shed as usual.
+	ready := w.opts.LastN
+	if err == nil {
+		ready = readyPeers(w.slices, w.opts.PodName)
+	}
+	last := w.p.update(ready)
+	if w.opts.OnChange != nil && (ready != w.prev || err != nil) {
+		w.opts.OnChange(ready, last, err)
+	}
+	w.prev = ready
+}
+
+// podName returns the POD_NAME environment variable, else the hostname,
+// which is the pod name unless the pod spec overrides it.
+func podName() string {
+	if name := os.Getenv("POD_NAME"); name != "" {
+		return name
+	}
+	name, _ := os.Hostname()
+	return name
+}
+
+// readyPeers counts the ready endpoints in slices other than the pod named
+// self. Pods listed in several slices, e.g. one per IP family, are counted
+// once.
+func readyPeers(slices map[string]kube.EndpointSlice, self string) int {
+	seen := make(map[string]bool)
+	for _, slice := range slices {
+		for _, ep := range slice.Endpoints {
+			key := ""
+			if ep.TargetRef != nil {
+				key = ep.TargetRef.Name
+			} else if len(ep.Addresses) > 0 {
+				key = ep.Addresses[0]
+			}
+			if key == "" || key == self || !ep.Conditions.IsReady() {
+				continue
+			}
+			seen[key] = true
+		}
+	}
+	return len(seen)
+}
+
+// lastStanding reports whether WatchPeers found the pod to be one of the
+// last ready pods of its Service.
+func (s *Shedder) lastStanding() bool {
+	p := s.peers.Load()
+	return p != nil && p.lastStanding.Load()
+}
@@ -0,0 +1,145 @@
+package shedder
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sampath030/kube-shedder/internal/kube"
+)
+
+func TestShedder_WatchPeers(t *testing.T) {
+	events := make(chan string)
+	versions := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apis/discovery.k8s.io/v1/namespaces/prod/endpointslices" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("watch") != "true" {
+			w.Write([]byte(`{"metadata":{"resourceVersion":"5"},"items":[
+				{"metadata":{"name":"checkout-v4"},"endpoints":[
+					{"addresses":["10.0.0.1"],"conditions":{"ready":true},"targetRef":{"kind":"Pod","name":"self"}},
+					{"addresses":["10.0.0.2"],"conditions":{"ready":true},"targetRef":{"kind":"Pod","name":"b"}}]},
+				{"metadata":{"name":"checkout-v6"},"endpoints":[
+					{"addresses":["fd00::2"],"conditions":{"ready":true},"targetRef":{"kind":"Pod","name":"b"}}]}]}`))
+			return
+		}
+		versions <- r.URL.Query().Get("resourceVersion")
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event := <-events:
+				w.Write([]byte(event + "\n"))
+				w.(http.Flusher).Flush()
+			}
+		}
+	}))
+	defer srv.Close()
+
+	s := New(Config{HardLimit: 10})
+	changes := make(chan int, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := s.watchPeers(ctx, &kube.Client{Host: srv.URL}, "checkout", PeerOptions{
+		Namespace:  "prod",
+		PodName:    "self",
+		Interval:   time.Hour,
+		Resync:     -1,
+		LimitBoost: 1.5,
+		OnChange:   func(ready int, last bool, err error) { changes <- ready },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := <-changes; got != 1 {
+		t.Errorf("expected one ready peer counted once across slices, got %d", got)
+	}
+	if v := <-versions; v != "5" {
+		t.Errorf("expected the watch to start from the list's version 5, got %q", v)
+	}
+
+	s.increment(11)
+	ready := func() int {
+		rec := httptest.NewRecorder()
+		s.ReadyHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/ready", nil))
+		return rec.Code
+	}
+	if code := ready(); code != http.StatusServiceUnavailable {
+		t.Errorf("expected unready under overload with a ready peer, got %d", code)
+	}
+
+	// The peer turning unready is seen as soon as the API server reports
+	// it, not at the next resync.
+	events <- `{"type":"DELETED","object":{"metadata":{"name":"checkout-v6","resourceVersion":"6"}}}`
+	events <- `{"type":"MODIFIED","object":{"metadata":{"name":"checkout-v4","resourceVersion":"7"},"endpoints":[
+		{"addresses":["10.0.0.1"],"conditions":{"ready":true},"targetRef":{"kind":"Pod","name":"self"}},
+		{"addresses":["10.0.0.2"],"conditions":{"ready":false},"targetRef":{"kind":"Pod","name":"b"}}]}}`
+	select {
+	case got := <-changes:
+		if got != 0 {
+			t.Fatalf("expected no ready peers, got %d", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the peers to change")
+	}
+	if code := ready(); code != http.StatusOK {
+		t.Errorf("expected the last ready pod to stay ready under overload, got %d", code)
+	}
+	if st := s.Stats(); st.HardLimit != 15 || !st.LastStanding || st.ReadyPeers != 0 {
+		t.Errorf("expected a boosted limit of 15 while last standing, got %+v", st)
+	}
+	s.StartDraining()
+	if code := ready(); code != http.StatusServiceUnavailable {
+		t.Errorf("expected draining to make the last pod unready, got %d", code)
+	}
+}
+
+func TestShedder_WatchPeersFailedWatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") == "true" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"items":[{"metadata":{"name":"checkout"},"endpoints":[
+			{"addresses":["10.0.0.1"],"conditions":{"ready":true},"targetRef":{"kind":"Pod","name":"self"}}]}]}`))
+	}))
+	defer srv.Close()
+
+	s := New(Config{HardLimit: 10})
+	errs := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := s.watchPeers(ctx, &kube.Client{Host: srv.URL}, "checkout", PeerOptions{
+		Namespace: "prod",
+		PodName:   "self",
+		Interval:  time.Hour,
+		OnChange:  func(ready int, last bool, err error) { errs <- err },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-errs
+	if !s.lastStanding() {
+		t.Fatal("expected the pod to be last standing")
+	}
+	// Without a working watch the pod cannot tell whether peers came
+	// back, so it sheds as usual.
+	select {
+	case err := <-errs:
+		var se *kube.StatusError
+		if !errors.As(err, &se) || se.StatusCode != http.StatusForbidden {
+			t.Errorf("expected the failed watch reported, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the failed watch")
+	}
+	if s.lastStanding() {
+		t.Error("expected the pod not to be last standing while the watch fails")
+	}
+}
@@ -0,0 +1,50 @@
+package shedder
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrPreempted is the context cause of a request canceled to make room
+// for a higher-priority one; see context.Cause.
+var ErrPreempted = errors.New("shedder: request preempted")
+
+// PreemptionConfig configures preemption of low-priority requests.
+type PreemptionConfig struct {
+	// Preemptible reports whether an admitted request may be canceled to
+	// free a slot for a higher-priority one. Required.
+	Preemptible func(r *http.Request) bool
+
+	// Preemptor reports whether a request arriving at the hard limit may
+	// preempt. Defaults to requests that are not Preemptible.
+	Preemptor func(r *http.Request) bool
+}
+
+// preemption cancels low-priority requests at the hard limit.
+type preemption struct {
+	preemptible func(r *http.Request) bool
+	preemptor   func(r *http.Request) bool
+}
+
+// newPreemption returns a preemption for cfg with defaults applied.
+func newPreemption(cfg PreemptionConfig) *preemption {
+	p := &preemption{preemptible: cfg.Preemptible, preemptor: cfg.Preemptor}
+	if p.preemptor == nil {
+		p.preemptor = func(r *http.Request) bool { return !cfg.Preemptible(r) }
+	}
+	return p
+}
+
+// preempt cancels the longest-running preemptible request on behalf of r,
+// if r may preempt. The victim's slot frees once its handler returns, so
+// r is admitted right away and the pod briefly runs one over the limit.
+func (s *Shedder) preempt(r *http.Request) bool {
+	if !s.preemption.preemptor(r) {
+		return false
+	}
+	if !s.active.cancelOldest(func(ar *activeRequest) bool { return ar.preemptible }, ErrPreempted) {
+		return false
+	}
+	s.preempted.Add(1)
+	return true
+}
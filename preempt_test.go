@@ -0,0 +1,92 @@
+package shedder
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestMiddleware_PreemptsLowPriority(t *testing.T) {
+	s := New(Config{HardLimit: 1, Preemption: &PreemptionConfig{
+		Preemptible: func(r *http.Request) bool { return r.Header.Get("X-Priority") == "low" },
+	}})
+
+	started := make(chan struct{})
+	var cause error
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/low" {
+			close(started)
+			<-r.Context().Done()
+			cause = context.Cause(r.Context())
+		}
+	}))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		req := httptest.NewRequest("GET", "/low", nil)
+		req.Header.Set("X-Priority", "low")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/high", nil))
+	wg.Wait()
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected high-priority request to be admitted, got %d", rec.Code)
+	}
+	if !errors.Is(cause, ErrPreempted) {
+		t.Errorf("expected victim to be canceled with ErrPreempted, got %v", cause)
+	}
+	if got := s.Stats().Preempted; got != 1 {
+		t.Errorf("expected 1 preempted request, got %d", got)
+	}
+}
+
+func TestMiddleware_PreemptionWithoutVictim(t *testing.T) {
+	s := New(Config{HardLimit: 1, Preemption: &PreemptionConfig{
+		Preemptible: func(r *http.Request) bool { return r.Header.Get("X-Priority") == "low" },
+	}})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			<-release
+		}
+	}))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+	}()
+	<-started
+
+	// Only a high-priority request is in flight, so nothing can be preempted.
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 without a preemptible request, got %d", rec.Code)
+	}
+
+	// A low-priority request may not preempt either.
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Priority", "low")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 for a low-priority request, got %d", rec.Code)
+	}
+
+	close(release)
+	wg.Wait()
+}
@@ -0,0 +1,260 @@
+package shedder
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// PriorityLevel defines a class of traffic with a share of the hard limit,
+// similar to a Kubernetes API Priority and Fairness priority level.
+type PriorityLevel struct {
+	// Name identifies the level; Config.Classify returns it.
+	Name string
+
+	// Shares is the level's weight. Its concurrency limit, in in-flight
+	// cost units like HardLimit, is
+	// Reserved + (HardLimit - all Reserved) * Shares / (sum of all Shares),
+	// but at least 1. A request costing more than the whole limit is only
+	// admitted while the level is idle. Defaults to 1 unless Reserved is
+	// set.
+	Shares int
+
+	// Reserved is the number of cost units kept for this level however
+	// busy the other levels are, e.g. for low-volume health-critical
+	// internal calls. While the level's requests fit in Reserved units
+	// they are admitted even above the hard limit.
+	Reserved int
+
+	// QueueLength is the number of requests that may wait for a slot of
+	// this level. Zero disables queueing: requests over the level's limit
+	// are shed immediately.
+	QueueLength int
+
+	// QueueTimeout is the longest a request waits in the level's queue.
+	// Defaults to one second when QueueLength > 0.
+	QueueTimeout time.Duration
+}
+
+// PriorityRule maps requests matching Match to the priority level Level.
+type PriorityRule struct {
+	Level string
+	Match func(r *http.Request) bool
+}
+
+// ClassifyRules returns a classifier that evaluates rules in order and
+// returns the level of the first matching rule, or def if none matches.
+func ClassifyRules(def string, rules ...PriorityRule) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		for _, rule := range rules {
+			if rule.Match(r) {
+				return rule.Level
+			}
+		}
+		return def
+	}
+}
+
+// PriorityLevelStats describes the state of one priority level.
+type PriorityLevelStats struct {
+	Name     string `json:"name"`
+	Inflight int64  `json:"inflight"`
+	Limit    int64  `json:"limit"`
+	Reserved int64  `json:"reserved"`
+	Queued   int64  `json:"queued"`
+	Rejected int64  `json:"rejected"`
+}
+
+// prioritySet divides the hard limit between priority levels and queues
+// requests per level. All state is guarded by a single mutex so that
+// admission and dispatch of queued requests see a consistent view.
+type prioritySet struct {
+	classify func(r *http.Request) string
+	limit    func() int64
+	clock    Clock
+
+	mu          sync.Mutex
+	levels      []*priorityLevel
+	byName      map[string]*priorityLevel
+	totalShares int
+	reserved    int64
+}
+
+// priorityLevel is the runtime state of one PriorityLevel.
+type priorityLevel struct {
+	cfg      PriorityLevel
+	inflight int64     // cost units
+	queue    list.List // of *priorityWaiter
+	rejected int64
+}
+
+// priorityWaiter is a queued request; ready is closed once it holds a slot.
+type priorityWaiter struct {
+	cost    int64
+	ready   chan struct{}
+	granted bool
+}
+
+// newPrioritySet returns a set for levels whose queues are timed by clock.
+// Requests classified into an unknown level use the last level.
+func newPrioritySet(levels []PriorityLevel, classify func(r *http.Request) string, limit func() int64, clock Clock) *prioritySet {
+	ps := &prioritySet{classify: classify, limit: limit, clock: clock, byName: make(map[string]*priorityLevel)}
+	for _, cfg := range levels {
+		if cfg.Reserved < 0 {
+			cfg.Reserved = 0
+		}
+		if cfg.Shares < 0 || (cfg.Shares == 0 && cfg.Reserved == 0) {
+			cfg.Shares = 1
+		}
+		if cfg.QueueLength > 0 && cfg.QueueTimeout <= 0 {
+			cfg.QueueTimeout = time.Second
+		}
+		pl := &priorityLevel{cfg: cfg}
+		ps.levels = append(ps.levels, pl)
+		ps.byName[cfg.Name] = pl
+		ps.totalShares += cfg.Shares
+		ps.reserved += int64(cfg.Reserved)
+	}
+	return ps
+}
+
+// level returns the priority level for r.
+func (ps *prioritySet) level(r *http.Request) *priorityLevel {
+	if ps.classify != nil {
+		if pl, ok := ps.byName[ps.classify(r)]; ok {
+			return pl
+		}
+	}
+	return ps.levels[len(ps.levels)-1]
+}
+
+// nominal returns pl's concurrency limit for the given total. Must be
+// called with ps.mu held.
+func (ps *prioritySet) nominal(pl *priorityLevel, total int64) int64 {
+	n := int64(pl.cfg.Reserved)
+	if spare := total - ps.reserved; spare > 0 && ps.totalShares > 0 {
+		n += spare * int64(pl.cfg.Shares) / int64(ps.totalShares)
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// fits reports whether a request of the given cost fits in pl's limit for
+// the given total. Must be called with ps.mu held.
+func (ps *prioritySet) fits(pl *priorityLevel, cost, total int64) bool {
+	return pl.inflight == 0 || pl.inflight+cost <= ps.nominal(pl, total)
+}
+
+// acquire takes cost units of pl, waiting in its queue if allowed. On
+// success the caller must call release. With wait false, a request over
+// the limit is rejected without queueing.
+func (ps *prioritySet) acquire(ctx context.Context, pl *priorityLevel, cost int64, wait bool) (ShedReason, bool) {
+	ps.mu.Lock()
+	if pl.queue.Len() == 0 && ps.fits(pl, cost, ps.limit()) {
+		pl.inflight += cost
+		ps.mu.Unlock()
+		return 0, false
+	}
+	if !wait || pl.queue.Len() >= pl.cfg.QueueLength {
+		pl.rejected++
+		ps.mu.Unlock()
+		return ShedReasonPriorityLevel, true
+	}
+	w := &priorityWaiter{cost: cost, ready: make(chan struct{})}
+	elem := pl.queue.PushBack(w)
+	ps.mu.Unlock()
+
+	timer := ps.clock.NewTimer(pl.cfg.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case <-w.ready:
+		return 0, false
+	case <-ctx.Done():
+	case <-timer.C():
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if w.granted {
+		// Granted while giving up: return the units to the others.
+		pl.inflight -= cost
+	} else {
+		pl.queue.Remove(elem)
+	}
+	// Either way the requests behind this one may fit now.
+	ps.dispatch()
+	pl.rejected++
+	return ShedReasonQueueTimeout, true
+}
+
+// hasReserve reports whether pl's requests, including the caller's, fit
+// in its reserved units.
+func (ps *prioritySet) hasReserve(pl *priorityLevel) bool {
+	if pl.cfg.Reserved == 0 {
+		return false
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return pl.inflight <= int64(pl.cfg.Reserved)
+}
+
+// forceAcquire takes cost units of pl regardless of its limit. It is used
+// in DryRun mode, where over-limit requests are served anyway.
+func (ps *prioritySet) forceAcquire(pl *priorityLevel, cost int64) {
+	ps.mu.Lock()
+	pl.inflight += cost
+	ps.mu.Unlock()
+}
+
+// release returns cost units of pl and hands free units to queued
+// requests.
+func (ps *prioritySet) release(pl *priorityLevel, cost int64) {
+	ps.mu.Lock()
+	pl.inflight -= cost
+	ps.dispatch()
+	ps.mu.Unlock()
+}
+
+// dispatch grants units to queued requests of levels with room for them,
+// in FIFO order per level. Must be called with ps.mu held.
+func (ps *prioritySet) dispatch() {
+	total := ps.limit()
+	for _, pl := range ps.levels {
+		for pl.queue.Len() > 0 && ps.fits(pl, pl.queue.Front().Value.(*priorityWaiter).cost, total) {
+			ps.grant(pl)
+		}
+	}
+}
+
+// grant gives units of pl to its longest-waiting request. Must be called
+// with ps.mu held and a non-empty queue.
+func (ps *prioritySet) grant(pl *priorityLevel) {
+	w := pl.queue.Remove(pl.queue.Front()).(*priorityWaiter)
+	pl.inflight += w.cost
+	w.granted = true
+	close(w.ready)
+}
+
+// stats returns a snapshot of all levels in configuration order.
+func (ps *prioritySet) stats() []PriorityLevelStats {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	total := ps.limit()
+	out := make([]PriorityLevelStats, 0, len(ps.levels))
+	for _, pl := range ps.levels {
+		out = append(out, PriorityLevelStats{
+			Name:     pl.cfg.Name,
+			Inflight: pl.inflight,
+			Limit:    ps.nominal(pl, total),
+			Reserved: int64(pl.cfg.Reserved),
+			Queued:   int64(pl.queue.Len()),
+			Rejected: pl.rejected,
+		})
+	}
+	return out
+}
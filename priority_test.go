@@ -0,0 +1,314 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClassifyRules(t *testing.T) {
+	classify := ClassifyRules("low",
+		PriorityRule{Level: "high", Match: func(r *http.Request) bool { return r.URL.Path == "/a" }},
+		PriorityRule{Level: "mid", Match: func(r *http.Request) bool { return r.Method == "POST" }},
+	)
+
+	tests := []struct {
+		method, path, want string
+	}{
+		{"GET", "/a", "high"},
+		{"POST", "/a", "high"},
+		{"POST", "/b", "mid"},
+		{"GET", "/b", "low"},
+	}
+	for _, tt := range tests {
+		if got := classify(httptest.NewRequest(tt.method, tt.path, nil)); got != tt.want {
+			t.Errorf("%s %s: expected %q, got %q", tt.method, tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestPrioritySet_NominalLimits(t *testing.T) {
+	ps := newPrioritySet([]PriorityLevel{
+		{Name: "a", Shares: 3},
+		{Name: "b", Shares: 1},
+		{Name: "c", Shares: 0}, // defaults to 1
+	}, nil, func() int64 { return 10 }, systemClock{})
+
+	want := map[string]int64{"a": 6, "b": 2, "c": 2}
+	for _, st := range ps.stats() {
+		if st.Limit != want[st.Name] {
+			t.Errorf("level %s: expected limit %d, got %d", st.Name, want[st.Name], st.Limit)
+		}
+	}
+}
+
+func TestPrioritySet_UnknownLevelUsesLast(t *testing.T) {
+	ps := newPrioritySet([]PriorityLevel{{Name: "a"}, {Name: "b"}},
+		func(r *http.Request) string { return "missing" }, func() int64 { return 10 }, systemClock{})
+
+	if got := ps.level(httptest.NewRequest("GET", "/", nil)).cfg.Name; got != "b" {
+		t.Errorf("expected last level b, got %s", got)
+	}
+}
+
+func TestMiddleware_PriorityLevelShedsOverShare(t *testing.T) {
+	s := New(Config{
+		HardLimit: 10,
+		PriorityLevels: []PriorityLevel{
+			{Name: "high", Shares: 9},
+			{Name: "low", Shares: 1},
+		},
+		Classify: func(r *http.Request) string { return r.Header.Get("X-Class") },
+	})
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			started <- struct{}{}
+			<-release
+		}
+	}))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		req := httptest.NewRequest("GET", "/slow", nil)
+		req.Header.Set("X-Class", "low")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	<-started
+
+	// The low level holds its single slot; another low request is shed.
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Class", "low")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 for low request, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("X-Shed-Reason"); got != "priority_level" {
+		t.Errorf("expected X-Shed-Reason priority_level, got %q", got)
+	}
+
+	// The high level is unaffected.
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Class", "high")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 for high request, got %d", rec.Code)
+	}
+
+	close(release)
+	wg.Wait()
+
+	for _, st := range s.Stats().PriorityLevels {
+		if st.Inflight != 0 {
+			t.Errorf("level %s: expected inflight 0, got %d", st.Name, st.Inflight)
+		}
+		if st.Name == "low" && st.Rejected != 1 {
+			t.Errorf("expected 1 rejected low request, got %d", st.Rejected)
+		}
+	}
+}
+
+func TestMiddleware_PriorityLevelQueues(t *testing.T) {
+	s := New(Config{
+		HardLimit:      10,
+		PriorityLevels: []PriorityLevel{{Name: "only", Shares: 1, QueueLength: 1, QueueTimeout: time.Second}},
+	})
+	// Shrink the level to one slot by holding nine of them.
+	for i := 0; i < 9; i++ {
+		s.priority.forceAcquire(s.priority.levels[0], 1)
+	}
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			started <- struct{}{}
+			<-release
+		}
+	}))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/queued", nil))
+		close(done)
+	}()
+	for s.Stats().PriorityLevels[0].Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	// The queued request holds no in-flight slot while it waits.
+	if got := s.Inflight(); got != 1 {
+		t.Errorf("expected only the slow request in flight, got %d", got)
+	}
+
+	// The queue is full, so a third request is shed.
+	full := httptest.NewRecorder()
+	handler.ServeHTTP(full, httptest.NewRequest("GET", "/", nil))
+	if full.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 with a full queue, got %d", full.Code)
+	}
+
+	close(release)
+	<-done
+	wg.Wait()
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected queued request to be admitted, got %d", rec.Code)
+	}
+}
+
+func TestMiddleware_PriorityLevelChargesCost(t *testing.T) {
+	s := New(Config{
+		HardLimit: 10,
+		Cost: func(r *http.Request) int64 {
+			if r.URL.Path == "/heavy" {
+				return 4
+			}
+			return 1
+		},
+		PriorityLevels: []PriorityLevel{
+			{Name: "low", Shares: 1},
+			{Name: "high", Shares: 1},
+		},
+		Classify: func(r *http.Request) string { return "low" },
+	})
+	// The level has 5 units; hold 2 of them.
+	s.priority.forceAcquire(s.priority.levels[0], 2)
+
+	var served int64
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = s.Stats().PriorityLevels[0].Inflight
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/heavy", nil))
+	if got := rec.Header().Get("X-Shed-Reason"); got != "priority_level" {
+		t.Errorf("expected a 4-unit request over the level's 3 free units shed, got %q", got)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if served != 3 {
+		t.Errorf("expected a 1-unit request to hold 1 unit, got inflight %d", served)
+	}
+
+	// With the units back, the heavy request fits.
+	s.priority.release(s.priority.levels[0], 2)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/heavy", nil))
+	if rec.Code != http.StatusOK || served != 4 {
+		t.Errorf("expected the heavy request served holding 4 units, got %d with inflight %d", rec.Code, served)
+	}
+	if got := s.Stats().PriorityLevels[0].Inflight; got != 0 {
+		t.Errorf("expected all units released, got %d", got)
+	}
+}
+
+func TestMiddleware_PriorityLevelQueueTimeout(t *testing.T) {
+	clock := newFakeClock()
+	s := New(Config{
+		HardLimit:      1,
+		Clock:          clock,
+		PriorityLevels: []PriorityLevel{{Name: "only", QueueLength: 1, QueueTimeout: 10 * time.Millisecond}},
+	})
+	s.priority.forceAcquire(s.priority.levels[0], 1)
+
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+			ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	}()
+	for clock.pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if got := s.Stats().PriorityLevels[0].Queued; got != 1 {
+		t.Fatalf("expected the request to be queued, got %d", got)
+	}
+	clock.Advance(10 * time.Millisecond)
+	<-done
+
+	if got := rec.Header().Get("X-Shed-Reason"); got != "queue_timeout" {
+		t.Errorf("expected X-Shed-Reason queue_timeout, got %q", got)
+	}
+	if got := s.Stats().PriorityLevels[0].Queued; got != 0 {
+		t.Errorf("expected empty queue after timeout, got %d", got)
+	}
+}
+
+func TestMiddleware_PriorityLevelDryRun(t *testing.T) {
+	s := New(Config{
+		HardLimit:      1,
+		DryRun:         true,
+		PriorityLevels: []PriorityLevel{{Name: "only", QueueLength: 1}},
+	})
+	s.priority.forceAcquire(s.priority.levels[0], 1)
+
+	rec := httptest.NewRecorder()
+	s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+		ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 in dry run, got %d", rec.Code)
+	}
+	if got := s.Stats().PriorityLevels[0].Inflight; got != 1 {
+		t.Errorf("expected the dry-run slot to be released, got inflight %d", got)
+	}
+}
+
+func TestPrioritySet_ReservedLimits(t *testing.T) {
+	ps := newPrioritySet([]PriorityLevel{
+		{Name: "high", Shares: 3},
+		{Name: "low", Shares: 1},
+		{Name: "health", Reserved: 5}, // no shares
+	}, nil, func() int64 { return 25 }, systemClock{})
+
+	want := map[string]int64{"high": 15, "low": 5, "health": 5}
+	for _, st := range ps.stats() {
+		if st.Limit != want[st.Name] {
+			t.Errorf("level %s: expected limit %d, got %d", st.Name, want[st.Name], st.Limit)
+		}
+	}
+}
+
+func TestMiddleware_PriorityReservedSkipsHardLimit(t *testing.T) {
+	s := New(Config{
+		HardLimit: 10,
+		PriorityLevels: []PriorityLevel{
+			{Name: "high", Shares: 1},
+			{Name: "health", Reserved: 1},
+		},
+		Classify: func(r *http.Request) string { return r.URL.Path[1:] },
+	})
+	// Saturate the pod.
+	for i := 0; i < 10; i++ {
+		s.increment(1)
+	}
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/high", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected high request to be shed at the hard limit, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected reserved health request to be admitted, got %d", rec.Code)
+	}
+}
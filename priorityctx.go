@@ -0,0 +1,49 @@
+package shedder
+
+import (
+	"context"
+	"net/http"
+)
+
+// priorityKey is the context key of a request's priority class.
+type priorityKey struct{}
+
+// WithPriority returns a copy of ctx carrying the priority class. Earlier
+// middleware that has authenticated or routed the request sets it, so
+// shedding never has to trust client-supplied priority headers:
+//
+//	r = r.WithContext(shedder.WithPriority(r.Context(), "critical"))
+func WithPriority(ctx context.Context, class string) context.Context {
+	return context.WithValue(ctx, priorityKey{}, class)
+}
+
+// PriorityFromContext returns the priority class set by WithPriority.
+func PriorityFromContext(ctx context.Context) (string, bool) {
+	class, ok := ctx.Value(priorityKey{}).(string)
+	return class, ok
+}
+
+// ShedPriorities returns a ShedDecider that sheds requests whose context
+// priority is one of classes. Include "" to also shed requests without a
+// priority.
+func ShedPriorities(classes ...string) ShedDecider {
+	set := make(map[string]bool, len(classes))
+	for _, c := range classes {
+		set[c] = true
+	}
+	return func(r *http.Request) bool {
+		class, _ := PriorityFromContext(r.Context())
+		return set[class]
+	}
+}
+
+// ClassifyContext returns a Config.Classify function that reads the
+// context priority, or def for requests without one.
+func ClassifyContext(def string) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		if class, ok := PriorityFromContext(r.Context()); ok {
+			return class
+		}
+		return def
+	}
+}
@@ -0,0 +1,85 @@
+package shedder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPriorityFromContext(t *testing.T) {
+	if _, ok := PriorityFromContext(context.Background()); ok {
+		t.Error("expected no priority on a bare context")
+	}
+	class, ok := PriorityFromContext(WithPriority(context.Background(), "critical"))
+	if !ok || class != "critical" {
+		t.Errorf("expected critical, got %q (ok=%v)", class, ok)
+	}
+}
+
+func TestShedPriorities(t *testing.T) {
+	decide := ShedPriorities("batch", "")
+	tests := []struct {
+		name  string
+		class string
+		set   bool
+		want  bool
+	}{
+		{"listed class", "batch", true, true},
+		{"other class", "critical", true, false},
+		{"no priority", "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.set {
+				r = r.WithContext(WithPriority(r.Context(), tt.class))
+			}
+			if got := decide(r); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestClassifyContext(t *testing.T) {
+	classify := ClassifyContext("default")
+	r := httptest.NewRequest("GET", "/", nil)
+	if got := classify(r); got != "default" {
+		t.Errorf("expected default, got %q", got)
+	}
+	if got := classify(r.WithContext(WithPriority(r.Context(), "system"))); got != "system" {
+		t.Errorf("expected system, got %q", got)
+	}
+}
+
+func TestMiddleware_ShedsByContextPriority(t *testing.T) {
+	s := New(Config{HardLimit: 10, SoftLimit: 1, ShedDecider: ShedPriorities("batch")})
+	s.increment(1)
+
+	// Upstream auth middleware assigns the priority; the header is ignored.
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			class := "batch"
+			if r.URL.Path == "/checkout" {
+				class = "critical"
+			}
+			next.ServeHTTP(w, r.WithContext(WithPriority(r.Context(), class)))
+		})
+	}
+	handler := auth(s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	req := httptest.NewRequest("GET", "/report", nil)
+	req.Header.Set("X-Priority", "critical")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected batch request to be shed, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/checkout", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected critical request to be admitted, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,54 @@
+package shedder
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ProblemType is the RFC 7807 problem type of shed responses; Problem.Reason
+// tells the shed reasons apart.
+const ProblemType = "urn:kube-shedder:load-shed"
+
+// Problem is the application/problem+json document written to shed requests
+// when Config.ProblemJSON is set.
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail"`
+
+	// Reason is the ShedReason, as in the X-Shed-Reason header.
+	Reason string `json:"reason"`
+
+	// RetryAfter mirrors the Retry-After header, in seconds.
+	RetryAfter int `json:"retry_after"`
+
+	// Limit and Inflight are the hard limit and in-flight count when the
+	// request was shed.
+	Limit    int64 `json:"limit"`
+	Inflight int64 `json:"inflight"`
+}
+
+// problem returns the Problem for a request shed for reason.
+func (s *Shedder) problem(reason ShedReason, status, retryAfter int) *Problem {
+	return &Problem{
+		Type:       ProblemType,
+		Title:      http.StatusText(status),
+		Status:     status,
+		Detail:     "The request was shed to protect the service from overload.",
+		Reason:     reason.String(),
+		RetryAfter: retryAfter,
+		Limit:      s.currentLimit(),
+		Inflight:   s.Inflight(),
+	}
+}
+
+// response returns p as a ShedResponse.
+func (p *Problem) response() *ShedResponse {
+	body, _ := json.Marshal(p)
+	return &ShedResponse{
+		StatusCode:  p.Status,
+		ContentType: "application/problem+json",
+		Body:        body,
+	}
+}
@@ -0,0 +1,52 @@
+package shedder
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestProblemJSON(t *testing.T) {
+	s := New(Config{
+		HardLimit:   4,
+		ProblemJSON: true,
+		ShedStatus:  map[ShedReason]int{ShedReasonHardLimit: http.StatusTooManyRequests},
+	})
+	rec := shedOne(t, s)
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("expected application/problem+json, got %q", ct)
+	}
+
+	var p Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatalf("invalid problem document: %v", err)
+	}
+	want := Problem{
+		Type:       ProblemType,
+		Title:      "Too Many Requests",
+		Status:     http.StatusTooManyRequests,
+		Detail:     p.Detail,
+		Reason:     "hard_limit",
+		RetryAfter: 1,
+		Limit:      4,
+		Inflight:   5,
+	}
+	if p != want {
+		t.Errorf("expected %+v, got %+v", want, p)
+	}
+}
+
+func TestProblemJSON_ShedResponseTakesPrecedence(t *testing.T) {
+	s := New(Config{
+		HardLimit:    1,
+		ProblemJSON:  true,
+		ShedResponse: &ShedResponse{Body: []byte("custom")},
+	})
+	if rec := shedOne(t, s); rec.Body.String() != "custom" {
+		t.Errorf("expected ShedResponse body, got %q", rec.Body.String())
+	}
+}
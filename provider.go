@@ -0,0 +1,75 @@
+package shedder
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// LimitProvider supplies limits from an external system, such as a
+// feature-flag service, so limit experiments can be run via flags.
+// GetLimits returns the hard and soft limits to apply; a hard limit of 0
+// keeps the current one and a negative soft limit keeps the current one
+// (0 disables soft limiting).
+type LimitProvider interface {
+	GetLimits(ctx context.Context) (hard, soft int64, err error)
+}
+
+// LimitProviderFunc adapts a function to a LimitProvider.
+type LimitProviderFunc func(ctx context.Context) (hard, soft int64, err error)
+
+// GetLimits calls f.
+func (f LimitProviderFunc) GetLimits(ctx context.Context) (hard, soft int64, err error) {
+	return f(ctx)
+}
+
+// FlagEvaluator evaluates an integer feature flag, returning def when the
+// flag is not set. OpenFeature's Client.IntValue and LaunchDarkly's
+// LDClient.IntVariation fit it with a one-line wrapper.
+type FlagEvaluator func(ctx context.Context, flag string, def int64) (int64, error)
+
+// FlagLimits returns a LimitProvider reading the hard and soft limits from
+// two integer flags. Unset flags keep the current limits. An empty softFlag
+// leaves the soft limit alone.
+func FlagLimits(eval FlagEvaluator, hardFlag, softFlag string) LimitProvider {
+	return LimitProviderFunc(func(ctx context.Context) (int64, int64, error) {
+		hard, err := eval(ctx, hardFlag, 0)
+		if err != nil {
+			return 0, 0, err
+		}
+		soft := int64(-1)
+		if softFlag != "" {
+			if soft, err = eval(ctx, softFlag, -1); err != nil {
+				return 0, 0, err
+			}
+		}
+		return hard, soft, nil
+	})
+}
+
+// PollLimits applies the limits from p, then polls it every Interval and
+// applies changes until ctx is done. Invalid limits are reported to
+// OnReload and not applied.
+//
+// It returns an error, without polling, if the limits cannot be loaded or
+// applied initially.
+func (s *Shedder) PollLimits(ctx context.Context, p LimitProvider, opts ReloadOptions) error {
+	rl := newReloader(s, &opts, func(ctx context.Context) ([]byte, error) {
+		hard, soft, err := p.GetLimits(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var dc DynamicConfig
+		if hard != 0 {
+			dc.HardLimit = &hard
+		}
+		if soft >= 0 {
+			dc.SoftLimit = &soft
+		}
+		return json.Marshal(dc)
+	})
+	if err := rl.reload(ctx); err != nil {
+		return err
+	}
+	rl.start(ctx, opts)
+	return nil
+}
@@ -0,0 +1,77 @@
+package shedder
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShedder_PollLimits(t *testing.T) {
+	var hard atomic.Int64
+	hard.Store(50)
+	p := LimitProviderFunc(func(ctx context.Context) (int64, int64, error) {
+		return hard.Load(), -1, nil
+	})
+
+	s := New(Config{HardLimit: 100, SoftLimit: 30})
+	reloads := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := s.PollLimits(ctx, p, ReloadOptions{
+		Interval: 5 * time.Millisecond,
+		OnReload: func(dc DynamicConfig, err error) { reloads <- err },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-reloads
+	if s.HardLimit() != 50 || s.SoftLimit() != 30 {
+		t.Errorf("expected limits 50/30, got %d/%d", s.HardLimit(), s.SoftLimit())
+	}
+
+	hard.Store(20) // below the soft limit: rejected
+	if err := waitReload(t, reloads); err == nil {
+		t.Error("expected invalid limits to be reported")
+	}
+	if s.HardLimit() != 50 {
+		t.Errorf("expected hard limit to stay 50, got %d", s.HardLimit())
+	}
+
+	hard.Store(80)
+	if err := waitReload(t, reloads); err != nil {
+		t.Fatal(err)
+	}
+	if s.HardLimit() != 80 {
+		t.Errorf("expected hard limit 80, got %d", s.HardLimit())
+	}
+}
+
+func TestFlagLimits(t *testing.T) {
+	flags := map[string]int64{"shedder-hard-limit": 120}
+	eval := func(ctx context.Context, flag string, def int64) (int64, error) {
+		if flag == "broken" {
+			return 0, errors.New("flag service down")
+		}
+		if v, ok := flags[flag]; ok {
+			return v, nil
+		}
+		return def, nil
+	}
+
+	hard, soft, err := FlagLimits(eval, "shedder-hard-limit", "shedder-soft-limit").GetLimits(context.Background())
+	if err != nil || hard != 120 || soft != -1 {
+		t.Errorf("expected 120/-1 (unset soft flag), got %d/%d (%v)", hard, soft, err)
+	}
+
+	flags["shedder-soft-limit"] = 90
+	if _, soft, _ = FlagLimits(eval, "shedder-hard-limit", "shedder-soft-limit").GetLimits(context.Background()); soft != 90 {
+		t.Errorf("expected soft 90, got %d", soft)
+	}
+
+	if _, _, err := FlagLimits(eval, "broken", "").GetLimits(context.Background()); err == nil {
+		t.Error("expected evaluator error")
+	}
+}
@@ -0,0 +1,191 @@
+package shedder
+
+import (
+	"container/list"
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// QueueConfig configures waiting for capacity at the hard limit.
+type QueueConfig struct {
+	// Timeout is the longest a request waits for a free slot before it is
+	// shed. The request's context deadline also bounds the wait. Required.
+	Timeout time.Duration
+
+	// MaxLength bounds the number of waiting requests; requests arriving
+	// at a full queue are shed immediately. Defaults to the hard limit.
+	MaxLength int
+
+	// Weights enables weighted fair queueing across request classes, as
+	// returned by Config.Classify. Freed slots are shared between waiting
+	// classes in proportion to their weights instead of going to whoever
+	// queued first, so a busy class cannot starve the others. Classes
+	// without a weight get 1. If nil, the queue is a single FIFO.
+	Weights map[string]int
+}
+
+// waitQueue holds requests waiting for an in-flight slot. Each class has
+// its own FIFO; wakeups go to the head with the smallest virtual finish
+// tag (self-clocked fair queueing), which with a single class is plain
+// FIFO order.
+type waitQueue struct {
+	clock     Clock
+	timeout   time.Duration
+	maxLength int
+	weights   map[string]int
+
+	mu      sync.Mutex
+	classes map[string]*waitClass
+	vtime   float64 // finish tag of the last waiter woken
+	waiting int
+	length  atomic.Int64
+}
+
+// waitClass is the FIFO of one request class.
+type waitClass struct {
+	waiters    list.List // of *waiter
+	lastFinish float64
+}
+
+// waiter is a queued request; ch is closed to wake it.
+type waiter struct {
+	ch     chan struct{}
+	finish float64
+}
+
+// newWaitQueue returns a queue for cfg, timed by clock; hardLimit is the
+// default length.
+func newWaitQueue(cfg QueueConfig, hardLimit int64, clock Clock) *waitQueue {
+	if cfg.MaxLength <= 0 {
+		cfg.MaxLength = int(hardLimit)
+	}
+	return &waitQueue{
+		clock:     clock,
+		timeout:   cfg.Timeout,
+		maxLength: cfg.MaxLength,
+		weights:   cfg.Weights,
+		classes:   make(map[string]*waitClass),
+	}
+}
+
+// weight returns the weight of class.
+func (q *waitQueue) weight(class string) float64 {
+	if w := q.weights[class]; w > 0 {
+		return float64(w)
+	}
+	return 1
+}
+
+// wait blocks until the caller is notified of a free slot, ready reports
+// capacity right after enqueueing, ctx is done, or the deadline passes.
+// It returns false if the queue is full or the wait gave up.
+func (q *waitQueue) wait(ctx context.Context, deadline time.Time, class string, ready func() bool) bool {
+	q.mu.Lock()
+	if q.waiting >= q.maxLength {
+		q.mu.Unlock()
+		return false
+	}
+	// Re-check under the lock: a slot released before we enqueued would
+	// otherwise never notify us.
+	if ready() {
+		q.mu.Unlock()
+		return true
+	}
+	wc := q.classes[class]
+	if wc == nil {
+		wc = &waitClass{}
+		q.classes[class] = wc
+	}
+	w := &waiter{ch: make(chan struct{}), finish: math.Max(q.vtime, wc.lastFinish) + 1/q.weight(class)}
+	wc.lastFinish = w.finish
+	elem := wc.waiters.PushBack(w)
+	q.waiting++
+	q.length.Add(1)
+	q.mu.Unlock()
+
+	timer := q.clock.NewTimer(deadline.Sub(q.clock.Now()))
+	defer timer.Stop()
+
+	select {
+	case <-w.ch:
+		return true
+	case <-ctx.Done():
+	case <-timer.C():
+	}
+
+	q.mu.Lock()
+	select {
+	case <-w.ch:
+		// Notified while giving up: hand the wakeup to the next waiter.
+		q.mu.Unlock()
+		q.notify()
+	default:
+		wc.waiters.Remove(elem)
+		q.waiting--
+		q.length.Add(-1)
+		q.mu.Unlock()
+	}
+	return false
+}
+
+// notify wakes the waiting request with the smallest finish tag, if any.
+func (q *waitQueue) notify() {
+	if q.length.Load() == 0 {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var next *waitClass
+	for _, wc := range q.classes {
+		if front := wc.waiters.Front(); front != nil &&
+			(next == nil || front.Value.(*waiter).finish < next.waiters.Front().Value.(*waiter).finish) {
+			next = wc
+		}
+	}
+	if next == nil {
+		return
+	}
+	w := next.waiters.Remove(next.waiters.Front()).(*waiter)
+	q.vtime = w.finish
+	q.waiting--
+	q.length.Add(-1)
+	close(w.ch)
+}
+
+// waitForSlot queues a request that exceeded the hard limit until a slot
+// frees up, then re-runs admission. It returns the new in-flight count and
+// the admission outcome; on timeout the request is shed with
+// ShedReasonQueueTimeout. The caller's in-flight increment is preserved so
+// the deferred decrement stays balanced.
+func (s *Shedder) waitForSlot(r *http.Request, current, cost int64, route *routeState, retry bool) (int64, ShedReason, bool) {
+	// The context's own deadline ends the wait through its Done channel.
+	deadline := s.now().Add(s.queue.timeout)
+
+	var class string
+	if s.queue.weights != nil && s.classify != nil {
+		class = s.classify(r)
+	}
+
+	for {
+		// Give up our over-limit slot while waiting, without waking others.
+		s.inflight.Add(-cost)
+		ok := s.queue.wait(r.Context(), deadline, class, func() bool {
+			return s.inflight.Load()+cost <= s.currentLimit()
+		})
+		current = s.inflight.Add(cost)
+		if !ok {
+			return current, ShedReasonQueueTimeout, true
+		}
+
+		reason, shed := s.admit(r, current, route, retry)
+		if !shed || reason != ShedReasonHardLimit {
+			return current, reason, shed
+		}
+		// Another request took the slot first; wait for the next one.
+	}
+}
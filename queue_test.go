@@ -0,0 +1,163 @@
+package shedder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMiddleware_QueueAdmitsWhenSlotFrees(t *testing.T) {
+	s := New(Config{HardLimit: 1, Queue: &QueueConfig{Timeout: time.Second}})
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/queued", nil))
+		close(done)
+	}()
+
+	// Wait until the second request is queued, then free the slot.
+	for s.Stats().Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	<-done
+	wg.Wait()
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected queued request to be admitted, got %d", rec.Code)
+	}
+	if s.Inflight() != 0 {
+		t.Errorf("expected inflight 0, got %d", s.Inflight())
+	}
+}
+
+func TestMiddleware_QueueTimeout(t *testing.T) {
+	clock := newFakeClock()
+	s := New(Config{HardLimit: 1, Clock: clock, Queue: &QueueConfig{Timeout: 20 * time.Millisecond}})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	s.increment(1)
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	}()
+	for clock.pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	clock.Advance(19 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("expected request to wait for the queue timeout")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.Advance(time.Millisecond)
+	<-done
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 after queue timeout, got %d", rec.Code)
+	}
+	if rec.Header().Get("X-Shed-Reason") != "queue_timeout" {
+		t.Errorf("expected X-Shed-Reason 'queue_timeout', got %q", rec.Header().Get("X-Shed-Reason"))
+	}
+	if s.Inflight() != 1 {
+		t.Errorf("expected only the pre-existing request in flight, got %d", s.Inflight())
+	}
+}
+
+func TestMiddleware_QueueRespectsContext(t *testing.T) {
+	s := New(Config{HardLimit: 1, Queue: &QueueConfig{Timeout: time.Hour}})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	s.increment(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 when the request context expires, got %d", rec.Code)
+	}
+}
+
+func TestWaitQueue_Full(t *testing.T) {
+	q := newWaitQueue(QueueConfig{Timeout: time.Hour, MaxLength: 1}, 10, systemClock{})
+	never := func() bool { return false }
+
+	go q.wait(context.Background(), time.Now().Add(time.Hour), "", never)
+	for q.length.Load() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if q.wait(context.Background(), time.Now().Add(time.Hour), "", never) {
+		t.Error("expected a full queue to reject immediately")
+	}
+	q.notify()
+}
+
+func TestWaitQueue_WeightedFairOrder(t *testing.T) {
+	q := newWaitQueue(QueueConfig{Timeout: time.Hour, MaxLength: 100, Weights: map[string]int{"heavy": 2}}, 10, systemClock{})
+	never := func() bool { return false }
+
+	// Queue five "light" requests before three "heavy" ones; FIFO would
+	// wake all light requests first.
+	woken := make(chan string, 8)
+	enqueue := func(class string) {
+		n := q.length.Load()
+		go func() {
+			if q.wait(context.Background(), time.Now().Add(time.Hour), class, never) {
+				woken <- class
+			}
+		}()
+		for q.length.Load() != n+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		enqueue("light")
+	}
+	for i := 0; i < 3; i++ {
+		enqueue("heavy")
+	}
+
+	var order []string
+	for i := 0; i < 8; i++ {
+		q.notify()
+		order = append(order, <-woken)
+	}
+
+	// Finish tags: light 1,2,3,4,5 and heavy 0.5,1,1.5 (ties keep either).
+	heavy := 0
+	for _, class := range order[:4] {
+		if class == "heavy" {
+			heavy++
+		}
+	}
+	if heavy != 3 {
+		t.Errorf("expected all heavy requests within the first four wakeups, got order %v", order)
+	}
+}
@@ -0,0 +1,76 @@
+package shedder
+
+import (
+	"net/http"
+	"sort"
+	"sync/atomic"
+)
+
+// ClassQuotaStats describes the in-flight usage of one class quota.
+type ClassQuotaStats struct {
+	Class    string `json:"class"`
+	Inflight int64  `json:"inflight"`
+	Limit    int64  `json:"limit"`
+}
+
+// classQuotas caps each class's in-flight units at a fraction of the hard
+// limit in effect. The set of classes is fixed, so counting is lock-free;
+// Apply replaces the whole set.
+type classQuotas struct {
+	classify func(r *http.Request) string
+	quotas   map[string]*classQuota
+}
+
+// classQuota tracks one class.
+type classQuota struct {
+	fraction float64
+	inflight *atomic.Int64 // shared with the quota's replacements
+}
+
+// newClassQuotas returns quotas for fractions; a fraction of 1, or one
+// outside (0, 1) that validation would reject, leaves a class unlimited. Classes also in prev, if non-nil, keep counting
+// their in-flight units, which requests admitted under prev release.
+func newClassQuotas(fractions map[string]float64, classify func(r *http.Request) string, prev *classQuotas) *classQuotas {
+	cq := &classQuotas{classify: classify, quotas: make(map[string]*classQuota)}
+	for class, f := range fractions {
+		if f <= 0 || f >= 1 {
+			continue
+		}
+		q := &classQuota{fraction: f, inflight: new(atomic.Int64)}
+		if prev != nil {
+			if old, ok := prev.quotas[class]; ok {
+				q.inflight = old.inflight
+			}
+		}
+		cq.quotas[class] = q
+	}
+	return cq
+}
+
+// limit returns q's limit for the hard limit in effect, at least 1.
+func (q *classQuota) limit(hardLimit int64) int64 {
+	if l := int64(q.fraction * float64(hardLimit)); l > 0 {
+		return l
+	}
+	return 1
+}
+
+// acquire counts cost units of r against its class quota and reports
+// whether the class is over it. The caller must release a non-nil quota.
+func (cq *classQuotas) acquire(r *http.Request, cost, hardLimit int64) (*classQuota, bool) {
+	q, ok := cq.quotas[cq.classify(r)]
+	if !ok {
+		return nil, false
+	}
+	return q, q.inflight.Add(cost) > q.limit(hardLimit)
+}
+
+// stats returns a snapshot of all quotas, sorted by class.
+func (cq *classQuotas) stats(hardLimit int64) []ClassQuotaStats {
+	out := make([]ClassQuotaStats, 0, len(cq.quotas))
+	for class, q := range cq.quotas {
+		out = append(out, ClassQuotaStats{Class: class, Inflight: q.inflight.Load(), Limit: q.limit(hardLimit)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Class < out[j].Class })
+	return out
+}
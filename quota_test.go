@@ -0,0 +1,68 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClassQuotas_Acquire(t *testing.T) {
+	cq := newClassQuotas(map[string]float64{"batch": 0.2, "interactive": 1, "tiny": 0.01},
+		func(r *http.Request) string { return r.URL.Path[1:] }, nil)
+	request := func(class string) *http.Request { return httptest.NewRequest("GET", "/"+class, nil) }
+
+	for i := 0; i < 2; i++ {
+		if _, over := cq.acquire(request("batch"), 1, 10); over {
+			t.Fatalf("batch request %d: expected under the quota of 2", i+1)
+		}
+	}
+	if _, over := cq.acquire(request("batch"), 1, 10); !over {
+		t.Error("expected third batch request to exceed the quota of 2")
+	}
+	if q, over := cq.acquire(request("interactive"), 100, 10); q != nil || over {
+		t.Error("expected a quota of 100% to leave the class unlimited")
+	}
+	if _, over := cq.acquire(request("tiny"), 1, 10); over {
+		t.Error("expected a quota to allow at least one unit")
+	}
+
+	st := cq.stats(10)
+	if len(st) != 2 || st[0].Class != "batch" || st[0].Inflight != 3 || st[0].Limit != 2 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestMiddleware_ClassQuota(t *testing.T) {
+	s := New(Config{
+		HardLimit:   10,
+		Classify:    func(r *http.Request) string { return r.Header.Get("X-Class") },
+		ClassQuotas: map[string]float64{"batch": 0.1},
+	})
+	var nested *httptest.ResponseRecorder
+	var handler http.Handler
+	handler = s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/outer" {
+			nested = httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("X-Class", r.Header.Get("X-Class"))
+			handler.ServeHTTP(nested, req)
+		}
+	}))
+	request := func(class string) {
+		req := httptest.NewRequest("GET", "/outer", nil)
+		req.Header.Set("X-Class", class)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	request("batch")
+	if got := nested.Header().Get("X-Shed-Reason"); got != "class_quota" {
+		t.Errorf("expected second batch request to be shed with class_quota, got %q", got)
+	}
+	request("interactive")
+	if nested.Code != http.StatusOK {
+		t.Errorf("expected unlimited class to be admitted, got %d", nested.Code)
+	}
+	if got := s.Stats().ClassQuotas[0].Inflight; got != 0 {
+		t.Errorf("expected quota slots released, got %d", got)
+	}
+}
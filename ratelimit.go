@@ -0,0 +1,42 @@
+package shedder
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// RateLimitHeaders selects which responses carry the draft-standard
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers, which
+// describe the concurrency budget: the limit is the hard limit, remaining
+// is the number of free in-flight slots, 0 on shed responses, and reset is
+// the number of seconds until a slot is expected to free up.
+type RateLimitHeaders int
+
+const (
+	// RateLimitHeadersOff sends no RateLimit headers.
+	RateLimitHeadersOff RateLimitHeaders = iota
+
+	// RateLimitHeadersShed sends RateLimit headers on shed responses.
+	RateLimitHeadersShed
+
+	// RateLimitHeadersAll sends RateLimit headers on all responses.
+	RateLimitHeadersAll
+)
+
+// setRateLimitHeaders sets the RateLimit headers with the given number of
+// free in-flight slots, which is 0 on shed responses; reset is the
+// Retry-After value of shed responses, or 0.
+func (s *Shedder) setRateLimitHeaders(h http.Header, remaining int64, reset int) {
+	setHeaders(h,
+		"Ratelimit-Limit", strconv.FormatInt(s.currentLimit(), 10),
+		"Ratelimit-Remaining", strconv.FormatInt(remaining, 10),
+		"Ratelimit-Reset", strconv.Itoa(reset))
+}
+
+// setTelemetryHeaders sets the X-Shedder-* load headers for current
+// in-flight units.
+func (s *Shedder) setTelemetryHeaders(h http.Header, current int64) {
+	setHeaders(h,
+		"X-Shedder-Inflight", strconv.FormatInt(current, 10),
+		"X-Shedder-Utilization", strconv.FormatFloat(float64(current)/float64(s.currentLimit()), 'f', 2, 64))
+}
@@ -0,0 +1,91 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRateLimitHeaders(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      RateLimitHeaders
+		wantShed  bool
+		wantServe bool
+	}{
+		{"off", RateLimitHeadersOff, false, false},
+		{"shed", RateLimitHeadersShed, true, false},
+		{"all", RateLimitHeadersAll, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(Config{HardLimit: 4, RateLimitHeaders: tt.mode})
+
+			rec := shedOne(t, s)
+			if got := rec.Header().Get("RateLimit-Limit") != ""; got != tt.wantShed {
+				t.Fatalf("shed response: expected headers %v, got %v", tt.wantShed, got)
+			}
+			if tt.wantShed {
+				assertHeader(t, rec, "RateLimit-Limit", "4")
+				assertHeader(t, rec, "RateLimit-Remaining", "0")
+				assertHeader(t, rec, "RateLimit-Reset", "1")
+			}
+
+			s.increment(1)
+			rec = httptest.NewRecorder()
+			s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+				ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+			s.decrement(1)
+			if got := rec.Header().Get("RateLimit-Limit") != ""; got != tt.wantServe {
+				t.Fatalf("admitted response: expected headers %v, got %v", tt.wantServe, got)
+			}
+			if tt.wantServe {
+				assertHeader(t, rec, "RateLimit-Limit", "4")
+				assertHeader(t, rec, "RateLimit-Remaining", "2")
+				assertHeader(t, rec, "RateLimit-Reset", "0")
+			}
+		})
+	}
+}
+
+func TestRateLimitHeaders_SoftShed(t *testing.T) {
+	s := New(Config{
+		HardLimit:        4,
+		SoftLimit:        2,
+		ShedDecider:      func(r *http.Request) bool { return true },
+		RateLimitHeaders: RateLimitHeadersShed,
+	})
+	s.increment(2)
+	defer s.decrement(2)
+
+	rec := httptest.NewRecorder()
+	s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler should not be called")
+	})).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	// Slots are free below the hard limit, but none for this request.
+	assertHeader(t, rec, "RateLimit-Limit", "4")
+	assertHeader(t, rec, "RateLimit-Remaining", "0")
+}
+
+func assertHeader(t *testing.T, rec *httptest.ResponseRecorder, name, want string) {
+	t.Helper()
+	if got := rec.Header().Get(name); got != want {
+		t.Errorf("expected %s %q, got %q", name, want, got)
+	}
+}
+
+func TestTelemetryHeaders(t *testing.T) {
+	s := New(Config{HardLimit: 4, TelemetryHeaders: true})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	s.increment(2)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	s.decrement(2)
+	assertHeader(t, rec, "X-Shedder-Inflight", "3")
+	assertHeader(t, rec, "X-Shedder-Utilization", "0.75")
+
+	if got := shedOne(t, s).Header().Get("X-Shedder-Inflight"); got != "" {
+		t.Errorf("expected no telemetry headers on shed responses, got %q", got)
+	}
+}
@@ -0,0 +1,74 @@
+package shedder
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ReadinessConfig configures hysteresis for the readiness probe.
+type ReadinessConfig struct {
+	// UnreadyAbove is the fraction of the hard limit in effect above which
+	// in-flight requests make the pod unready. Defaults to 1.
+	UnreadyAbove float64
+
+	// ReadyBelow is the fraction of the hard limit in effect at or below
+	// which in-flight requests make an unready pod ready again. It should be
+	// below UnreadyAbove, so a pod under steady borderline load does not
+	// flap in and out of the Service. Defaults to UnreadyAbove (no
+	// hysteresis).
+	ReadyBelow float64
+
+	// MinUnready is the least time the pod stays unready once it became
+	// unready, e.g. 10s, damping endpoint churn that causes connection
+	// resets and retry storms under oscillating load. 0 disables it.
+	MinUnready time.Duration
+
+	// MinReady is the least time the pod stays ready once it became ready
+	// again before it can be marked unready by load. 0 disables it.
+	// Draining is never delayed.
+	MinReady time.Duration
+}
+
+// readiness tracks the readiness probe state for ReadinessConfig.
+type readiness struct {
+	unreadyAbove float64
+	readyBelow   float64
+	minUnready   int64 // nanoseconds
+	minReady     int64 // nanoseconds
+
+	unready atomic.Bool
+	since   atomic.Int64 // unix nanoseconds of the last flip, 0 if none
+}
+
+// newReadiness returns readiness state for cfg with defaults applied.
+func newReadiness(cfg ReadinessConfig) *readiness {
+	if cfg.UnreadyAbove <= 0 {
+		cfg.UnreadyAbove = 1
+	}
+	if cfg.ReadyBelow <= 0 || cfg.ReadyBelow > cfg.UnreadyAbove {
+		cfg.ReadyBelow = cfg.UnreadyAbove
+	}
+	return &readiness{
+		unreadyAbove: cfg.UnreadyAbove,
+		readyBelow:   cfg.ReadyBelow,
+		minUnready:   int64(cfg.MinUnready),
+		minReady:     int64(cfg.MinReady),
+	}
+}
+
+// overloaded updates the state at now for inflight requests under limit,
+// with signal reporting whether the OverloadSignal fires, and reports
+// whether the pod is unready. The state only flips once it has been held
+// for its minimum duration.
+func (rd *readiness) overloaded(inflight, limit int64, signal bool, now time.Time) bool {
+	unready := rd.unready.Load()
+	threshold, hold := rd.unreadyAbove, rd.minReady
+	if unready {
+		threshold, hold = rd.readyBelow, rd.minUnready
+	}
+	want := signal || float64(inflight) > threshold*float64(limit)
+	if want != unready && now.UnixNano()-rd.since.Load() >= hold && rd.unready.CompareAndSwap(unready, want) {
+		rd.since.Store(now.UnixNano())
+	}
+	return rd.unready.Load()
+}
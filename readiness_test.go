@@ -0,0 +1,83 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReadiness_Hysteresis(t *testing.T) {
+	rd := newReadiness(ReadinessConfig{UnreadyAbove: 0.9, ReadyBelow: 0.7})
+
+	steps := []struct {
+		inflight int64
+		want     bool
+	}{
+		{90, false}, // at the threshold
+		{91, true},  // above it
+		{80, true},  // between the thresholds: stays unready
+		{71, true},
+		{70, false}, // at the ready threshold
+		{80, false}, // between the thresholds: stays ready
+	}
+	for i, st := range steps {
+		if got := rd.overloaded(st.inflight, 100, false, time.Now()); got != st.want {
+			t.Errorf("step %d (inflight %d): overloaded = %v, want %v", i, st.inflight, got, st.want)
+		}
+	}
+}
+
+func TestReadiness_Defaults(t *testing.T) {
+	rd := newReadiness(ReadinessConfig{ReadyBelow: 2})
+	if rd.unreadyAbove != 1 || rd.readyBelow != 1 {
+		t.Errorf("expected thresholds of 1, got %v and %v", rd.unreadyAbove, rd.readyBelow)
+	}
+	if !rd.overloaded(11, 10, false, time.Now()) || rd.overloaded(10, 10, false, time.Now()) {
+		t.Error("expected the default to match the hard limit without hysteresis")
+	}
+}
+
+func TestReadyHandler_Hysteresis(t *testing.T) {
+	s := New(Config{HardLimit: 10, Readiness: &ReadinessConfig{ReadyBelow: 0.5}})
+	probe := func() int {
+		rec := httptest.NewRecorder()
+		s.ReadyHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/ready", nil))
+		return rec.Code
+	}
+
+	s.increment(11)
+	if code := probe(); code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 above the hard limit, got %d", code)
+	}
+	s.decrement(3)
+	if code := probe(); code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 until below the ready threshold, got %d", code)
+	}
+	s.decrement(3)
+	if code := probe(); code != http.StatusOK {
+		t.Errorf("expected 200 at the ready threshold, got %d", code)
+	}
+}
+
+func TestReadiness_HoldDurations(t *testing.T) {
+	rd := newReadiness(ReadinessConfig{MinUnready: 10 * time.Second, MinReady: 5 * time.Second})
+	start := time.Now()
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	if !rd.overloaded(11, 10, false, at(0)) {
+		t.Fatal("expected the first transition to be immediate")
+	}
+	if !rd.overloaded(0, 10, false, at(9*time.Second)) {
+		t.Error("expected to stay unready for MinUnready")
+	}
+	if rd.overloaded(0, 10, false, at(10*time.Second)) {
+		t.Error("expected to become ready after MinUnready")
+	}
+	if rd.overloaded(0, 10, true, at(14*time.Second)) {
+		t.Error("expected to stay ready for MinReady, even with the overload signal")
+	}
+	if !rd.overloaded(0, 10, true, at(15*time.Second)) {
+		t.Error("expected the overload signal to make the pod unready after MinReady")
+	}
+}
@@ -0,0 +1,114 @@
+package shedder
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sampath030/kube-shedder/internal/redis"
+)
+
+// RedisOptions configures a RedisCounterStore.
+type RedisOptions struct {
+	// Addr is the server's host:port, e.g. "redis.infra:6379".
+	Addr string
+
+	// Username and Password authenticate with AUTH if Password is set.
+	Username string
+	Password string
+
+	// DB is the database to use.
+	DB int
+
+	// TLSConfig, if set, makes connections use TLS.
+	TLSConfig *tls.Config
+
+	// PoolSize is the number of idle connections kept. Defaults to 16.
+	PoolSize int
+}
+
+// acquireScript takes a lease if fewer than ARGV[1] unexpired leases are
+// held on KEYS[1], a sorted set of leases scored by their expiry in
+// milliseconds. It reads the server's clock, so the pods' clocks need
+// not agree.
+const acquireScript = `
+redis.replicate_commands()
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], now + tonumber(ARGV[2]), ARGV[3])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return 1
+`
+
+// RedisCounterStore is a CounterStore in Redis. Each key is a sorted set
+// of leases, so a SharedBudget costs one round trip per admitted request
+// and one, in the background, when it completes. It needs Redis 3.2 or
+// later and a single primary: replicas and Cluster resharding may lose
+// leases for up to their TTL.
+type RedisCounterStore struct {
+	client *redis.Client
+	sha    string
+}
+
+// NewRedisCounterStore returns a store for opts. It connects on first use.
+func NewRedisCounterStore(opts RedisOptions) *RedisCounterStore {
+	sum := sha1.Sum([]byte(acquireScript))
+	return &RedisCounterStore{
+		client: redis.New(redis.Options{
+			Addr:      opts.Addr,
+			Username:  opts.Username,
+			Password:  opts.Password,
+			DB:        opts.DB,
+			TLSConfig: opts.TLSConfig,
+			PoolSize:  opts.PoolSize,
+		}),
+		sha: hex.EncodeToString(sum[:]),
+	}
+}
+
+// Acquire implements CounterStore.
+func (rs *RedisCounterStore) Acquire(ctx context.Context, key string, limit int64, ttl time.Duration) (string, bool, error) {
+	var id [16]byte
+	rand.Read(id[:])
+	lease := hex.EncodeToString(id[:])
+	args := []string{key, strconv.FormatInt(limit, 10), strconv.FormatInt(ttl.Milliseconds(), 10), lease}
+
+	reply, err := rs.client.Do(ctx, append([]string{"EVALSHA", rs.sha, "1"}, args...)...)
+	var rerr redis.Error
+	if errors.As(err, &rerr) && rerr.Prefix() == "NOSCRIPT" {
+		// Loaded once per server; EVAL caches the script for EVALSHA.
+		reply, err = rs.client.Do(ctx, append([]string{"EVAL", acquireScript, "1"}, args...)...)
+	}
+	if err != nil {
+		return "", false, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return "", false, fmt.Errorf("shedder: unexpected reply %v from Redis", reply)
+	}
+	if n == 0 {
+		return "", false, nil
+	}
+	return lease, true, nil
+}
+
+// Release implements CounterStore.
+func (rs *RedisCounterStore) Release(ctx context.Context, key, lease string) error {
+	_, err := rs.client.Do(ctx, "ZREM", key, lease)
+	return err
+}
+
+// Close closes the store's idle connections.
+func (rs *RedisCounterStore) Close() error {
+	return rs.client.Close()
+}
@@ -0,0 +1,124 @@
+package shedder
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis serves the commands RedisCounterStore sends, emulating the
+// acquire script without expiry. It reports NOSCRIPT until EVAL is used.
+type fakeRedis struct {
+	mu     sync.Mutex
+	loaded bool
+	leases map[string]map[string]bool
+	cmds   []string
+}
+
+func (fr *fakeRedis) serve(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go fr.handle(nc)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func (fr *fakeRedis) handle(nc net.Conn) {
+	defer nc.Close()
+	r := bufio.NewReader(nc)
+	for {
+		var n int
+		if _, err := fmt.Fscanf(r, "*%d\r\n", &n); err != nil {
+			return
+		}
+		args := make([]string, n)
+		for i := range args {
+			var size int
+			if _, err := fmt.Fscanf(r, "$%d\r\n", &size); err != nil {
+				return
+			}
+			buf := make([]byte, size+2)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			args[i] = string(buf[:size])
+		}
+		nc.Write([]byte(fr.reply(args)))
+	}
+}
+
+func (fr *fakeRedis) reply(args []string) string {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	fr.cmds = append(fr.cmds, args[0])
+	switch args[0] {
+	case "EVALSHA", "EVAL":
+		if args[0] == "EVALSHA" && !fr.loaded {
+			return "-NOSCRIPT No matching script\r\n"
+		}
+		fr.loaded = true
+		key, lease := args[3], args[6]
+		limit, _ := strconv.Atoi(args[4])
+		if fr.leases == nil {
+			fr.leases = make(map[string]map[string]bool)
+		}
+		if fr.leases[key] == nil {
+			fr.leases[key] = make(map[string]bool)
+		}
+		if len(fr.leases[key]) >= limit {
+			return ":0\r\n"
+		}
+		fr.leases[key][lease] = true
+		return ":1\r\n"
+	case "ZREM":
+		delete(fr.leases[args[1]], args[2])
+		return ":1\r\n"
+	default:
+		return "-ERR unknown command\r\n"
+	}
+}
+
+func TestRedisCounterStore(t *testing.T) {
+	fr := &fakeRedis{}
+	rs := NewRedisCounterStore(RedisOptions{Addr: fr.serve(t)})
+	defer rs.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	first, ok, err := rs.Acquire(ctx, "db", 1, time.Minute)
+	if err != nil || !ok || first == "" {
+		t.Fatalf("expected a lease, got %q, %v, %v", first, ok, err)
+	}
+	if _, ok, err := rs.Acquire(ctx, "db", 1, time.Minute); err != nil || ok {
+		t.Errorf("expected the budget spent, got %v, %v", ok, err)
+	}
+	if err := rs.Release(ctx, "db", first); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := rs.Acquire(ctx, "db", 1, time.Minute); err != nil || !ok {
+		t.Errorf("expected a lease after release, got %v, %v", ok, err)
+	}
+
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if got := strings.Join(fr.cmds, " "); got != "EVALSHA EVAL EVALSHA ZREM EVALSHA" {
+		t.Errorf("expected the script loaded once, then run by hash, got %s", got)
+	}
+}
@@ -0,0 +1,148 @@
+package shedder
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// ReloadOptions configures reloading a DynamicConfig into a running
+// Shedder.
+type ReloadOptions struct {
+	// Interval is how often the source is checked for changes. Defaults
+	// to 10s; a negative value disables polling, e.g. to reload only on
+	// SIGHUP. WatchConfigMap, which watches instead of polling, waits
+	// Interval, or 10s if negative, before retrying a failed watch.
+	Interval time.Duration
+
+	// ReloadOnSIGHUP also re-reads the source when the process receives
+	// SIGHUP, the reload convention of many daemons and sidecars.
+	ReloadOnSIGHUP bool
+
+	// OnReload, if set, is called after each changed configuration is
+	// loaded, with the error if it could not be read, parsed or applied.
+	// Invalid configurations are not applied; the previous one stays in
+	// effect.
+	OnReload func(dc DynamicConfig, err error)
+}
+
+// reloader applies configurations read by load whenever their content
+// changes.
+type reloader struct {
+	s        *Shedder
+	load     func(ctx context.Context) ([]byte, error)
+	onReload func(dc DynamicConfig, err error)
+	last     []byte
+}
+
+// newReloader returns a reloader for s with opts' defaults applied.
+func newReloader(s *Shedder, opts *ReloadOptions, load func(ctx context.Context) ([]byte, error)) *reloader {
+	if opts.Interval == 0 {
+		opts.Interval = 10 * time.Second
+	}
+	return &reloader{s: s, load: load, onReload: opts.OnReload}
+}
+
+// reload loads the configuration and applies it if it changed since the
+// last load.
+func (rl *reloader) reload(ctx context.Context) error {
+	data, err := rl.load(ctx)
+	if err != nil {
+		rl.report(DynamicConfig{}, err)
+		return err
+	}
+	return rl.apply(data)
+}
+
+// apply applies the configuration in data if it changed since the last
+// one.
+func (rl *reloader) apply(data []byte) error {
+	if rl.last != nil && bytes.Equal(data, rl.last) {
+		return nil
+	}
+	// Remember invalid content too, so it is reported only once.
+	rl.last = data
+	dc, err := ParseDynamicConfig(data)
+	if err == nil {
+		err = rl.s.Apply(dc)
+	}
+	rl.report(dc, err)
+	return err
+}
+
+// report invokes the OnReload hook, if any.
+func (rl *reloader) report(dc DynamicConfig, err error) {
+	if rl.onReload != nil {
+		rl.onReload(dc, err)
+	}
+}
+
+// watch reloads every interval (unless negative) and, if signals is
+// non-nil, whenever a signal arrives, until ctx is done.
+func (rl *reloader) watch(ctx context.Context, interval time.Duration, signals <-chan os.Signal) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-signals:
+		}
+		rl.reload(ctx)
+	}
+}
+
+// start watches in the background according to opts. The SIGHUP
+// subscription is taken before start returns, so no signal is missed.
+func (rl *reloader) start(ctx context.Context, opts ReloadOptions) {
+	signals := notifySIGHUP(opts)
+	go func() {
+		if signals != nil {
+			defer signal.Stop(signals)
+		}
+		rl.watch(ctx, opts.Interval, signals)
+	}()
+}
+
+// notifySIGHUP subscribes to SIGHUP if opts reload on it, and otherwise
+// returns nil.
+func notifySIGHUP(opts ReloadOptions) chan os.Signal {
+	if !opts.ReloadOnSIGHUP {
+		return nil
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	return signals
+}
+
+// WatchFile applies the JSON DynamicConfig in the file at path, e.g. a
+// ConfigMap volume, and re-applies it whenever its content changes until
+// ctx is done. The file is polled, which also catches the symlink swaps
+// kubelet uses to update ConfigMap volumes, and optionally re-read on
+// SIGHUP.
+//
+// Only JSON is accepted, whatever the file's extension: YAML would need a
+// third-party parser, and the module has no dependencies. JSON is valid
+// YAML, so a ConfigMap written as YAML can embed the configuration as a
+// JSON string. Polling likewise stands in for fsnotify.
+//
+// It returns an error, without watching, if the file cannot be loaded or
+// applied initially.
+func (s *Shedder) WatchFile(ctx context.Context, path string, opts ReloadOptions) error {
+	rl := newReloader(s, &opts, func(context.Context) ([]byte, error) {
+		return os.ReadFile(path)
+	})
+	if err := rl.reload(ctx); err != nil {
+		return err
+	}
+	rl.start(ctx, opts)
+	return nil
+}
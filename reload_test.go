@@ -0,0 +1,71 @@
+package shedder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestShedder_WatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shedder.json")
+	if err := os.WriteFile(path, []byte(`{"hard_limit": 50}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(Config{HardLimit: 100})
+	reloads := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := s.WatchFile(ctx, path, ReloadOptions{
+		Interval: 5 * time.Millisecond,
+		OnReload: func(dc DynamicConfig, err error) { reloads <- err },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-reloads; err != nil || s.HardLimit() != 50 {
+		t.Fatalf("expected initial load to apply hard limit 50, got %d (%v)", s.HardLimit(), err)
+	}
+
+	// Invalid content is reported and not applied.
+	os.WriteFile(path, []byte(`{"hard_limit": -1}`), 0o644)
+	if err := waitReload(t, reloads); err == nil {
+		t.Error("expected invalid config to be reported")
+	}
+	if s.HardLimit() != 50 {
+		t.Errorf("expected hard limit to stay 50, got %d", s.HardLimit())
+	}
+
+	os.WriteFile(path, []byte(`{"hard_limit": 70}`), 0o644)
+	for {
+		if err := waitReload(t, reloads); err == nil {
+			break
+		}
+	}
+	if s.HardLimit() != 70 {
+		t.Errorf("expected hard limit 70, got %d", s.HardLimit())
+	}
+}
+
+func TestShedder_WatchFileInitialError(t *testing.T) {
+	s := New(Config{HardLimit: 100})
+	err := s.WatchFile(context.Background(), filepath.Join(t.TempDir(), "missing.json"), ReloadOptions{})
+	if err == nil {
+		t.Error("expected error for a missing file")
+	}
+}
+
+// waitReload returns the next OnReload error, failing the test on timeout.
+func waitReload(t *testing.T, reloads <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-reloads:
+		return err
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for reload")
+		return nil
+	}
+}
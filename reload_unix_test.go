@@ -0,0 +1,44 @@
+//go:build unix
+
+package shedder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestShedder_WatchFileSIGHUP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shedder.json")
+	if err := os.WriteFile(path, []byte(`{"soft_limit": 10}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(Config{HardLimit: 100})
+	reloads := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := s.WatchFile(ctx, path, ReloadOptions{
+		Interval:       -1, // signal only
+		ReloadOnSIGHUP: true,
+		OnReload:       func(dc DynamicConfig, err error) { reloads <- err },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-reloads
+
+	os.WriteFile(path, []byte(`{"soft_limit": 20}`), 0o644)
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitReload(t, reloads); err != nil {
+		t.Fatal(err)
+	}
+	if s.SoftLimit() != 20 {
+		t.Errorf("expected soft limit 20 after SIGHUP, got %d", s.SoftLimit())
+	}
+}
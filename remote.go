@@ -0,0 +1,78 @@
+package shedder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// RemoteOptions configures WatchURL.
+type RemoteOptions struct {
+	ReloadOptions
+
+	// Client sends the requests. Defaults to http.DefaultClient; set one
+	// with a timeout in production.
+	Client *http.Client
+
+	// Header is added to each request, e.g. for authentication.
+	Header http.Header
+}
+
+// maxRemoteConfig bounds the size of a remote configuration document.
+const maxRemoteConfig = 1 << 20
+
+// WatchURL applies the JSON DynamicConfig served at url, for platforms that
+// centralize runtime tuning in a control service, and polls it every
+// Interval until ctx is done. Polls send If-None-Match with the last ETag,
+// so an unchanged configuration costs a 304 response.
+//
+// It returns an error, without watching, if the configuration cannot be
+// loaded or applied initially.
+func (s *Shedder) WatchURL(ctx context.Context, url string, opts RemoteOptions) error {
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var etag string
+	var last []byte
+	rl := newReloader(s, &opts.ReloadOptions, func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		for name, values := range opts.Header {
+			for _, v := range values {
+				req.Header.Add(name, v)
+			}
+		}
+		req.Header.Set("Accept", "application/json")
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		switch {
+		case resp.StatusCode == http.StatusNotModified && last != nil:
+			return last, nil
+		case resp.StatusCode != http.StatusOK:
+			return nil, fmt.Errorf("shedder: fetching %s: %s", url, resp.Status)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteConfig))
+		if err != nil {
+			return nil, err
+		}
+		etag, last = resp.Header.Get("ETag"), data
+		return data, nil
+	})
+	if err := rl.reload(ctx); err != nil {
+		return err
+	}
+	rl.start(ctx, opts.ReloadOptions)
+	return nil
+}
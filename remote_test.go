@@ -0,0 +1,84 @@
+package shedder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShedder_WatchURL(t *testing.T) {
+	var version atomic.Int32
+	var notModified atomic.Int32
+	version.Store(1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		etag := `"v` + string('0'+rune(version.Load())) + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		if version.Load() == 1 {
+			w.Write([]byte(`{"hard_limit": 50}`))
+		} else {
+			w.Write([]byte(`{"hard_limit": 60}`))
+		}
+	}))
+	defer srv.Close()
+
+	s := New(Config{HardLimit: 100})
+	reloads := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := s.WatchURL(ctx, srv.URL, RemoteOptions{
+		Header: http.Header{"Authorization": {"Bearer token"}},
+		ReloadOptions: ReloadOptions{
+			Interval: 5 * time.Millisecond,
+			OnReload: func(dc DynamicConfig, err error) { reloads <- err },
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-reloads
+	if s.HardLimit() != 50 {
+		t.Errorf("expected hard limit 50, got %d", s.HardLimit())
+	}
+
+	for notModified.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-reloads:
+		t.Errorf("expected no reload for 304 responses, got %v", err)
+	default:
+	}
+
+	version.Store(2)
+	if err := waitReload(t, reloads); err != nil {
+		t.Fatal(err)
+	}
+	if s.HardLimit() != 60 {
+		t.Errorf("expected hard limit 60, got %d", s.HardLimit())
+	}
+}
+
+func TestShedder_WatchURLError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	s := New(Config{HardLimit: 100})
+	if err := s.WatchURL(context.Background(), srv.URL, RemoteOptions{}); err == nil {
+		t.Error("expected error for a failing endpoint")
+	}
+}
@@ -0,0 +1,176 @@
+package shedder
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+)
+
+// ShedResponse describes the response written to shed requests.
+type ShedResponse struct {
+	// StatusCode defaults to Config.ShedStatus for the shed reason, or
+	// 503 Service Unavailable.
+	StatusCode int
+
+	// ContentType defaults to "text/plain; charset=utf-8".
+	ContentType string
+
+	// Header holds additional response headers. They are applied after
+	// Retry-After and X-Shed-Reason and may override them.
+	Header http.Header
+
+	Body []byte
+}
+
+// defaultShedResponse is written when no custom response is configured.
+var defaultShedResponse = &ShedResponse{
+	ContentType: "text/plain; charset=utf-8",
+	Body:        []byte("Service Unavailable: load shedding active\n"),
+}
+
+// Header values set on shed responses, allocated once so shedding under
+// overload does not allocate them for every request. Each slice has a
+// capacity of one, so Header.Add copies it rather than appending in
+// place; nothing may modify them otherwise. They are copied by
+// ownHeaderValues before a ShedHandler sees them.
+var (
+	nosniffValue   = []string{"nosniff"}
+	closeValue     = []string{"close"}
+	trueValue      = []string{"true"}
+	textPlainValue = []string{"text/plain; charset=utf-8"}
+	reasonValues   = headerValues(int(numShedReasons), func(i int) string { return ShedReason(i).String() })
+	secondsValues  = headerValues(100, strconv.Itoa)
+)
+
+// headerValues returns single-value header slices holding format(i) for
+// i in [0, n).
+func headerValues(n int, format func(int) string) [][]string {
+	values := make([][]string, n)
+	for i := range values {
+		values[i] = []string{format(i)}
+	}
+	return values
+}
+
+// sharedHeaders are the headers shed may set to the shared values above.
+var sharedHeaders = [...]string{"Retry-After", "X-Shed-Reason", "Connection", "X-Envoy-Overloaded"}
+
+// ownHeaderValues replaces the shared values set in h with copies, so
+// user code may modify them in place.
+func ownHeaderValues(h http.Header) {
+	for _, name := range sharedHeaders {
+		if values, ok := h[name]; ok {
+			h[name] = append([]string(nil), values...)
+		}
+	}
+}
+
+// reasonValue returns the X-Shed-Reason header value of reason.
+func reasonValue(reason ShedReason) []string {
+	if reason >= 0 && reason < numShedReasons {
+		return reasonValues[reason]
+	}
+	return []string{reason.String()}
+}
+
+// secondsValue returns the header value of a number of seconds.
+func secondsValue(seconds int) []string {
+	if seconds >= 0 && seconds < len(secondsValues) {
+		return secondsValues[seconds]
+	}
+	return []string{strconv.Itoa(seconds)}
+}
+
+// setHeaders sets the canonical header keys of kv, a list of key-value
+// pairs, to their values with a single allocation for the value slices.
+func setHeaders(h http.Header, kv ...string) {
+	values := make([]string, len(kv)/2)
+	for i := range values {
+		values[i] = kv[2*i+1]
+		h[kv[2*i]] = values[i : i+1 : i+1]
+	}
+}
+
+// shedReasonKey is the context key carrying the ShedReason to ShedHandler.
+type shedReasonKey struct{}
+
+// ShedReasonFromContext returns the reason a request was shed. It is set
+// for requests passed to Config.ShedHandler.
+func ShedReasonFromContext(ctx context.Context) (ShedReason, bool) {
+	reason, ok := ctx.Value(shedReasonKey{}).(ShedReason)
+	return reason, ok
+}
+
+// retryAfter returns the Retry-After value for r, in seconds.
+func (s *Shedder) retryAfter(r *http.Request, reason ShedReason) int {
+	seconds := 1
+	if s.backoff != nil {
+		var queued int64
+		if s.queue != nil {
+			queued = s.queue.length.Load()
+		}
+		estimate := s.backoff.estimate(s.now(), s.Inflight(), queued, s.currentLimit())
+		seconds = s.backoff.seconds(r, reason, estimate)
+	}
+	if s.jitter > 0 {
+		seconds += rand.Intn(s.jitter + 1)
+	}
+	return seconds
+}
+
+// writeShedResponse writes the configured shed response for r.
+func (s *Shedder) writeShedResponse(w http.ResponseWriter, r *http.Request, reason ShedReason, retryAfter int) {
+	if s.shedHandler != nil {
+		ownHeaderValues(w.Header())
+		s.shedHandler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), shedReasonKey{}, reason)))
+		return
+	}
+	resp := s.shedResponse
+	if s.shedResponseFunc != nil {
+		resp = s.shedResponseFunc(r, reason)
+	}
+	status := s.shedStatusCode(reason)
+	if resp == nil && s.problemJSON {
+		resp = s.problem(reason, status, retryAfter).response()
+	}
+	if resp == nil {
+		resp = defaultShedResponse
+	}
+	resp.write(w, status)
+}
+
+// shedStatusCode returns the configured status for reason, or 503.
+func (s *Shedder) shedStatusCode(reason ShedReason) int {
+	if status := s.shedStatus[reason]; status != 0 {
+		return status
+	}
+	return http.StatusServiceUnavailable
+}
+
+// write writes resp to w, with status unless resp sets its own.
+func (resp *ShedResponse) write(w http.ResponseWriter, status int) {
+	h := w.Header()
+	contentType := resp.ContentType
+	if contentType == "" {
+		contentType = defaultShedResponse.ContentType
+	}
+	if contentType == textPlainValue[0] {
+		h["Content-Type"] = textPlainValue
+	} else {
+		h["Content-Type"] = []string{contentType}
+	}
+	h["X-Content-Type-Options"] = nosniffValue
+	for name, values := range resp.Header {
+		h.Del(name)
+		for _, v := range values {
+			h.Add(name, v)
+		}
+	}
+
+	if resp.StatusCode != 0 {
+		status = resp.StatusCode
+	}
+	w.WriteHeader(status)
+	w.Write(resp.Body)
+}
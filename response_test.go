@@ -0,0 +1,252 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// shedOne serves one request through s while it is over its hard limit and
+// returns the recorded response.
+func shedOne(t *testing.T, s *Shedder) *httptest.ResponseRecorder {
+	t.Helper()
+	s.increment(s.currentLimit())
+	defer s.decrement(s.currentLimit())
+
+	rec := httptest.NewRecorder()
+	s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler should not be called")
+	})).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	return rec
+}
+
+func TestShedResponse_Default(t *testing.T) {
+	rec := shedOne(t, New(Config{HardLimit: 1}))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("expected plain text, got %q", ct)
+	}
+	if body := rec.Body.String(); body != "Service Unavailable: load shedding active\n" {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+func TestShedResponse_Static(t *testing.T) {
+	s := New(Config{
+		HardLimit: 1,
+		ShedResponse: &ShedResponse{
+			ContentType: "application/json",
+			Header:      http.Header{"cache-control": {"no-store"}, "Retry-After": {"5"}},
+			Body:        []byte(`{"error":"busy"}`),
+		},
+	})
+	rec := shedOne(t, s)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected default 503, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected application/json, got %q", ct)
+	}
+	if cc := rec.Header().Get("Cache-Control"); cc != "no-store" {
+		t.Errorf("expected Cache-Control no-store, got %q", cc)
+	}
+	if ra := rec.Header().Get("Retry-After"); ra != "5" {
+		t.Errorf("expected overridden Retry-After 5, got %q", ra)
+	}
+	if reason := rec.Header().Get("X-Shed-Reason"); reason != "hard_limit" {
+		t.Errorf("expected X-Shed-Reason hard_limit, got %q", reason)
+	}
+	if body := rec.Body.String(); body != `{"error":"busy"}` {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+func TestShedResponse_Func(t *testing.T) {
+	var gotReason ShedReason = -1
+	s := New(Config{
+		HardLimit:    1,
+		ShedResponse: &ShedResponse{Body: []byte("static")},
+		ShedResponseFunc: func(r *http.Request, reason ShedReason) *ShedResponse {
+			gotReason = reason
+			return &ShedResponse{StatusCode: http.StatusTooManyRequests, Body: []byte(reason.String())}
+		},
+	})
+	rec := shedOne(t, s)
+
+	if gotReason != ShedReasonHardLimit {
+		t.Errorf("expected builder called with hard_limit, got %v", gotReason)
+	}
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429, got %d", rec.Code)
+	}
+	if body := rec.Body.String(); body != "hard_limit" {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+func TestShedResponse_FuncNilFallsBack(t *testing.T) {
+	s := New(Config{
+		HardLimit:        1,
+		ShedResponseFunc: func(r *http.Request, reason ShedReason) *ShedResponse { return nil },
+	})
+	rec := shedOne(t, s)
+
+	if body := rec.Body.String(); body != "Service Unavailable: load shedding active\n" {
+		t.Errorf("expected default body, got %q", body)
+	}
+}
+
+func TestShedHandler(t *testing.T) {
+	s := New(Config{
+		HardLimit:    1,
+		ShedResponse: &ShedResponse{Body: []byte("unused")},
+		ShedHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reason, ok := ShedReasonFromContext(r.Context())
+			if !ok {
+				t.Error("expected shed reason in context")
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("busy: " + reason.String()))
+		}),
+	})
+	rec := shedOne(t, s)
+
+	if body := rec.Body.String(); body != "busy: hard_limit" {
+		t.Errorf("unexpected body %q", body)
+	}
+	if ra := rec.Header().Get("Retry-After"); ra != "1" {
+		t.Errorf("expected Retry-After set before ShedHandler, got %q", ra)
+	}
+}
+
+func TestShedHandler_OwnsHeaderValues(t *testing.T) {
+	s := New(Config{
+		HardLimit: 1,
+		ShedHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h["Retry-After"][0] = "mutated"
+			h["X-Shed-Reason"][0] = "mutated"
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}),
+	})
+	shedOne(t, s)
+
+	rec := shedOne(t, New(Config{HardLimit: 1}))
+	assertHeader(t, rec, "Retry-After", "1")
+	assertHeader(t, rec, "X-Shed-Reason", "hard_limit")
+}
+
+func TestShedReasonFromContext_Unset(t *testing.T) {
+	if _, ok := ShedReasonFromContext(httptest.NewRequest("GET", "/", nil).Context()); ok {
+		t.Error("expected no shed reason")
+	}
+}
+
+func TestShedStatus(t *testing.T) {
+	s := New(Config{
+		HardLimit:   10,
+		SoftLimit:   1,
+		ShedDecider: func(r *http.Request) bool { return true },
+		ShedStatus:  map[ShedReason]int{ShedReasonSoftLimit: http.StatusTooManyRequests},
+	})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	s.increment(1)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	s.decrement(1)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 for soft shed, got %d", rec.Code)
+	}
+
+	s.increment(10)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	s.decrement(10)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 for hard shed, got %d", rec.Code)
+	}
+}
+
+func TestShedStatus_ResponseOverrides(t *testing.T) {
+	s := New(Config{
+		HardLimit:    1,
+		ShedStatus:   map[ShedReason]int{ShedReasonHardLimit: http.StatusTooManyRequests},
+		ShedResponse: &ShedResponse{StatusCode: http.StatusBadGateway},
+	})
+	if rec := shedOne(t, s); rec.Code != http.StatusBadGateway {
+		t.Errorf("expected ShedResponse.StatusCode to win, got %d", rec.Code)
+	}
+
+	s = New(Config{
+		HardLimit:    1,
+		ShedStatus:   map[ShedReason]int{ShedReasonHardLimit: http.StatusTooManyRequests},
+		ShedResponse: &ShedResponse{Body: []byte("busy")},
+	})
+	if rec := shedOne(t, s); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected ShedStatus for response without status, got %d", rec.Code)
+	}
+}
+
+func TestCloseOnHardShed(t *testing.T) {
+	s := New(Config{
+		HardLimit:       10,
+		SoftLimit:       1,
+		ShedDecider:     func(r *http.Request) bool { return true },
+		CloseOnHardShed: true,
+	})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	s.increment(1)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	s.decrement(1)
+	if got := rec.Header().Get("Connection"); got != "" {
+		t.Errorf("expected no Connection header on soft shed, got %q", got)
+	}
+
+	if got := shedOne(t, s).Header().Get("Connection"); got != "close" {
+		t.Errorf("expected Connection: close on hard shed, got %q", got)
+	}
+}
+
+func TestEnvoyOverloaded(t *testing.T) {
+	s := New(Config{
+		HardLimit:       10,
+		ShedDecider:     func(r *http.Request) bool { return r.Header.Get("X-Priority") == "low" },
+		EnvoyOverloaded: true,
+	})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(priority, hint string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Priority", priority)
+		if hint != "" {
+			req.Header.Set("X-Envoy-Overloaded", hint)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve("low", ""); rec.Code != http.StatusOK {
+		t.Errorf("expected low priority admitted without overload, got %d", rec.Code)
+	}
+	if rec := serve("high", "true"); rec.Code != http.StatusOK {
+		t.Errorf("expected the hint to leave high priority admitted, got %d", rec.Code)
+	}
+	rec := serve("low", "true")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("X-Shed-Reason") != "soft_limit" {
+		t.Errorf("expected the hint to shed low priority as under soft overload, got %d %q", rec.Code, rec.Header().Get("X-Shed-Reason"))
+	}
+	if got := rec.Header().Get("X-Envoy-Overloaded"); got != "true" {
+		t.Errorf("expected x-envoy-overloaded on the shed response, got %q", got)
+	}
+
+	if got := shedOne(t, New(Config{HardLimit: 10})).Header().Get("X-Envoy-Overloaded"); got != "" {
+		t.Errorf("expected no x-envoy-overloaded by default, got %q", got)
+	}
+}
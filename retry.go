@@ -0,0 +1,114 @@
+package shedder
+
+import (
+	"container/list"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryConfig configures detection of retried requests, which are shed
+// first under soft overload because retry storms are typically what
+// pushes a pod over the edge.
+type RetryConfig struct {
+	// Headers mark a request as a retry when present with a value other
+	// than "0", e.g. "X-Retry-Attempt: 2". Defaults to X-Retry-Attempt
+	// unless IdempotencyHeader is set.
+	Headers []string
+
+	// IdempotencyHeader names a header carrying a client idempotency key,
+	// e.g. "Idempotency-Key". A key seen again within Window marks a retry.
+	IdempotencyHeader string
+
+	// Window is how long idempotency keys are remembered. Defaults to 1m.
+	Window time.Duration
+
+	// MaxKeys bounds the number of remembered idempotency keys; the oldest
+	// are forgotten first. Defaults to 10000.
+	MaxKeys int
+}
+
+// retryDetector recognizes retried requests.
+type retryDetector struct {
+	headers   []string
+	keyHeader string
+	window    time.Duration
+	maxKeys   int
+
+	mu   sync.Mutex
+	keys map[string]*list.Element
+	lru  list.List // of *seenKey, oldest first
+}
+
+// seenKey is a remembered idempotency key.
+type seenKey struct {
+	key  string
+	seen time.Time
+}
+
+// newRetryDetector returns a detector for cfg with defaults applied.
+func newRetryDetector(cfg RetryConfig) *retryDetector {
+	if len(cfg.Headers) == 0 && cfg.IdempotencyHeader == "" {
+		cfg.Headers = []string{"X-Retry-Attempt"}
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = time.Minute
+	}
+	if cfg.MaxKeys <= 0 {
+		cfg.MaxKeys = 10000
+	}
+	return &retryDetector{
+		headers:   cfg.Headers,
+		keyHeader: cfg.IdempotencyHeader,
+		window:    cfg.Window,
+		maxKeys:   cfg.MaxKeys,
+		keys:      make(map[string]*list.Element),
+	}
+}
+
+// observe reports whether r is a retry. It must be called once per
+// request, since it remembers r's idempotency key.
+func (d *retryDetector) observe(r *http.Request, now time.Time) bool {
+	for _, h := range d.headers {
+		if v := r.Header.Get(h); v != "" {
+			if n, err := strconv.Atoi(v); err != nil || n > 0 {
+				return true
+			}
+		}
+	}
+	if d.keyHeader == "" {
+		return false
+	}
+	key := r.Header.Get(d.keyHeader)
+	if key == "" {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.expire(now)
+	if elem, ok := d.keys[key]; ok {
+		elem.Value.(*seenKey).seen = now
+		d.lru.MoveToBack(elem)
+		return true
+	}
+	d.keys[key] = d.lru.PushBack(&seenKey{key: key, seen: now})
+	if d.lru.Len() > d.maxKeys {
+		d.forget(d.lru.Front())
+	}
+	return false
+}
+
+// expire forgets keys older than the window. Must be called with d.mu held.
+func (d *retryDetector) expire(now time.Time) {
+	for elem := d.lru.Front(); elem != nil && now.Sub(elem.Value.(*seenKey).seen) > d.window; elem = d.lru.Front() {
+		d.forget(elem)
+	}
+}
+
+// forget removes elem. Must be called with d.mu held.
+func (d *retryDetector) forget(elem *list.Element) {
+	d.lru.Remove(elem)
+	delete(d.keys, elem.Value.(*seenKey).key)
+}
@@ -0,0 +1,86 @@
+package shedder
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryDetector_Headers(t *testing.T) {
+	d := newRetryDetector(RetryConfig{})
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"", false},
+		{"0", false},
+		{"1", true},
+		{"3", true},
+		{"yes", true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.value != "" {
+			r.Header.Set("X-Retry-Attempt", tt.value)
+		}
+		if got := d.observe(r, time.Now()); got != tt.want {
+			t.Errorf("X-Retry-Attempt %q: expected %v, got %v", tt.value, tt.want, got)
+		}
+	}
+}
+
+func TestRetryDetector_IdempotencyKeys(t *testing.T) {
+	d := newRetryDetector(RetryConfig{IdempotencyHeader: "Idempotency-Key", Window: time.Minute, MaxKeys: 2})
+	now := time.Now()
+	request := func(key string) *http.Request {
+		r := httptest.NewRequest("POST", "/", nil)
+		r.Header.Set("Idempotency-Key", key)
+		return r
+	}
+
+	if d.observe(request("a"), now) {
+		t.Error("expected first attempt not to be a retry")
+	}
+	if !d.observe(request("a"), now.Add(time.Second)) {
+		t.Error("expected repeated key to be a retry")
+	}
+	if d.observe(request("a"), now.Add(3*time.Minute)) {
+		t.Error("expected key outside the window to be forgotten")
+	}
+
+	// Only MaxKeys keys are remembered.
+	for i := 0; i < 3; i++ {
+		d.observe(request(fmt.Sprint(i)), now.Add(4*time.Minute))
+	}
+	if d.observe(request("0"), now.Add(4*time.Minute)) {
+		t.Error("expected the oldest key to be evicted")
+	}
+	if d.observe(httptest.NewRequest("POST", "/", nil), now) {
+		t.Error("expected request without a key not to be a retry")
+	}
+}
+
+func TestMiddleware_ShedsRetriesFirst(t *testing.T) {
+	s := New(Config{HardLimit: 10, SoftLimit: 1, Retry: &RetryConfig{}})
+	s.increment(1)
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	retry := httptest.NewRequest("GET", "/", nil)
+	retry.Header.Set("X-Retry-Attempt", "1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, retry)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected retry to be shed under soft overload, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("X-Shed-Reason"); got != "retry" {
+		t.Errorf("expected X-Shed-Reason retry, got %q", got)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected first attempt to be admitted, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,87 @@
+package shedder
+
+import (
+	"math"
+	"net/http"
+	"time"
+)
+
+// RetryAfterConfig derives the Retry-After header of shed responses from an
+// estimate of how long the backlog takes to drain, so clients back off in
+// proportion to actual congestion.
+type RetryAfterConfig struct {
+	// Min and Max bound the Retry-After value. They default to one second
+	// and one minute. Min is used while the pod is below its hard limit.
+	Min time.Duration
+	Max time.Duration
+
+	// Window is the period over which the completion rate is measured.
+	// Defaults to 10s.
+	Window time.Duration
+
+	// Func optionally overrides the value for a shed request, given the
+	// drain estimate. Its result is still bounded by Min and Max.
+	Func func(r *http.Request, reason ShedReason, estimate time.Duration) time.Duration
+}
+
+// retryAfterEstimator estimates the time until capacity frees up from the
+// completion rate and the number of requests ahead of a new one.
+type retryAfterEstimator struct {
+	min, max    time.Duration
+	span        time.Duration
+	fn          func(r *http.Request, reason ShedReason, estimate time.Duration) time.Duration
+	completions *window // total: completed cost units
+}
+
+// newRetryAfterEstimator returns an estimator for cfg with defaults applied.
+func newRetryAfterEstimator(cfg RetryAfterConfig) *retryAfterEstimator {
+	if cfg.Min <= 0 {
+		cfg.Min = time.Second
+	}
+	if cfg.Max <= 0 {
+		cfg.Max = time.Minute
+	}
+	if cfg.Max < cfg.Min {
+		cfg.Max = cfg.Min
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+	return &retryAfterEstimator{
+		min:         cfg.Min,
+		max:         cfg.Max,
+		span:        cfg.Window,
+		fn:          cfg.Func,
+		completions: newWindow(cfg.Window, 10),
+	}
+}
+
+// complete records that an admitted request of the given cost finished.
+func (e *retryAfterEstimator) complete(now time.Time, cost int64) {
+	e.completions.add(now, cost, 0)
+}
+
+// estimate returns the expected time until the inflight and queued
+// requests have drained below limit, assuming the recent completion rate.
+func (e *retryAfterEstimator) estimate(now time.Time, inflight, queued, limit int64) time.Duration {
+	backlog := inflight + queued - limit
+	if backlog <= 0 {
+		return 0
+	}
+	completed, _ := e.completions.sum(now)
+	if completed == 0 {
+		return e.max
+	}
+	rate := float64(completed) / e.span.Seconds()
+	return time.Duration(float64(backlog) / rate * float64(time.Second))
+}
+
+// seconds returns the Retry-After value for r in whole seconds.
+func (e *retryAfterEstimator) seconds(r *http.Request, reason ShedReason, estimate time.Duration) int {
+	d := estimate
+	if e.fn != nil {
+		d = e.fn(r, reason, estimate)
+	}
+	d = min(max(d, e.min), e.max)
+	return int(math.Ceil(d.Seconds()))
+}
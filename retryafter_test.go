@@ -0,0 +1,124 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryAfterEstimator_Defaults(t *testing.T) {
+	e := newRetryAfterEstimator(RetryAfterConfig{})
+	if e.min != time.Second || e.max != time.Minute || e.span != 10*time.Second {
+		t.Errorf("unexpected defaults: min=%v max=%v window=%v", e.min, e.max, e.span)
+	}
+}
+
+func TestRetryAfterEstimator_Estimate(t *testing.T) {
+	e := newRetryAfterEstimator(RetryAfterConfig{Window: 10 * time.Second})
+	now := time.Now()
+
+	if got := e.estimate(now, 120, 0, 100); got != e.max {
+		t.Errorf("expected Max without completions, got %v", got)
+	}
+
+	e.complete(now, 50) // 5 per second
+	tests := []struct {
+		inflight, queued, limit int64
+		want                    time.Duration
+	}{
+		{80, 0, 100, 0},
+		{110, 0, 100, 2 * time.Second},
+		{110, 20, 100, 6 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := e.estimate(now, tt.inflight, tt.queued, tt.limit); got != tt.want {
+			t.Errorf("inflight=%d queued=%d: expected %v, got %v", tt.inflight, tt.queued, tt.want, got)
+		}
+	}
+}
+
+func TestRetryAfterEstimator_Seconds(t *testing.T) {
+	e := newRetryAfterEstimator(RetryAfterConfig{Min: 2 * time.Second, Max: 30 * time.Second})
+	r := httptest.NewRequest("GET", "/", nil)
+
+	tests := []struct {
+		estimate time.Duration
+		want     int
+	}{
+		{0, 2},
+		{4500 * time.Millisecond, 5},
+		{time.Hour, 30},
+	}
+	for _, tt := range tests {
+		if got := e.seconds(r, ShedReasonHardLimit, tt.estimate); got != tt.want {
+			t.Errorf("estimate %v: expected %d, got %d", tt.estimate, tt.want, got)
+		}
+	}
+}
+
+func TestRetryAfterEstimator_Func(t *testing.T) {
+	e := newRetryAfterEstimator(RetryAfterConfig{
+		Max: 30 * time.Second,
+		Func: func(r *http.Request, reason ShedReason, estimate time.Duration) time.Duration {
+			if reason == ShedReasonSoftLimit {
+				return 10 * time.Second
+			}
+			return estimate * 2
+		},
+	})
+	r := httptest.NewRequest("GET", "/", nil)
+
+	if got := e.seconds(r, ShedReasonSoftLimit, 0); got != 10 {
+		t.Errorf("expected override of 10, got %d", got)
+	}
+	if got := e.seconds(r, ShedReasonHardLimit, time.Minute); got != 30 {
+		t.Errorf("expected override bounded by Max, got %d", got)
+	}
+}
+
+func TestMiddleware_DynamicRetryAfter(t *testing.T) {
+	s := New(Config{HardLimit: 2, RetryAfter: &RetryAfterConfig{Max: 20 * time.Second}})
+
+	// Without completions the backlog never drains: Max.
+	if got := shedOne(t, s).Header().Get("Retry-After"); got != "20" {
+		t.Errorf("expected Retry-After 20, got %q", got)
+	}
+
+	// Ten completions over the 10s window: 1 per second, and the shed
+	// request is 1 over the limit.
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for i := 0; i < 10; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+	if got := shedOne(t, s).Header().Get("Retry-After"); got != "1" {
+		t.Errorf("expected Retry-After 1, got %q", got)
+	}
+}
+
+func TestRetryAfterJitter(t *testing.T) {
+	s := New(Config{HardLimit: 10, RetryAfterJitter: 3 * time.Second})
+	r := httptest.NewRequest("GET", "/", nil)
+
+	seen := make(map[int]bool)
+	for i := 0; i < 500; i++ {
+		got := s.retryAfter(r, ShedReasonHardLimit)
+		if got < 1 || got > 4 {
+			t.Fatalf("expected Retry-After in [1, 4], got %d", got)
+		}
+		seen[got] = true
+	}
+	if len(seen) != 4 {
+		t.Errorf("expected all values in [1, 4], got %v", seen)
+	}
+
+	// Sub-second jitter rounds up to a whole second instead of vanishing.
+	s = New(Config{HardLimit: 10, RetryAfterJitter: 500 * time.Millisecond})
+	seen = make(map[int]bool)
+	for i := 0; i < 500; i++ {
+		seen[s.retryAfter(r, ShedReasonHardLimit)] = true
+	}
+	if len(seen) != 2 || !seen[1] || !seen[2] {
+		t.Errorf("expected Retry-After 1 or 2, got %v", seen)
+	}
+}
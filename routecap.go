@@ -0,0 +1,195 @@
+package shedder
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// otherRoute is the key shared by routes beyond RouteCapacityConfig.MaxRoutes.
+const otherRoute = "other"
+
+// RouteCapacityConfig configures per-route capacity tuning.
+type RouteCapacityConfig struct {
+	// Key maps a request to its route, e.g. the mux pattern or a
+	// normalized path. It must have bounded cardinality. Required.
+	Key func(r *http.Request) string
+
+	// Interval is how often per-route limits are recomputed from the
+	// observed throughput. Defaults to 10s.
+	Interval time.Duration
+
+	// Headroom multiplies each route's throughput share of the hard limit,
+	// allowing routes to burst above their proportional share.
+	// Defaults to 2.
+	Headroom float64
+
+	// MinLimit is the smallest per-route limit. Defaults to 1.
+	MinLimit int64
+
+	// EnforceAbove is the fraction (0-1) of the hard limit above which
+	// per-route limits are enforced. Below it the budget is uncontested and
+	// every route may use as much concurrency as it needs. Defaults to 0.5.
+	EnforceAbove float64
+
+	// MaxRoutes bounds the number of tracked routes; additional routes
+	// share the "other" entry. Defaults to 100.
+	MaxRoutes int
+}
+
+// RouteStats describes the observed capacity of one route.
+type RouteStats struct {
+	Route string `json:"route"`
+
+	// Inflight is the number of requests currently in flight for the route.
+	Inflight int64 `json:"inflight"`
+
+	// Limit is the route's current concurrency limit (0 until computed).
+	Limit int64 `json:"limit"`
+
+	// Throughput is the route's completions per second over the last
+	// interval.
+	Throughput float64 `json:"throughput"`
+}
+
+// routeCapacity divides the shared hard limit between routes in proportion
+// to their observed throughput, so a pathologically slow route (high
+// latency, low throughput) cannot occupy the whole pod's concurrency.
+type routeCapacity struct {
+	cfg RouteCapacityConfig
+
+	mu     sync.RWMutex
+	routes map[string]*routeState
+
+	recomputeMu sync.Mutex
+	next        atomic.Int64 // unix nanoseconds of the next recompute
+}
+
+// routeState tracks one route.
+type routeState struct {
+	name        string
+	inflight    atomic.Int64
+	completions atomic.Int64 // since the last recompute
+	last        atomic.Int64 // completions in the last interval
+	limit       atomic.Int64 // 0 means not yet computed
+}
+
+// newRouteCapacity returns a tracker for cfg with defaults applied.
+func newRouteCapacity(cfg RouteCapacityConfig, now time.Time) *routeCapacity {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Second
+	}
+	if cfg.Headroom <= 0 {
+		cfg.Headroom = 2
+	}
+	if cfg.MinLimit <= 0 {
+		cfg.MinLimit = 1
+	}
+	if cfg.EnforceAbove <= 0 || cfg.EnforceAbove > 1 {
+		cfg.EnforceAbove = 0.5
+	}
+	if cfg.MaxRoutes <= 0 {
+		cfg.MaxRoutes = 100
+	}
+	rc := &routeCapacity{cfg: cfg, routes: make(map[string]*routeState)}
+	rc.next.Store(now.Add(cfg.Interval).UnixNano())
+	return rc
+}
+
+// route returns the state for the request's route, creating it if needed.
+func (rc *routeCapacity) route(r *http.Request) *routeState {
+	key := rc.cfg.Key(r)
+
+	rc.mu.RLock()
+	rs, ok := rc.routes[key]
+	rc.mu.RUnlock()
+	if ok {
+		return rs
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rs, ok := rc.routes[key]; ok {
+		return rs
+	}
+	if len(rc.routes) >= rc.cfg.MaxRoutes {
+		key = otherRoute
+		if rs, ok := rc.routes[key]; ok {
+			return rs
+		}
+	}
+	rs = &routeState{name: key}
+	rc.routes[key] = rs
+	return rs
+}
+
+// acquire counts a request against its route and returns the route.
+func (rc *routeCapacity) acquire(r *http.Request, globalLimit int64, now time.Time) *routeState {
+	rc.maybeRecompute(globalLimit, now)
+	rs := rc.route(r)
+	rs.inflight.Add(1)
+	return rs
+}
+
+// exceeded reports whether rs is over its share while the global budget
+// is contested.
+func (rc *routeCapacity) exceeded(rs *routeState, current, globalLimit int64) bool {
+	limit := rs.limit.Load()
+	if limit <= 0 || float64(current) <= rc.cfg.EnforceAbove*float64(globalLimit) {
+		return false
+	}
+	return rs.inflight.Load() > limit
+}
+
+// maybeRecompute recomputes per-route limits if the interval has elapsed.
+func (rc *routeCapacity) maybeRecompute(globalLimit int64, now time.Time) {
+	if now.UnixNano() < rc.next.Load() || !rc.recomputeMu.TryLock() {
+		return
+	}
+	defer rc.recomputeMu.Unlock()
+	if now.UnixNano() < rc.next.Load() {
+		return
+	}
+	rc.next.Store(now.Add(rc.cfg.Interval).UnixNano())
+
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+
+	var total int64
+	for _, rs := range rc.routes {
+		c := rs.completions.Swap(0)
+		rs.last.Store(c)
+		total += c
+	}
+	for _, rs := range rc.routes {
+		limit := rc.cfg.MinLimit
+		if total > 0 {
+			share := float64(rs.last.Load()) / float64(total)
+			if l := int64(math.Ceil(float64(globalLimit) * share * rc.cfg.Headroom)); l > limit {
+				limit = l
+			}
+		}
+		rs.limit.Store(limit)
+	}
+}
+
+// stats returns a snapshot of all tracked routes, sorted by name.
+func (rc *routeCapacity) stats() []RouteStats {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+
+	out := make([]RouteStats, 0, len(rc.routes))
+	for _, rs := range rc.routes {
+		out = append(out, RouteStats{
+			Route:      rs.name,
+			Inflight:   rs.inflight.Load(),
+			Limit:      rs.limit.Load(),
+			Throughput: float64(rs.last.Load()) / rc.cfg.Interval.Seconds(),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Route < out[j].Route })
+	return out
+}
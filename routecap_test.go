@@ -0,0 +1,90 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func pathKey(r *http.Request) string { return r.URL.Path }
+
+func TestRouteCapacity_LimitsFollowThroughputShare(t *testing.T) {
+	rc := newRouteCapacity(RouteCapacityConfig{Key: pathKey, Interval: time.Second, Headroom: 1}, time.Unix(1000, 0))
+	now := time.Unix(0, rc.next.Load())
+
+	fast := rc.route(httptest.NewRequest("GET", "/fast", nil))
+	slow := rc.route(httptest.NewRequest("GET", "/slow", nil))
+	fast.completions.Store(90)
+	slow.completions.Store(10)
+
+	rc.maybeRecompute(100, now)
+
+	if got := fast.limit.Load(); got != 90 {
+		t.Errorf("expected fast route limit 90, got %d", got)
+	}
+	if got := slow.limit.Load(); got != 10 {
+		t.Errorf("expected slow route limit 10, got %d", got)
+	}
+
+	// Uncontested budget: no enforcement even above the route limit.
+	slow.inflight.Store(20)
+	if rc.exceeded(slow, 40, 100) {
+		t.Error("route limits must not apply below EnforceAbove")
+	}
+	if !rc.exceeded(slow, 60, 100) {
+		t.Error("expected slow route to exceed its share of a contested budget")
+	}
+}
+
+func TestRouteCapacity_MaxRoutes(t *testing.T) {
+	rc := newRouteCapacity(RouteCapacityConfig{Key: pathKey, MaxRoutes: 1}, time.Unix(1000, 0))
+
+	a := rc.route(httptest.NewRequest("GET", "/a", nil))
+	b := rc.route(httptest.NewRequest("GET", "/b", nil))
+	c := rc.route(httptest.NewRequest("GET", "/c", nil))
+
+	if a.name != "/a" || b.name != otherRoute || c != b {
+		t.Errorf("expected overflow routes to share %q, got %q %q %q", otherRoute, a.name, b.name, c.name)
+	}
+}
+
+func TestMiddleware_RouteLimit(t *testing.T) {
+	s := New(Config{
+		HardLimit:     10,
+		RouteCapacity: &RouteCapacityConfig{Key: pathKey, Interval: time.Hour},
+	})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// Register the route and pin its limit as if recomputed.
+	req := httptest.NewRequest("GET", "/export", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	route := s.routes.route(req)
+	route.limit.Store(2)
+	route.inflight.Store(2)
+	for i := 0; i < 6; i++ {
+		s.increment(1)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/export", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 for route over its share, got %d", rec.Code)
+	}
+	if rec.Header().Get("X-Shed-Reason") != "route_limit" {
+		t.Errorf("expected X-Shed-Reason 'route_limit', got %q", rec.Header().Get("X-Shed-Reason"))
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/other-route", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected other routes to be admitted, got %d", rec.Code)
+	}
+
+	st := s.Stats()
+	if len(st.Routes) != 2 || st.Routes[0].Route != "/export" || st.Routes[0].Inflight != 2 {
+		t.Errorf("unexpected route stats: %+v", st.Routes)
+	}
+}
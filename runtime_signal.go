@@ -0,0 +1,247 @@
+package shedder
+
+import (
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultSampleInterval is how often runtime signals re-read runtime metrics.
+const defaultSampleInterval = time.Second
+
+// sampler caches the verdict of an expensive measurement so that signals
+// can be consulted on every request. At most one caller re-samples per
+// interval; concurrent callers never block and see the previous verdict.
+type sampler struct {
+	interval   time.Duration
+	mu         sync.Mutex
+	next       time.Time
+	overloaded atomic.Bool
+}
+
+// get returns the cached verdict, refreshing it with measure when due.
+func (s *sampler) get(measure func() bool) bool {
+	if s.mu.TryLock() {
+		if now := time.Now(); !now.Before(s.next) {
+			s.overloaded.Store(measure())
+			interval := s.interval
+			if interval <= 0 {
+				interval = defaultSampleInterval
+			}
+			s.next = now.Add(interval)
+		}
+		s.mu.Unlock()
+	}
+	return s.overloaded.Load()
+}
+
+// histogramDelta tracks a cumulative runtime/metrics histogram and reports
+// quantiles over the observations made since the previous call.
+type histogramDelta struct {
+	prev []uint64
+}
+
+// quantile returns the q-quantile of the observations recorded in h since
+// the last call, and false if there were none.
+func (d *histogramDelta) quantile(h *metrics.Float64Histogram, q float64) (float64, bool) {
+	if d.prev == nil {
+		d.prev = append([]uint64(nil), h.Counts...)
+		return 0, false
+	}
+
+	var total uint64
+	delta := make([]uint64, len(h.Counts))
+	for i, c := range h.Counts {
+		if i < len(d.prev) {
+			delta[i] = c - d.prev[i]
+		} else {
+			delta[i] = c
+		}
+		total += delta[i]
+	}
+	d.prev = append(d.prev[:0], h.Counts...)
+	if total == 0 {
+		return 0, false
+	}
+
+	rank := uint64(math.Ceil(q * float64(total)))
+	var seen uint64
+	for i, c := range delta {
+		seen += c
+		if seen >= rank {
+			// Buckets[i+1] is the bucket's upper bound; fall back to the
+			// lower bound for the unbounded last bucket.
+			if upper := h.Buckets[i+1]; !math.IsInf(upper, 1) {
+				return upper, true
+			}
+			return h.Buckets[i], true
+		}
+	}
+	return h.Buckets[len(h.Buckets)-1], true
+}
+
+// firstSupported returns the first metric name that the running Go version
+// provides, or "" if none is supported.
+func firstSupported(names ...string) string {
+	supported := make(map[string]bool)
+	for _, d := range metrics.All() {
+		supported[d.Name] = true
+	}
+	for _, name := range names {
+		if supported[name] {
+			return name
+		}
+	}
+	return ""
+}
+
+// GCSignalConfig configures a GCSignal. Zero thresholds are disabled.
+type GCSignalConfig struct {
+	// MaxPause is the GC stop-the-world pause, at PauseQuantile over the
+	// last sample interval, above which the signal reports overload.
+	MaxPause time.Duration
+
+	// PauseQuantile selects the pause quantile compared against MaxPause.
+	// Defaults to 0.99.
+	PauseQuantile float64
+
+	// MaxCPUFraction is the fraction (0-1) of total CPU time spent in the
+	// garbage collector over the last sample interval above which the
+	// signal reports overload.
+	MaxCPUFraction float64
+
+	// Interval is the minimum time between runtime metric reads.
+	// Defaults to one second.
+	Interval time.Duration
+}
+
+// GCSignal is an OverloadSignal that watches garbage collection pauses and
+// GC CPU usage via runtime/metrics. GC thrash saturates a pod long before
+// the in-flight count notices, so it is typically used as (part of)
+// Config.SoftOverloadSignal.
+type GCSignal struct {
+	cfg     GCSignalConfig
+	sampler sampler
+
+	samples  []metrics.Sample
+	pauses   histogramDelta
+	prevGC   float64
+	prevCPU  float64
+	hasPause bool
+}
+
+// NewGCSignal returns a GCSignal with the given thresholds.
+func NewGCSignal(cfg GCSignalConfig) *GCSignal {
+	if cfg.PauseQuantile <= 0 || cfg.PauseQuantile > 1 {
+		cfg.PauseQuantile = 0.99
+	}
+	g := &GCSignal{cfg: cfg, sampler: sampler{interval: cfg.Interval}}
+
+	g.samples = []metrics.Sample{
+		{Name: "/cpu/classes/gc/total:cpu-seconds"},
+		{Name: "/cpu/classes/total:cpu-seconds"},
+	}
+	if name := firstSupported("/sched/pauses/total/gc:seconds", "/gc/pauses:seconds"); name != "" {
+		g.samples = append(g.samples, metrics.Sample{Name: name})
+		g.hasPause = true
+	}
+	return g
+}
+
+// Overloaded reports whether GC exceeded a threshold during the last
+// sample interval.
+func (g *GCSignal) Overloaded() bool {
+	return g.sampler.get(g.measure)
+}
+
+// measure reads the GC metrics and compares the deltas with the thresholds.
+func (g *GCSignal) measure() bool {
+	metrics.Read(g.samples)
+	overloaded := false
+
+	if g.samples[0].Value.Kind() == metrics.KindFloat64 && g.samples[1].Value.Kind() == metrics.KindFloat64 {
+		gc, total := g.samples[0].Value.Float64(), g.samples[1].Value.Float64()
+		if g.cfg.MaxCPUFraction > 0 && total > g.prevCPU && g.prevCPU > 0 {
+			if (gc-g.prevGC)/(total-g.prevCPU) > g.cfg.MaxCPUFraction {
+				overloaded = true
+			}
+		}
+		g.prevGC, g.prevCPU = gc, total
+	}
+
+	if g.hasPause && g.samples[2].Value.Kind() == metrics.KindFloat64Histogram {
+		p, ok := g.pauses.quantile(g.samples[2].Value.Float64Histogram(), g.cfg.PauseQuantile)
+		if ok && g.cfg.MaxPause > 0 && p > g.cfg.MaxPause.Seconds() {
+			overloaded = true
+		}
+	}
+	return overloaded
+}
+
+// SchedLatencySignalConfig configures a SchedLatencySignal.
+type SchedLatencySignalConfig struct {
+	// MaxLatency is the time goroutines spend runnable before running, at
+	// Quantile over the last sample interval, above which the signal
+	// reports overload. Required.
+	MaxLatency time.Duration
+
+	// Quantile selects the scheduling latency quantile compared against
+	// MaxLatency. Defaults to 0.99.
+	Quantile float64
+
+	// Interval is the minimum time between runtime metric reads.
+	// Defaults to one second.
+	Interval time.Duration
+}
+
+// SchedLatencySignal is an OverloadSignal based on the Go scheduler's
+// latency histogram (/sched/latencies:seconds). When goroutines wait long
+// to be scheduled the pod's CPUs are saturated, even if the in-flight
+// count is still below the configured limits.
+type SchedLatencySignal struct {
+	cfg       SchedLatencySignalConfig
+	sampler   sampler
+	samples   []metrics.Sample
+	latencies histogramDelta
+}
+
+// NewSchedLatencySignal returns a SchedLatencySignal with the given threshold.
+func NewSchedLatencySignal(cfg SchedLatencySignalConfig) *SchedLatencySignal {
+	if cfg.Quantile <= 0 || cfg.Quantile > 1 {
+		cfg.Quantile = 0.99
+	}
+	return &SchedLatencySignal{
+		cfg:     cfg,
+		sampler: sampler{interval: cfg.Interval},
+		samples: []metrics.Sample{{Name: "/sched/latencies:seconds"}},
+	}
+}
+
+// Overloaded reports whether scheduling latency exceeded MaxLatency during
+// the last sample interval.
+func (l *SchedLatencySignal) Overloaded() bool {
+	return l.sampler.get(l.measure)
+}
+
+// measure reads the scheduler latency histogram and checks the quantile.
+func (l *SchedLatencySignal) measure() bool {
+	metrics.Read(l.samples)
+	if l.samples[0].Value.Kind() != metrics.KindFloat64Histogram || l.cfg.MaxLatency <= 0 {
+		return false
+	}
+	p, ok := l.latencies.quantile(l.samples[0].Value.Float64Histogram(), l.cfg.Quantile)
+	return ok && p > l.cfg.MaxLatency.Seconds()
+}
+
+// GoroutineSignal returns an OverloadSignal that reports overload while the
+// process runs more than max goroutines. It suits services that fan out or
+// leak goroutines per request, where the in-flight HTTP count underestimates
+// the real load. runtime.NumGoroutine is cheap, so no sampling is applied.
+func GoroutineSignal(max int) OverloadSignal {
+	return SignalFunc(func() bool {
+		return runtime.NumGoroutine() > max
+	})
+}
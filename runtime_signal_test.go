@@ -0,0 +1,111 @@
+package shedder
+
+import (
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"testing"
+	"time"
+)
+
+func TestHistogramDelta_Quantile(t *testing.T) {
+	h := &metrics.Float64Histogram{
+		Counts:  []uint64{0, 0, 0},
+		Buckets: []float64{0, 1, 2, math.Inf(1)},
+	}
+	var d histogramDelta
+
+	if _, ok := d.quantile(h, 0.5); ok {
+		t.Error("first call should only establish a baseline")
+	}
+
+	h.Counts = []uint64{90, 9, 1}
+	if got, ok := d.quantile(h, 0.5); !ok || got != 1 {
+		t.Errorf("p50 = %v, %v; want 1, true", got, ok)
+	}
+
+	h.Counts = []uint64{90, 9, 11}
+	if got, ok := d.quantile(h, 0.99); !ok || got != 2 {
+		t.Errorf("p99 of delta = %v, %v; want lower bound 2 of unbounded bucket", got, ok)
+	}
+
+	if _, ok := d.quantile(h, 0.99); ok {
+		t.Error("expected no observations without new counts")
+	}
+}
+
+func TestSampler_CachesVerdict(t *testing.T) {
+	s := sampler{interval: time.Hour}
+	calls := 0
+	measure := func() bool {
+		calls++
+		return true
+	}
+
+	for i := 0; i < 3; i++ {
+		if !s.get(measure) {
+			t.Fatal("expected cached overloaded verdict")
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 measurement within interval, got %d", calls)
+	}
+}
+
+func TestGCSignal_DetectsPauses(t *testing.T) {
+	g := NewGCSignal(GCSignalConfig{MaxPause: time.Nanosecond})
+	if !g.hasPause {
+		t.Skip("GC pause histogram not supported by this Go version")
+	}
+
+	g.measure() // baseline
+	runtime.GC()
+	if !g.measure() {
+		t.Error("expected overload after a GC pause above 1ns")
+	}
+}
+
+func TestGCSignal_DisabledThresholds(t *testing.T) {
+	g := NewGCSignal(GCSignalConfig{})
+	g.measure()
+	runtime.GC()
+	if g.measure() {
+		t.Error("signal with no thresholds should never report overload")
+	}
+}
+
+func TestSchedLatencySignal(t *testing.T) {
+	l := NewSchedLatencySignal(SchedLatencySignalConfig{MaxLatency: time.Hour})
+	l.measure() // baseline
+
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		go func() { <-done }()
+	}
+	runtime.Gosched()
+	close(done)
+
+	if l.measure() {
+		t.Error("scheduling latency should never exceed an hour")
+	}
+	if l.cfg.Quantile != 0.99 {
+		t.Errorf("expected default quantile 0.99, got %v", l.cfg.Quantile)
+	}
+}
+
+func TestGoroutineSignal(t *testing.T) {
+	base := runtime.NumGoroutine()
+	sig := GoroutineSignal(base + 5)
+	if sig.Overloaded() {
+		t.Error("should not be overloaded below the threshold")
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	for i := 0; i < 10; i++ {
+		go func() { <-done }()
+	}
+	if !sig.Overloaded() {
+		t.Error("should be overloaded above the threshold")
+	}
+}
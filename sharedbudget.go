@@ -0,0 +1,116 @@
+package shedder
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// CounterStore holds in-flight counters shared by the pods of a fleet,
+// e.g. in Redis; see NewRedisCounterStore. Each admitted request holds a
+// lease on its counter's key, which expires after a TTL so the leases of
+// a crashed pod are reclaimed rather than leaking budget forever.
+type CounterStore interface {
+	// Acquire takes a lease on key, valid for ttl, if fewer than limit
+	// unexpired leases are held on it, and reports whether it did.
+	Acquire(ctx context.Context, key string, limit int64, ttl time.Duration) (lease string, ok bool, err error)
+
+	// Release gives back a lease taken by Acquire.
+	Release(ctx context.Context, key, lease string) error
+}
+
+// SharedBudgetConfig configures a concurrency budget shared by every pod
+// using the same Store and Key, typically to cap the fleet's in-flight
+// requests toward a fragile shared dependency, such as a database, that
+// the pods' own limits cannot protect as the Deployment scales.
+type SharedBudgetConfig struct {
+	// Store holds the shared counter. Required.
+	Store CounterStore
+
+	// Key names the budget in Store. Defaults to "kube-shedder".
+	Key string
+
+	// Limit is the number of requests the whole fleet may have in flight
+	// under the budget. Required.
+	Limit int64
+
+	// Match selects the requests counted against the budget, e.g. those
+	// calling the shared dependency. Defaults to all requests.
+	Match func(r *http.Request) bool
+
+	// TTL is how long a lease outlives a pod that crashed while holding
+	// it. It must exceed the longest request, since a longer request's
+	// lease lapses while it runs; Eviction.MaxAge can enforce that.
+	// Defaults to 1m.
+	TTL time.Duration
+
+	// Timeout bounds each call to Store. Defaults to 100ms.
+	Timeout time.Duration
+
+	// FailClosed sheds matching requests while Store returns errors. By
+	// default they are admitted, so an outage of the store degrades the
+	// fleet to its per-pod limits instead of taking it down.
+	FailClosed bool
+
+	// OnError, if set, is called with each error returned by Store.
+	OnError func(err error)
+}
+
+// sharedBudget takes and returns the leases of a SharedBudgetConfig.
+type sharedBudget struct {
+	cfg SharedBudgetConfig
+
+	errors atomic.Int64
+}
+
+// newSharedBudget returns a budget for cfg with defaults applied.
+func newSharedBudget(cfg SharedBudgetConfig) *sharedBudget {
+	if cfg.Key == "" {
+		cfg.Key = "kube-shedder"
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = time.Minute
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 100 * time.Millisecond
+	}
+	return &sharedBudget{cfg: cfg}
+}
+
+// acquire takes a lease for r, if r is under the budget, and reports
+// whether the budget is spent. The caller must release a non-empty lease.
+func (b *sharedBudget) acquire(r *http.Request) (lease string, spent bool) {
+	if b.cfg.Match != nil && !b.cfg.Match(r) {
+		return "", false
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), b.cfg.Timeout)
+	defer cancel()
+	lease, ok, err := b.cfg.Store.Acquire(ctx, b.cfg.Key, b.cfg.Limit, b.cfg.TTL)
+	if err != nil {
+		b.fail(err)
+		return "", b.cfg.FailClosed
+	}
+	return lease, !ok
+}
+
+// release returns lease in the background, so the response is not
+// delayed by a round trip to the store.
+func (b *sharedBudget) release(lease string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), b.cfg.Timeout)
+		defer cancel()
+		if err := b.cfg.Store.Release(ctx, b.cfg.Key, lease); err != nil {
+			// The lease expires after TTL.
+			b.fail(err)
+		}
+	}()
+}
+
+// fail records a store error.
+func (b *sharedBudget) fail(err error) {
+	b.errors.Add(1)
+	if b.cfg.OnError != nil {
+		b.cfg.OnError(err)
+	}
+}
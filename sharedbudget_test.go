@@ -0,0 +1,136 @@
+package shedder
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore is a CounterStore in memory, shared by the shedders of a
+// test fleet. Leases do not expire.
+type memoryStore struct {
+	mu     sync.Mutex
+	leases map[string]map[string]bool
+	next   int
+	err    error
+}
+
+func (ms *memoryStore) Acquire(ctx context.Context, key string, limit int64, ttl time.Duration) (string, bool, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if ms.err != nil {
+		return "", false, ms.err
+	}
+	if ms.leases == nil {
+		ms.leases = make(map[string]map[string]bool)
+	}
+	if ms.leases[key] == nil {
+		ms.leases[key] = make(map[string]bool)
+	}
+	if int64(len(ms.leases[key])) >= limit {
+		return "", false, nil
+	}
+	ms.next++
+	lease := strconv.Itoa(ms.next)
+	ms.leases[key][lease] = true
+	return lease, true, nil
+}
+
+func (ms *memoryStore) Release(ctx context.Context, key, lease string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.leases[key], lease)
+	return nil
+}
+
+func (ms *memoryStore) held(key string) int {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return len(ms.leases[key])
+}
+
+func TestMiddleware_SharedBudget(t *testing.T) {
+	store := &memoryStore{}
+	budget := &SharedBudgetConfig{
+		Store: store,
+		Limit: 2,
+		Match: func(r *http.Request) bool { return r.URL.Path != "/local" },
+	}
+	// Two pods, each with room for more than the fleet's budget.
+	pods := []*Shedder{New(Config{HardLimit: 10, SharedBudget: budget}), New(Config{HardLimit: 10, SharedBudget: budget})}
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, s := range pods {
+		h := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started <- struct{}{}
+			<-release
+		}))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/db", nil))
+		}()
+		<-started
+	}
+	if store.held("kube-shedder") != 2 {
+		t.Fatalf("expected both requests to hold a lease, got %d", store.held("kube-shedder"))
+	}
+
+	h := pods[0].Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/db", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("X-Shed-Reason") != "shared_budget" {
+		t.Errorf("expected 503 with the fleet's budget spent, got %d %q", rec.Code, rec.Header().Get("X-Shed-Reason"))
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/local", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected requests outside the budget admitted, got %d", rec.Code)
+	}
+
+	close(release)
+	wg.Wait()
+	deadline := time.Now().Add(time.Second)
+	for store.held("kube-shedder") != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if store.held("kube-shedder") != 0 {
+		t.Errorf("expected leases released, got %d held", store.held("kube-shedder"))
+	}
+}
+
+func TestMiddleware_SharedBudgetStoreErrors(t *testing.T) {
+	store := &memoryStore{err: errors.New("connection refused")}
+	var reported int
+	for _, failClosed := range []bool{false, true} {
+		s := New(Config{HardLimit: 10, SharedBudget: &SharedBudgetConfig{
+			Store:      store,
+			Limit:      1,
+			FailClosed: failClosed,
+			OnError:    func(error) { reported++ },
+		}})
+		rec := httptest.NewRecorder()
+		s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+		want := http.StatusOK
+		if failClosed {
+			want = http.StatusServiceUnavailable
+		}
+		if rec.Code != want {
+			t.Errorf("FailClosed=%v: expected %d, got %d", failClosed, want, rec.Code)
+		}
+		if got := s.Stats().SharedBudgetErrors; got != 1 {
+			t.Errorf("FailClosed=%v: expected 1 store error in Stats, got %d", failClosed, got)
+		}
+	}
+	if reported != 2 {
+		t.Errorf("expected OnError called for each error, got %d", reported)
+	}
+}
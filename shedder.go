@@ -2,7 +2,9 @@ package shedder
 
 import (
 	"net/http"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
 // ShedDecider is a callback function that determines whether a request
@@ -13,35 +15,358 @@ type ShedDecider func(r *http.Request) bool
 // Config holds the configuration for a Shedder instance.
 type Config struct {
 	// HardLimit is the maximum number of in-flight requests before the
-	// readiness endpoint returns 503. This is required and must be > 0.
+	// readiness endpoint returns 503. This is required and must be > 0,
+	// or Auto to derive it from the container's CPU quota.
 	HardLimit int64
 
+	// PerCoreConcurrency is the number of concurrent requests allowed per
+	// CPU core when HardLimit is Auto. Defaults to DefaultPerCoreConcurrency.
+	PerCoreConcurrency float64
+
 	// SoftLimit is the threshold for soft overload behavior.
 	// If SoftLimit > 0 and inflight > SoftLimit (but <= HardLimit),
 	// the ShedDecider is consulted to determine if requests should be fast-failed.
 	// If SoftLimit is 0 or negative, soft overload behavior is disabled.
 	SoftLimit int64
 
+	// SoftLimitRatio optionally expresses the soft limit as a fraction of
+	// the hard limit currently in effect (e.g. 0.8). It keeps the soft limit
+	// in step with adaptive or auto-derived hard limits and takes precedence
+	// over SoftLimit. Values outside (0, 1) are ignored.
+	SoftLimitRatio float64
+
+	// FastPathBelow, if in (0, 1], admits requests arriving while fewer
+	// than this fraction of the soft limit (or of the hard limit, without
+	// one) are in flight on a fast path that skips the deadline, priority
+	// cutoff, ShedDeciderV2, soft overload and route capacity checks, and
+	// does not time the request: a pod that far below its limits is not
+	// stressed. Client, tenant, quota and route caps, PriorityLevels,
+	// SharedBudget and OverloadSignal still apply. The LimitAlgorithm,
+	// CapacityEstimator and Deadline do not see latencies of fast path
+	// requests, nor Stats their peaks, so keep it well below the load they
+	// need to learn from, e.g. 0.5.
+	FastPathBelow float64
+
+	// CoarseClock, if set, times admitted requests with a clock advanced
+	// every CoarseClock (e.g. time.Millisecond) by a background goroutine
+	// instead of reading the system clock when each request starts and
+	// finishes, for services at very high request rates where those reads
+	// show in profiles. Latencies are then only accurate to CoarseClock,
+	// and requests faster than it may be measured as 0, so keep it well
+	// below the latencies the LimitAlgorithm, CapacityEstimator and
+	// Deadline act on. Shedders with the same CoarseClock share one
+	// goroutine, which runs for the life of the process. Must be at least
+	// 100µs.
+	CoarseClock time.Duration
+
+	// Clock, if set, tells the time instead of the system clock to the
+	// features that measure or wait out time by it, so tests and
+	// simulations can step through warmup, cooldown, hysteresis, sliding
+	// windows, queue timeouts and eviction without sleeping. See Clock for
+	// the loops that keep the system clock. CoarseClock is ignored with a
+	// Clock.
+	Clock Clock
+
 	// ShedDecider is called when in soft overload state to determine
 	// whether to shed a request. If nil and SoftLimit > 0, soft shedding
-	// is effectively disabled unless ShedHeader is set.
+	// is effectively disabled unless ShedHeader or ShedHeaders is set.
 	ShedDecider ShedDecider
 
+	// ShedDeciderV2 optionally decides for every request that passed the
+	// hard limit checks, given a LoadInfo snapshot, whether to admit it,
+	// shed it, or leave it to the soft limit and ShedDecider.
+	ShedDeciderV2 ShedDeciderV2
+
 	// ShedHeader specifies a header name and value for automatic shedding.
 	// When in soft overload state, requests with this header matching will be shed.
 	// This is an alternative to ShedDecider for simple priority-based shedding.
 	// If both ShedDecider and ShedHeader are set, ShedDecider takes precedence.
 	ShedHeader *HeaderMatcher
 
+	// ShedHeaders is an ordered list of header matchers for multi-tier
+	// header policies. The first matching entry decides: the request is
+	// shed unless the entry has Keep set. Requests matching no entry are
+	// kept. ShedHeader, if also set, is evaluated first.
+	ShedHeaders []HeaderMatcher
+
+	// ShedPaths sheds requests whose URL path matches, e.g. low-value
+	// endpoints such as exports or search suggestions. If set together
+	// with other matchers, a request is shed if any of them decides so.
+	// Ignored if ShedDecider is set.
+	ShedPaths *PathMatcher
+
+	// ShedMethods sheds requests with one of the listed HTTP methods,
+	// e.g. MethodMatcher{"GET", "HEAD"} to protect mutating requests.
+	// Combined with the other matchers like ShedPaths.
+	ShedMethods MethodMatcher
+
+	// ShedQuery sheds requests with a matching query parameter, e.g.
+	// best-effort calls marked with ?prefetch=true. A request is shed if
+	// any entry matches. Combined with the other matchers like ShedPaths.
+	ShedQuery []QueryMatcher
+
+	// ShedResponse replaces the default plain-text 503 response written to
+	// shed requests, e.g. with a branded JSON error payload.
+	ShedResponse *ShedResponse
+
+	// ShedResponseFunc builds the response for each shed request and takes
+	// precedence over ShedResponse. Returning nil writes the default.
+	ShedResponseFunc func(r *http.Request, reason ShedReason) *ShedResponse
+
+	// RetryAfter derives the Retry-After header of shed responses from the
+	// observed completion rate and backlog instead of always sending 1.
+	RetryAfter *RetryAfterConfig
+
+	// RetryAfterJitter adds a random delay of up to this duration to
+	// Retry-After, so clients shed together do not all retry in the same
+	// second and re-overload the pod. Retry-After counts whole seconds, so
+	// the duration is rounded up to one: 500ms adds 0 or 1 second.
+	RetryAfterJitter time.Duration
+
+	// RateLimitHeaders sends RateLimit-Limit, RateLimit-Remaining and
+	// RateLimit-Reset headers describing the concurrency budget on shed
+	// responses or on all responses, for clients that adapt to them.
+	RateLimitHeaders RateLimitHeaders
+
+	// TelemetryHeaders adds X-Shedder-Inflight and X-Shedder-Utilization
+	// (in-flight units over the hard limit, e.g. "0.75") to admitted
+	// responses, so clients and meshes can balance load before any
+	// request is shed.
+	TelemetryHeaders bool
+
+	// CloseOnHardShed sets Connection: close on responses shed because the
+	// pod is over capacity (hard limit, overload signal or queue timeout),
+	// so persistent connections from aggressive clients are re-balanced to
+	// other pods. net/http closes HTTP/1.x connections after the response
+	// and gracefully shuts down HTTP/2 connections.
+	CloseOnHardShed bool
+
+	// EnvoyOverloaded speaks Envoy's overload dialect: shed responses carry
+	// x-envoy-overloaded: true, which keeps Envoy from retrying them, and
+	// requests carrying x-envoy-overloaded, e.g. from an overloaded Envoy
+	// upstream, are treated as arriving under soft overload, so Retry
+	// detection and the ShedDecider apply to them.
+	EnvoyOverloaded bool
+
+	// ShedBody controls what happens to the unread request body of shed
+	// requests. By default net/http discards a small body after the
+	// response so the connection can be reused.
+	ShedBody ShedBodyPolicy
+
+	// ShedBodyLimit is the most bytes ShedBodyDiscard reads. Defaults to
+	// 256 KiB.
+	ShedBodyLimit int64
+
+	// ProblemJSON writes shed responses as RFC 7807 application/problem+json
+	// documents (see Problem) instead of plain text, so gateways and
+	// clients can parse rejections. ShedResponse, ShedResponseFunc and
+	// ShedHandler take precedence.
+	ProblemJSON bool
+
+	// ShedStatus sets the response status per shed reason, e.g.
+	// {ShedReasonSoftLimit: 429} to tell clients a soft shed is a
+	// per-request rejection rather than an unavailable pod. Reasons not
+	// listed get 503. A ShedResponse with a StatusCode overrides it.
+	ShedStatus map[ShedReason]int
+
+	// ShedHandler, if set, serves shed requests instead of ShedResponse,
+	// e.g. to render a static page or delegate to an existing error
+	// renderer. ShedReasonFromContext reports why the request was shed;
+	// Retry-After and X-Shed-Reason are already set when it is called,
+	// to values it may modify in place.
+	ShedHandler http.Handler
+
 	// OnShed is an optional callback invoked when a request is shed.
 	// Useful for logging or metrics (without adding direct dependencies).
+	// In DryRun mode it is invoked for requests that would have been shed;
+	// IsDryRun(r.Context()) reports true for those.
 	OnShed func(r *http.Request, reason ShedReason)
-}
 
-// HeaderMatcher defines a header name and value to match for shedding.
-type HeaderMatcher struct {
-	Name  string // Header name, e.g., "X-Priority"
-	Value string // Header value to match, e.g., "low"
+	// Brownout optionally derives degradation levels (normal, degraded,
+	// critical) from load and exposes the level at admission through
+	// DegradationFromContext, so handlers can skip expensive optional work
+	// before any request has to be rejected.
+	Brownout *BrownoutConfig
+
+	// PriorityCutoff optionally sheds requests whose numeric priority
+	// (e.g. X-Priority: 0-9) is below a cutoff that rises with occupancy,
+	// so the higher the load, the fewer priorities are admitted.
+	PriorityCutoff *PriorityCutoffConfig
+
+	// Retry optionally detects retried requests and sheds them first under
+	// soft overload, whatever the ShedDecider decides.
+	Retry *RetryConfig
+
+	// ShedBudget optionally caps the fraction of requests the soft limit
+	// may shed over a rolling window, bounding the collateral damage of an
+	// overly aggressive ShedDecider or a misconfigured SoftLimit. Requests
+	// over the budget are admitted.
+	ShedBudget *ShedBudgetConfig
+
+	// Cost optionally weighs requests: each in-flight request counts Cost
+	// units against the limits instead of one, so heavy requests such as
+	// bulk exports use more of the capacity than cheap ones. Results below
+	// 1 count as 1 and results above the hard limit in effect are capped to
+	// it, so a heavy request can still run on an idle pod.
+	Cost func(r *http.Request) int64
+
+	// ExemptPaths lists paths that are never shed, even above the hard
+	// limit, e.g. drain endpoints or webhook receivers whose senders do
+	// not retry. Exempt requests still count towards the in-flight total.
+	ExemptPaths *PathMatcher
+
+	// DryRun evaluates and records all limit checks (Stats, OnShed) without
+	// rejecting any request, so limit values can be trialled safely in
+	// production before they are enforced.
+	DryRun bool
+
+	// LimitAlgorithm optionally adjusts the hard limit at runtime based on
+	// observed latency and shedding. HardLimit is used as the initial limit.
+	// If nil, StaticLimit(HardLimit) is used and the limit never changes.
+	// A StaticLimit other than HardLimit is ignored and fails Validate.
+	LimitAlgorithm LimitAlgorithm
+
+	// OverloadSignal optionally declares hard overload from sources other
+	// than the in-flight count (CPU, memory, custom). While it reports
+	// overload, the readiness endpoint returns 503 and all requests are
+	// shed, exactly as if HardLimit were exceeded. Combine several sources
+	// with AnySignal, AllSignals or WeightedSignals.
+	OverloadSignal OverloadSignal
+
+	// SoftOverloadSignal optionally declares soft overload. While it
+	// reports overload, requests are offered to the ShedDecider (or
+	// ShedHeader) even if in-flight requests are below SoftLimit.
+	SoftOverloadSignal OverloadSignal
+
+	// CapacityEstimator optionally measures throughput and latency of
+	// admitted requests and exposes a recommended limit via Stats. To let
+	// the estimate drive the hard limit, also set it as LimitAlgorithm.
+	CapacityEstimator *CapacityEstimator
+
+	// ErrorRate optionally tracks the fraction of 5xx responses returned by
+	// the wrapped handler and enters soft overload when it exceeds the
+	// configured threshold, since rising errors often precede saturation.
+	ErrorRate *ErrorRateConfig
+
+	// Warmup optionally starts the effective hard limit low when the
+	// shedder is created and ramps it up to the full limit, protecting cold
+	// pods (empty caches, fresh connection pools) from a full load the
+	// moment they become ready.
+	Warmup *WarmupConfig
+
+	// Cooldown optionally keeps the hard limit reduced for a while after
+	// requests were shed for exceeding it, preventing the pod from
+	// re-overloading the moment in-flight requests dip below the limit.
+	Cooldown *CooldownConfig
+
+	// Readiness optionally adds hysteresis to the readiness probe: the pod
+	// becomes unready above one in-flight threshold and ready again only
+	// below a lower one, so steady borderline load does not make it flap
+	// in and out of the Service.
+	Readiness *ReadinessConfig
+
+	// RouteCapacity optionally tracks throughput per route and, while the
+	// hard limit is contested, caps each route at its throughput share of
+	// the limit, so one pathologically slow endpoint cannot consume the
+	// whole pod's concurrency.
+	RouteCapacity *RouteCapacityConfig
+
+	// RouteCeilings optionally caps routes at fixed in-flight maximums
+	// enforced alongside the hard limit: a request is admitted only if
+	// both its route and the pod have room, so noisy endpoints are
+	// contained without splitting the pod's capacity into fixed silos.
+	RouteCeilings *RouteCeilingConfig
+
+	// Routes optionally declares hard and soft limits per path pattern,
+	// enforced alongside the global limits by the one middleware instance.
+	// Keys ending in "/" match path prefixes, keys containing "*" are
+	// path.Match globs and other keys match exactly; the most specific
+	// entry applies.
+	Routes map[string]RouteLimits
+
+	// MethodLimits optionally declares hard and soft limits per class of
+	// HTTP methods, e.g. one for GET and HEAD and a smaller one for POST,
+	// PUT and DELETE, so read storms cannot starve mutations and vice
+	// versa. They are enforced alongside the global limits.
+	MethodLimits []MethodLimit
+
+	// Hosts optionally declares hard and soft limits per Host header, for
+	// pods serving several virtual hosts, so a surge on one hostname sheds
+	// only that host's requests. Keys starting with "*." match any
+	// subdomain. They are enforced alongside the global limits.
+	Hosts map[string]RouteLimits
+
+	// PeakHalfLife is the half-life of the decaying peak in-flight
+	// watermark reported by Stats. Defaults to DefaultPeakHalfLife.
+	PeakHalfLife time.Duration
+
+	// Surge optionally enters soft overload when in-flight requests rise
+	// faster than a configured rate, so that shedding starts during flash
+	// crowds before the absolute limits are reached.
+	Surge *SurgeConfig
+
+	// Burst optionally admits short bursts above HardLimit, controlled by a
+	// token bucket, so momentary spikes are absorbed while sustained
+	// overload is still rejected. The readiness endpoint is unaffected.
+	Burst *BurstConfig
+
+	// Queue optionally makes requests that exceed the hard limit wait for a
+	// free slot, up to a timeout, instead of being rejected immediately.
+	// Many callers prefer a short wait to a retry round-trip.
+	Queue *QueueConfig
+
+	// PriorityLevels optionally divides the hard limit between named
+	// traffic classes by their shares, with a queue per class, similar to
+	// Kubernetes API Priority and Fairness. It generalizes the binary
+	// ShedDecider to any number of tiers. Classify assigns requests to
+	// levels; unknown or unclassified requests use the last level.
+	PriorityLevels []PriorityLevel
+
+	// Deadline optionally sheds requests whose remaining time, from the
+	// context deadline or the X-Request-Timeout or grpc-timeout header, is
+	// less than the recent handler latency: work for requests that will
+	// time out anyway is wasted capacity.
+	Deadline *DeadlineConfig
+
+	// Preemption optionally lets high-priority requests arriving at the
+	// hard limit cancel, via their context, the longest-running
+	// low-priority request instead of being rejected. Handlers must honor
+	// context cancellation for the victim's slot to be freed.
+	Preemption *PreemptionConfig
+
+	// Eviction optionally cancels the context of requests in flight longer
+	// than MaxAge and reclaims their slots, protecting the pod from slot
+	// exhaustion by stuck or runaway requests.
+	Eviction *EvictionConfig
+
+	// ClientIP optionally caps the requests a single client IP may have in
+	// flight, as a cheap defense against one misbehaving caller. It is
+	// enforced before all other limits.
+	ClientIP *ClientIPConfig
+
+	// Tenant optionally caps the share of the hard limit any one tenant
+	// may hold in flight, so a single noisy customer cannot trigger
+	// shedding for everyone on the pod. Over-cap requests are shed before
+	// the global limits are checked.
+	Tenant *TenantConfig
+
+	// ClassQuotas optionally caps each class returned by Classify at a
+	// fraction (0, 1] of the hard limit in effect, e.g. {"batch": 0.2}.
+	// Classes without a quota, or with a quota of 1, are unlimited. A
+	// quota of 0 is invalid rather than "no share": to shed a class
+	// entirely, use a ShedDecider or matcher. Quotas are enforced on every
+	// request, before the global limits.
+	ClassQuotas map[string]float64
+
+	// SharedBudget optionally caps the requests the whole fleet has in
+	// flight, counted in a CounterStore shared by its pods, e.g. Redis. It
+	// is checked last, so only requests the pod would admit take a lease.
+	SharedBudget *SharedBudgetConfig
+
+	// Classify returns the name of the traffic class of a request. It is
+	// used by PriorityLevels, Queue.Weights and ClassQuotas and must have
+	// bounded cardinality; ClassifyRules builds one from simple rules.
+	Classify func(r *http.Request) string
 }
 
 // ShedReason indicates why a request was shed.
@@ -56,6 +381,61 @@ const (
 	// in-flight requests exceeded SoftLimit and the ShedDecider
 	// (or header match) determined it should be shed.
 	ShedReasonSoftLimit
+
+	// ShedReasonOverloadSignal indicates the request was shed because
+	// Config.OverloadSignal reported overload.
+	ShedReasonOverloadSignal
+
+	// ShedReasonRouteLimit indicates the request was shed because its
+	// route exceeded its share of the hard limit.
+	ShedReasonRouteLimit
+
+	// ShedReasonQueueTimeout indicates the request waited in the queue
+	// for a free slot but none became available in time.
+	ShedReasonQueueTimeout
+
+	// ShedReasonPriorityLevel indicates the request's priority level was
+	// at its share of the hard limit and its queue was full.
+	ShedReasonPriorityLevel
+
+	// ShedReasonTenantLimit indicates the request's tenant was at its
+	// share of the hard limit.
+	ShedReasonTenantLimit
+
+	// ShedReasonClientLimit indicates the request's client IP was at its
+	// concurrency cap.
+	ShedReasonClientLimit
+
+	// ShedReasonDeadline indicates the request could not have finished
+	// before its deadline.
+	ShedReasonDeadline
+
+	// ShedReasonRetry indicates a retried request was shed under soft
+	// overload.
+	ShedReasonRetry
+
+	// ShedReasonPriorityCutoff indicates the request's numeric priority
+	// was below the cutoff for the current load.
+	ShedReasonPriorityCutoff
+
+	// ShedReasonClassQuota indicates the request's class was at its quota.
+	ShedReasonClassQuota
+
+	// ShedReasonMethodLimit indicates the request's method class was at
+	// its MethodLimits hard limit.
+	ShedReasonMethodLimit
+
+	// ShedReasonHostLimit indicates the request's Host was at its Hosts
+	// hard limit.
+	ShedReasonHostLimit
+
+	// ShedReasonDraining indicates the shedder was draining for shutdown.
+	ShedReasonDraining
+
+	// ShedReasonSharedBudget indicates the fleet's SharedBudget was spent.
+	ShedReasonSharedBudget
+
+	numShedReasons // must be last
 )
 
 func (r ShedReason) String() string {
@@ -64,43 +444,285 @@ func (r ShedReason) String() string {
 		return "hard_limit"
 	case ShedReasonSoftLimit:
 		return "soft_limit"
+	case ShedReasonOverloadSignal:
+		return "overload_signal"
+	case ShedReasonRouteLimit:
+		return "route_limit"
+	case ShedReasonQueueTimeout:
+		return "queue_timeout"
+	case ShedReasonPriorityLevel:
+		return "priority_level"
+	case ShedReasonTenantLimit:
+		return "tenant_limit"
+	case ShedReasonClientLimit:
+		return "client_limit"
+	case ShedReasonDeadline:
+		return "deadline"
+	case ShedReasonRetry:
+		return "retry"
+	case ShedReasonPriorityCutoff:
+		return "priority_cutoff"
+	case ShedReasonClassQuota:
+		return "class_quota"
+	case ShedReasonMethodLimit:
+		return "method_limit"
+	case ShedReasonHostLimit:
+		return "host_limit"
+	case ShedReasonDraining:
+		return "draining"
+	case ShedReasonSharedBudget:
+		return "shared_budget"
 	default:
 		return "unknown"
 	}
 }
 
+// overCapacity reports whether r means the pod as a whole is over
+// capacity, rather than the request being selectively rejected.
+func (r ShedReason) overCapacity() bool {
+	return r == ShedReasonHardLimit || r == ShedReasonOverloadSignal || r == ShedReasonQueueTimeout
+}
+
 // Shedder tracks in-flight requests and provides load shedding capabilities.
 type Shedder struct {
-	hardLimit   int64
-	softLimit   int64
-	inflight    atomic.Int64
-	shedDecider ShedDecider
-	onShed      func(r *http.Request, reason ShedReason)
+	hardLimit int64
+	inflight  *inflightCounter // shared with Child shedders
+	parent    *Shedder
+	onShed    func(r *http.Request, reason ShedReason)
+	cost      func(r *http.Request) int64
+
+	config    atomic.Pointer[config] // replaced by Apply and the setters
+	configMu  sync.Mutex             // serializes writers of config
+	deciderV2 ShedDeciderV2
+	latencies *latencyHistogram // nil unless ShedDeciderV2 is set
+
+	limit     atomic.Int64 // hard limit currently in effect
+	algorithm LimitAlgorithm
+	static    bool
+
+	overloadSignal OverloadSignal
+	softSignal     OverloadSignal
+
+	estimator     *CapacityEstimator
+	timed         bool // whether admitted requests are timed
+	fastPathBelow float64
+	coarse        *coarseClock // nil unless CoarseClock is set
+	clock         Clock
+	costSamples   bool // whether the algorithm learns from ReportCost
+	errorRate     *errorRateTracker
+	warmup        *warmupRamp
+	cooldown      *cooldown
+	routes        *routeCapacity
+	peaks         *peakTracker
+	surge         *surgeDetector
+	burst         *burstBucket
+	queue         *waitQueue
+	priority      *prioritySet
+	classify      func(r *http.Request) string
+	tenants       *tenantCaps
+	clients       *clientCaps
+	deadline      *deadlineAdmission
+
+	active     *activeRequests // nil unless requests can be canceled
+	preemption *preemption
+	eviction   *eviction
+	budget     *shedBudget
+	backoff    *retryAfterEstimator
+	brownout   *brownout
+	retries    *retryDetector
+	cutoff     *priorityCutoff
+	ceilings   []*ceilingSet
+	shared     *sharedBudget
+	exempt     *PathMatcher
+	readiness  *readiness
+	peers      atomic.Pointer[peers]  // set by WatchPeers
+	gossip     atomic.Pointer[gossip] // set by Gossip
+
+	nodePressure atomic.Pointer[nodePressure]      // set by WatchNodePressure
+	metrics      atomic.Pointer[metricsAggregator] // set by ReportMetrics
+
+	shedResponse     *ShedResponse
+	shedResponseFunc func(r *http.Request, reason ShedReason) *ShedResponse
+	shedHandler      http.Handler
+	shedStatus       map[ShedReason]int
+	problemJSON      bool
+	jitter           int // seconds
+	rateLimitHeaders RateLimitHeaders
+	telemetryHeaders bool
+	closeOnHardShed  bool
+	envoyOverloaded  bool
+	shedBody         ShedBodyPolicy
+	shedBodyLimit    int64
+
+	dryRun   bool
+	draining atomic.Bool
+	startup  startup
+
+	admitted   atomic.Int64
+	shedCount  atomic.Int64
+	dryRunShed atomic.Int64
+	preempted  atomic.Int64
+	evicted    atomic.Int64
 }
 
 // New creates a new Shedder with the given configuration.
-// It panics if HardLimit is <= 0.
+// It panics if HardLimit is <= 0 (other than Auto).
 func New(cfg Config) *Shedder {
+	if cfg.HardLimit == Auto {
+		cfg.HardLimit = autoLimit(cfg.PerCoreConcurrency)
+	}
 	if cfg.HardLimit <= 0 {
 		panic("shedder: HardLimit must be > 0")
 	}
 
 	s := &Shedder{
 		hardLimit: cfg.HardLimit,
-		softLimit: cfg.SoftLimit,
+		inflight:  new(inflightCounter),
 		onShed:    cfg.OnShed,
+		cost:      cfg.Cost,
+		dryRun:    cfg.DryRun,
+		exempt:    cfg.ExemptPaths,
+		algorithm: cfg.LimitAlgorithm,
+		clock:     cfg.Clock,
+
+		shedResponse:     cfg.ShedResponse,
+		shedResponseFunc: cfg.ShedResponseFunc,
+		shedHandler:      cfg.ShedHandler,
+		shedStatus:       cfg.ShedStatus,
+		problemJSON:      cfg.ProblemJSON,
+		jitter:           int((cfg.RetryAfterJitter + time.Second - 1) / time.Second),
+		rateLimitHeaders: cfg.RateLimitHeaders,
+		telemetryHeaders: cfg.TelemetryHeaders,
+		closeOnHardShed:  cfg.CloseOnHardShed,
+		envoyOverloaded:  cfg.EnvoyOverloaded,
+		shedBody:         cfg.ShedBody,
+		shedBodyLimit:    cfg.ShedBodyLimit,
+
+		overloadSignal: cfg.OverloadSignal,
+		softSignal:     cfg.SoftOverloadSignal,
+
+		estimator: cfg.CapacityEstimator,
+	}
+	if cfg.FastPathBelow > 0 && cfg.FastPathBelow <= 1 {
+		s.fastPathBelow = cfg.FastPathBelow
+	}
+	if s.algorithm == nil {
+		s.algorithm = StaticLimit(cfg.HardLimit)
+	}
+	if s.clock == nil {
+		s.clock = systemClock{}
+	}
+	c := &config{softLimit: cfg.SoftLimit}
+	if cfg.SoftLimitRatio > 0 && cfg.SoftLimitRatio < 1 {
+		c.softRatio = cfg.SoftLimitRatio
 	}
+	s.peaks = newPeakTracker(cfg.PeakHalfLife, s.now())
+	s.static = isStatic(s.algorithm)
+	_, s.costSamples = s.algorithm.(CostLimitAlgorithm)
+	if cfg.Preemption != nil && cfg.Preemption.Preemptible != nil {
+		s.preemption = newPreemption(*cfg.Preemption)
+		s.active = &activeRequests{}
+	}
+	if cfg.Eviction != nil && cfg.Eviction.MaxAge > 0 {
+		s.eviction = &eviction{maxAge: cfg.Eviction.MaxAge, onEvict: cfg.Eviction.OnEvict}
+		s.active = &activeRequests{}
+	}
+	if cfg.Brownout != nil {
+		s.brownout = newBrownout(*cfg.Brownout)
+	}
+	if len(cfg.ClassQuotas) > 0 && cfg.Classify != nil {
+		c.quotas = newClassQuotas(cfg.ClassQuotas, cfg.Classify, nil)
+	}
+	if cfg.PriorityCutoff != nil {
+		s.cutoff = newPriorityCutoff(*cfg.PriorityCutoff)
+	}
+	if cfg.Retry != nil {
+		s.retries = newRetryDetector(*cfg.Retry)
+	}
+	if cfg.ShedBudget != nil && cfg.ShedBudget.MaxFraction > 0 {
+		s.budget = newShedBudget(*cfg.ShedBudget)
+	}
+	if s.shedBodyLimit <= 0 {
+		s.shedBodyLimit = defaultShedBodyLimit
+	}
+	if cfg.RetryAfter != nil {
+		s.backoff = newRetryAfterEstimator(*cfg.RetryAfter)
+	}
+	if cfg.Deadline != nil {
+		s.deadline = newDeadlineAdmission(*cfg.Deadline)
+	}
+	if cfg.ShedDeciderV2 != nil {
+		s.deciderV2 = cfg.ShedDeciderV2
+		s.latencies = newLatencyHistogram()
+	}
+	s.timed = !s.static || s.estimator != nil || s.deadline != nil || s.latencies != nil
+	if s.timed && cfg.CoarseClock > 0 && cfg.Clock == nil {
+		s.coarse = sharedCoarseClock(max(cfg.CoarseClock, minCoarseClock))
+	}
+
+	if cfg.Warmup != nil && cfg.Warmup.Duration > 0 {
+		s.warmup = newWarmupRamp(*cfg.Warmup, s.now())
+	}
+	if cfg.Cooldown != nil && cfg.Cooldown.Duration > 0 {
+		s.cooldown = newCooldown(*cfg.Cooldown)
+	}
+	if cfg.Burst != nil && cfg.Burst.Size > 0 {
+		s.burst = newBurstBucket(*cfg.Burst, s.now())
+	}
+	s.classify = cfg.Classify
+	if cfg.Queue != nil && cfg.Queue.Timeout > 0 {
+		s.queue = newWaitQueue(*cfg.Queue, cfg.HardLimit, s.clock)
+		s.inflight.watch(s.queue)
+	}
+	if len(cfg.PriorityLevels) > 0 {
+		s.priority = newPrioritySet(cfg.PriorityLevels, cfg.Classify, s.currentLimit, s.clock)
+	}
+	if cfg.ClientIP != nil && cfg.ClientIP.MaxInflight > 0 {
+		s.clients = newClientCaps(*cfg.ClientIP)
+	}
+	if cfg.Tenant != nil && cfg.Tenant.Key != nil {
+		s.tenants = newTenantCaps(*cfg.Tenant)
+	}
+	if cfg.Readiness != nil {
+		s.readiness = newReadiness(*cfg.Readiness)
+	}
+	if cfg.RouteCapacity != nil && cfg.RouteCapacity.Key != nil {
+		s.routes = newRouteCapacity(*cfg.RouteCapacity, s.now())
+	}
+	if cfg.RouteCeilings != nil && cfg.RouteCeilings.Key != nil {
+		s.ceilings = append(s.ceilings, newRouteCeilings(*cfg.RouteCeilings))
+	}
+	if len(cfg.Routes) > 0 {
+		s.ceilings = append(s.ceilings, newRoutes(cfg.Routes))
+	}
+	if len(cfg.MethodLimits) > 0 {
+		s.ceilings = append(s.ceilings, newMethodLimits(cfg.MethodLimits))
+	}
+	if len(cfg.Hosts) > 0 {
+		s.ceilings = append(s.ceilings, newHosts(cfg.Hosts))
+	}
+	if cfg.SharedBudget != nil && cfg.SharedBudget.Store != nil && cfg.SharedBudget.Limit > 0 {
+		s.shared = newSharedBudget(*cfg.SharedBudget)
+	}
+	if cfg.ErrorRate != nil {
+		s.errorRate = newErrorRateTracker(*cfg.ErrorRate, s.clock)
+		s.softSignal = AnySignal(s.softSignal, s.errorRate)
+	}
+	if cfg.Surge != nil && cfg.Surge.MaxRisePerSecond > 0 {
+		s.surge = newSurgeDetector(*cfg.Surge, s.Inflight, s.clock)
+		s.softSignal = AnySignal(s.softSignal, s.surge)
+	}
+	s.limit.Store(cfg.HardLimit)
 
 	// Determine the shed decider to use
 	if cfg.ShedDecider != nil {
-		s.shedDecider = cfg.ShedDecider
-	} else if cfg.ShedHeader != nil {
-		// Create a header-based decider
-		s.shedDecider = func(r *http.Request) bool {
-			return r.Header.Get(cfg.ShedHeader.Name) == cfg.ShedHeader.Value
-		}
+		c.decider = cfg.ShedDecider
+	} else {
+		// Create a decider from the configured matchers
+		c.decider = matcherDecider(cfg)
 	}
-	// If neither is set, shedDecider remains nil (soft shedding disabled)
+	// If none is set, the decider remains nil (soft shedding disabled)
+	s.config.Store(c)
 
 	return s
 }
@@ -114,32 +736,39 @@ func NewWithLimits(hardLimit, softLimit int64) *Shedder {
 	})
 }
 
-// Inflight returns the current number of in-flight requests.
+// Inflight returns the current number of in-flight requests, or of
+// in-flight cost units when Config.Cost is set.
 func (s *Shedder) Inflight() int64 {
 	return s.inflight.Load()
 }
 
-// IsOverloaded returns true if in-flight requests exceed HardLimit.
+// IsOverloaded returns true if in-flight requests exceed HardLimit
+// or the configured OverloadSignal reports overload.
 func (s *Shedder) IsOverloaded() bool {
-	return s.inflight.Load() > s.hardLimit
+	return s.inflight.Load() > s.currentLimit() || s.signalOverloaded()
 }
 
-// IsSoftOverloaded returns true if soft limit is configured and
-// in-flight requests exceed SoftLimit (but not HardLimit).
+// IsSoftOverloaded returns true if the shedder is not hard overloaded and
+// either in-flight requests exceed the soft limit in effect or the
+// SoftOverloadSignal reports overload.
 func (s *Shedder) IsSoftOverloaded() bool {
-	if s.softLimit <= 0 {
+	if s.IsOverloaded() {
 		return false
 	}
-	inflight := s.inflight.Load()
-	return inflight > s.softLimit && inflight <= s.hardLimit
+	if soft := s.currentSoftLimit(); soft > 0 && s.inflight.Load() > soft {
+		return true
+	}
+	return s.softSignalOverloaded()
 }
 
-// increment adds one to the in-flight counter and returns the new value.
-func (s *Shedder) increment() int64 {
-	return s.inflight.Add(1)
+// increment adds n units to the in-flight counter and returns the new value.
+func (s *Shedder) increment(n int64) int64 {
+	return s.inflight.Add(n)
 }
 
-// decrement subtracts one from the in-flight counter.
-func (s *Shedder) decrement() {
-	s.inflight.Add(-1)
+// decrement subtracts n units from the in-flight counter and wakes a
+// queued request, if any, of every shedder sharing the counter.
+func (s *Shedder) decrement(n int64) {
+	s.inflight.Add(-n)
+	s.inflight.notify()
 }
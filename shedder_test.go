@@ -38,8 +38,8 @@ func TestNew_ValidConfig(t *testing.T) {
 
 func TestNew_WithSoftLimit(t *testing.T) {
 	s := New(Config{HardLimit: 100, SoftLimit: 80})
-	if s.softLimit != 80 {
-		t.Errorf("expected softLimit 80, got %d", s.softLimit)
+	if s.config.Load().softLimit != 80 {
+		t.Errorf("expected softLimit 80, got %d", s.config.Load().softLimit)
 	}
 }
 
@@ -48,8 +48,8 @@ func TestNewWithLimits(t *testing.T) {
 	if s.hardLimit != 100 {
 		t.Errorf("expected hardLimit 100, got %d", s.hardLimit)
 	}
-	if s.softLimit != 80 {
-		t.Errorf("expected softLimit 80, got %d", s.softLimit)
+	if s.config.Load().softLimit != 80 {
+		t.Errorf("expected softLimit 80, got %d", s.config.Load().softLimit)
 	}
 }
 
@@ -57,7 +57,7 @@ func TestShedder_IncrementDecrement(t *testing.T) {
 	s := New(Config{HardLimit: 100})
 
 	// Increment
-	if val := s.increment(); val != 1 {
+	if val := s.increment(1); val != 1 {
 		t.Errorf("expected 1 after increment, got %d", val)
 	}
 	if s.Inflight() != 1 {
@@ -65,17 +65,17 @@ func TestShedder_IncrementDecrement(t *testing.T) {
 	}
 
 	// Another increment
-	if val := s.increment(); val != 2 {
+	if val := s.increment(1); val != 2 {
 		t.Errorf("expected 2 after second increment, got %d", val)
 	}
 
 	// Decrement
-	s.decrement()
+	s.decrement(1)
 	if s.Inflight() != 1 {
 		t.Errorf("expected inflight 1 after decrement, got %d", s.Inflight())
 	}
 
-	s.decrement()
+	s.decrement(1)
 	if s.Inflight() != 0 {
 		t.Errorf("expected inflight 0 after second decrement, got %d", s.Inflight())
 	}
@@ -88,22 +88,22 @@ func TestShedder_IsOverloaded(t *testing.T) {
 		t.Error("should not be overloaded initially")
 	}
 
-	s.increment() // 1
+	s.increment(1) // 1
 	if s.IsOverloaded() {
 		t.Error("should not be overloaded at 1")
 	}
 
-	s.increment() // 2
+	s.increment(1) // 2
 	if s.IsOverloaded() {
 		t.Error("should not be overloaded at hard limit")
 	}
 
-	s.increment() // 3
+	s.increment(1) // 3
 	if !s.IsOverloaded() {
 		t.Error("should be overloaded above hard limit")
 	}
 
-	s.decrement() // back to 2
+	s.decrement(1) // back to 2
 	if s.IsOverloaded() {
 		t.Error("should not be overloaded after decrement")
 	}
@@ -114,21 +114,21 @@ func TestShedder_IsSoftOverloaded(t *testing.T) {
 
 	// Under soft limit
 	for i := 0; i < 5; i++ {
-		s.increment()
+		s.increment(1)
 	}
 	if s.IsSoftOverloaded() {
 		t.Error("should not be soft overloaded at soft limit")
 	}
 
 	// Above soft limit, below hard limit
-	s.increment() // 6
+	s.increment(1) // 6
 	if !s.IsSoftOverloaded() {
 		t.Error("should be soft overloaded")
 	}
 
 	// At hard limit
 	for i := 0; i < 4; i++ {
-		s.increment()
+		s.increment(1)
 	}
 	// Now at 10
 	if !s.IsSoftOverloaded() {
@@ -136,7 +136,7 @@ func TestShedder_IsSoftOverloaded(t *testing.T) {
 	}
 
 	// Above hard limit - no longer "soft" overloaded, just overloaded
-	s.increment() // 11
+	s.increment(1) // 11
 	if s.IsSoftOverloaded() {
 		t.Error("should not be soft overloaded above hard limit")
 	}
@@ -146,7 +146,7 @@ func TestShedder_SoftLimitDisabledWhenZero(t *testing.T) {
 	s := New(Config{HardLimit: 10, SoftLimit: 0})
 
 	for i := 0; i < 10; i++ {
-		s.increment()
+		s.increment(1)
 	}
 	if s.IsSoftOverloaded() {
 		t.Error("soft overload should be disabled when SoftLimit is 0")
@@ -157,7 +157,7 @@ func TestShedder_SoftLimitDisabledWhenNegative(t *testing.T) {
 	s := New(Config{HardLimit: 10, SoftLimit: -1})
 
 	for i := 0; i < 10; i++ {
-		s.increment()
+		s.increment(1)
 	}
 	if s.IsSoftOverloaded() {
 		t.Error("soft overload should be disabled when SoftLimit is negative")
@@ -171,6 +171,19 @@ func TestShedReason_String(t *testing.T) {
 	}{
 		{ShedReasonHardLimit, "hard_limit"},
 		{ShedReasonSoftLimit, "soft_limit"},
+		{ShedReasonOverloadSignal, "overload_signal"},
+		{ShedReasonRouteLimit, "route_limit"},
+		{ShedReasonQueueTimeout, "queue_timeout"},
+		{ShedReasonPriorityLevel, "priority_level"},
+		{ShedReasonTenantLimit, "tenant_limit"},
+		{ShedReasonClientLimit, "client_limit"},
+		{ShedReasonDeadline, "deadline"},
+		{ShedReasonRetry, "retry"},
+		{ShedReasonPriorityCutoff, "priority_cutoff"},
+		{ShedReasonClassQuota, "class_quota"},
+		{ShedReasonMethodLimit, "method_limit"},
+		{ShedReasonHostLimit, "host_limit"},
+		{ShedReasonDraining, "draining"},
 		{ShedReason(99), "unknown"},
 	}
 
@@ -191,7 +204,7 @@ func TestNew_WithHeaderMatcher(t *testing.T) {
 		},
 	})
 
-	if s.shedDecider == nil {
+	if s.decider() == nil {
 		t.Error("shedDecider should be set when ShedHeader is provided")
 	}
 }
@@ -212,7 +225,7 @@ func TestNew_ShedDeciderTakesPrecedence(t *testing.T) {
 	})
 
 	// Call the decider
-	s.shedDecider(nil)
+	s.decider()(nil)
 	if !customCalled {
 		t.Error("custom ShedDecider should take precedence over ShedHeader")
 	}
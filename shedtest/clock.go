@@ -0,0 +1,117 @@
+package shedtest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	shedder "github.com/sampath030/kube-shedder"
+)
+
+// Clock is a shedder.Clock that only moves when the test advances it. Set
+// it as Config.Clock to step a real Shedder through warmup, cooldown,
+// readiness hysteresis, sliding windows, queue timeouts and eviction
+// without sleeping:
+//
+//	clock := shedtest.NewClock(time.Time{})
+//	s := shedder.New(shedder.Config{HardLimit: 100, Clock: clock, Warmup: &shedder.WarmupConfig{Duration: time.Minute}})
+//	clock.Advance(time.Minute) // the warmup is over
+//
+// It is safe for concurrent use.
+type Clock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers map[*timer]bool
+}
+
+var _ shedder.Clock = (*Clock)(nil)
+
+// NewClock returns a Clock reading start, or an arbitrary fixed time if
+// start is zero.
+func NewClock(start time.Time) *Clock {
+	if start.IsZero() {
+		start = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return &Clock{now: start}
+}
+
+// Now returns the clock's time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel the time is sent on once the clock is advanced
+// by d.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+// NewTimer returns a Timer that fires once the clock is advanced by d.
+func (c *Clock) NewTimer(d time.Duration) shedder.Timer {
+	return c.schedule(d, &timer{c: c, ch: make(chan time.Time, 1)})
+}
+
+// AfterFunc returns a Timer that calls f once the clock is advanced by d.
+func (c *Clock) AfterFunc(d time.Duration, f func()) shedder.Timer {
+	return c.schedule(d, &timer{c: c, f: f})
+}
+
+// schedule adds t to fire after d, at once if d is not positive.
+func (c *Clock) schedule(d time.Duration, t *timer) *timer {
+	c.mu.Lock()
+	if c.timers == nil {
+		c.timers = make(map[*timer]bool)
+	}
+	t.when = c.now.Add(d)
+	c.timers[t] = true
+	c.mu.Unlock()
+	c.Advance(0)
+	return t
+}
+
+// Advance moves the clock forward by d and fires the timers that are due,
+// in order. Functions passed to AfterFunc run before Advance returns.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	var due []*timer
+	for t := range c.timers {
+		if !t.when.After(now) {
+			due = append(due, t)
+			delete(c.timers, t)
+		}
+	}
+	c.mu.Unlock()
+
+	sort.Slice(due, func(i, j int) bool { return due[i].when.Before(due[j].when) })
+	for _, t := range due {
+		if t.f != nil {
+			t.f()
+		} else {
+			t.ch <- now
+		}
+	}
+}
+
+// timer is a Timer of a Clock.
+type timer struct {
+	c    *Clock
+	when time.Time
+	ch   chan time.Time
+	f    func()
+}
+
+// C returns the timer's channel.
+func (t *timer) C() <-chan time.Time { return t.ch }
+
+// Stop stops the timer, reporting whether it had yet to fire.
+func (t *timer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	pending := t.c.timers[t]
+	delete(t.c.timers, t)
+	return pending
+}
@@ -0,0 +1,77 @@
+package shedtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	shedder "github.com/sampath030/kube-shedder"
+)
+
+func TestClock_ReadinessHysteresis(t *testing.T) {
+	clock := NewClock(time.Time{})
+	var overloaded atomic.Bool
+	s := shedder.New(shedder.Config{
+		HardLimit:      10,
+		Clock:          clock,
+		OverloadSignal: shedder.SignalFunc(overloaded.Load),
+		Readiness:      &shedder.ReadinessConfig{MinUnready: 10 * time.Second},
+	})
+	probe := func() int {
+		rec := httptest.NewRecorder()
+		s.ReadyHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/ready", nil))
+		return rec.Code
+	}
+
+	overloaded.Store(true)
+	if code := probe(); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 under overload, got %d", code)
+	}
+	overloaded.Store(false)
+	clock.Advance(9 * time.Second)
+	if code := probe(); code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 for MinUnready, got %d", code)
+	}
+	clock.Advance(time.Second)
+	if code := probe(); code != http.StatusOK {
+		t.Errorf("expected 200 after MinUnready, got %d", code)
+	}
+}
+
+func TestClock_Timers(t *testing.T) {
+	clock := NewClock(time.Time{})
+	var fired []string
+	clock.AfterFunc(2*time.Second, func() { fired = append(fired, "late") })
+	clock.AfterFunc(time.Second, func() { fired = append(fired, "early") })
+	stopped := clock.AfterFunc(time.Second, func() { fired = append(fired, "stopped") })
+	timer := clock.NewTimer(time.Second)
+
+	if !stopped.Stop() {
+		t.Error("expected Stop to report a pending timer")
+	}
+	clock.Advance(999 * time.Millisecond)
+	select {
+	case <-timer.C():
+		t.Fatal("expected the timer not to fire early")
+	default:
+	}
+	clock.Advance(2 * time.Second)
+	select {
+	case <-timer.C():
+	default:
+		t.Fatal("expected the timer to fire")
+	}
+	if timer.Stop() {
+		t.Error("expected Stop to report a fired timer")
+	}
+	if len(fired) != 2 || fired[0] != "early" || fired[1] != "late" {
+		t.Errorf("expected AfterFunc calls in order, got %v", fired)
+	}
+	select {
+	case <-clock.After(0):
+	default:
+		t.Error("expected After(0) to fire at once")
+	}
+}
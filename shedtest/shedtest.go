@@ -0,0 +1,274 @@
+// Package shedtest provides a fake shedder whose load and shed decisions
+// are scripted by tests, so applications can unit-test their handling of
+// shed requests and degradation levels without generating real
+// concurrency:
+//
+//	f := shedtest.New(cfg)
+//	f.Script(shedtest.Admit, shedtest.Shed(shedder.ShedReasonSoftLimit))
+//	h := f.Middleware(app)
+//
+//	// The first request reaches app, the second gets the 503 the
+//	// middleware would write with cfg.
+//
+// Accept shedder.Interface in application code to inject a Fake in tests.
+package shedtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	shedder "github.com/sampath030/kube-shedder"
+)
+
+// Decision is a scripted admission decision for one request.
+type Decision struct {
+	// Shed reports whether the request is shed, and Reason why.
+	Shed   bool
+	Reason shedder.ShedReason
+}
+
+// Admit is the Decision to admit a request.
+var Admit = Decision{}
+
+// Shed returns the Decision to shed a request with reason.
+func Shed(reason shedder.ShedReason) Decision {
+	return Decision{Shed: true, Reason: reason}
+}
+
+// Fake is a shedder.Interface whose in-flight count, limits, overload
+// state, degradation level and shed decisions are set by the test. Its
+// middleware admits or sheds each request as scripted, writing shed
+// responses exactly as a real Shedder built from the same Config would.
+// It is safe for concurrent use.
+type Fake struct {
+	shedder *shedder.Shedder // writes shed responses
+
+	mu             sync.Mutex
+	inflight       int64 // scripted, plus requests in the middleware
+	hardLimit      int64
+	softLimit      int64
+	overloaded     bool
+	softOverloaded bool
+	degradation    shedder.DegradationLevel
+	script         []Decision
+	decide         func(r *http.Request) Decision
+	admitted       int64
+	shed           []shedder.ShedReason
+}
+
+var _ shedder.Interface = (*Fake)(nil)
+
+// New returns a Fake that admits every request. cfg shapes its shed
+// responses, as it would a real Shedder's: ShedResponse, ShedHandler,
+// ShedStatus, ProblemJSON, RateLimitHeaders, OnShed and the like. Its
+// HardLimit (default 100) and SoftLimit are the initial limits; the
+// settings that decide what to shed are ignored.
+func New(cfg shedder.Config) *Fake {
+	if cfg.HardLimit <= 0 {
+		cfg.HardLimit = 100
+	}
+	responses := shedder.New(shedder.Config{
+		HardLimit:        cfg.HardLimit,
+		OnShed:           cfg.OnShed,
+		ShedResponse:     cfg.ShedResponse,
+		ShedResponseFunc: cfg.ShedResponseFunc,
+		ShedHandler:      cfg.ShedHandler,
+		ShedStatus:       cfg.ShedStatus,
+		ShedBody:         cfg.ShedBody,
+		ShedBodyLimit:    cfg.ShedBodyLimit,
+		ProblemJSON:      cfg.ProblemJSON,
+		RateLimitHeaders: cfg.RateLimitHeaders,
+		RetryAfterJitter: cfg.RetryAfterJitter,
+		CloseOnHardShed:  cfg.CloseOnHardShed,
+		EnvoyOverloaded:  cfg.EnvoyOverloaded,
+	})
+	return &Fake{shedder: responses, hardLimit: cfg.HardLimit, softLimit: cfg.SoftLimit}
+}
+
+// SetInflight sets the in-flight count, to which requests in the
+// middleware are added.
+func (f *Fake) SetInflight(n int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.inflight = n
+}
+
+// SetLimits sets the hard and soft limits in effect.
+func (f *Fake) SetLimits(hard, soft int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.hardLimit, f.softLimit = hard, soft
+}
+
+// SetOverloaded sets the overload state, on top of the in-flight count
+// exceeding the hard limit. An overloaded Fake is not ready.
+func (f *Fake) SetOverloaded(overloaded bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.overloaded = overloaded
+}
+
+// SetSoftOverloaded sets the soft overload state, on top of the in-flight
+// count exceeding the soft limit.
+func (f *Fake) SetSoftOverloaded(overloaded bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.softOverloaded = overloaded
+}
+
+// SetDegradation sets the degradation level attached to admitted
+// requests, as read by shedder.DegradationFromContext.
+func (f *Fake) SetDegradation(level shedder.DegradationLevel) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.degradation = level
+}
+
+// Script queues decisions for the next requests, in order. Once they are
+// used up, requests are decided by ShedWhen.
+func (f *Fake) Script(decisions ...Decision) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.script = append(f.script, decisions...)
+}
+
+// ShedWhen decides the requests not covered by Script with decide; nil
+// admits them.
+func (f *Fake) ShedWhen(decide func(r *http.Request) Decision) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.decide = decide
+}
+
+// ShedAll sheds every request not covered by Script with reason.
+func (f *Fake) ShedAll(reason shedder.ShedReason) {
+	f.ShedWhen(func(*http.Request) Decision { return Shed(reason) })
+}
+
+// Admitted returns the number of requests the middleware admitted.
+func (f *Fake) Admitted() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.admitted
+}
+
+// ShedReasons returns the reasons of the requests the middleware shed, in
+// order.
+func (f *Fake) ShedReasons() []shedder.ShedReason {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]shedder.ShedReason(nil), f.shed...)
+}
+
+// next decides r, recording the decision, and returns the degradation
+// level of admitted requests.
+func (f *Fake) next(r *http.Request) (Decision, shedder.DegradationLevel) {
+	f.mu.Lock()
+	var d Decision
+	scripted := len(f.script) > 0
+	if scripted {
+		d, f.script = f.script[0], f.script[1:]
+	}
+	decide := f.decide
+	f.mu.Unlock()
+	if !scripted && decide != nil {
+		d = decide(r) // unlocked, as it may call back into f
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if d.Shed {
+		f.shed = append(f.shed, d.Reason)
+		return d, 0
+	}
+	f.admitted++
+	f.inflight++
+	return d, f.degradation
+}
+
+// Middleware admits or sheds each request as scripted. Admitted requests
+// count as in flight while next serves them and carry the degradation
+// level set with SetDegradation.
+func (f *Fake) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, level := f.next(r)
+		if d.Shed {
+			f.shedder.WriteShed(w, r, d.Reason)
+			return
+		}
+		defer func() {
+			f.mu.Lock()
+			f.inflight--
+			f.mu.Unlock()
+		}()
+		next.ServeHTTP(w, r.WithContext(shedder.WithDegradation(r.Context(), level)))
+	})
+}
+
+// MiddlewareFunc returns Middleware as a function for middleware chains.
+func (f *Fake) MiddlewareFunc() func(http.Handler) http.Handler {
+	return f.Middleware
+}
+
+// ReadyHandler returns 503 while the Fake is overloaded and 200 OK
+// otherwise, with the bodies of Shedder.ReadyHandler.
+func (f *Fake) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st := f.Stats()
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if st.Overloaded {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, "not ready: inflight=%d, hardLimit=%d", st.Inflight, st.HardLimit)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "ready: inflight=%d, hardLimit=%d", st.Inflight, st.HardLimit)
+	})
+}
+
+// StatusHandler serves Stats as JSON.
+func (f *Fake) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(f.Stats())
+	})
+}
+
+// Inflight returns the in-flight count.
+func (f *Fake) Inflight() int64 { return f.Stats().Inflight }
+
+// IsOverloaded reports whether the Fake was set overloaded or the
+// in-flight count exceeds the hard limit.
+func (f *Fake) IsOverloaded() bool { return f.Stats().Overloaded }
+
+// IsSoftOverloaded reports whether the Fake was set soft overloaded or the
+// in-flight count exceeds a soft limit.
+func (f *Fake) IsSoftOverloaded() bool { return f.Stats().SoftOverloaded }
+
+// HardLimit returns the hard limit set.
+func (f *Fake) HardLimit() int64 { return f.Stats().HardLimit }
+
+// SoftLimit returns the soft limit set.
+func (f *Fake) SoftLimit() int64 { return f.Stats().SoftLimit }
+
+// Stats returns the scripted state and the middleware's counts.
+func (f *Fake) Stats() shedder.Stats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	st := shedder.Stats{
+		Inflight:       f.inflight,
+		HardLimit:      f.hardLimit,
+		SoftLimit:      f.softLimit,
+		Overloaded:     f.overloaded || f.inflight > f.hardLimit,
+		SoftOverloaded: f.softOverloaded || f.softLimit > 0 && f.inflight > f.softLimit,
+		Admitted:       f.admitted,
+		Shed:           int64(len(f.shed)),
+	}
+	if f.degradation != shedder.DegradationNormal {
+		st.Degradation = f.degradation.String()
+	}
+	return st
+}
@@ -0,0 +1,94 @@
+package shedtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	shedder "github.com/sampath030/kube-shedder"
+)
+
+func TestFake_Script(t *testing.T) {
+	f := New(shedder.Config{ProblemJSON: true})
+	var levels []shedder.DegradationLevel
+	var inflight int64
+	h := f.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		levels = append(levels, shedder.DegradationFromContext(r.Context()))
+		inflight = f.Inflight()
+	}))
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		return rec
+	}
+
+	f.SetDegradation(shedder.DegradationDegraded)
+	f.Script(Admit, Shed(shedder.ShedReasonSoftLimit))
+	f.ShedWhen(func(r *http.Request) Decision {
+		if f.Inflight() >= 5 {
+			return Shed(shedder.ShedReasonHardLimit)
+		}
+		return Admit
+	})
+
+	if rec := serve(); rec.Code != http.StatusOK || inflight != 1 {
+		t.Errorf("expected the first request admitted and in flight, got %d with %d in flight", rec.Code, inflight)
+	}
+	rec := serve()
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("X-Shed-Reason") != "soft_limit" {
+		t.Errorf("expected the scripted soft shed, got %d %q", rec.Code, rec.Header().Get("X-Shed-Reason"))
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("expected the configured problem response, got %q", ct)
+	}
+	if rec := serve(); rec.Code != http.StatusOK {
+		t.Errorf("expected ShedWhen to admit after the script, got %d", rec.Code)
+	}
+	f.SetInflight(5)
+	if rec := serve(); rec.Header().Get("X-Shed-Reason") != "hard_limit" {
+		t.Errorf("expected ShedWhen to shed at the scripted in-flight count, got %q", rec.Header().Get("X-Shed-Reason"))
+	}
+
+	if f.Admitted() != 2 || len(f.ShedReasons()) != 2 || f.ShedReasons()[1] != shedder.ShedReasonHardLimit {
+		t.Errorf("expected 2 admitted and 2 shed, got %d %v", f.Admitted(), f.ShedReasons())
+	}
+	for _, level := range levels {
+		if level != shedder.DegradationDegraded {
+			t.Errorf("expected admitted requests degraded, got %v", level)
+		}
+	}
+}
+
+func TestFake_State(t *testing.T) {
+	f := New(shedder.Config{HardLimit: 10, SoftLimit: 8})
+	if f.IsOverloaded() || f.IsSoftOverloaded() || f.HardLimit() != 10 || f.SoftLimit() != 8 {
+		t.Errorf("unexpected initial state: %+v", f.Stats())
+	}
+
+	f.SetInflight(9)
+	if !f.IsSoftOverloaded() || f.IsOverloaded() {
+		t.Errorf("expected soft overload above the soft limit, got %+v", f.Stats())
+	}
+	rec := httptest.NewRecorder()
+	f.ReadyHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/ready", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected ready below the hard limit, got %d", rec.Code)
+	}
+
+	f.SetLimits(20, 0)
+	f.SetInflight(0)
+	f.SetOverloaded(true)
+	rec = httptest.NewRecorder()
+	f.ReadyHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/ready", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected unready while overloaded, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	f.StatusHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/status", nil))
+	var st shedder.Stats
+	if err := json.NewDecoder(rec.Body).Decode(&st); err != nil || !st.Overloaded || st.HardLimit != 20 {
+		t.Errorf("expected the scripted Stats, got %+v, %v", st, err)
+	}
+}
@@ -0,0 +1,120 @@
+package shedder
+
+// OverloadSignal reports whether some resource of the pod is overloaded.
+// Signals let overload be declared from sources other than the in-flight
+// request count, such as CPU, memory, or application-specific state.
+//
+// Overloaded is called on the request path, so implementations must be
+// cheap and safe for concurrent use. Expensive measurements should be
+// sampled in the background or cached between calls.
+type OverloadSignal interface {
+	Overloaded() bool
+}
+
+// SignalFunc adapts an ordinary function to the OverloadSignal interface.
+type SignalFunc func() bool
+
+// Overloaded calls f().
+func (f SignalFunc) Overloaded() bool {
+	return f()
+}
+
+// AnySignal returns a signal that is overloaded when at least one of the
+// given signals is overloaded. Nil signals are ignored.
+func AnySignal(signals ...OverloadSignal) OverloadSignal {
+	signals = compactSignals(signals)
+	return SignalFunc(func() bool {
+		for _, sig := range signals {
+			if sig.Overloaded() {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// AllSignals returns a signal that is overloaded only when every given
+// signal is overloaded. Nil signals are ignored; with no signals the
+// result is never overloaded.
+func AllSignals(signals ...OverloadSignal) OverloadSignal {
+	signals = compactSignals(signals)
+	return SignalFunc(func() bool {
+		if len(signals) == 0 {
+			return false
+		}
+		for _, sig := range signals {
+			if !sig.Overloaded() {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// WeightedSignal pairs a signal with its weight for WeightedSignals.
+type WeightedSignal struct {
+	Signal OverloadSignal
+	Weight float64
+}
+
+// WeightedSignals returns a signal that is overloaded when the summed
+// weight of the currently overloaded signals reaches threshold.
+//
+// For example, with CPU weighted 0.6, memory 0.3 and a custom signal 0.3,
+// a threshold of 0.9 requires CPU plus one of the others.
+func WeightedSignals(threshold float64, signals ...WeightedSignal) OverloadSignal {
+	var ws []WeightedSignal
+	for _, w := range signals {
+		if w.Signal != nil {
+			ws = append(ws, w)
+		}
+	}
+	return SignalFunc(func() bool {
+		var total float64
+		for _, w := range ws {
+			if w.Signal.Overloaded() {
+				total += w.Weight
+				if total >= threshold {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+// InflightSignal returns a signal that is overloaded when the shedder's
+// in-flight count exceeds its hard limit. It is useful for composing the
+// shedder's own verdict with other signals.
+func (s *Shedder) InflightSignal() OverloadSignal {
+	return SignalFunc(func() bool {
+		return s.inflight.Load() > s.currentLimit()
+	})
+}
+
+// compactSignals returns signals with nil entries removed.
+func compactSignals(signals []OverloadSignal) []OverloadSignal {
+	out := make([]OverloadSignal, 0, len(signals))
+	for _, sig := range signals {
+		if sig != nil {
+			out = append(out, sig)
+		}
+	}
+	return out
+}
+
+// signalOverloaded reports whether the configured hard overload signal, or
+// that of a Child shedder's parent, fires.
+func (s *Shedder) signalOverloaded() bool {
+	if s.overloadSignal != nil && s.overloadSignal.Overloaded() {
+		return true
+	}
+	return s.parent != nil && s.parent.signalOverloaded()
+}
+
+// softSignalOverloaded reports whether the configured soft overload signal
+// fires, Gossip found the fleet saturated or WatchNodePressure found the
+// node under pressure.
+func (s *Shedder) softSignalOverloaded() bool {
+	return s.softSignal != nil && s.softSignal.Overloaded() || s.gossipOverloaded() || s.nodeUnderPressure()
+}
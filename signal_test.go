@@ -0,0 +1,126 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSignalCombinators(t *testing.T) {
+	on := SignalFunc(func() bool { return true })
+	off := SignalFunc(func() bool { return false })
+
+	tests := []struct {
+		name   string
+		signal OverloadSignal
+		want   bool
+	}{
+		{"any none", AnySignal(), false},
+		{"any off", AnySignal(off, off), false},
+		{"any one on", AnySignal(off, on), true},
+		{"any ignores nil", AnySignal(nil, on), true},
+		{"all none", AllSignals(), false},
+		{"all mixed", AllSignals(on, off), false},
+		{"all on", AllSignals(on, on), true},
+		{"weighted below", WeightedSignals(1, WeightedSignal{on, 0.6}, WeightedSignal{off, 0.6}), false},
+		{"weighted reached", WeightedSignals(1, WeightedSignal{on, 0.6}, WeightedSignal{on, 0.4}), true},
+		{"weighted ignores nil", WeightedSignals(0.5, WeightedSignal{nil, 1}), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.signal.Overloaded(); got != tt.want {
+				t.Errorf("Overloaded() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShedder_OverloadSignal(t *testing.T) {
+	var overloaded atomic.Bool
+	s := New(Config{
+		HardLimit:      10,
+		OverloadSignal: SignalFunc(overloaded.Load),
+	})
+
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	overloaded.Store(true)
+	if !s.IsOverloaded() {
+		t.Error("should be overloaded when signal fires")
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", rec.Code)
+	}
+	if rec.Header().Get("X-Shed-Reason") != "overload_signal" {
+		t.Errorf("expected X-Shed-Reason 'overload_signal', got %q", rec.Header().Get("X-Shed-Reason"))
+	}
+
+	rec = httptest.NewRecorder()
+	s.ReadyHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/ready", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected readiness 503, got %d", rec.Code)
+	}
+
+	overloaded.Store(false)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 once signal clears, got %d", rec.Code)
+	}
+}
+
+func TestShedder_SoftOverloadSignal(t *testing.T) {
+	var overloaded atomic.Bool
+	s := New(Config{
+		HardLimit:          10,
+		SoftOverloadSignal: SignalFunc(overloaded.Load),
+		ShedHeader:         &HeaderMatcher{Name: "X-Priority", Value: "low"},
+	})
+
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Priority", "low")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 without soft overload, got %d", rec.Code)
+	}
+
+	overloaded.Store(true)
+	if !s.IsSoftOverloaded() {
+		t.Error("should be soft overloaded when soft signal fires")
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 under soft signal, got %d", rec.Code)
+	}
+	if rec.Header().Get("X-Shed-Reason") != "soft_limit" {
+		t.Errorf("expected X-Shed-Reason 'soft_limit', got %q", rec.Header().Get("X-Shed-Reason"))
+	}
+}
+
+func TestShedder_InflightSignal(t *testing.T) {
+	s := New(Config{HardLimit: 1})
+	sig := s.InflightSignal()
+
+	s.increment(1)
+	if sig.Overloaded() {
+		t.Error("should not be overloaded at hard limit")
+	}
+	s.increment(1)
+	if !sig.Overloaded() {
+		t.Error("should be overloaded above hard limit")
+	}
+}
@@ -0,0 +1,112 @@
+package simulation
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Arrivals is a synthetic arrival process.
+type Arrivals interface {
+	// Next returns the time from an arrival at elapsed, the time since
+	// the start of the run, to the next arrival. It must be positive.
+	Next(rng *rand.Rand, elapsed time.Duration) time.Duration
+}
+
+// ArrivalsFunc adapts an ordinary function to the Arrivals interface.
+type ArrivalsFunc func(rng *rand.Rand, elapsed time.Duration) time.Duration
+
+// Next calls f(rng, elapsed).
+func (f ArrivalsFunc) Next(rng *rand.Rand, elapsed time.Duration) time.Duration {
+	return f(rng, elapsed)
+}
+
+// Poisson returns a Poisson process of rate arrivals per second: the gaps
+// between arrivals are exponentially distributed, as for requests from
+// many independent clients.
+func Poisson(rate float64) Arrivals {
+	return ArrivalsFunc(func(rng *rand.Rand, elapsed time.Duration) time.Duration {
+		return atLeastOne(rng.ExpFloat64() / rate * float64(time.Second))
+	})
+}
+
+// Uniform returns arrivals evenly spaced at rate arrivals per second.
+func Uniform(rate float64) Arrivals {
+	gap := atLeastOne(float64(time.Second) / rate)
+	return ArrivalsFunc(func(rng *rand.Rand, elapsed time.Duration) time.Duration {
+		return gap
+	})
+}
+
+// Phase is one phase of a Phases arrival process.
+type Phase struct {
+	// Duration is how long the phase lasts.
+	Duration time.Duration
+
+	// Arrivals is the arrival process during the phase.
+	Arrivals Arrivals
+}
+
+// Phases returns an arrival process that follows each phase's Arrivals in
+// turn, e.g. a steady load, a spike and the steady load again, to see how
+// a limit algorithm reacts to and recovers from a change. The last phase
+// lasts for the rest of the run.
+func Phases(phases ...Phase) Arrivals {
+	if len(phases) == 0 {
+		panic("simulation: Phases needs at least one phase")
+	}
+	return ArrivalsFunc(func(rng *rand.Rand, elapsed time.Duration) time.Duration {
+		var end time.Duration
+		for _, p := range phases[:len(phases)-1] {
+			end += p.Duration
+			if elapsed < end {
+				return p.Arrivals.Next(rng, elapsed)
+			}
+		}
+		return phases[len(phases)-1].Arrivals.Next(rng, elapsed)
+	})
+}
+
+// ServiceTime is a distribution of the time requests take to serve on an
+// otherwise idle worker.
+type ServiceTime interface {
+	Sample(rng *rand.Rand) time.Duration
+}
+
+// ServiceTimeFunc adapts an ordinary function to the ServiceTime
+// interface.
+type ServiceTimeFunc func(rng *rand.Rand) time.Duration
+
+// Sample calls f(rng).
+func (f ServiceTimeFunc) Sample(rng *rand.Rand) time.Duration {
+	return f(rng)
+}
+
+// Fixed returns a ServiceTime of always d.
+func Fixed(d time.Duration) ServiceTime {
+	return ServiceTimeFunc(func(rng *rand.Rand) time.Duration { return d })
+}
+
+// Exponential returns exponentially distributed service times with the
+// given mean.
+func Exponential(mean time.Duration) ServiceTime {
+	return ServiceTimeFunc(func(rng *rand.Rand) time.Duration {
+		return time.Duration(rng.ExpFloat64() * float64(mean))
+	})
+}
+
+// LogNormal returns log-normally distributed service times with the given
+// median, the long-tailed shape typical of real handlers. sigma is the
+// standard deviation of the logarithm: 0.5 puts the 99th percentile at
+// about 3.2 times the median, 1 at about 10 times.
+func LogNormal(median time.Duration, sigma float64) ServiceTime {
+	return ServiceTimeFunc(func(rng *rand.Rand) time.Duration {
+		return time.Duration(float64(median) * math.Exp(rng.NormFloat64()*sigma))
+	})
+}
+
+// atLeastOne converts nanoseconds to a Duration of at least 1ns, so
+// arrivals always move time forward.
+func atLeastOne(ns float64) time.Duration {
+	return max(time.Duration(ns), 1)
+}
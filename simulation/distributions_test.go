@@ -0,0 +1,59 @@
+package simulation
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestPoisson(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	arrivals := Poisson(1000)
+	var total time.Duration
+	for i := 0; i < 10000; i++ {
+		gap := arrivals.Next(rng, total)
+		if gap <= 0 {
+			t.Fatalf("expected positive gaps, got %v", gap)
+		}
+		total += gap
+	}
+	if total < 9500*time.Millisecond || total > 10500*time.Millisecond {
+		t.Errorf("expected 10000 arrivals in about 10s, got %v", total)
+	}
+}
+
+func TestPhases(t *testing.T) {
+	arrivals := Phases(
+		Phase{Duration: time.Second, Arrivals: Uniform(10)},
+		Phase{Duration: time.Second, Arrivals: Uniform(100)},
+		Phase{Arrivals: Uniform(1000)},
+	)
+	for _, tt := range []struct {
+		elapsed time.Duration
+		want    time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{999 * time.Millisecond, 100 * time.Millisecond},
+		{time.Second, 10 * time.Millisecond},
+		{2 * time.Second, time.Millisecond},
+		{time.Hour, time.Millisecond},
+	} {
+		if got := arrivals.Next(nil, tt.elapsed); got != tt.want {
+			t.Errorf("at %v: expected a gap of %v, got %v", tt.elapsed, tt.want, got)
+		}
+	}
+}
+
+func TestLogNormal(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	service := LogNormal(10*time.Millisecond, 0.5)
+	below := 0
+	for i := 0; i < 10000; i++ {
+		if service.Sample(rng) < 10*time.Millisecond {
+			below++
+		}
+	}
+	if below < 4800 || below > 5200 {
+		t.Errorf("expected half the samples below the median, got %d of 10000", below)
+	}
+}
@@ -0,0 +1,466 @@
+// Package simulation drives a Shedder with synthetic load in virtual time,
+// so limit algorithms and configurations can be compared reproducibly
+// before they meet production traffic:
+//
+//	res, err := simulation.Run(simulation.Scenario{
+//		Config:      shedder.Config{HardLimit: 50, LimitAlgorithm: algorithm},
+//		Arrivals:    simulation.Poisson(1500),
+//		ServiceTime: simulation.LogNormal(20*time.Millisecond, 0.5),
+//		Workers:     16,
+//		Duration:    time.Minute,
+//	})
+//	fmt.Println(res) // shed rate, utilization, latency percentiles
+//
+// Requests pass through the real Middleware. Time only moves from one
+// simulated arrival or completion to the next, read by the shedder through
+// Config.Clock, so a minute of load takes milliseconds to simulate and the
+// same Seed always yields the same Result. Each admitted request parks a
+// goroutine in the wrapped handler until its simulated completion, but only
+// one goroutine runs at a time, handing over control in event order; no
+// goroutine sleeps or races another.
+package simulation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"time"
+
+	shedder "github.com/sampath030/kube-shedder"
+	"github.com/sampath030/kube-shedder/shedtest"
+)
+
+// Scenario describes a simulated load on a shedder.
+type Scenario struct {
+	// Config configures the shedder under test. Its Clock is replaced by
+	// the Scenario's. Queue and queueing PriorityLevels hold requests a
+	// run does not track, and Preemption and Eviction cancel requests the
+	// simulated service ignores, so they are not supported.
+	Config shedder.Config
+
+	// Clock is the virtual clock of the run, advanced by Run. A
+	// CapacityEstimator in Config must tell time by it. Defaults to a new
+	// shedtest.Clock.
+	Clock *shedtest.Clock
+
+	// Arrivals is the arrival process of requests. Required.
+	Arrivals Arrivals
+
+	// ServiceTime is the distribution of the time requests take on an idle
+	// worker. Required.
+	ServiceTime ServiceTime
+
+	// Workers is the number of requests the simulated server processes at
+	// full speed, such as its CPU cores. Beyond it, the workers are shared
+	// and every admitted request slows down in proportion, so latency
+	// grows with concurrency as on a saturated server. 0 means unlimited:
+	// every request takes exactly its service time.
+	Workers int
+
+	// Duration is how long requests arrive for. Requests admitted by then
+	// are run to completion. Required.
+	Duration time.Duration
+
+	// Interval, if set, splits the Result into Intervals of this length,
+	// to follow how the shedder reacts to changes in load over the run.
+	Interval time.Duration
+
+	// Request returns each arriving request, e.g. with a random priority
+	// header for a ShedDecider. Defaults to GET /.
+	Request func(rng *rand.Rand) *http.Request
+
+	// Seed seeds the random numbers of Arrivals, ServiceTime and Request.
+	Seed int64
+}
+
+// Result reports a simulation run.
+type Result struct {
+	// Arrived counts the requests that arrived, of which Admitted were
+	// served and Shed were shed, by reason in ShedReasons.
+	Arrived     int64
+	Admitted    int64
+	Shed        int64
+	ShedReasons map[shedder.ShedReason]int64
+
+	// ShedRate is the fraction of arrived requests shed.
+	ShedRate float64
+
+	// Utilization is the fraction of the Workers' capacity busy over the
+	// run, 0 without Workers. MeanInflight is the mean number of admitted
+	// requests in flight.
+	Utilization  float64
+	MeanInflight float64
+
+	// Latency summarizes the latencies of the admitted requests, from
+	// arrival to completion.
+	Latency Latency
+
+	// Elapsed is the length of the run: Duration, or longer if admitted
+	// requests finished after it.
+	Elapsed time.Duration
+
+	// Intervals splits the run by Scenario.Interval.
+	Intervals []Interval
+}
+
+// Latency summarizes a set of request latencies.
+type Latency struct {
+	Mean, P50, P90, P99, Max time.Duration
+}
+
+// Interval reports one Scenario.Interval of a run.
+type Interval struct {
+	// Start is the start of the interval, since the start of the run.
+	Start time.Duration
+
+	// Arrived, Admitted and Shed count the requests that arrived in the
+	// interval, and Completed the requests that finished in it.
+	Arrived   int64
+	Admitted  int64
+	Shed      int64
+	Completed int64
+
+	// MeanLatency is the mean latency of the completed requests.
+	MeanLatency time.Duration
+
+	// Utilization and MeanInflight are as in Result, over the interval.
+	Utilization  float64
+	MeanInflight float64
+
+	// HardLimit and SoftLimit are the shedder's limits at the end of the
+	// interval.
+	HardLimit int64
+	SoftLimit int64
+}
+
+// String summarizes r on one line.
+func (r Result) String() string {
+	return fmt.Sprintf("arrived=%d shed=%.1f%% utilization=%.1f%% inflight=%.1f latency mean=%v p50=%v p90=%v p99=%v max=%v",
+		r.Arrived, r.ShedRate*100, r.Utilization*100, r.MeanInflight,
+		r.Latency.Mean, r.Latency.P50, r.Latency.P90, r.Latency.P99, r.Latency.Max)
+}
+
+// Run simulates sc and reports the outcome.
+func Run(sc Scenario) (Result, error) {
+	if err := sc.validate(); err != nil {
+		return Result{}, err
+	}
+	if sc.Clock == nil {
+		sc.Clock = shedtest.NewClock(time.Time{})
+	}
+	if sc.Request == nil {
+		sc.Request = func(*rand.Rand) *http.Request { return httptest.NewRequest("GET", "/", nil) }
+	}
+
+	cfg := sc.Config
+	cfg.Clock = sc.Clock
+	onShed := cfg.OnShed
+	cfg.OnShed = func(r *http.Request, reason shedder.ShedReason) {
+		if c, ok := r.Context().Value(callKey{}).(*call); ok {
+			c.reason = reason
+		}
+		if onShed != nil {
+			onShed(r, reason)
+		}
+	}
+	s := shedder.New(cfg)
+
+	sim := &simulator{
+		Scenario:    sc,
+		rng:         rand.New(rand.NewSource(sc.Seed)),
+		shedder:     s,
+		reasons:     make(map[shedder.ShedReason]int64),
+		intervalEnd: sc.Interval,
+	}
+	sim.handler = s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := r.Context().Value(callKey{}).(*call)
+		close(c.entered)
+		<-c.release
+	}))
+	if sc.Interval > 0 {
+		sim.intervals = []Interval{{}}
+	}
+	return sim.run(), nil
+}
+
+// validate reports a Scenario that cannot be simulated.
+func (sc *Scenario) validate() error {
+	var errs []error
+	if sc.Arrivals == nil {
+		errs = append(errs, errors.New("simulation: Arrivals is required"))
+	}
+	if sc.ServiceTime == nil {
+		errs = append(errs, errors.New("simulation: ServiceTime is required"))
+	}
+	if sc.Duration <= 0 {
+		errs = append(errs, fmt.Errorf("simulation: Duration must be > 0, got %v", sc.Duration))
+	}
+	if sc.Workers < 0 {
+		errs = append(errs, fmt.Errorf("simulation: Workers must be >= 0, got %d", sc.Workers))
+	}
+	if sc.Interval < 0 {
+		errs = append(errs, fmt.Errorf("simulation: Interval must be >= 0, got %v", sc.Interval))
+	}
+	if err := sc.Config.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if sc.Config.Queue != nil {
+		errs = append(errs, errors.New("simulation: Config.Queue is not supported"))
+	}
+	if sc.Config.Preemption != nil {
+		errs = append(errs, errors.New("simulation: Config.Preemption is not supported"))
+	}
+	if sc.Config.Eviction != nil {
+		errs = append(errs, errors.New("simulation: Config.Eviction is not supported"))
+	}
+	for _, level := range sc.Config.PriorityLevels {
+		if level.QueueLength > 0 {
+			errs = append(errs, fmt.Errorf("simulation: queueing priority level %q is not supported", level.Name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// callKey is the context key of a request's call.
+type callKey struct{}
+
+// call is a simulated request.
+type call struct {
+	arrived time.Duration
+	work    float64 // nanoseconds of service left on a full worker
+	reason  shedder.ShedReason
+
+	entered chan struct{} // closed when the wrapped handler is called
+	release chan struct{} // closed to return from the wrapped handler
+	done    chan struct{} // closed when the middleware returned
+}
+
+// simulator is the state of a run.
+type simulator struct {
+	Scenario
+	rng     *rand.Rand
+	shedder *shedder.Shedder
+	handler http.Handler
+
+	now     time.Duration // since the start of the run
+	running []*call       // admitted requests, by arrival
+
+	arrived, admitted, shed int64
+	reasons                 map[shedder.ShedReason]int64
+	latencies               []time.Duration
+	busy, inflight          float64 // integrals over time, in nanoseconds
+
+	intervals   []Interval // the last one is current
+	intervalEnd time.Duration
+	busyStart   float64 // busy and inflight at the start of the interval
+	inflightAt  float64
+	latencySum  time.Duration // of the current interval
+}
+
+// run processes arrivals and completions in time order until no request
+// is left, then reports them.
+func (m *simulator) run() Result {
+	var next time.Duration // the first request arrives at the start
+	for {
+		done, at := m.nextCompletion()
+		arriving := next < m.Duration
+		if done < 0 && !arriving {
+			break
+		}
+		if done >= 0 && (!arriving || at <= next) {
+			m.advance(at)
+			m.complete(done)
+			continue
+		}
+		m.advance(next)
+		m.arrive()
+		next += m.Arrivals.Next(m.rng, next)
+	}
+	m.advance(m.Duration)
+	return m.result()
+}
+
+// nextCompletion returns the index in running of the request that
+// finishes first, and when, or -1 if none is running.
+func (m *simulator) nextCompletion() (int, time.Duration) {
+	if len(m.running) == 0 {
+		return -1, 0
+	}
+	first := 0
+	for i, c := range m.running {
+		if c.work < m.running[first].work {
+			first = i
+		}
+	}
+	return first, m.now + time.Duration(m.running[first].work/m.speed()+0.5)
+}
+
+// speed is the fraction of a full worker each running request gets.
+func (m *simulator) speed() float64 {
+	if n := len(m.running); m.Workers > 0 && n > m.Workers {
+		return float64(m.Workers) / float64(n)
+	}
+	return 1
+}
+
+// advance moves time forward to at, serving the running requests and
+// closing the intervals that end on the way.
+func (m *simulator) advance(at time.Duration) {
+	for m.now < at {
+		to := at
+		if m.Interval > 0 && m.intervalEnd < to {
+			to = m.intervalEnd
+		}
+		dt := float64(to - m.now)
+		speed := m.speed()
+		for _, c := range m.running {
+			c.work = max(c.work-dt*speed, 0)
+		}
+		n := float64(len(m.running))
+		if m.Workers > 0 {
+			m.busy += min(n, float64(m.Workers)) * dt
+		}
+		m.inflight += n * dt
+		m.Clock.Advance(to - m.now)
+		m.now = to
+		if m.Interval > 0 && m.now == m.intervalEnd {
+			m.closeInterval()
+			m.intervals = append(m.intervals, Interval{Start: m.now})
+			m.intervalEnd += m.Interval
+		}
+	}
+}
+
+// arrive sends a request to the middleware and waits until it is either
+// admitted into the handler or shed.
+func (m *simulator) arrive() {
+	r := m.Request(m.rng)
+	c := &call{
+		arrived: m.now,
+		work:    float64(m.ServiceTime.Sample(m.rng)),
+		entered: make(chan struct{}),
+		release: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	r = r.WithContext(context.WithValue(r.Context(), callKey{}, c))
+	go func() {
+		defer close(c.done)
+		m.handler.ServeHTTP(httptest.NewRecorder(), r)
+	}()
+
+	m.arrived++
+	iv := m.interval()
+	if iv != nil {
+		iv.Arrived++
+	}
+	select {
+	case <-c.entered:
+		m.admitted++
+		m.running = append(m.running, c)
+		if iv != nil {
+			iv.Admitted++
+		}
+	case <-c.done:
+		m.shed++
+		m.reasons[c.reason]++
+		if iv != nil {
+			iv.Shed++
+		}
+	}
+}
+
+// complete returns the i-th running request from the handler and waits
+// for the middleware to finish with it.
+func (m *simulator) complete(i int) {
+	c := m.running[i]
+	m.running = append(m.running[:i], m.running[i+1:]...)
+	close(c.release)
+	<-c.done
+
+	latency := m.now - c.arrived
+	m.latencies = append(m.latencies, latency)
+	if iv := m.interval(); iv != nil {
+		iv.Completed++
+		m.latencySum += latency
+	}
+}
+
+// interval returns the current interval, or nil without Interval.
+func (m *simulator) interval() *Interval {
+	if len(m.intervals) == 0 {
+		return nil
+	}
+	return &m.intervals[len(m.intervals)-1]
+}
+
+// closeInterval completes the current interval at now.
+func (m *simulator) closeInterval() {
+	iv := m.interval()
+	if length := float64(m.now - iv.Start); length > 0 {
+		if m.Workers > 0 {
+			iv.Utilization = (m.busy - m.busyStart) / (length * float64(m.Workers))
+		}
+		iv.MeanInflight = (m.inflight - m.inflightAt) / length
+	}
+	if iv.Completed > 0 {
+		iv.MeanLatency = m.latencySum / time.Duration(iv.Completed)
+	}
+	iv.HardLimit, iv.SoftLimit = m.shedder.HardLimit(), m.shedder.SoftLimit()
+	m.busyStart, m.inflightAt, m.latencySum = m.busy, m.inflight, 0
+}
+
+// result reports the run once every request finished.
+func (m *simulator) result() Result {
+	res := Result{
+		Arrived:     m.arrived,
+		Admitted:    m.admitted,
+		Shed:        m.shed,
+		ShedReasons: m.reasons,
+		Elapsed:     m.now,
+		Latency:     summarize(m.latencies),
+	}
+	if m.arrived > 0 {
+		res.ShedRate = float64(m.shed) / float64(m.arrived)
+	}
+	if elapsed := float64(m.now); elapsed > 0 {
+		if m.Workers > 0 {
+			res.Utilization = m.busy / (elapsed * float64(m.Workers))
+		}
+		res.MeanInflight = m.inflight / elapsed
+	}
+	if iv := m.interval(); iv != nil {
+		if iv.Start < m.now {
+			m.closeInterval()
+		} else {
+			m.intervals = m.intervals[:len(m.intervals)-1] // empty, as the run ended on its start
+		}
+		res.Intervals = m.intervals
+	}
+	return res
+}
+
+// summarize returns the summary of latencies, sorting them.
+func summarize(latencies []time.Duration) Latency {
+	if len(latencies) == 0 {
+		return Latency{}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var sum time.Duration
+	for _, l := range latencies {
+		sum += l
+	}
+	at := func(q float64) time.Duration {
+		return latencies[int(q*float64(len(latencies)-1)+0.5)]
+	}
+	return Latency{
+		Mean: sum / time.Duration(len(latencies)),
+		P50:  at(0.5),
+		P90:  at(0.9),
+		P99:  at(0.99),
+		Max:  latencies[len(latencies)-1],
+	}
+}
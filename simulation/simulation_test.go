@@ -0,0 +1,180 @@
+package simulation
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	shedder "github.com/sampath030/kube-shedder"
+	"github.com/sampath030/kube-shedder/shedtest"
+)
+
+func TestRun_BelowCapacity(t *testing.T) {
+	res, err := Run(Scenario{
+		Config:      shedder.Config{HardLimit: 20},
+		Arrivals:    Uniform(500),
+		ServiceTime: Fixed(10 * time.Millisecond),
+		Workers:     10,
+		Duration:    time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Arrived != 500 || res.Shed != 0 {
+		t.Errorf("expected 500 requests and none shed, got %+v", res)
+	}
+	if res.Latency.P99 != 10*time.Millisecond || res.Latency.Max != 10*time.Millisecond {
+		t.Errorf("expected every request to take its service time, got %+v", res.Latency)
+	}
+	if res.Utilization < 0.49 || res.Utilization > 0.51 {
+		t.Errorf("expected a utilization of 50%%, got %v", res.Utilization)
+	}
+	if res.MeanInflight < 4.9 || res.MeanInflight > 5.1 {
+		t.Errorf("expected 5 requests in flight on average, got %v", res.MeanInflight)
+	}
+}
+
+func TestRun_Overload(t *testing.T) {
+	scenario := func(limit int64) Scenario {
+		return Scenario{
+			Config:      shedder.Config{HardLimit: limit},
+			Arrivals:    Poisson(2000),
+			ServiceTime: Exponential(10 * time.Millisecond),
+			Workers:     10,
+			Duration:    5 * time.Second,
+			Seed:        1,
+		}
+	}
+	tight, err := Run(scenario(15))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loose, err := Run(scenario(200))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The workers serve 1000 requests per second, half the arrivals.
+	for _, res := range []Result{tight, loose} {
+		if res.ShedRate < 0.4 || res.ShedRate > 0.6 {
+			t.Errorf("expected about half the requests shed, got %v", res)
+		}
+		if res.Utilization < 0.95 {
+			t.Errorf("expected saturated workers, got %v", res)
+		}
+		if res.ShedReasons[shedder.ShedReasonHardLimit] != res.Shed {
+			t.Errorf("expected only hard limit sheds, got %v", res.ShedReasons)
+		}
+	}
+	if tight.Latency.P99 >= loose.Latency.P99/4 {
+		t.Errorf("expected a tight limit to keep latency far lower, got p99 %v and %v", tight.Latency.P99, loose.Latency.P99)
+	}
+}
+
+func TestRun_Deterministic(t *testing.T) {
+	scenario := Scenario{
+		Config: shedder.Config{
+			HardLimit:   30,
+			SoftLimit:   20,
+			ShedDecider: func(r *http.Request) bool { return r.Header.Get("X-Priority") == "low" },
+		},
+		Arrivals:    Poisson(1500),
+		ServiceTime: LogNormal(8*time.Millisecond, 0.8),
+		Workers:     8,
+		Duration:    2 * time.Second,
+		Interval:    500 * time.Millisecond,
+		Request: func(rng *rand.Rand) *http.Request {
+			r := httptest.NewRequest("GET", "/", nil)
+			if rng.Intn(2) == 0 {
+				r.Header.Set("X-Priority", "low")
+			}
+			return r
+		},
+		Seed: 42,
+	}
+	first, err := Run(scenario)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Run(scenario)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("expected the same result from the same seed, got\n%+v\n%+v", first, second)
+	}
+	if first.ShedReasons[shedder.ShedReasonSoftLimit] == 0 {
+		t.Errorf("expected low priority requests shed at the soft limit, got %v", first.ShedReasons)
+	}
+
+	if len(first.Intervals) < 4 {
+		t.Fatalf("expected an interval per 500ms, got %d", len(first.Intervals))
+	}
+	var arrived, completed int64
+	for i, iv := range first.Intervals {
+		if iv.Start != time.Duration(i)*500*time.Millisecond || iv.HardLimit != 30 {
+			t.Errorf("unexpected interval %d: %+v", i, iv)
+		}
+		arrived += iv.Arrived
+		completed += iv.Completed
+	}
+	if arrived != first.Arrived || completed != first.Admitted {
+		t.Errorf("expected the intervals to add up to %d arrivals and %d completions, got %d and %d", first.Arrived, first.Admitted, arrived, completed)
+	}
+}
+
+func TestRun_AdaptiveLimit(t *testing.T) {
+	run := func(headroom float64) Result {
+		clock := shedtest.NewClock(time.Time{})
+		estimator := shedder.NewCapacityEstimator(shedder.CapacityEstimatorConfig{Window: time.Second, Headroom: headroom, Clock: clock})
+		res, err := Run(Scenario{
+			Config:      shedder.Config{HardLimit: 500, LimitAlgorithm: estimator},
+			Clock:       clock,
+			Arrivals:    Poisson(800),
+			ServiceTime: Fixed(10 * time.Millisecond),
+			Workers:     10,
+			Duration:    5 * time.Second,
+			Interval:    time.Second,
+			Seed:        7,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	// By Little's Law, 800 requests per second of 10ms keep 8 in flight
+	// on average, which the estimator takes over as the limit after its
+	// first window.
+	exact := run(1)
+	if iv := exact.Intervals[0]; iv.Shed != 0 || iv.HardLimit != 500 {
+		t.Errorf("expected the configured limit during the first window, got %+v", iv)
+	}
+	if limit := exact.Intervals[1].HardLimit; limit < 7 || limit > 11 {
+		t.Errorf("expected a limit of about 8, got %d", limit)
+	}
+	// Bursts of Poisson arrivals exceed the mean, so a limit without
+	// headroom sheds requests the workers had capacity for.
+	if exact.ShedRate < 0.05 {
+		t.Errorf("expected sheds without headroom, got %v", exact)
+	}
+	if roomy := run(2); roomy.ShedRate > exact.ShedRate/10 {
+		t.Errorf("expected headroom to absorb the bursts, got %v and %v", roomy, exact)
+	}
+}
+
+func TestRun_Invalid(t *testing.T) {
+	_, err := Run(Scenario{Config: shedder.Config{HardLimit: 10, Queue: &shedder.QueueConfig{Timeout: time.Second}}})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"Arrivals", "ServiceTime", "Duration", "Queue"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %s in %q", want, err)
+		}
+	}
+}
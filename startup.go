@@ -0,0 +1,120 @@
+package shedder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// startup tracks the warmup functions gating StartupHandler.
+type startup struct {
+	mu      sync.Mutex
+	warmups map[string]func(ctx context.Context) error
+	done    map[string]bool
+
+	started atomic.Bool
+}
+
+// AddWarmup registers a function that must succeed before the pod takes
+// traffic, such as priming caches or filling connection pools. Warmups are
+// run by RunWarmups. Registering a name again replaces its function.
+func (s *Shedder) AddWarmup(name string, fn func(ctx context.Context) error) {
+	s.startup.mu.Lock()
+	defer s.startup.mu.Unlock()
+	if s.startup.warmups == nil {
+		s.startup.warmups = make(map[string]func(ctx context.Context) error)
+		s.startup.done = make(map[string]bool)
+	}
+	s.startup.warmups[name] = fn
+	delete(s.startup.done, name)
+}
+
+// RunWarmups runs the registered warmups that have not yet succeeded,
+// concurrently, and returns their errors joined. Once all have succeeded
+// the shedder is started: StartupHandler returns 200 and the Warmup ramp,
+// if configured, restarts so the limit ramps up as traffic arrives rather
+// than from when the shedder was created. A failed RunWarmups can be
+// retried.
+func (s *Shedder) RunWarmups(ctx context.Context) error {
+	su := &s.startup
+	su.mu.Lock()
+	pending := make(map[string]func(ctx context.Context) error)
+	for name, fn := range su.warmups {
+		if !su.done[name] {
+			pending[name] = fn
+		}
+	}
+	su.mu.Unlock()
+
+	var wg sync.WaitGroup
+	var errsMu sync.Mutex
+	var errs []error
+	for name, fn := range pending {
+		wg.Add(1)
+		go func(name string, fn func(ctx context.Context) error) {
+			defer wg.Done()
+			if err := fn(ctx); err != nil {
+				errsMu.Lock()
+				errs = append(errs, fmt.Errorf("shedder: warmup %q: %w", name, err))
+				errsMu.Unlock()
+				return
+			}
+			su.mu.Lock()
+			su.done[name] = true
+			su.mu.Unlock()
+		}(name, fn)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	if !su.started.Swap(true) && s.warmup != nil {
+		s.warmup.start.Store(s.now().UnixNano())
+	}
+	return nil
+}
+
+// IsStarted reports whether RunWarmups has succeeded.
+func (s *Shedder) IsStarted() bool {
+	return s.startup.started.Load()
+}
+
+// pendingWarmups returns the names of the warmups that have not yet
+// succeeded, sorted.
+func (su *startup) pendingWarmups() []string {
+	su.mu.Lock()
+	defer su.mu.Unlock()
+	var pending []string
+	for name := range su.warmups {
+		if !su.done[name] {
+			pending = append(pending, name)
+		}
+	}
+	sort.Strings(pending)
+	return pending
+}
+
+// StartupHandler returns an http.Handler that implements a Kubernetes
+// startup probe endpoint.
+//
+// Returns:
+//   - 200 OK once RunWarmups has succeeded
+//   - 503 Service Unavailable before, listing the pending warmups
+func (s *Shedder) StartupHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if !s.IsStarted() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, "starting: pending=[%s]", strings.Join(s.startup.pendingWarmups(), ", "))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "started")
+	})
+}
@@ -0,0 +1,71 @@
+package shedder
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStartupHandler(t *testing.T) {
+	s := New(Config{HardLimit: 10})
+	fail := true
+	var cacheRuns int
+	s.AddWarmup("cache", func(ctx context.Context) error {
+		cacheRuns++
+		return nil
+	})
+	s.AddWarmup("db", func(ctx context.Context) error {
+		if fail {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	probe := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.StartupHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/startup", nil))
+		return rec
+	}
+
+	if rec := probe(); rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "cache, db") {
+		t.Errorf("expected 503 listing pending warmups, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	err := s.RunWarmups(context.Background())
+	if err == nil || !strings.Contains(err.Error(), `warmup "db": connection refused`) {
+		t.Errorf("expected db warmup error, got %v", err)
+	}
+	if rec := probe(); rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "[db]") {
+		t.Errorf("expected 503 pending db only, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	fail = false
+	if err := s.RunWarmups(context.Background()); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if rec := probe(); rec.Code != http.StatusOK || !s.IsStarted() {
+		t.Errorf("expected 200 once warmups succeeded, got %d", rec.Code)
+	}
+	if cacheRuns != 1 {
+		t.Errorf("expected succeeded warmups not to rerun, ran %d times", cacheRuns)
+	}
+}
+
+func TestRunWarmups_RestartsRamp(t *testing.T) {
+	clock := newFakeClock()
+	s := New(Config{HardLimit: 100, Clock: clock, Warmup: &WarmupConfig{Duration: time.Minute, StartFraction: 0.5}})
+	clock.Advance(time.Hour)
+	if s.IsWarmingUp() {
+		t.Fatal("expected ramp to be over")
+	}
+
+	if err := s.RunWarmups(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !s.IsWarmingUp() || s.HardLimit() > 60 {
+		t.Errorf("expected the ramp to restart once started, limit %d", s.HardLimit())
+	}
+}
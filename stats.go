@@ -0,0 +1,159 @@
+package shedder
+
+import "math"
+
+// Stats is a point-in-time snapshot of a Shedder's state.
+type Stats struct {
+	// Inflight is the current number of in-flight requests.
+	Inflight int64 `json:"inflight"`
+
+	// HardLimit is the hard limit currently in effect.
+	HardLimit int64 `json:"hard_limit"`
+
+	// SoftLimit is the soft limit currently in effect (0 if disabled).
+	SoftLimit int64 `json:"soft_limit"`
+
+	// Overloaded and SoftOverloaded mirror IsOverloaded and IsSoftOverloaded.
+	Overloaded     bool `json:"overloaded"`
+	SoftOverloaded bool `json:"soft_overloaded"`
+
+	// Draining reports whether the shedder is draining for shutdown.
+	Draining bool `json:"draining,omitempty"`
+
+	// ReadyPeers is the number of other ready endpoints of the pod's
+	// Service, and LastStanding whether the pod is one of its last ready
+	// pods, when WatchPeers is running.
+	ReadyPeers   int  `json:"ready_peers,omitempty"`
+	LastStanding bool `json:"last_standing,omitempty"`
+
+	// GossipPeers is the number of peers Gossip heard from recently, and
+	// FleetUtilization the mean utilization of the pod and those peers.
+	GossipPeers      int     `json:"gossip_peers,omitempty"`
+	FleetUtilization float64 `json:"fleet_utilization,omitempty"`
+
+	// NodePressure lists the pressure conditions of the pod's node, when
+	// WatchNodePressure is running.
+	NodePressure []string `json:"node_pressure,omitempty"`
+
+	// Degradation is the current degradation level when Brownout is
+	// configured.
+	Degradation string `json:"degradation,omitempty"`
+
+	// Admitted and Shed count the requests admitted and shed by the
+	// middleware since the shedder was created.
+	Admitted int64 `json:"admitted"`
+	Shed     int64 `json:"shed"`
+
+	// DryRunShed counts the requests that would have been shed but were
+	// served because DryRun is enabled. They are included in Admitted.
+	DryRunShed int64 `json:"dry_run_shed"`
+
+	// Preempted counts admitted requests canceled to make room for
+	// higher-priority ones.
+	Preempted int64 `json:"preempted,omitempty"`
+
+	// Evicted counts requests canceled for exceeding Eviction.MaxAge.
+	Evicted int64 `json:"evicted,omitempty"`
+
+	// SharedBudgetErrors counts the errors returned by the SharedBudget's
+	// store.
+	SharedBudgetErrors int64 `json:"shared_budget_errors,omitempty"`
+
+	// Queued is the number of requests currently waiting for a slot.
+	Queued int64 `json:"queued"`
+
+	// PeakInflight is the maximum in-flight count since the shedder was
+	// created or ResetPeaks was called.
+	PeakInflight int64 `json:"peak_inflight"`
+
+	// RecentPeakInflight is an exponentially decaying peak that halves
+	// every Config.PeakHalfLife unless a higher in-flight count is seen.
+	RecentPeakInflight float64 `json:"recent_peak_inflight"`
+
+	// RecommendedLimit is the concurrency recommended by the configured
+	// CapacityEstimator, or 0 if none is configured or no estimate exists yet.
+	RecommendedLimit int64 `json:"recommended_limit,omitempty"`
+
+	// Routes describes per-route capacity when RouteCapacity is configured.
+	Routes []RouteStats `json:"routes,omitempty"`
+
+	// Clients is the number of tracked client IPs when ClientIP is
+	// configured.
+	Clients int `json:"clients,omitempty"`
+
+	// Tenants is the number of tenants with requests in flight when Tenant
+	// is configured.
+	Tenants int `json:"tenants,omitempty"`
+
+	// ClassQuotas describes each class quota when ClassQuotas is
+	// configured.
+	ClassQuotas []ClassQuotaStats `json:"class_quotas,omitempty"`
+
+	// Ceilings describes each fixed ceiling when RouteCeilings, Routes,
+	// MethodLimits or Hosts are configured.
+	Ceilings []CeilingStats `json:"ceilings,omitempty"`
+
+	// PriorityLevels describes each level when PriorityLevels are configured.
+	PriorityLevels []PriorityLevelStats `json:"priority_levels,omitempty"`
+}
+
+// Stats returns a snapshot of the shedder's current state.
+func (s *Shedder) Stats() Stats {
+	st := Stats{
+		Inflight:           s.Inflight(),
+		HardLimit:          s.currentLimit(),
+		SoftLimit:          s.currentSoftLimit(),
+		Overloaded:         s.IsOverloaded(),
+		SoftOverloaded:     s.IsSoftOverloaded(),
+		Draining:           s.IsDraining(),
+		Admitted:           s.admitted.Load(),
+		Shed:               s.shedCount.Load(),
+		DryRunShed:         s.dryRunShed.Load(),
+		Preempted:          s.preempted.Load(),
+		Evicted:            s.evicted.Load(),
+		PeakInflight:       s.peaks.max.Load(),
+		RecentPeakInflight: s.peaks.decayed(s.now()),
+	}
+	if s.brownout != nil {
+		st.Degradation = s.Degradation().String()
+	}
+	if p := s.peers.Load(); p != nil {
+		st.ReadyPeers, st.LastStanding = int(p.ready.Load()), p.lastStanding.Load()
+	}
+	if g := s.gossip.Load(); g != nil {
+		st.GossipPeers, st.FleetUtilization = int(g.peers.Load()), math.Float64frombits(g.utilization.Load())
+	}
+	if np := s.nodePressure.Load(); np != nil {
+		if p := np.pressures.Load(); p != nil && len(*p) > 0 {
+			st.NodePressure = *p
+		}
+	}
+	if s.shared != nil {
+		st.SharedBudgetErrors = s.shared.errors.Load()
+	}
+	if s.queue != nil {
+		st.Queued = s.queue.length.Load()
+	}
+	if s.estimator != nil {
+		st.RecommendedLimit = s.estimator.Recommendation()
+	}
+	if s.routes != nil {
+		st.Routes = s.routes.stats()
+	}
+	if s.clients != nil {
+		st.Clients = s.clients.tracked()
+	}
+	if s.tenants != nil {
+		st.Tenants = s.tenants.active()
+	}
+	if quotas := s.config.Load().quotas; quotas != nil {
+		st.ClassQuotas = quotas.stats(st.HardLimit)
+	}
+	for _, cs := range s.ceilings {
+		st.Ceilings = append(st.Ceilings, cs.stats()...)
+	}
+	if s.priority != nil {
+		st.PriorityLevels = s.priority.stats()
+	}
+	return st
+}
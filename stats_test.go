@@ -0,0 +1,72 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestShedder_Stats(t *testing.T) {
+	s := New(Config{HardLimit: 1, SoftLimit: 1})
+
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	s.increment(1)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	st := s.Stats()
+	if st.Inflight != 1 || st.HardLimit != 1 || st.SoftLimit != 1 {
+		t.Errorf("unexpected limits in stats: %+v", st)
+	}
+	if st.Admitted != 1 || st.Shed != 1 {
+		t.Errorf("expected 1 admitted and 1 shed, got %+v", st)
+	}
+	if st.Overloaded || st.SoftOverloaded {
+		t.Errorf("expected no overload at the limit, got %+v", st)
+	}
+	if st.RecommendedLimit != 0 {
+		t.Errorf("expected no recommendation without estimator, got %d", st.RecommendedLimit)
+	}
+}
+
+func TestShedder_PeakStatsAndReset(t *testing.T) {
+	s := New(Config{HardLimit: 10})
+	block := make(chan struct{})
+	started := make(chan struct{}, 3)
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-block
+	}))
+
+	done := make(chan struct{})
+	for i := 0; i < 3; i++ {
+		go func() {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			done <- struct{}{}
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		<-started
+	}
+	close(block)
+	for i := 0; i < 3; i++ {
+		<-done
+	}
+
+	st := s.Stats()
+	if st.PeakInflight != 3 {
+		t.Errorf("expected peak 3, got %d", st.PeakInflight)
+	}
+	if st.RecentPeakInflight <= 2.9 || st.RecentPeakInflight > 3 {
+		t.Errorf("expected recent peak close to 3, got %v", st.RecentPeakInflight)
+	}
+
+	s.ResetPeaks()
+	st = s.Stats()
+	if st.PeakInflight != 0 || st.RecentPeakInflight != 0 {
+		t.Errorf("expected peaks cleared, got %d / %v", st.PeakInflight, st.RecentPeakInflight)
+	}
+}
@@ -0,0 +1,108 @@
+package shedder
+
+import (
+	"sync"
+	"time"
+)
+
+// SurgeConfig configures rate-of-change overload detection.
+type SurgeConfig struct {
+	// MaxRisePerSecond is the growth rate of in-flight requests, per
+	// second over Window, above which the shedder enters soft overload.
+	// Required.
+	MaxRisePerSecond float64
+
+	// Window is the period over which the rise is measured.
+	// Defaults to one second.
+	Window time.Duration
+
+	// MinInflight is the in-flight count below which surges are ignored,
+	// so that ramps from idle do not trigger shedding. Defaults to 0.
+	MinInflight int64
+}
+
+// surgeSamples is the number of in-flight samples kept per window.
+const surgeSamples = 10
+
+// surgeDetector samples the in-flight count and reports soft overload when
+// it rises faster than the configured rate, so shedding can start during a
+// flash crowd before the absolute limits are reached.
+type surgeDetector struct {
+	maxRise     float64
+	minInflight int64
+	step        time.Duration
+	inflight    func() int64
+	clock       Clock
+
+	mu      sync.Mutex
+	samples [surgeSamples + 1]surgeSample
+	next    int
+	filled  int
+}
+
+type surgeSample struct {
+	at       time.Time
+	inflight int64
+}
+
+// newSurgeDetector returns a detector for cfg reading the in-flight count
+// from inflight and the time from clock.
+func newSurgeDetector(cfg SurgeConfig, inflight func() int64, clock Clock) *surgeDetector {
+	if cfg.Window <= 0 {
+		cfg.Window = time.Second
+	}
+	return &surgeDetector{
+		maxRise:     cfg.MaxRisePerSecond,
+		minInflight: cfg.MinInflight,
+		step:        cfg.Window / surgeSamples,
+		inflight:    inflight,
+		clock:       clock,
+	}
+}
+
+// record stores an in-flight sample if one is due. It never blocks.
+func (d *surgeDetector) record(now time.Time, inflight int64) {
+	if !d.mu.TryLock() {
+		return
+	}
+	defer d.mu.Unlock()
+	if d.filled > 0 {
+		last := d.samples[(d.next+len(d.samples)-1)%len(d.samples)]
+		if now.Sub(last.at) < d.step {
+			return
+		}
+	}
+	d.samples[d.next] = surgeSample{at: now, inflight: inflight}
+	d.next = (d.next + 1) % len(d.samples)
+	if d.filled < len(d.samples) {
+		d.filled++
+	}
+}
+
+// rise returns the in-flight growth per second between the oldest sample
+// and the in-flight count at now.
+func (d *surgeDetector) rise(now time.Time, inflight int64) float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.filled == 0 {
+		return 0
+	}
+	oldest := d.samples[0]
+	if d.filled == len(d.samples) {
+		oldest = d.samples[d.next]
+	}
+	elapsed := now.Sub(oldest.at).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(inflight-oldest.inflight) / elapsed
+}
+
+// Overloaded implements OverloadSignal.
+func (d *surgeDetector) Overloaded() bool {
+	inflight := d.inflight()
+	if inflight < d.minInflight {
+		return false
+	}
+	return d.rise(d.clock.Now(), inflight) > d.maxRise
+}
@@ -0,0 +1,59 @@
+package shedder
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSurgeDetector_Rise(t *testing.T) {
+	var inflight int64
+	d := newSurgeDetector(SurgeConfig{MaxRisePerSecond: 50, Window: time.Second}, func() int64 { return inflight }, newFakeClock())
+	start := time.Unix(1000, 0)
+
+	d.record(start, 10)
+	d.record(start.Add(10*time.Millisecond), 50) // too soon, ignored
+
+	if got := d.rise(start.Add(500*time.Millisecond), 40); got != 60 {
+		t.Errorf("expected rise of 60/s, got %v", got)
+	}
+
+	// Samples roll: the oldest retained sample is one window old.
+	for i := 1; i <= surgeSamples*2; i++ {
+		d.record(start.Add(time.Duration(i)*100*time.Millisecond), int64(10+i))
+	}
+	now := start.Add(surgeSamples * 2 * 100 * time.Millisecond)
+	if got := d.rise(now, 30); got != 10 {
+		t.Errorf("expected rise of 10/s over the last window, got %v", got)
+	}
+}
+
+func TestSurgeDetector_Overloaded(t *testing.T) {
+	inflight := int64(5)
+	clock := newFakeClock()
+	d := newSurgeDetector(SurgeConfig{MaxRisePerSecond: 10, MinInflight: 20}, func() int64 { return inflight }, clock)
+	d.record(clock.Now(), 0)
+	clock.Advance(500 * time.Millisecond)
+
+	if d.Overloaded() {
+		t.Error("should ignore surges below MinInflight")
+	}
+
+	inflight = 100
+	if !d.Overloaded() {
+		t.Error("expected overload for a rise of 200/s")
+	}
+}
+
+func TestShedder_SurgeEntersSoftOverload(t *testing.T) {
+	clock := newFakeClock()
+	s := New(Config{HardLimit: 100, Clock: clock, Surge: &SurgeConfig{MaxRisePerSecond: 10}})
+	s.surge.record(clock.Now(), 0)
+	clock.Advance(100 * time.Millisecond)
+
+	for i := 0; i < 50; i++ {
+		s.increment(1)
+	}
+	if !s.IsSoftOverloaded() {
+		t.Error("expected soft overload during a surge")
+	}
+}
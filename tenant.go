@@ -0,0 +1,96 @@
+package shedder
+
+import (
+	"net/http"
+	"sync"
+)
+
+// TenantConfig configures per-tenant fairness caps.
+type TenantConfig struct {
+	// Key returns the tenant of a request, e.g. from TenantHeader or
+	// TenantClaim. Requests with an empty key, e.g. without a verifiable
+	// token, share one capped anonymous tenant. Required.
+	Key func(r *http.Request) string
+
+	// MaxShare is the fraction (0-1] of the hard limit, in in-flight cost
+	// units, a single tenant may hold. Defaults to 0.5.
+	MaxShare float64
+}
+
+// TenantHeader returns a tenant key reading the named request header.
+func TenantHeader(name string) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// TenantClaim returns a tenant key reading the named claim of the bearer
+// JWT in the Authorization header, as verified by parse. A tenant that
+// could forge the claim could spread its requests over made-up tenants
+// and escape its cap, so parse must verify the token unless a gateway
+// already has; requests whose token fails verification get an empty key
+// and share the anonymous tenant's cap. TenantClaim panics if parse is
+// nil.
+func TenantClaim(parse ClaimsParser, claim string) func(r *http.Request) string {
+	mustParser("TenantClaim", parse)
+	return func(r *http.Request) string {
+		claims, _ := bearerClaims(r, parse)
+		return claimString(claims, claim)
+	}
+}
+
+// anonymousTenant is the tenant of requests with an empty key. The NUL
+// byte keeps it apart from any key read from a header or claim.
+const anonymousTenant = "\x00anonymous"
+
+// tenantCaps counts in-flight cost units per tenant. Entries are removed
+// when a tenant has nothing in flight, so memory is bounded by in-flight.
+type tenantCaps struct {
+	key      func(r *http.Request) string
+	maxShare float64
+
+	mu       sync.Mutex
+	inflight map[string]int64
+}
+
+// newTenantCaps returns caps for cfg with defaults applied.
+func newTenantCaps(cfg TenantConfig) *tenantCaps {
+	if cfg.MaxShare <= 0 || cfg.MaxShare > 1 {
+		cfg.MaxShare = 0.5
+	}
+	return &tenantCaps{key: cfg.Key, maxShare: cfg.MaxShare, inflight: make(map[string]int64)}
+}
+
+// acquire charges cost units of r to its tenant and reports whether the
+// tenant is over its share of limit. The caller must release the tenant.
+func (tc *tenantCaps) acquire(r *http.Request, cost, limit int64) (string, bool) {
+	tenant := tc.key(r)
+	if tenant == "" {
+		tenant = anonymousTenant
+	}
+	max := int64(tc.maxShare * float64(limit))
+	if max < 1 {
+		max = 1
+	}
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.inflight[tenant] += cost
+	return tenant, tc.inflight[tenant] > max
+}
+
+// release returns cost units of tenant.
+func (tc *tenantCaps) release(tenant string, cost int64) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tc.inflight[tenant] -= cost; tc.inflight[tenant] <= 0 {
+		delete(tc.inflight, tenant)
+	}
+}
+
+// active returns the number of tenants with requests in flight.
+func (tc *tenantCaps) active() int {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	return len(tc.inflight)
+}
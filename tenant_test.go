@@ -0,0 +1,142 @@
+package shedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestTenantClaim(t *testing.T) {
+	key := TenantClaim(UnverifiedClaims, "org")
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer "+testJWT(`{"org":"acme"}`))
+	if got := key(r); got != "acme" {
+		t.Errorf("expected acme, got %q", got)
+	}
+	if got := key(httptest.NewRequest("GET", "/", nil)); got != "" {
+		t.Errorf("expected empty tenant without a token, got %q", got)
+	}
+	if got := TenantClaim(rejectAll, "org")(r); got != "" {
+		t.Errorf("expected empty tenant for an unverifiable token, got %q", got)
+	}
+}
+
+func TestTenantCaps_AcquireRelease(t *testing.T) {
+	tc := newTenantCaps(TenantConfig{Key: TenantHeader("X-Tenant"), MaxShare: 0.2})
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Tenant", "a")
+
+	for i := 0; i < 2; i++ {
+		if _, over := tc.acquire(r, 1, 10); over {
+			t.Fatalf("request %d: expected under the cap of 2", i+1)
+		}
+	}
+	if _, over := tc.acquire(r, 1, 10); !over {
+		t.Error("expected third request to exceed the cap of 2")
+	}
+	for i := 0; i < 3; i++ {
+		tc.release("a", 1)
+	}
+	if tc.active() != 0 {
+		t.Errorf("expected idle tenants to be forgotten, got %d active", tc.active())
+	}
+
+	// Costs count against the cap.
+	if _, over := tc.acquire(r, 3, 10); !over {
+		t.Error("expected a 3-unit request to exceed the cap of 2")
+	}
+	tc.release("a", 3)
+
+	// Requests without a tenant share one capped tenant.
+	untenanted := httptest.NewRequest("GET", "/", nil)
+	for i := 0; i < 2; i++ {
+		if tenant, over := tc.acquire(untenanted, 1, 10); tenant != anonymousTenant || over {
+			t.Fatalf("request %d: expected the anonymous tenant under its cap, got %q, %v", i+1, tenant, over)
+		}
+	}
+	if _, over := tc.acquire(untenanted, 1, 10); !over {
+		t.Error("expected untenanted requests to be capped together")
+	}
+}
+
+func TestMiddleware_TenantLimitInvalidToken(t *testing.T) {
+	s := New(Config{HardLimit: 10, Tenant: &TenantConfig{Key: TenantClaim(rejectAll, "org"), MaxShare: 0.1}})
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			started <- struct{}{}
+			<-release
+		}
+	}))
+	request := func(path, org string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+testJWT(`{"org":"`+org+`"}`))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		request("/slow", "forged-1")
+	}()
+	<-started
+
+	// Forged tokens naming another tenant still share one cap.
+	if rec := request("/", "forged-2"); rec.Header().Get("X-Shed-Reason") != "tenant_limit" {
+		t.Errorf("expected a request with an invalid token capped, got %d", rec.Code)
+	}
+
+	close(release)
+	wg.Wait()
+}
+
+func TestMiddleware_TenantLimit(t *testing.T) {
+	s := New(Config{HardLimit: 10, Tenant: &TenantConfig{Key: TenantHeader("X-Tenant"), MaxShare: 0.1}})
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			started <- struct{}{}
+			<-release
+		}
+	}))
+	request := func(path, tenant string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-Tenant", tenant)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		request("/slow", "noisy")
+	}()
+	<-started
+
+	rec := request("/", "noisy")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 for the noisy tenant, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("X-Shed-Reason"); got != "tenant_limit" {
+		t.Errorf("expected X-Shed-Reason tenant_limit, got %q", got)
+	}
+	if rec := request("/", "quiet"); rec.Code != http.StatusOK {
+		t.Errorf("expected 200 for another tenant, got %d", rec.Code)
+	}
+
+	close(release)
+	wg.Wait()
+	if got := s.Stats().Tenants; got != 0 {
+		t.Errorf("expected no active tenants, got %d", got)
+	}
+}
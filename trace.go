@@ -0,0 +1,36 @@
+package shedder
+
+import (
+	"encoding/hex"
+	"net/http"
+)
+
+// traceSampled parses a W3C traceparent header and reports whether the
+// trace is sampled. ok is false if the header is missing or invalid.
+func traceSampled(r *http.Request) (sampled, ok bool) {
+	// version "-" trace-id "-" parent-id "-" trace-flags
+	tp := r.Header.Get("Traceparent")
+	if len(tp) < 55 || tp[2] != '-' || tp[35] != '-' || tp[52] != '-' {
+		return false, false
+	}
+	version, err := hex.DecodeString(tp[0:2])
+	if err != nil || version[0] == 0xff || (version[0] == 0 && len(tp) != 55) {
+		return false, false
+	}
+	flags, err := hex.DecodeString(tp[53:55])
+	if err != nil {
+		return false, false
+	}
+	return flags[0]&0x01 != 0, true
+}
+
+// ShedUnsampled returns a ShedDecider that sheds requests whose W3C
+// traceparent marks the trace as unsampled, keeping sampled, observable
+// requests alive for debugging during incidents. Requests without a valid
+// traceparent are not shed.
+func ShedUnsampled() ShedDecider {
+	return func(r *http.Request) bool {
+		sampled, ok := traceSampled(r)
+		return ok && !sampled
+	}
+}
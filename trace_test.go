@@ -0,0 +1,36 @@
+package shedder
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestShedUnsampled(t *testing.T) {
+	decide := ShedUnsampled()
+	tests := []struct {
+		name        string
+		traceparent string
+		want        bool
+	}{
+		{"sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"unsampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true},
+		{"other flags unsampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-02", true},
+		{"future version with suffix", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra", true},
+		{"version 00 with suffix", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra", false},
+		{"invalid version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", false},
+		{"bad flags", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-zz", false},
+		{"truncated", "00-4bf92f3577b34da6a3ce929d0e0e4736", false},
+		{"missing", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.traceparent != "" {
+				r.Header.Set("traceparent", tt.traceparent)
+			}
+			if got := decide(r); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package shedder
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// ServeUnix serves the shedder's state on a Unix domain socket at path
+// until ctx is done, so other containers of the pod can read it from a
+// shared emptyDir volume without the application exposing another TCP
+// port, e.g. a metrics exporter or a non-HTTP main container's probes
+// run with kube-shedder-probe. It serves:
+//
+//   - /health: HealthHandler
+//   - /ready: ReadyHandler
+//   - /startup: StartupHandler
+//   - /status: StatusHandler
+//
+// A stale socket left at path by a previous container is replaced. The
+// socket is made accessible to every user, since the containers may run
+// as different users; the volume limits it to the pod. It returns an
+// error, without serving, if the socket cannot be created.
+func (s *Shedder) ServeUnix(ctx context.Context, path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0o666); err != nil {
+		ln.Close()
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/health", HealthHandler())
+	mux.Handle("/ready", s.ReadyHandler())
+	mux.Handle("/startup", s.StartupHandler())
+	mux.Handle("/status", s.StatusHandler())
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	go srv.Serve(ln)
+	go func() {
+		<-ctx.Done()
+		srv.Close() // also removes the socket
+	}()
+	return nil
+}
@@ -0,0 +1,66 @@
+package shedder
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestShedder_ServeUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shedder.sock")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(Config{HardLimit: 2})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.ServeUnix(ctx, path); err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}}
+	get := func(p string) *http.Response {
+		t.Helper()
+		resp, err := client.Get("http://shedder" + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	if code := get("/ready").StatusCode; code != http.StatusOK {
+		t.Errorf("expected ready, got %d", code)
+	}
+	s.increment(3)
+	if code := get("/ready").StatusCode; code != http.StatusServiceUnavailable {
+		t.Errorf("expected unready over the hard limit, got %d", code)
+	}
+	var st Stats
+	if err := json.NewDecoder(get("/status").Body).Decode(&st); err != nil || st.Inflight != 3 {
+		t.Errorf("expected Stats with 3 in flight, got %+v, %v", st, err)
+	}
+
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the socket removed when ctx is done")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,53 @@
+package shedder
+
+import (
+	"net/http"
+	"strings"
+)
+
+// DefaultBotPatterns match the User-Agents of common crawlers, scrapers,
+// HTTP libraries and synthetic monitors.
+var DefaultBotPatterns = []string{
+	"bot", "crawler", "spider", "slurp", "scraper",
+	"curl/", "wget/", "python-requests", "go-http-client", "headlesschrome",
+	"pingdom", "uptimerobot", "statuscake", "site24x7", "datadog", "newrelicpinger", "synthetic",
+}
+
+// MatchUserAgent returns a matcher for requests whose User-Agent contains
+// any of patterns, ignoring case; the pattern "" matches requests without
+// a User-Agent. With no patterns, DefaultBotPatterns is used. It can be
+// used as a PriorityRule.Match.
+func MatchUserAgent(patterns ...string) func(r *http.Request) bool {
+	if len(patterns) == 0 {
+		patterns = DefaultBotPatterns
+	}
+	lower := make([]string, 0, len(patterns))
+	var empty bool
+	for _, p := range patterns {
+		if p == "" {
+			empty = true
+			continue
+		}
+		lower = append(lower, strings.ToLower(p))
+	}
+	return func(r *http.Request) bool {
+		ua := r.UserAgent()
+		if ua == "" {
+			return empty
+		}
+		ua = strings.ToLower(ua)
+		for _, p := range lower {
+			if strings.Contains(ua, p) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ShedUserAgents returns a ShedDecider that sheds bots, crawlers and
+// synthetic monitors matched by MatchUserAgent(patterns...), so real users
+// survive overload at the expense of background scraping.
+func ShedUserAgents(patterns ...string) ShedDecider {
+	return ShedDecider(MatchUserAgent(patterns...))
+}
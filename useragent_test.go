@@ -0,0 +1,43 @@
+package shedder
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestShedUserAgents_Defaults(t *testing.T) {
+	decide := ShedUserAgents()
+	tests := []struct {
+		ua   string
+		want bool
+	}{
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", true},
+		{"Pingdom.com_bot_version_1.4", true},
+		{"curl/8.4.0", true},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) AppleWebKit/605.1.15 Safari/605.1.15", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("User-Agent", tt.ua)
+		if got := decide(r); got != tt.want {
+			t.Errorf("%q: expected %v, got %v", tt.ua, tt.want, got)
+		}
+	}
+}
+
+func TestMatchUserAgent_Custom(t *testing.T) {
+	match := MatchUserAgent("Internal-Scraper", "")
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("User-Agent", "internal-scraper/1.0")
+	if !match(r) {
+		t.Error("expected case-insensitive match")
+	}
+	r.Header.Set("User-Agent", "Googlebot")
+	if match(r) {
+		t.Error("expected custom patterns to replace the defaults")
+	}
+	if !match(httptest.NewRequest("GET", "/", nil)) {
+		t.Error(`expected "" to match a missing User-Agent`)
+	}
+}
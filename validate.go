@@ -0,0 +1,192 @@
+package shedder
+
+import (
+	"errors"
+	"fmt"
+	"path"
+)
+
+// Validate reports invalid or ineffective settings in cfg, such as a soft
+// limit at or above the hard limit or a shed decider without any soft
+// limit. All problems found are joined into one error.
+//
+// New ignores most of these (it only panics on an invalid HardLimit);
+// Validate lets configuration loaded from files or the environment be
+// rejected with a descriptive error instead.
+func (cfg Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("shedder: "+format, args...))
+	}
+
+	if cfg.HardLimit <= 0 && cfg.HardLimit != Auto {
+		add("HardLimit must be > 0 or Auto, got %d", cfg.HardLimit)
+	}
+	if cfg.SoftLimit < 0 {
+		add("SoftLimit must not be negative, got %d", cfg.SoftLimit)
+	}
+	if cfg.SoftLimit > 0 && cfg.HardLimit > 0 && cfg.SoftLimit >= cfg.HardLimit {
+		add("SoftLimit (%d) must be below HardLimit (%d)", cfg.SoftLimit, cfg.HardLimit)
+	}
+	if sl, ok := cfg.LimitAlgorithm.(StaticLimit); ok && int64(sl) != cfg.HardLimit {
+		add("LimitAlgorithm StaticLimit(%d) is ignored; HardLimit is the static limit", int64(sl))
+	}
+	if cfg.SoftLimitRatio != 0 && (cfg.SoftLimitRatio <= 0 || cfg.SoftLimitRatio >= 1) {
+		add("SoftLimitRatio must be in (0, 1), got %v", cfg.SoftLimitRatio)
+	}
+	if cfg.FastPathBelow != 0 && (cfg.FastPathBelow <= 0 || cfg.FastPathBelow > 1) {
+		add("FastPathBelow must be in (0, 1], got %v", cfg.FastPathBelow)
+	}
+	if cfg.RetryAfterJitter < 0 {
+		add("RetryAfterJitter must not be negative, got %v", cfg.RetryAfterJitter)
+	}
+	if cfg.CoarseClock != 0 && cfg.CoarseClock < minCoarseClock {
+		add("CoarseClock must be at least %v, got %v", minCoarseClock, cfg.CoarseClock)
+	}
+
+	soft := cfg.SoftLimit > 0 || cfg.SoftLimitRatio > 0 || cfg.SoftOverloadSignal != nil ||
+		cfg.ErrorRate != nil || cfg.Surge != nil || cfg.EnvoyOverloaded || hasSoftLimit(cfg)
+	if !soft {
+		if cfg.ShedDecider != nil || matcherDecider(cfg) != nil {
+			add("ShedDecider or shed matchers are set but no SoftLimit, SoftLimitRatio or soft overload signal enables them")
+		}
+		if cfg.Retry != nil {
+			add("Retry is set but no SoftLimit, SoftLimitRatio or soft overload signal enables it")
+		}
+	}
+	if cfg.ShedDecider != nil && matcherDecider(cfg) != nil {
+		add("ShedDecider and shed matchers are both set; the matchers are ignored")
+	}
+	if cfg.ShedHeader != nil && cfg.ShedHeader.Name == "" {
+		add("ShedHeader.Name is empty")
+	}
+	for i, m := range cfg.ShedHeaders {
+		if m.Name == "" {
+			add("ShedHeaders[%d].Name is empty", i)
+		}
+	}
+	for i, m := range cfg.ShedQuery {
+		if m.Name == "" {
+			add("ShedQuery[%d].Name is empty", i)
+		}
+	}
+
+	if cfg.Queue != nil && cfg.Queue.Timeout <= 0 {
+		add("Queue.Timeout must be > 0")
+	}
+	if cfg.Queue != nil && cfg.Queue.Weights != nil && cfg.Classify == nil {
+		add("Queue.Weights needs Classify")
+	}
+	if len(cfg.ClassQuotas) > 0 && cfg.Classify == nil {
+		add("ClassQuotas needs Classify")
+	}
+	for class, f := range cfg.ClassQuotas {
+		if f <= 0 || f > 1 {
+			add("ClassQuotas[%q] must be in (0, 1], got %v", class, f)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, pl := range cfg.PriorityLevels {
+		if seen[pl.Name] {
+			add("duplicate priority level %q", pl.Name)
+		}
+		seen[pl.Name] = true
+	}
+	if len(cfg.PriorityLevels) > 1 && cfg.Classify == nil {
+		add("PriorityLevels needs Classify")
+	}
+	for pattern, l := range cfg.Routes {
+		validateLimits(add, fmt.Sprintf("Routes[%q]", pattern), l)
+		if _, err := path.Match(pattern, ""); err != nil {
+			add("Routes[%q] is not a valid pattern: %v", pattern, err)
+		}
+	}
+	for host, l := range cfg.Hosts {
+		validateLimits(add, fmt.Sprintf("Hosts[%q]", host), l)
+	}
+	methods := make(map[string]bool)
+	for i, ml := range cfg.MethodLimits {
+		name := fmt.Sprintf("MethodLimits[%d]", i)
+		validateLimits(add, name, RouteLimits{HardLimit: ml.HardLimit, SoftLimit: ml.SoftLimit})
+		if len(ml.Methods) == 0 {
+			add("%s.Methods is empty", name)
+		}
+		for _, method := range ml.Methods {
+			if methods[method] {
+				add("method %q is in more than one MethodLimits class", method)
+			}
+			methods[method] = true
+		}
+	}
+	if rc := cfg.Readiness; rc != nil && rc.ReadyBelow > 0 && rc.UnreadyAbove > 0 && rc.ReadyBelow > rc.UnreadyAbove {
+		add("Readiness.ReadyBelow (%v) must not exceed Readiness.UnreadyAbove (%v)", rc.ReadyBelow, rc.UnreadyAbove)
+	}
+	if cfg.ShedBudget != nil && (cfg.ShedBudget.MaxFraction <= 0 || cfg.ShedBudget.MaxFraction > 1) {
+		add("ShedBudget.MaxFraction must be in (0, 1], got %v", cfg.ShedBudget.MaxFraction)
+	}
+
+	// Sub-configurations that are silently disabled without their
+	// required field.
+	required := []struct {
+		set, ok bool
+		field   string
+	}{
+		{cfg.Burst != nil, cfg.Burst != nil && cfg.Burst.Size > 0, "Burst.Size"},
+		{cfg.ClientIP != nil, cfg.ClientIP != nil && cfg.ClientIP.MaxInflight > 0, "ClientIP.MaxInflight"},
+		{cfg.Cooldown != nil, cfg.Cooldown != nil && cfg.Cooldown.Duration > 0, "Cooldown.Duration"},
+		{cfg.ErrorRate != nil, cfg.ErrorRate != nil && cfg.ErrorRate.Threshold > 0, "ErrorRate.Threshold"},
+		{cfg.Eviction != nil, cfg.Eviction != nil && cfg.Eviction.MaxAge > 0, "Eviction.MaxAge"},
+		{cfg.Preemption != nil, cfg.Preemption != nil && cfg.Preemption.Preemptible != nil, "Preemption.Preemptible"},
+		{cfg.RouteCapacity != nil, cfg.RouteCapacity != nil && cfg.RouteCapacity.Key != nil, "RouteCapacity.Key"},
+		{cfg.RouteCeilings != nil, cfg.RouteCeilings != nil && cfg.RouteCeilings.Key != nil, "RouteCeilings.Key"},
+		{cfg.SharedBudget != nil, cfg.SharedBudget != nil && cfg.SharedBudget.Store != nil, "SharedBudget.Store"},
+		{cfg.SharedBudget != nil, cfg.SharedBudget != nil && cfg.SharedBudget.Limit > 0, "SharedBudget.Limit"},
+		{cfg.Surge != nil, cfg.Surge != nil && cfg.Surge.MaxRisePerSecond > 0, "Surge.MaxRisePerSecond"},
+		{cfg.Tenant != nil, cfg.Tenant != nil && cfg.Tenant.Key != nil, "Tenant.Key"},
+		{cfg.Warmup != nil, cfg.Warmup != nil && cfg.Warmup.Duration > 0, "Warmup.Duration"},
+	}
+	for _, r := range required {
+		if r.set && !r.ok {
+			add("%s is required", r.field)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateLimits reports invalid limits l of the named entry.
+func validateLimits(add func(format string, args ...any), name string, l RouteLimits) {
+	if l.HardLimit < 0 || l.SoftLimit < 0 {
+		add("%s limits must not be negative", name)
+	}
+	if l.HardLimit > 0 && l.SoftLimit >= l.HardLimit {
+		add("%s SoftLimit (%d) must be below HardLimit (%d)", name, l.SoftLimit, l.HardLimit)
+	}
+}
+
+// hasSoftLimit reports whether any of cfg's routes, method classes or
+// hosts sets a soft limit.
+func hasSoftLimit(cfg Config) bool {
+	for _, limits := range []map[string]RouteLimits{cfg.Routes, cfg.Hosts} {
+		for _, l := range limits {
+			if l.SoftLimit > 0 {
+				return true
+			}
+		}
+	}
+	for _, ml := range cfg.MethodLimits {
+		if ml.SoftLimit > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// NewWithError is like New but validates cfg first and returns the
+// Validate error instead of panicking or silently ignoring bad settings.
+func NewWithError(cfg Config) (*Shedder, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return New(cfg), nil
+}
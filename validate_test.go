@@ -0,0 +1,82 @@
+package shedder
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	decider := func(r *http.Request) bool { return true }
+	classify := func(r *http.Request) string { return "" }
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{"valid", Config{HardLimit: 100, SoftLimit: 80, ShedDecider: decider}, ""},
+		{"auto", Config{HardLimit: Auto, SoftLimit: 80, ShedDecider: decider}, ""},
+		{"soft ratio", Config{HardLimit: 100, SoftLimitRatio: 0.8, ShedHeader: &HeaderMatcher{Name: "X-Priority", Value: "low"}}, ""},
+		{"zero hard limit", Config{}, "HardLimit must be > 0"},
+		{"negative soft limit", Config{HardLimit: 10, SoftLimit: -1}, "SoftLimit must not be negative"},
+		{"soft above hard", Config{HardLimit: 10, SoftLimit: 10}, "SoftLimit (10) must be below HardLimit (10)"},
+		{"static limit", Config{HardLimit: 10, LimitAlgorithm: StaticLimit(10)}, ""},
+		{"static limit mismatch", Config{HardLimit: 10, LimitAlgorithm: StaticLimit(5)}, "StaticLimit(5) is ignored"},
+		{"bad ratio", Config{HardLimit: 10, SoftLimitRatio: 1.5}, "SoftLimitRatio must be in (0, 1)"},
+		{"bad fast path", Config{HardLimit: 10, FastPathBelow: 1.5}, "FastPathBelow must be in (0, 1]"},
+		{"negative jitter", Config{HardLimit: 10, RetryAfterJitter: -time.Second}, "RetryAfterJitter must not be negative"},
+		{"fine coarse clock", Config{HardLimit: 10, CoarseClock: time.Microsecond}, "CoarseClock must be at least 100µs"},
+		{"decider without soft limit", Config{HardLimit: 10, ShedDecider: decider}, "no SoftLimit"},
+		{"matcher without soft limit", Config{HardLimit: 10, ShedMethods: MethodMatcher{"GET"}}, "no SoftLimit"},
+		{"decider and matcher", Config{HardLimit: 10, SoftLimit: 5, ShedDecider: decider, ShedPaths: &PathMatcher{}}, "matchers are ignored"},
+		{"empty header name", Config{HardLimit: 10, SoftLimit: 5, ShedHeader: &HeaderMatcher{Value: "low"}}, "ShedHeader.Name is empty"},
+		{"queue without timeout", Config{HardLimit: 10, Queue: &QueueConfig{}}, "Queue.Timeout must be > 0"},
+		{"weights without classify", Config{HardLimit: 10, Queue: &QueueConfig{Timeout: time.Second, Weights: map[string]int{"a": 1}}}, "Queue.Weights needs Classify"},
+		{"quota out of range", Config{HardLimit: 10, Classify: classify, ClassQuotas: map[string]float64{"batch": 1.2}}, `ClassQuotas["batch"] must be in (0, 1]`},
+		{"zero quota", Config{HardLimit: 10, Classify: classify, ClassQuotas: map[string]float64{"batch": 0}}, `ClassQuotas["batch"] must be in (0, 1]`},
+		{"full quota", Config{HardLimit: 10, Classify: classify, ClassQuotas: map[string]float64{"bulk": 1}}, ""},
+		{"duplicate level", Config{HardLimit: 10, Classify: classify, PriorityLevels: []PriorityLevel{{Name: "a"}, {Name: "a"}}}, `duplicate priority level "a"`},
+		{"missing required", Config{HardLimit: 10, Tenant: &TenantConfig{}}, "Tenant.Key is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateJoinsErrors(t *testing.T) {
+	err := Config{HardLimit: 10, SoftLimit: 20, Queue: &QueueConfig{}}.Validate()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if n := strings.Count(err.Error(), "shedder: "); n != 2 {
+		t.Errorf("expected 2 joined errors, got %d: %v", n, err)
+	}
+}
+
+func TestNewWithError(t *testing.T) {
+	s, err := NewWithError(Config{HardLimit: 0})
+	if err == nil || s != nil {
+		t.Errorf("expected error and nil shedder, got %v, %v", s, err)
+	}
+
+	s, err = NewWithError(Config{HardLimit: 10, SoftLimit: 5})
+	if err != nil || s == nil {
+		t.Fatalf("expected shedder, got %v", err)
+	}
+	if s.currentLimit() != 10 {
+		t.Errorf("expected hard limit 10, got %d", s.currentLimit())
+	}
+}
@@ -0,0 +1,60 @@
+package shedder
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// WarmupConfig configures the slow-start ramp applied after startup.
+type WarmupConfig struct {
+	// Duration is how long the effective hard limit takes to ramp up to
+	// its full value. Required.
+	Duration time.Duration
+
+	// StartFraction is the fraction (0-1) of the hard limit in effect at
+	// the start of the ramp. Defaults to 0.1.
+	StartFraction float64
+}
+
+// warmupRamp scales the hard limit linearly from StartFraction to 1 over
+// Duration, starting when the shedder is created.
+type warmupRamp struct {
+	duration      time.Duration
+	startFraction float64
+	start         atomic.Int64 // unix nanoseconds
+}
+
+// newWarmupRamp returns a ramp for cfg starting at now.
+func newWarmupRamp(cfg WarmupConfig, now time.Time) *warmupRamp {
+	if cfg.StartFraction <= 0 || cfg.StartFraction > 1 {
+		cfg.StartFraction = 0.1
+	}
+	w := &warmupRamp{duration: cfg.Duration, startFraction: cfg.StartFraction}
+	w.start.Store(now.UnixNano())
+	return w
+}
+
+// scale returns limit reduced according to the ramp's progress at now.
+func (w *warmupRamp) scale(limit int64, now time.Time) int64 {
+	elapsed := now.UnixNano() - w.start.Load()
+	if elapsed >= int64(w.duration) {
+		return limit
+	}
+	fraction := w.startFraction
+	if elapsed > 0 {
+		fraction += (1 - w.startFraction) * float64(elapsed) / float64(w.duration)
+	}
+	scaled := int64(float64(limit) * fraction)
+	if scaled < 1 {
+		scaled = 1
+	}
+	return scaled
+}
+
+// IsWarmingUp reports whether the warmup ramp is still limiting the hard limit.
+func (s *Shedder) IsWarmingUp() bool {
+	if s.warmup == nil {
+		return false
+	}
+	return s.now().UnixNano()-s.warmup.start.Load() < int64(s.warmup.duration)
+}
@@ -0,0 +1,55 @@
+package shedder
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWarmupRamp_Scale(t *testing.T) {
+	start := time.Unix(1000, 0)
+	w := newWarmupRamp(WarmupConfig{Duration: 10 * time.Second, StartFraction: 0.2}, start)
+
+	tests := []struct {
+		elapsed time.Duration
+		want    int64
+	}{
+		{0, 20},
+		{5 * time.Second, 60},
+		{10 * time.Second, 100},
+		{time.Minute, 100},
+	}
+	for _, tt := range tests {
+		if got := w.scale(100, start.Add(tt.elapsed)); got != tt.want {
+			t.Errorf("scale after %v = %d, want %d", tt.elapsed, got, tt.want)
+		}
+	}
+}
+
+func TestWarmupRamp_NeverBelowOne(t *testing.T) {
+	start := time.Unix(1000, 0)
+	w := newWarmupRamp(WarmupConfig{Duration: time.Second}, start)
+	if got := w.scale(1, start); got != 1 {
+		t.Errorf("expected minimum limit 1, got %d", got)
+	}
+	if w.startFraction != 0.1 {
+		t.Errorf("expected default start fraction 0.1, got %v", w.startFraction)
+	}
+}
+
+func TestShedder_WarmupReducesLimit(t *testing.T) {
+	s := New(Config{HardLimit: 100, Warmup: &WarmupConfig{Duration: time.Hour}})
+
+	if !s.IsWarmingUp() {
+		t.Error("expected shedder to be warming up")
+	}
+	if got := s.currentLimit(); got != 10 {
+		t.Errorf("expected warmup limit 10, got %d", got)
+	}
+
+	for i := 0; i < 11; i++ {
+		s.increment(1)
+	}
+	if !s.IsOverloaded() {
+		t.Error("expected overload above the warmup limit")
+	}
+}
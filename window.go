@@ -0,0 +1,70 @@
+package shedder
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// window counts events over a sliding time window divided into fixed
+// buckets. Each event carries a total and a hits count, which is enough for
+// ratios such as errors/requests or sheds/requests.
+//
+// Updates are lock-free. A bucket is recycled by the first writer that
+// observes it belongs to an old epoch; a concurrent write racing with the
+// reset may be lost, which is acceptable for the statistics it backs.
+type window struct {
+	width   int64 // bucket width in nanoseconds
+	buckets []windowBucket
+}
+
+type windowBucket struct {
+	epoch atomic.Int64
+	total atomic.Int64
+	hits  atomic.Int64
+}
+
+// newWindow returns a window covering span with the given number of buckets.
+func newWindow(span time.Duration, buckets int) *window {
+	if buckets <= 0 {
+		buckets = 10
+	}
+	width := int64(span) / int64(buckets)
+	if width <= 0 {
+		width = 1
+	}
+	return &window{width: width, buckets: make([]windowBucket, buckets)}
+}
+
+// bucket returns the bucket for now, resetting it if it is stale.
+func (w *window) bucket(now time.Time) *windowBucket {
+	epoch := now.UnixNano() / w.width
+	b := &w.buckets[epoch%int64(len(w.buckets))]
+	if old := b.epoch.Load(); old != epoch && b.epoch.CompareAndSwap(old, epoch) {
+		b.total.Store(0)
+		b.hits.Store(0)
+	}
+	return b
+}
+
+// add records total events, hits of which matched, at now.
+func (w *window) add(now time.Time, total, hits int64) {
+	b := w.bucket(now)
+	b.total.Add(total)
+	if hits != 0 {
+		b.hits.Add(hits)
+	}
+}
+
+// sum returns the totals over the window ending at now.
+func (w *window) sum(now time.Time) (total, hits int64) {
+	epoch := now.UnixNano() / w.width
+	oldest := epoch - int64(len(w.buckets)) + 1
+	for i := range w.buckets {
+		b := &w.buckets[i]
+		if e := b.epoch.Load(); e >= oldest && e <= epoch {
+			total += b.total.Load()
+			hits += b.hits.Load()
+		}
+	}
+	return total, hits
+}
@@ -0,0 +1,29 @@
+package shedder
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindow_SumsRecentBuckets(t *testing.T) {
+	w := newWindow(10*time.Second, 10)
+	start := time.Unix(1000, 0)
+
+	w.add(start, 3, 1)
+	w.add(start.Add(5*time.Second), 2, 2)
+
+	if total, hits := w.sum(start.Add(5 * time.Second)); total != 5 || hits != 3 {
+		t.Errorf("sum = %d/%d, want 5/3", total, hits)
+	}
+
+	// The first bucket ages out of the window.
+	if total, hits := w.sum(start.Add(11 * time.Second)); total != 2 || hits != 2 {
+		t.Errorf("sum after expiry = %d/%d, want 2/2", total, hits)
+	}
+
+	// Reusing a stale bucket resets it.
+	w.add(start.Add(20*time.Second), 1, 0)
+	if total, hits := w.sum(start.Add(20 * time.Second)); total != 1 || hits != 0 {
+		t.Errorf("sum after reuse = %d/%d, want 1/0", total, hits)
+	}
+}